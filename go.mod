@@ -9,3 +9,5 @@ require (
 )
 
 require periph.io/x/host/v3 v3.8.5
+
+require github.com/jonboulle/clockwork v0.4.0 // indirect
@@ -1,6 +1,7 @@
 package actuator
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -20,13 +21,61 @@ const settlingDelay = 100 * time.Millisecond
 // Retract extra time to counter drift in repeated cycles.
 const retractExtra = 1 * time.Second
 
+// Actuator drives the linear actuator. Every movement honours ctx: cancelling it
+// stops the motor and returns the context error. EmergencyStop aborts movements with
+// ErrEmergencyStop and refuses new ones until ResetEmergencyStop.
+type Actuator interface {
+	// Home retracts to the shortest (home) position.
+	Home(ctx context.Context) error
+	// Extend moves forward by the stroke a full-speed run of duration would cover.
+	Extend(ctx context.Context, duration time.Duration) error
+	// Retract moves backward by the stroke a full-speed run of duration would cover.
+	Retract(ctx context.Context, duration time.Duration) error
+	// Trigger runs one extend-retract cycle and returns the total time in milliseconds.
+	Trigger(ctx context.Context) (int, error)
+	// EmergencyStop cuts motor power immediately, aborts the running movement and
+	// latches until ResetEmergencyStop. It is safe to call from any goroutine.
+	EmergencyStop() error
+	// ResetEmergencyStop accepts movements again. The position is unknown afterwards,
+	// so callers should home before the next stroke.
+	ResetEmergencyStop()
+	// Close releases hardware resources.
+	Close() error
+}
+
 type Config struct {
 	Enabled      bool
-	ENAPin       string // e.g., "GPIO25"
-	IN1Pin       string // e.g., "GPIO8"
-	IN2Pin       string // e.g., "GPIO7"
-	MovementTime int    // seconds - MUST be identical for extend and retract
-	PauseTime    int    // seconds, deprecated: ignored (kept for config compatibility)
+	ENAPin       string  // e.g., "GPIO25" (supports hardware PWM on Raspberry Pi)
+	IN1Pin       string  // e.g., "GPIO8"
+	IN2Pin       string  // e.g., "GPIO7"
+	MovementTime int     // seconds - MUST be identical for extend and retract
+	PauseTime    int     // seconds, deprecated: ignored (kept for config compatibility)
+	ExtendSpeed  float64 // 0.0–1.0 ENA duty cycle while extending (0 means full speed)
+	RetractSpeed float64 // 0.0–1.0 ENA duty cycle while retracting (0 means full speed)
+	RampUpMs     int     // soft-start ramp from standstill to speed
+	RampDownMs   int     // soft-stop ramp from speed to standstill
+
+	// Stall/overload detection; only active when CurrentSensor is set
+	CurrentSensor     CurrentReader
+	StallCurrentMa    int // sustained current that counts as a stall
+	StallDurationMs   int // how long the stall current must persist
+	OverloadCurrentMa int // current that aborts immediately
+	InrushIgnoreMs    int // ignore samples at movement start (motor inrush)
+	CurrentSampleMs   int // current sampling interval
+	StallReverseMs    int // brief reverse run after an abort to relieve the mechanism
+
+	// Usage receives motor usage of hardware movements for wear tracking (optional)
+	Usage UsageRecorder
+}
+
+// UsageRecorder receives actuator motor usage for wear tracking.
+type UsageRecorder interface {
+	// RecordMotorOn adds motor-on time in direction "extend" or "retract".
+	RecordMotorOn(direction string, d time.Duration)
+	// RecordHoming counts one homing run.
+	RecordHoming()
+	// RecordCycle counts one completed extend-retract cycle.
+	RecordCycle()
 }
 
 type ActuateResult struct {
@@ -35,121 +84,233 @@ type ActuateResult struct {
 	Error       string `json:"error,omitempty"`
 }
 
-type Actuator struct {
-	enabled      bool
-	enaPin       gpio.PinOut
-	in1Pin       gpio.PinOut
-	in2Pin       gpio.PinOut
+// motion holds the stroke timing shared by the GPIO and simulated actuators.
+type motion struct {
 	movementTime time.Duration // Identical for extend and retract
-	pause        time.Duration
-	isHome       bool // Track if actuator is at home position
+	extendSpeed  float64
+	retractSpeed float64
+	rampUp       time.Duration
+	rampDown     time.Duration
 }
 
-var actuator *Actuator
+func newMotion(config Config) motion {
+	if config.MovementTime == 0 {
+		config.MovementTime = 2
+	}
+	return motion{
+		movementTime: time.Duration(config.MovementTime) * time.Second,
+		extendSpeed:  config.ExtendSpeed,
+		retractSpeed: config.RetractSpeed,
+		rampUp:       time.Duration(config.RampUpMs) * time.Millisecond,
+		rampDown:     time.Duration(config.RampDownMs) * time.Millisecond,
+	}
+}
+
+// extendProfile returns the ENA profile covering the same stroke as a full-speed
+// extend of the given duration.
+func (m motion) extendProfile(distance time.Duration) rampProfile {
+	return newRampProfile(m.extendSpeed, m.rampUp, m.rampDown, distance)
+}
+
+// retractProfile returns the ENA profile covering the same stroke as a full-speed
+// retract of the given duration.
+func (m motion) retractProfile(distance time.Duration) rampProfile {
+	return newRampProfile(m.retractSpeed, m.rampUp, m.rampDown, distance)
+}
+
+// homeProfile runs retract for a fixed duration to guarantee full retraction. The
+// actuator's internal end stop ends travel, so the profile is not stroke-compensated.
+func (m motion) homeProfile() rampProfile {
+	return rampProfile{speed: normalizeSpeed(m.retractSpeed), rampUp: m.rampUp, total: homingDuration}
+}
 
-// Init initializes GPIO and the actuator control pins
-func Init(config Config) error {
+// GPIO drives the actuator through an H-bridge (ENA/IN1/IN2) on Raspberry Pi GPIO.
+type GPIO struct {
+	motion
+	enaPin      gpio.PinOut
+	in1Pin      gpio.PinOut
+	in2Pin      gpio.PinOut
+	isHome      bool // Track if actuator is at home position
+	softwarePWM bool // set once hardware PWM on ENA turned out to be unavailable
+	// currentSensor is nil when stall detection is disabled
+	currentSensor CurrentReader
+	stallLimits   stallLimits
+	estop         estopLatch
+	usage         UsageRecorder // nil when wear tracking is disabled
+}
+
+// New returns the actuator for config: GPIO hardware when available, otherwise a
+// simulated actuator with the same timing.
+func New(config Config) (Actuator, error) {
 	if !config.Enabled {
-		log.Println("Actuator control disabled")
-		return nil
+		log.Println("Actuator control disabled (simulated timing only)")
+		return NewSimulated(config), nil
 	}
 
-	// Enforce identical movement time for extend and retract
 	if config.MovementTime == 0 {
 		config.MovementTime = 2
 	}
 
-	log.Printf("Actuator config: movement_time=%ds (extend=retract), pause disabled", config.MovementTime)
+	log.Printf("Actuator config: movement_time=%ds (extend=retract), extend_speed=%.0f%%, retract_speed=%.0f%%, ramp_up=%dms, ramp_down=%dms, pause disabled",
+		config.MovementTime, speedPercent(config.ExtendSpeed), speedPercent(config.RetractSpeed), config.RampUpMs, config.RampDownMs)
 
 	// Initialize periph/x host
 	if _, err := host.Init(); err != nil {
 		// GPIO not available - enable simulation mode
 		log.Printf("Warning: GPIO not available, running in simulation mode: %v", err)
-		actuator = &Actuator{
-			enabled:      true,  // Enable simulation
-			enaPin:       nil,
-			in1Pin:       nil,
-			in2Pin:       nil,
-			movementTime: time.Duration(config.MovementTime) * time.Second,
-			pause:        0,
-			isHome:       false,
-		}
-		return nil
+		return NewSimulated(config), nil
 	}
 
 	// Open pins
 	enaPin := gpioreg.ByName(config.ENAPin)
 	if enaPin == nil {
 		log.Printf("Warning: failed to open ENA pin %s, running in simulation mode", config.ENAPin)
-		actuator = &Actuator{
-			enabled:      true,
-			enaPin:       nil,
-			in1Pin:       nil,
-			in2Pin:       nil,
-			movementTime: time.Duration(config.MovementTime) * time.Second,
-			pause:        0,
-			isHome:       false,
-		}
-		return nil
+		return NewSimulated(config), nil
 	}
 
 	in1Pin := gpioreg.ByName(config.IN1Pin)
 	if in1Pin == nil {
 		log.Printf("Warning: failed to open IN1 pin %s, running in simulation mode", config.IN1Pin)
-		actuator = &Actuator{
-			enabled:      true,
-			enaPin:       nil,
-			in1Pin:       nil,
-			in2Pin:       nil,
-			movementTime: time.Duration(config.MovementTime) * time.Second,
-			pause:        0,
-			isHome:       false,
-		}
-		return nil
+		return NewSimulated(config), nil
 	}
 
 	in2Pin := gpioreg.ByName(config.IN2Pin)
 	if in2Pin == nil {
 		log.Printf("Warning: failed to open IN2 pin %s, running in simulation mode", config.IN2Pin)
-		actuator = &Actuator{
-			enabled:      true,
-			enaPin:       nil,
-			in1Pin:       nil,
-			in2Pin:       nil,
-			movementTime: time.Duration(config.MovementTime) * time.Second,
-			pause:        0,
-			isHome:       false,
-		}
+		return NewSimulated(config), nil
+	}
+
+	a := newGPIO(config, enaPin, in1Pin, in2Pin)
+
+	// Keep ENA LOW until a movement drives it; each movement ramps ENA itself.
+	if err := a.enaPin.Out(gpio.Low); err != nil {
+		return nil, fmt.Errorf("failed to set ENA pin low: %w", err)
+	}
+
+	log.Println("Actuator initialized successfully (homing will run in background)")
+	return a, nil
+}
+
+func newGPIO(config Config, enaPin, in1Pin, in2Pin gpio.PinOut) *GPIO {
+	a := &GPIO{
+		motion: newMotion(config),
+		enaPin: enaPin,
+		in1Pin: in1Pin,
+		in2Pin: in2Pin,
+		usage:  config.Usage,
+	}
+	a.attachCurrentSensor(config)
+	return a
+}
+
+// attachCurrentSensor enables stall/overload detection for hardware movements.
+func (a *GPIO) attachCurrentSensor(config Config) {
+	if config.CurrentSensor == nil {
+		return
+	}
+	a.currentSensor = config.CurrentSensor
+	a.stallLimits = newStallLimits(config)
+	log.Printf("Actuator stall detection: stall=%dmA for %dms, overload=%dmA, inrush_ignore=%dms, sample=%v, reverse=%dms",
+		config.StallCurrentMa, config.StallDurationMs, config.OverloadCurrentMa, config.InrushIgnoreMs, a.stallLimits.sampleEvery, config.StallReverseMs)
+}
+
+func speedPercent(speed float64) float64 {
+	return normalizeSpeed(speed) * 100
+}
+
+// drive sets the direction pins, runs ENA through the profile and stops the motor.
+// When the current monitor detects a stall or overload, the movement is aborted, the
+// motor is briefly reversed and a *StallError is returned. Cancelling ctx stops the
+// motor and returns the cancellation cause.
+func (a *GPIO) drive(ctx context.Context, direction string, in1, in2 gpio.Level, p rampProfile) error {
+	stall, err := a.driveMonitored(ctx, direction, in1, in2, p)
+	if err != nil {
+		return err
+	}
+	if stall == nil {
 		return nil
 	}
 
-	actuator = &Actuator{
-		enabled:      true,
-		enaPin:       enaPin,
-		in1Pin:       in1Pin,
-		in2Pin:       in2Pin,
-		movementTime: time.Duration(config.MovementTime) * time.Second,
-		pause:        0,
-		isHome:       false, // Will be set to true after homing completes
+	log.Printf("Actuator: %v - reversing for %v", stall, a.stallLimits.reverse)
+	if err := a.reverseAfterStall(ctx, in1, in2); err != nil {
+		log.Printf("Actuator: reverse after %s failed: %v", stall.Reason, err)
 	}
+	return stall
+}
 
-	// Set ENA pin HIGH to enable the actuator
-	if err := actuator.enaPin.Out(gpio.High); err != nil {
-		return fmt.Errorf("failed to set ENA pin high: %w", err)
+// driveMonitored runs one movement while sampling motor current and returns the
+// detected stall, if any. The motor is stopped in every case.
+func (a *GPIO) driveMonitored(ctx context.Context, direction string, in1, in2 gpio.Level, p rampProfile) (*StallError, error) {
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	if err := a.in1Pin.Out(in1); err != nil {
+		return nil, fmt.Errorf("failed to set IN1 %s: %w", levelName(in1), err)
+	}
+	if err := a.in2Pin.Out(in2); err != nil {
+		return nil, fmt.Errorf("failed to set IN2 %s: %w", levelName(in2), err)
 	}
 
-	log.Println("Actuator initialized successfully (homing will run in background)")
-	return nil
+	moveCtx, abort := context.WithCancel(ctx)
+	defer abort()
+
+	monitor := a.startCurrentMonitor(direction, abort)
+	started := time.Now()
+	a.runEnable(p, moveCtx.Done())
+	a.recordMotorOn(in1, time.Since(started))
+	stall := monitor.finish()
+
+	if err := a.stopMotor(); err != nil {
+		return nil, err
+	}
+	if stall == nil && ctx.Err() != nil {
+		log.Printf("Actuator: %s cancelled, motor stopped: %v", direction, context.Cause(ctx))
+		return nil, context.Cause(ctx)
+	}
+	return stall, nil
 }
 
-// stopMotor ensures motor fully stops with settling delay to prevent momentum
-func (a *Actuator) stopMotor() error {
-	// If pins are nil (simulation mode), just sleep
-	if a.in1Pin == nil || a.in2Pin == nil {
-		time.Sleep(settlingDelay)
+// reverseAfterStall runs the motor against the previous direction at full speed for
+// the configured reverse time, without current monitoring. Cancelling ctx cuts the
+// reverse run short.
+func (a *GPIO) reverseAfterStall(ctx context.Context, in1, in2 gpio.Level) error {
+	if a.stallLimits.reverse <= 0 {
 		return nil
 	}
-	
+	if err := a.in1Pin.Out(in2); err != nil {
+		return fmt.Errorf("failed to set IN1 %s: %w", levelName(in2), err)
+	}
+	if err := a.in2Pin.Out(in1); err != nil {
+		return fmt.Errorf("failed to set IN2 %s: %w", levelName(in1), err)
+	}
+	started := time.Now()
+	a.runEnable(rampProfile{speed: 1, total: a.stallLimits.reverse}, ctx.Done())
+	a.recordMotorOn(in2, time.Since(started))
+	return a.stopMotor()
+}
+
+// recordMotorOn reports motor-on time for the direction selected by IN1 (HIGH extends).
+func (a *GPIO) recordMotorOn(in1 gpio.Level, d time.Duration) {
+	if a.usage == nil {
+		return
+	}
+	if in1 == gpio.High {
+		a.usage.RecordMotorOn("extend", d)
+	} else {
+		a.usage.RecordMotorOn("retract", d)
+	}
+}
+
+func levelName(l gpio.Level) string {
+	if l == gpio.High {
+		return "high"
+	}
+	return "low"
+}
+
+// stopMotor ensures motor fully stops with settling delay to prevent momentum
+func (a *GPIO) stopMotor() error {
+	a.disableENA()
 	if err := a.in1Pin.Out(gpio.Low); err != nil {
 		return fmt.Errorf("failed to set IN1 low: %w", err)
 	}
@@ -161,227 +322,155 @@ func (a *Actuator) stopMotor() error {
 	return nil
 }
 
-// preciseDelay uses a timer for more accurate timing than time.Sleep
-func preciseDelay(d time.Duration) {
-	timer := time.NewTimer(d)
-	<-timer.C
-}
-
-// Home retracts the actuator to the shortest position (home position)
-// Should be called after server startup to avoid blocking
-func Home() {
-	if actuator == nil || !actuator.enabled {
-		log.Println("Actuator: skipping homing (not initialized or disabled)")
-		return
+// Home retracts the actuator to the shortest position (home position).
+// A sustained stall current while homing means the end stop was reached; only an
+// overload aborts homing.
+func (a *GPIO) Home(ctx context.Context) error {
+	ctx, done, err := a.estop.begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer done()
 
-	// Retract to shortest position on startup (home position)
-	// Run for fixed 10 seconds to ensure full retraction regardless of starting position
-	log.Println("Actuator: retracting to home position...")
-	
-	// If pins are nil, we're in simulation mode
-	if actuator.in1Pin == nil || actuator.in2Pin == nil {
-		log.Printf("Actuator (SIMULATION): homing for %v", homingDuration)
-		time.Sleep(homingDuration)
-		actuator.isHome = true
-		log.Println("Actuator (SIMULATION): homing complete - now at home position")
-		return
+	log.Printf("Actuator: retracting to home position (homing for %v)...", homingDuration)
+	if a.usage != nil {
+		a.usage.RecordHoming()
 	}
-	
-	if err := actuator.in1Pin.Out(gpio.Low); err != nil {
-		log.Printf("Actuator homing error: failed to set IN1 low: %v", err)
-		return
-	}
-	if err := actuator.in2Pin.Out(gpio.High); err != nil {
-		log.Printf("Actuator homing error: failed to set IN2 high: %v", err)
-		return
-	}
-	
-	// Run retract for fixed duration to guarantee full retraction
-	log.Printf("Actuator: homing for %v", homingDuration)
-	time.Sleep(homingDuration)
-	
-	// Stop: both LOW
-	if err := actuator.in1Pin.Out(gpio.Low); err != nil {
-		log.Printf("Actuator homing error: failed to set IN1 low after homing: %v", err)
-		return
+
+	stall, err := a.driveMonitored(ctx, "home", gpio.Low, gpio.High, a.homeProfile())
+	if err != nil {
+		return fmt.Errorf("homing failed: %w", err)
 	}
-	if err := actuator.in2Pin.Out(gpio.Low); err != nil {
-		log.Printf("Actuator homing error: failed to set IN2 low after homing: %v", err)
-		return
+	if stall != nil {
+		if stall.Reason == StallReasonOverload {
+			if err := a.reverseAfterStall(ctx, gpio.Low, gpio.High); err != nil {
+				log.Printf("Actuator: reverse after overload failed: %v", err)
+			}
+			return fmt.Errorf("homing failed: %w", stall)
+		}
+		log.Printf("Actuator: end stop reached after %v (%.0fmA)", stall.After.Round(time.Millisecond), stall.CurrentMa)
 	}
 
-	// Wait for motor to fully stop (settling time)
-	time.Sleep(settlingDelay)
-	
-	actuator.isHome = true
+	a.isHome = true
 	log.Println("Actuator: homing complete - now at home position")
+	return nil
 }
 
 // Trigger executes one extend-retract cycle with precise timing
 // Retract runs slightly longer to counter drift over repeated cycles.
-func (a *Actuator) Trigger() (int, error) {
-	start := time.Now()
-	if !a.enabled {
-		// Mock: wait for the configured time (extend + retract + settling)
-		mockDuration := a.movementTime + (a.movementTime + retractExtra) + 2*settlingDelay
-		time.Sleep(mockDuration)
-		return int(mockDuration.Milliseconds()), nil
+// With speed or ramp settings, motor-on times are stretched so both strokes still
+// cover the same distance as a full-speed run of the configured movement time.
+func (a *GPIO) Trigger(ctx context.Context) (int, error) {
+	ctx, done, err := a.estop.begin(ctx)
+	if err != nil {
+		return 0, err
 	}
+	defer done()
 
-	// If pins are nil, we're in simulation mode
-	if a.in1Pin == nil || a.in2Pin == nil {
-		log.Printf("Actuator (SIMULATION): extending for exactly %v...", a.movementTime)
-		preciseDelay(a.movementTime)
-		preciseDelay(settlingDelay)
-		a.isHome = false
-
-		log.Printf("Actuator (SIMULATION): retracting for exactly %v...", a.movementTime+retractExtra)
-		preciseDelay(a.movementTime + retractExtra)
-		preciseDelay(settlingDelay)
-		a.isHome = true
-
-		totalMs := int(time.Since(start).Milliseconds())
-		log.Printf("Actuator (SIMULATION) cycle complete: extend=%v, retract=%v, total=%dms", 
-			a.movementTime, a.movementTime+retractExtra, totalMs)
-		return totalMs, nil
-	}
+	start := time.Now()
+	extend := a.extendProfile(a.movementTime)
+	retract := a.retractProfile(a.movementTime + retractExtra)
 
 	if !a.isHome {
 		log.Println("Warning: actuator not at home position before trigger")
 	}
 
-	log.Printf("Actuator: extending for exactly %v...", a.movementTime)
-	// Extend: IN1 HIGH, IN2 LOW
-	if err := a.in1Pin.Out(gpio.High); err != nil {
-		return 0, fmt.Errorf("failed to set IN1 high: %w", err)
-	}
-	if err := a.in2Pin.Out(gpio.Low); err != nil {
-		return 0, fmt.Errorf("failed to set IN2 low: %w", err)
-	}
-
-	// Use precise timing for extend
-	preciseDelay(a.movementTime)
-
-	// Stop and settle before direction change
-	if err := a.stopMotor(); err != nil {
-		return 0, fmt.Errorf("failed to stop after extend: %w", err)
-	}
+	log.Printf("Actuator: extending for %v (stroke %v)...", extend.total, a.movementTime)
+	reportPhase(ctx, PhaseExtending)
+	// Extend: IN1 HIGH, IN2 LOW; stop and settle before direction change
 	a.isHome = false
-
-	log.Printf("Actuator: retracting for exactly %v...", a.movementTime+retractExtra)
-	// Retract: IN1 LOW, IN2 HIGH
-	if err := a.in1Pin.Out(gpio.Low); err != nil {
-		return 0, fmt.Errorf("failed to set IN1 low: %w", err)
-	}
-	if err := a.in2Pin.Out(gpio.High); err != nil {
-		return 0, fmt.Errorf("failed to set IN2 high: %w", err)
+	if err := a.drive(ctx, "extend", gpio.High, gpio.Low, extend); err != nil {
+		return 0, fmt.Errorf("extend failed: %w", err)
 	}
 
-	// Use a slightly longer retract to compensate for drift
-	preciseDelay(a.movementTime + retractExtra)
-
-	// Stop and settle - back at home position
-	if err := a.stopMotor(); err != nil {
-		return 0, fmt.Errorf("failed to stop after retract: %w", err)
+	log.Printf("Actuator: retracting for %v (stroke %v)...", retract.total, a.movementTime+retractExtra)
+	reportPhase(ctx, PhaseRetracting)
+	// Retract: IN1 LOW, IN2 HIGH; a slightly longer stroke compensates for drift
+	if err := a.drive(ctx, "retract", gpio.Low, gpio.High, retract); err != nil {
+		return 0, fmt.Errorf("retract failed: %w", err)
 	}
 	a.isHome = true
+	if a.usage != nil {
+		a.usage.RecordCycle()
+	}
 
 	totalMs := int(time.Since(start).Milliseconds())
-	log.Printf("Actuator cycle complete: extend=%v, retract=%v, total=%dms", 
-		a.movementTime, a.movementTime+retractExtra, totalMs)
+	log.Printf("Actuator cycle complete: extend=%v, retract=%v, total=%dms",
+		extend.total, retract.total, totalMs)
 	return totalMs, nil
 }
 
-// Extend moves the actuator forward for the specified duration (for testing)
-func Extend(duration time.Duration) error {
-	if actuator == nil || !actuator.enabled {
-		return fmt.Errorf("actuator not initialized or disabled")
-	}
-
-	log.Printf("Actuator: extending for %v...", duration)
-	
-	// If pins are nil, we're in simulation mode
-	if actuator.in1Pin == nil || actuator.in2Pin == nil {
-		log.Printf("Actuator (SIMULATION): would extend for %v", duration)
-		preciseDelay(duration)
-		log.Println("Actuator (SIMULATION): extend complete")
-		actuator.isHome = false
-		return nil
-	}
-	
-	// Extend: IN1 HIGH, IN2 LOW
-	if err := actuator.in1Pin.Out(gpio.High); err != nil {
-		return fmt.Errorf("failed to set IN1 high: %w", err)
-	}
-	if err := actuator.in2Pin.Out(gpio.Low); err != nil {
-		return fmt.Errorf("failed to set IN2 low: %w", err)
+// Extend moves the actuator forward by the stroke a full-speed run of duration
+// would cover (for testing)
+func (a *GPIO) Extend(ctx context.Context, duration time.Duration) error {
+	ctx, done, err := a.estop.begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer done()
 
-	preciseDelay(duration)
+	profile := a.extendProfile(duration)
+	log.Printf("Actuator: extending for %v (stroke %v)...", profile.total, duration)
 
-	// Stop motor
-	if err := actuator.stopMotor(); err != nil {
-		return fmt.Errorf("failed to stop after extend: %w", err)
+	// Extend: IN1 HIGH, IN2 LOW
+	a.isHome = false
+	if err := a.drive(ctx, "extend", gpio.High, gpio.Low, profile); err != nil {
+		return fmt.Errorf("extend failed: %w", err)
 	}
 
-	actuator.isHome = false
 	log.Println("Actuator: extend complete")
 	return nil
 }
 
-// Retract moves the actuator backward for the specified duration (for testing)
-func Retract(duration time.Duration) error {
-	if actuator == nil || !actuator.enabled {
-		return fmt.Errorf("actuator not initialized or disabled")
+// Retract moves the actuator backward by the stroke a full-speed run of duration
+// would cover (for testing)
+func (a *GPIO) Retract(ctx context.Context, duration time.Duration) error {
+	ctx, done, err := a.estop.begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer done()
 
-	log.Printf("Actuator: retracting for %v...", duration)
-	
-	// If pins are nil, we're in simulation mode
-	if actuator.in1Pin == nil || actuator.in2Pin == nil {
-		log.Printf("Actuator (SIMULATION): would retract for %v", duration)
-		preciseDelay(duration)
-		log.Println("Actuator (SIMULATION): retract complete")
-		return nil
-	}
-	
-	// Retract: IN1 LOW, IN2 HIGH
-	if err := actuator.in1Pin.Out(gpio.Low); err != nil {
-		return fmt.Errorf("failed to set IN1 low: %w", err)
-	}
-	if err := actuator.in2Pin.Out(gpio.High); err != nil {
-		return fmt.Errorf("failed to set IN2 high: %w", err)
-	}
+	profile := a.retractProfile(duration)
+	log.Printf("Actuator: retracting for %v (stroke %v)...", profile.total, duration)
 
-	preciseDelay(duration)
-
-	// Stop motor
-	if err := actuator.stopMotor(); err != nil {
-		return fmt.Errorf("failed to stop after retract: %w", err)
+	// Retract: IN1 LOW, IN2 HIGH
+	if err := a.drive(ctx, "retract", gpio.Low, gpio.High, profile); err != nil {
+		return fmt.Errorf("retract failed: %w", err)
 	}
 
 	log.Println("Actuator: retract complete")
 	return nil
 }
 
-// Trigger calls the actuator if initialized and returns timing info
-func Trigger() (int, error) {
-	if actuator == nil {
-		// No actuator configured; return mock timing (2+2+2 = 6 seconds)
-		time.Sleep(6 * time.Second)
-		return 6000, nil
+// EmergencyStop aborts the running movement and drives ENA, IN1 and IN2 low without
+// waiting for the movement goroutine. With both inputs low the H-bridge cannot drive
+// the motor even if a PWM cycle still toggles ENA before it sees the abort.
+func (a *GPIO) EmergencyStop() error {
+	a.estop.trip()
+	a.disableENA()
+	if err := a.in1Pin.Out(gpio.Low); err != nil {
+		return fmt.Errorf("emergency stop: failed to set IN1 low: %w", err)
+	}
+	if err := a.in2Pin.Out(gpio.Low); err != nil {
+		return fmt.Errorf("emergency stop: failed to set IN2 low: %w", err)
 	}
-	return actuator.Trigger()
+	log.Println("Actuator: EMERGENCY STOP - motor power cut")
+	return nil
 }
 
-// Cleanup closes GPIO resources
-func Cleanup() {
-	if actuator != nil {
-		actuator.enaPin.Out(gpio.Low)
-		actuator.enaPin.Halt()
-		actuator.in1Pin.Halt()
-		actuator.in2Pin.Halt()
-		log.Println("Actuator GPIO cleaned up")
-	}
+// ResetEmergencyStop accepts movements again.
+func (a *GPIO) ResetEmergencyStop() {
+	a.estop.reset()
+	log.Println("Actuator: emergency stop reset")
+}
+
+// Close drives ENA low and releases the GPIO pins.
+func (a *GPIO) Close() error {
+	a.disableENA()
+	a.enaPin.Halt()
+	a.in1Pin.Halt()
+	a.in2Pin.Halt()
+	log.Println("Actuator GPIO cleaned up")
+	return nil
 }
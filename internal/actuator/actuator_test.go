@@ -1,57 +1,221 @@
 package actuator
 
 import (
-    "testing"
-    "time"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
 )
 
-// ensure Init is a no-op when disabled and does not set the global actuator
-func TestInitDisabledLeavesActuatorNil(t *testing.T) {
-    prev := actuator
-    actuator = nil
-    defer func() { actuator = prev }()
-
-    cfg := Config{Enabled: false}
-    if err := Init(cfg); err != nil {
-        t.Fatalf("Init returned error for disabled config: %v", err)
-    }
-    if actuator != nil {
-        t.Fatalf("actuator should remain nil when disabled")
-    }
-}
-
-// validate Trigger uses mock timing path when actuator is disabled
-func TestTriggerWithDisabledActuatorUsesConfiguredDurations(t *testing.T) {
-    prev := actuator
-    actuator = &Actuator{
-        enabled:      false,
-        movementTime: 10 * time.Millisecond,
-        pause:        5 * time.Millisecond,
-    }
-    defer func() { actuator = prev }()
-
-    start := time.Now()
-    totalMs, err := Trigger()
-    elapsed := time.Since(start)
-
-    if err != nil {
-        t.Fatalf("Trigger returned error: %v", err)
-    }
-
-    // expected ~1225ms (10ms extend + 1010ms retract + 5ms pause + 2x 100ms settling); allow buffer for scheduling
-    if totalMs < 1100 || totalMs > 1500 {
-        t.Fatalf("unexpected reported duration: %dms", totalMs)
-    }
-    if elapsed < 1100*time.Millisecond || elapsed > 1800*time.Millisecond {
-        t.Fatalf("unexpected elapsed wall time: %v", elapsed)
-    }
-}
-
-// ensure Cleanup tolerates nil actuator without panicking
-func TestCleanupNilSafe(t *testing.T) {
-    prev := actuator
-    actuator = nil
-    defer func() { actuator = prev }()
-
-    Cleanup() // should not panic
+// ensure New falls back to the simulated actuator when disabled
+func TestNewDisabledReturnsSimulated(t *testing.T) {
+	a, err := New(Config{Enabled: false})
+	if err != nil {
+		t.Fatalf("New returned error for disabled config: %v", err)
+	}
+	if _, ok := a.(*Simulated); !ok {
+		t.Fatalf("expected *Simulated for disabled config, got %T", a)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+}
+
+// validate simulated Trigger uses the configured stroke timing and reports its phases
+func TestSimulatedTriggerUsesConfiguredDurations(t *testing.T) {
+	a := &Simulated{motion: motion{movementTime: 10 * time.Millisecond}}
+
+	var phases []string
+	ctx := WithPhaseObserver(context.Background(), func(phase string) { phases = append(phases, phase) })
+	start := time.Now()
+	totalMs, err := a.Trigger(ctx)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("Trigger returned error: %v", err)
+	}
+
+	// expected ~1220ms (10ms extend + 1010ms retract + 2x 100ms settling); allow buffer for scheduling
+	if totalMs < 1100 || totalMs > 1500 {
+		t.Fatalf("unexpected reported duration: %dms", totalMs)
+	}
+	if elapsed < 1100*time.Millisecond || elapsed > 1800*time.Millisecond {
+		t.Fatalf("unexpected elapsed wall time: %v", elapsed)
+	}
+	if len(phases) != 2 || phases[0] != PhaseExtending || phases[1] != PhaseRetracting {
+		t.Fatalf("unexpected phases: %v", phases)
+	}
+}
+
+func TestSimulatedExtendCancelledByContext(t *testing.T) {
+	a := NewSimulated(Config{MovementTime: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := a.Extend(ctx, 2*time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("extend did not stop on cancel: %v", elapsed)
+	}
+}
+
+func TestGPIOTriggerCancelStopsMotor(t *testing.T) {
+	ena := &gpiotest.Pin{N: "ENA"}
+	in1 := &gpiotest.Pin{N: "IN1"}
+	in2 := &gpiotest.Pin{N: "IN2"}
+	a := newGPIO(Config{MovementTime: 2}, ena, in1, in2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := a.Trigger(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("trigger did not stop on cancel: %v", elapsed)
+	}
+	if ena.L != gpio.Low || in1.L != gpio.Low || in2.L != gpio.Low {
+		t.Fatalf("expected all motor pins low after cancel, got ena=%v in1=%v in2=%v", ena.L, in1.L, in2.L)
+	}
+	if a.isHome {
+		t.Fatal("expected actuator not at home after cancelled extend")
+	}
+}
+
+func TestRecorderRecordsCallsAndHonoursContext(t *testing.T) {
+	r := NewRecorder()
+	r.TotalMs = 1234
+
+	if err := r.Extend(context.Background(), 300*time.Millisecond); err != nil {
+		t.Fatalf("Extend returned error: %v", err)
+	}
+	totalMs, err := r.Trigger(context.Background())
+	if err != nil || totalMs != 1234 {
+		t.Fatalf("Trigger = %d, %v; want 1234, nil", totalMs, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Home(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from cancelled Home, got %v", err)
+	}
+
+	calls := r.Calls()
+	if len(calls) != 3 || calls[0] != (Call{Method: "extend", Duration: 300 * time.Millisecond}) || calls[1].Method != "trigger" || calls[2].Method != "home" {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+}
+
+func TestGPIOEmergencyStopAbortsMovementAndLatches(t *testing.T) {
+	ena := &gpiotest.Pin{N: "ENA"}
+	in1 := &gpiotest.Pin{N: "IN1"}
+	in2 := &gpiotest.Pin{N: "IN2"}
+	a := newGPIO(Config{MovementTime: 2}, ena, in1, in2)
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		if err := a.EmergencyStop(); err != nil {
+			t.Errorf("EmergencyStop returned error: %v", err)
+		}
+	})
+
+	start := time.Now()
+	err := a.Extend(context.Background(), 2*time.Second)
+	if !errors.Is(err, ErrEmergencyStop) {
+		t.Fatalf("expected ErrEmergencyStop, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("extend did not stop on emergency stop: %v", elapsed)
+	}
+	if ena.L != gpio.Low || in1.L != gpio.Low || in2.L != gpio.Low {
+		t.Fatalf("expected all motor pins low after emergency stop, got ena=%v in1=%v in2=%v", ena.L, in1.L, in2.L)
+	}
+
+	if err := a.Retract(context.Background(), 10*time.Millisecond); !errors.Is(err, ErrEmergencyStop) {
+		t.Fatalf("expected latched emergency stop to refuse retract, got %v", err)
+	}
+	if in2.L != gpio.Low {
+		t.Fatal("refused retract must not touch the direction pins")
+	}
+
+	a.ResetEmergencyStop()
+	if err := a.Retract(context.Background(), 10*time.Millisecond); err != nil {
+		t.Fatalf("retract after reset returned error: %v", err)
+	}
+}
+
+func TestSimulatedEmergencyStopAbortsHome(t *testing.T) {
+	a := NewSimulated(Config{})
+	time.AfterFunc(50*time.Millisecond, func() { a.EmergencyStop() })
+
+	start := time.Now()
+	if err := a.Home(context.Background()); !errors.Is(err, ErrEmergencyStop) {
+		t.Fatalf("expected ErrEmergencyStop, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("home did not stop on emergency stop: %v", elapsed)
+	}
+	// A latched stop is reported as such, not as a failed stroke.
+	if _, err := a.Trigger(context.Background()); err != ErrEmergencyStop {
+		t.Fatalf("expected trigger to be refused with ErrEmergencyStop, got %v", err)
+	}
+}
+
+// usageLog records wear-tracking callbacks.
+type usageLog struct {
+	mu      sync.Mutex
+	motorOn map[string]time.Duration
+	cycles  int
+	homings int
+}
+
+func (u *usageLog) RecordMotorOn(direction string, d time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.motorOn == nil {
+		u.motorOn = make(map[string]time.Duration)
+	}
+	u.motorOn[direction] += d
+}
+
+func (u *usageLog) RecordHoming() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.homings++
+}
+
+func (u *usageLog) RecordCycle() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.cycles++
+}
+
+func TestGPIORecordsMotorUsage(t *testing.T) {
+	usage := &usageLog{}
+	a := newGPIO(Config{MovementTime: 2, Usage: usage}, &gpiotest.Pin{N: "ENA"}, &gpiotest.Pin{N: "IN1"}, &gpiotest.Pin{N: "IN2"})
+
+	if err := a.Extend(context.Background(), 50*time.Millisecond); err != nil {
+		t.Fatalf("Extend returned error: %v", err)
+	}
+	if err := a.Retract(context.Background(), 30*time.Millisecond); err != nil {
+		t.Fatalf("Retract returned error: %v", err)
+	}
+
+	if got := usage.motorOn["extend"]; got < 50*time.Millisecond || got > 200*time.Millisecond {
+		t.Fatalf("unexpected extend motor-on time: %v", got)
+	}
+	if got := usage.motorOn["retract"]; got < 30*time.Millisecond || got > 200*time.Millisecond {
+		t.Fatalf("unexpected retract motor-on time: %v", got)
+	}
+	if usage.cycles != 0 || usage.homings != 0 {
+		t.Fatalf("extend/retract must not count cycles or homings: %+v", usage)
+	}
 }
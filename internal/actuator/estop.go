@@ -0,0 +1,69 @@
+package actuator
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrEmergencyStop is returned by movements that were aborted by EmergencyStop or
+// refused while the emergency stop is latched.
+var ErrEmergencyStop = errors.New("emergency stop active")
+
+// estopLatch tracks running movements so an emergency stop can abort them, and
+// refuses new movements until it is reset.
+type estopLatch struct {
+	mu      sync.Mutex
+	stopped bool
+	nextID  int
+	running map[int]context.CancelCauseFunc
+}
+
+// begin registers a movement. The returned context is cancelled with
+// ErrEmergencyStop when the latch trips; done must be called when the movement ends.
+func (l *estopLatch) begin(ctx context.Context) (context.Context, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return nil, nil, ErrEmergencyStop
+	}
+
+	moveCtx, cancel := context.WithCancelCause(ctx)
+	if l.running == nil {
+		l.running = make(map[int]context.CancelCauseFunc)
+	}
+	id := l.nextID
+	l.nextID++
+	l.running[id] = cancel
+
+	return moveCtx, func() {
+		l.mu.Lock()
+		delete(l.running, id)
+		l.mu.Unlock()
+		cancel(nil)
+	}, nil
+}
+
+// trip latches the emergency stop and aborts every running movement.
+func (l *estopLatch) trip() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = true
+	for id, cancel := range l.running {
+		cancel(ErrEmergencyStop)
+		delete(l.running, id)
+	}
+}
+
+// reset clears the latch so movements are accepted again.
+func (l *estopLatch) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = false
+}
+
+func (l *estopLatch) isStopped() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopped
+}
@@ -0,0 +1,23 @@
+package actuator
+
+import "context"
+
+// Phases of a Trigger cycle reported to a phase observer.
+const (
+	PhaseExtending  = "extending"
+	PhaseRetracting = "retracting"
+)
+
+type phaseObserverKey struct{}
+
+// WithPhaseObserver returns a context that makes Trigger call observe when the cycle
+// starts extending and when it starts retracting.
+func WithPhaseObserver(ctx context.Context, observe func(phase string)) context.Context {
+	return context.WithValue(ctx, phaseObserverKey{}, observe)
+}
+
+func reportPhase(ctx context.Context, phase string) {
+	if observe, ok := ctx.Value(phaseObserverKey{}).(func(string)); ok && observe != nil {
+		observe(phase)
+	}
+}
@@ -0,0 +1,224 @@
+package actuator
+
+import (
+	"log"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/physic"
+)
+
+// pwmFrequency is the hardware PWM frequency used on the ENA pin.
+const pwmFrequency = 1 * physic.KiloHertz
+
+// rampStep is how often the ENA duty cycle is updated while ramping with hardware PWM.
+const rampStep = 20 * time.Millisecond
+
+// rampProfile describes one movement: ENA duty rises linearly from 0 to speed over
+// rampUp, holds, then falls back to 0 over rampDown. total is the full motor-on time.
+type rampProfile struct {
+	speed    float64
+	rampUp   time.Duration
+	rampDown time.Duration
+	total    time.Duration
+}
+
+// newRampProfile builds a profile that covers the same stroke distance as running at
+// full speed for distance. Travel is assumed proportional to duty cycle, so each ramp
+// only covers half of its duration and a lower speed needs a longer run.
+// If the ramps alone would overshoot the distance, they are shortened proportionally
+// (triangular profile) so the stroke still matches.
+func newRampProfile(speed float64, rampUp, rampDown, distance time.Duration) rampProfile {
+	speed = normalizeSpeed(speed)
+	if rampUp < 0 {
+		rampUp = 0
+	}
+	if rampDown < 0 {
+		rampDown = 0
+	}
+	if distance <= 0 {
+		return rampProfile{speed: speed}
+	}
+
+	atSpeed := time.Duration(float64(distance) / speed)
+	ramps := rampUp + rampDown
+	if ramps == 0 {
+		return rampProfile{speed: speed, total: atSpeed}
+	}
+
+	if atSpeed < ramps/2 {
+		scale := 2 * float64(atSpeed) / float64(ramps)
+		rampUp = time.Duration(float64(rampUp) * scale)
+		rampDown = time.Duration(float64(rampDown) * scale)
+		return rampProfile{speed: speed, rampUp: rampUp, rampDown: rampDown, total: rampUp + rampDown}
+	}
+
+	return rampProfile{speed: speed, rampUp: rampUp, rampDown: rampDown, total: atSpeed + ramps/2}
+}
+
+// normalizeSpeed maps unset or out-of-range speeds to full speed.
+func normalizeSpeed(speed float64) float64 {
+	if speed <= 0 || speed > 1 {
+		return 1
+	}
+	return speed
+}
+
+// isFlat reports whether the profile runs at a constant duty cycle (no ramps).
+func (p rampProfile) isFlat() bool {
+	return p.rampUp == 0 && p.rampDown == 0
+}
+
+// dutyAt returns the ENA duty cycle (0.0–1.0) at the given time into the movement.
+func (p rampProfile) dutyAt(elapsed time.Duration) float64 {
+	if elapsed < 0 || elapsed >= p.total {
+		return 0
+	}
+	if p.rampUp > 0 && elapsed < p.rampUp {
+		return p.speed * float64(elapsed) / float64(p.rampUp)
+	}
+	rampDownStart := p.total - p.rampDown
+	if p.rampDown > 0 && elapsed > rampDownStart {
+		return p.speed * float64(p.total-elapsed) / float64(p.rampDown)
+	}
+	return p.speed
+}
+
+// runEnable drives ENA through the profile and blocks for its full duration, or until
+// abort is closed. Hardware PWM is used when available; otherwise ENA is toggled in
+// software. ENA is left LOW when the profile completes or is aborted.
+func (a *GPIO) runEnable(p rampProfile, abort <-chan struct{}) {
+	if a.enaPin == nil {
+		sleepOrAbort(p.total, abort)
+		return
+	}
+
+	if p.isFlat() && p.speed >= 1 {
+		if err := a.enaPin.Out(gpio.High); err != nil {
+			log.Printf("Actuator: failed to set ENA high: %v", err)
+		}
+		sleepOrAbort(p.total, abort)
+		a.disableENA()
+		return
+	}
+
+	if !a.softwarePWM {
+		err := a.enaPin.PWM(dutyFor(p.dutyAt(0)), pwmFrequency)
+		if err == nil {
+			hardwareRamp(a.enaPin, p, abort)
+			a.disableENA()
+			return
+		}
+		log.Printf("Actuator: hardware PWM unavailable on ENA pin (using software PWM): %v", err)
+		a.softwarePWM = true
+	}
+
+	softwareRampPWM(a.enaPin, p, abort)
+}
+
+// sleepOrAbort waits for d or until abort is closed and reports whether it was aborted.
+// A nil abort channel never fires.
+func sleepOrAbort(d time.Duration, abort <-chan struct{}) bool {
+	if d <= 0 {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return false
+	case <-abort:
+		return true
+	}
+}
+
+// disableENA drives ENA LOW so the motor driver output is off.
+func (a *GPIO) disableENA() {
+	if a.enaPin == nil {
+		return
+	}
+	if err := a.enaPin.Out(gpio.Low); err != nil {
+		log.Printf("Actuator: failed to set ENA low: %v", err)
+	}
+}
+
+// dutyFor converts a 0.0–1.0 duty cycle into a gpio.Duty.
+func dutyFor(duty float64) gpio.Duty {
+	if duty < 0 {
+		duty = 0
+	}
+	if duty > 1 {
+		duty = 1
+	}
+	return gpio.Duty(float64(gpio.DutyMax) * duty)
+}
+
+// pwmPin is the subset of gpio.PinOut needed for hardware PWM ramps.
+type pwmPin interface {
+	PWM(duty gpio.Duty, f physic.Frequency) error
+}
+
+// hardwareRamp updates the hardware PWM duty cycle in rampStep increments until the
+// profile completes or abort is closed.
+func hardwareRamp(pin pwmPin, p rampProfile, abort <-chan struct{}) {
+	start := time.Now()
+	last := -1.0
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= p.total {
+			return
+		}
+		duty := p.dutyAt(elapsed)
+		if duty != last {
+			if err := pin.PWM(dutyFor(duty), pwmFrequency); err != nil {
+				log.Printf("Actuator: failed to update ENA PWM duty: %v", err)
+			}
+			last = duty
+		}
+		wait := rampStep
+		if remaining := p.total - elapsed; remaining < wait {
+			wait = remaining
+		}
+		if sleepOrAbort(wait, abort) {
+			return
+		}
+	}
+}
+
+// pinToggler is a minimal interface used by softwareRampPWM.
+type pinToggler interface {
+	Out(l gpio.Level) error
+}
+
+// softwareRampPWM emulates a ramped PWM signal on a digital output pin by toggling it
+// at ~100 Hz, recomputing the duty cycle every period. It stops early when abort is closed.
+func softwareRampPWM(pin pinToggler, p rampProfile, abort <-chan struct{}) {
+	const period = 10 * time.Millisecond // 100 Hz
+	start := time.Now()
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= p.total {
+			break
+		}
+		duty := p.dutyAt(elapsed)
+		highTime := time.Duration(float64(period) * duty)
+		lowTime := period - highTime
+		if remaining := p.total - elapsed; remaining < period {
+			highTime = time.Duration(float64(remaining) * duty)
+			lowTime = remaining - highTime
+		}
+		if highTime > 0 {
+			_ = pin.Out(gpio.High)
+			if sleepOrAbort(highTime, abort) {
+				break
+			}
+		}
+		if lowTime > 0 {
+			_ = pin.Out(gpio.Low)
+			if sleepOrAbort(lowTime, abort) {
+				break
+			}
+		}
+	}
+	_ = pin.Out(gpio.Low)
+}
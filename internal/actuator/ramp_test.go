@@ -0,0 +1,137 @@
+package actuator
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// strokeOf integrates the duty cycle over the profile to get the full-speed-equivalent travel.
+func strokeOf(p rampProfile) time.Duration {
+	const step = time.Millisecond
+	var sum float64
+	for t := time.Duration(0); t < p.total; t += step {
+		sum += p.dutyAt(t) * float64(step)
+	}
+	return time.Duration(sum)
+}
+
+func TestNewRampProfileFullSpeedWithoutRampsMatchesDistance(t *testing.T) {
+	p := newRampProfile(0, 0, 0, 2*time.Second)
+	if p.total != 2*time.Second {
+		t.Fatalf("expected total 2s, got %v", p.total)
+	}
+	if !p.isFlat() || p.speed != 1 {
+		t.Fatalf("expected flat full-speed profile, got %+v", p)
+	}
+}
+
+func TestNewRampProfileCompensatesRampsAndSpeed(t *testing.T) {
+	tests := []struct {
+		name      string
+		speed     float64
+		up, down  time.Duration
+		distance  time.Duration
+		wantTotal time.Duration
+	}{
+		{"ramps at full speed", 1, 200 * time.Millisecond, 200 * time.Millisecond, 2 * time.Second, 2200 * time.Millisecond},
+		{"half speed no ramps", 0.5, 0, 0, 2 * time.Second, 4 * time.Second},
+		{"half speed with ramps", 0.5, 400 * time.Millisecond, 200 * time.Millisecond, time.Second, 2300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newRampProfile(tt.speed, tt.up, tt.down, tt.distance)
+			if p.total != tt.wantTotal {
+				t.Fatalf("expected total %v, got %v", tt.wantTotal, p.total)
+			}
+			stroke := strokeOf(p)
+			if math.Abs(float64(stroke-tt.distance)) > float64(5*time.Millisecond) {
+				t.Fatalf("expected stroke ~%v, got %v", tt.distance, stroke)
+			}
+		})
+	}
+}
+
+func TestNewRampProfileShortensRampsForShortStrokes(t *testing.T) {
+	p := newRampProfile(1, 500*time.Millisecond, 500*time.Millisecond, 200*time.Millisecond)
+	if p.rampUp+p.rampDown != p.total {
+		t.Fatalf("expected triangular profile, got %+v", p)
+	}
+	if p.total != 400*time.Millisecond {
+		t.Fatalf("expected total 400ms, got %v", p.total)
+	}
+	stroke := strokeOf(p)
+	if math.Abs(float64(stroke-200*time.Millisecond)) > float64(5*time.Millisecond) {
+		t.Fatalf("expected stroke ~200ms, got %v", stroke)
+	}
+}
+
+func TestRampProfileDutyAt(t *testing.T) {
+	p := rampProfile{speed: 0.8, rampUp: 100 * time.Millisecond, rampDown: 100 * time.Millisecond, total: time.Second}
+
+	if got := p.dutyAt(0); got != 0 {
+		t.Fatalf("expected duty 0 at start, got %.2f", got)
+	}
+	if got := p.dutyAt(50 * time.Millisecond); math.Abs(got-0.4) > 1e-9 {
+		t.Fatalf("expected duty 0.4 mid ramp-up, got %.2f", got)
+	}
+	if got := p.dutyAt(500 * time.Millisecond); got != 0.8 {
+		t.Fatalf("expected duty 0.8 while holding, got %.2f", got)
+	}
+	if got := p.dutyAt(950 * time.Millisecond); math.Abs(got-0.4) > 1e-9 {
+		t.Fatalf("expected duty 0.4 mid ramp-down, got %.2f", got)
+	}
+	if got := p.dutyAt(time.Second); got != 0 {
+		t.Fatalf("expected duty 0 after profile, got %.2f", got)
+	}
+}
+
+type recordingPin struct {
+	levels []gpio.Level
+}
+
+func (p *recordingPin) Out(l gpio.Level) error {
+	p.levels = append(p.levels, l)
+	return nil
+}
+
+func TestSoftwareRampPWMRunsForProfileAndEndsLow(t *testing.T) {
+	pin := &recordingPin{}
+	p := newRampProfile(0.6, 30*time.Millisecond, 30*time.Millisecond, 60*time.Millisecond)
+
+	start := time.Now()
+	softwareRampPWM(pin, p, nil)
+	elapsed := time.Since(start)
+
+	if elapsed < p.total || elapsed > p.total+50*time.Millisecond {
+		t.Fatalf("unexpected elapsed time: %v (want ~%v)", elapsed, p.total)
+	}
+	if len(pin.levels) == 0 {
+		t.Fatal("no pin transitions recorded")
+	}
+	if last := pin.levels[len(pin.levels)-1]; last != gpio.Low {
+		t.Fatalf("expected pin to end LOW, got %v", last)
+	}
+}
+
+func TestSimulatedTriggerTimingIncludesRampCompensation(t *testing.T) {
+	a := &Simulated{motion: motion{
+		movementTime: 10 * time.Millisecond,
+		rampUp:       100 * time.Millisecond,
+		rampDown:     100 * time.Millisecond,
+	}}
+
+	totalMs, err := a.Trigger(context.Background())
+	if err != nil {
+		t.Fatalf("Trigger returned error: %v", err)
+	}
+
+	// extend: 10ms stroke shortened to a 20ms triangle; retract: 1010ms + 100ms ramps; 2x 100ms settling
+	if totalMs < 1300 || totalMs > 1360 {
+		t.Fatalf("unexpected reported duration: %dms", totalMs)
+	}
+}
@@ -0,0 +1,92 @@
+package actuator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Call is one movement recorded by a Recorder.
+type Call struct {
+	Method   string        // "home", "extend", "retract", "trigger", "estop" or "estop_reset"
+	Duration time.Duration // requested stroke for extend/retract
+}
+
+// Recorder is an Actuator that records movements instead of driving hardware.
+// Configure Delay, TotalMs and Err before use; they are not safe to change while
+// movements run.
+type Recorder struct {
+	Delay   time.Duration // how long each movement blocks (cancellable via ctx)
+	TotalMs int           // reported by Trigger
+	Err     error         // returned by every movement
+
+	mu    sync.Mutex
+	calls []Call
+	estop estopLatch
+}
+
+// NewRecorder returns a Recorder whose movements complete immediately.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Calls returns a copy of the recorded movements in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+func (r *Recorder) record(ctx context.Context, call Call) error {
+	r.append(call)
+
+	ctx, done, err := r.estop.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := sleepCtx(ctx, r.Delay); err != nil {
+		return err
+	}
+	return r.Err
+}
+
+func (r *Recorder) append(call Call) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) Home(ctx context.Context) error {
+	return r.record(ctx, Call{Method: "home"})
+}
+
+func (r *Recorder) Extend(ctx context.Context, duration time.Duration) error {
+	return r.record(ctx, Call{Method: "extend", Duration: duration})
+}
+
+func (r *Recorder) Retract(ctx context.Context, duration time.Duration) error {
+	return r.record(ctx, Call{Method: "retract", Duration: duration})
+}
+
+func (r *Recorder) Trigger(ctx context.Context) (int, error) {
+	reportPhase(ctx, PhaseExtending)
+	if err := r.record(ctx, Call{Method: "trigger"}); err != nil {
+		return 0, err
+	}
+	return r.TotalMs, nil
+}
+
+func (r *Recorder) EmergencyStop() error {
+	r.append(Call{Method: "estop"})
+	r.estop.trip()
+	return nil
+}
+
+func (r *Recorder) ResetEmergencyStop() {
+	r.append(Call{Method: "estop_reset"})
+	r.estop.reset()
+}
+
+func (r *Recorder) Close() error { return nil }
@@ -0,0 +1,123 @@
+package actuator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Simulated is an Actuator without hardware. Movements only wait for the time the
+// real actuator would need (including ramps and settling), so the state machine keeps
+// realistic timing on machines without GPIO.
+type Simulated struct {
+	motion
+	estop estopLatch
+}
+
+// NewSimulated returns a simulated actuator with the timing of config.
+func NewSimulated(config Config) *Simulated {
+	return &Simulated{motion: newMotion(config)}
+}
+
+// Home waits for the homing duration.
+func (s *Simulated) Home(ctx context.Context) error {
+	ctx, done, err := s.estop.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	log.Printf("Actuator (SIMULATION): homing for %v", homingDuration)
+	if err := sleepCtx(ctx, homingDuration); err != nil {
+		return fmt.Errorf("homing failed: %w", err)
+	}
+	log.Println("Actuator (SIMULATION): homing complete - now at home position")
+	return nil
+}
+
+// Extend waits for the time an extend of the given stroke would take.
+func (s *Simulated) Extend(ctx context.Context, duration time.Duration) error {
+	ctx, done, err := s.estop.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	profile := s.extendProfile(duration)
+	log.Printf("Actuator (SIMULATION): would extend for %v (stroke %v)", profile.total, duration)
+	if err := sleepCtx(ctx, profile.total); err != nil {
+		return fmt.Errorf("extend failed: %w", err)
+	}
+	return nil
+}
+
+// Retract waits for the time a retract of the given stroke would take.
+func (s *Simulated) Retract(ctx context.Context, duration time.Duration) error {
+	ctx, done, err := s.estop.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	profile := s.retractProfile(duration)
+	log.Printf("Actuator (SIMULATION): would retract for %v (stroke %v)", profile.total, duration)
+	if err := sleepCtx(ctx, profile.total); err != nil {
+		return fmt.Errorf("retract failed: %w", err)
+	}
+	return nil
+}
+
+// Trigger waits for one extend-retract cycle including settling delays.
+func (s *Simulated) Trigger(ctx context.Context) (int, error) {
+	ctx, done, err := s.estop.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	start := time.Now()
+	extend := s.extendProfile(s.movementTime)
+	retract := s.retractProfile(s.movementTime + retractExtra)
+
+	reportPhase(ctx, PhaseExtending)
+	if err := sleepCtx(ctx, extend.total+settlingDelay); err != nil {
+		return 0, fmt.Errorf("extend failed: %w", err)
+	}
+	reportPhase(ctx, PhaseRetracting)
+	if err := sleepCtx(ctx, retract.total+settlingDelay); err != nil {
+		return 0, fmt.Errorf("retract failed: %w", err)
+	}
+
+	totalMs := int(time.Since(start).Milliseconds())
+	log.Printf("Actuator (SIMULATION) cycle complete: extend=%v, retract=%v, total=%dms",
+		extend.total, retract.total, totalMs)
+	return totalMs, nil
+}
+
+// EmergencyStop aborts the running wait and refuses movements until reset.
+func (s *Simulated) EmergencyStop() error {
+	s.estop.trip()
+	log.Println("Actuator (SIMULATION): EMERGENCY STOP")
+	return nil
+}
+
+// ResetEmergencyStop accepts movements again.
+func (s *Simulated) ResetEmergencyStop() {
+	s.estop.reset()
+}
+
+// Close is a no-op.
+func (s *Simulated) Close() error { return nil }
+
+// sleepCtx waits for d or until ctx is done and returns the cancellation cause in the
+// latter case.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if sleepOrAbort(d, ctx.Done()) {
+		return context.Cause(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package actuator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// Stall reasons reported in StallError.
+const (
+	StallReasonStall    = "stall"
+	StallReasonOverload = "overload"
+)
+
+// profileBuckets is the number of time slices a per-stroke current profile is reduced to for logging.
+const profileBuckets = 10
+
+// ErrStall is matched (via errors.Is) by every StallError.
+var ErrStall = errors.New("actuator stalled")
+
+// CurrentReader reports the motor supply current, e.g. an INA219/INA226 on the H-bridge supply.
+type CurrentReader interface {
+	ReadCurrentMilliamps() (float64, error)
+}
+
+// StallError reports a movement that was aborted because of a motor stall or overload.
+type StallError struct {
+	Direction string        // "extend", "retract" or "home"
+	Reason    string        // StallReasonStall or StallReasonOverload
+	CurrentMa float64       // current that triggered the abort
+	After     time.Duration // time into the movement
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("actuator %s during %s after %v (%.0fmA)", e.Reason, e.Direction, e.After.Round(time.Millisecond), e.CurrentMa)
+}
+
+func (e *StallError) Unwrap() error { return ErrStall }
+
+// stallLimits holds the current thresholds used while monitoring a movement.
+// A zero threshold disables that check.
+type stallLimits struct {
+	stallMa     float64
+	stallFor    time.Duration
+	overloadMa  float64
+	inrush      time.Duration
+	sampleEvery time.Duration
+	reverse     time.Duration
+}
+
+func newStallLimits(config Config) stallLimits {
+	l := stallLimits{
+		stallMa:     float64(config.StallCurrentMa),
+		stallFor:    time.Duration(config.StallDurationMs) * time.Millisecond,
+		overloadMa:  float64(config.OverloadCurrentMa),
+		inrush:      time.Duration(config.InrushIgnoreMs) * time.Millisecond,
+		sampleEvery: time.Duration(config.CurrentSampleMs) * time.Millisecond,
+		reverse:     time.Duration(config.StallReverseMs) * time.Millisecond,
+	}
+	if l.sampleEvery <= 0 {
+		l.sampleEvery = 20 * time.Millisecond
+	}
+	return l
+}
+
+// stallDetector evaluates current samples against stallLimits.
+type stallDetector struct {
+	limits    stallLimits
+	highSince time.Duration
+	high      bool
+}
+
+// observe feeds one sample taken at the given time into the movement and returns a
+// stall reason once the limits are exceeded. Samples during inrush are ignored.
+func (d *stallDetector) observe(at time.Duration, ma float64) string {
+	if at < d.limits.inrush {
+		return ""
+	}
+	ma = math.Abs(ma)
+	if d.limits.overloadMa > 0 && ma >= d.limits.overloadMa {
+		return StallReasonOverload
+	}
+	if d.limits.stallMa <= 0 || ma < d.limits.stallMa {
+		d.high = false
+		return ""
+	}
+	if !d.high {
+		d.high = true
+		d.highSince = at
+	}
+	if at-d.highSince >= d.limits.stallFor {
+		return StallReasonStall
+	}
+	return ""
+}
+
+type currentSample struct {
+	at time.Duration
+	ma float64
+}
+
+// currentMonitor samples the motor current in the background during one movement and
+// calls abort when a stall or overload is detected.
+type currentMonitor struct {
+	reader    CurrentReader
+	detector  stallDetector
+	direction string
+	interval  time.Duration
+	abort     context.CancelFunc
+	stop      chan struct{}
+	done      chan struct{}
+	samples   []currentSample
+	stall     *StallError
+}
+
+// startCurrentMonitor starts sampling for a movement. It returns nil when no current
+// sensor is configured; a nil monitor is safe to use.
+func (a *GPIO) startCurrentMonitor(direction string, abort context.CancelFunc) *currentMonitor {
+	if a.currentSensor == nil {
+		return nil
+	}
+	m := &currentMonitor{
+		reader:    a.currentSensor,
+		detector:  stallDetector{limits: a.stallLimits},
+		direction: direction,
+		interval:  a.stallLimits.sampleEvery,
+		abort:     abort,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go m.run(time.Now())
+	return m
+}
+
+func (m *currentMonitor) run(start time.Time) {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	readErrLogged := false
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+
+		at := time.Since(start)
+		ma, err := m.reader.ReadCurrentMilliamps()
+		if err != nil {
+			if !readErrLogged {
+				log.Printf("Actuator: current sensor read failed during %s: %v", m.direction, err)
+				readErrLogged = true
+			}
+			continue
+		}
+		m.samples = append(m.samples, currentSample{at: at, ma: ma})
+
+		if reason := m.detector.observe(at, ma); reason != "" {
+			m.stall = &StallError{Direction: m.direction, Reason: reason, CurrentMa: math.Abs(ma), After: at}
+			m.abort()
+			return
+		}
+	}
+}
+
+// finish stops sampling, logs the stroke's current profile and returns the stall, if any.
+func (m *currentMonitor) finish() *StallError {
+	if m == nil {
+		return nil
+	}
+	select {
+	case <-m.done:
+	default:
+		close(m.stop)
+		<-m.done
+	}
+	log.Printf("Actuator current profile (%s): %s", m.direction, summarizeCurrentProfile(m.samples))
+	return m.stall
+}
+
+// summarizeCurrentProfile reduces the samples of one stroke to a single log line with
+// mean, peak and per-bucket means for trend analysis.
+func summarizeCurrentProfile(samples []currentSample) string {
+	if len(samples) == 0 {
+		return "samples=0"
+	}
+
+	var sum, peak float64
+	for _, s := range samples {
+		ma := math.Abs(s.ma)
+		sum += ma
+		if ma > peak {
+			peak = ma
+		}
+	}
+	duration := samples[len(samples)-1].at
+
+	buckets := profileBuckets
+	if len(samples) < buckets {
+		buckets = len(samples)
+	}
+	sums := make([]float64, buckets)
+	counts := make([]int, buckets)
+	for i, s := range samples {
+		b := i * buckets / len(samples)
+		sums[b] += math.Abs(s.ma)
+		counts[b]++
+	}
+	parts := make([]string, buckets)
+	for i := range sums {
+		parts[i] = fmt.Sprintf("%.0f", sums[i]/float64(counts[i]))
+	}
+
+	return fmt.Sprintf("samples=%d duration=%v mean=%.0fmA peak=%.0fmA profile_ma=[%s]",
+		len(samples), duration.Round(time.Millisecond), sum/float64(len(samples)), peak, strings.Join(parts, " "))
+}
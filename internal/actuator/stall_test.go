@@ -0,0 +1,135 @@
+package actuator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
+)
+
+// fakeCurrentReader returns current from a function of time since the first read.
+type fakeCurrentReader struct {
+	mu    sync.Mutex
+	start time.Time
+	at    func(elapsed time.Duration) float64
+}
+
+func (f *fakeCurrentReader) ReadCurrentMilliamps() (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.start.IsZero() {
+		f.start = time.Now()
+	}
+	return f.at(time.Since(f.start)), nil
+}
+
+func TestStallDetectorIgnoresInrush(t *testing.T) {
+	d := stallDetector{limits: stallLimits{stallMa: 1000, stallFor: 50 * time.Millisecond, overloadMa: 2000, inrush: 100 * time.Millisecond}}
+	if reason := d.observe(20*time.Millisecond, 5000); reason != "" {
+		t.Fatalf("expected inrush sample to be ignored, got %q", reason)
+	}
+}
+
+func TestStallDetectorOverloadAbortsImmediately(t *testing.T) {
+	d := stallDetector{limits: stallLimits{stallMa: 1000, stallFor: time.Second, overloadMa: 2000}}
+	if reason := d.observe(10*time.Millisecond, -2100); reason != StallReasonOverload {
+		t.Fatalf("expected overload, got %q", reason)
+	}
+}
+
+func TestStallDetectorRequiresSustainedCurrent(t *testing.T) {
+	d := stallDetector{limits: stallLimits{stallMa: 1000, stallFor: 100 * time.Millisecond}}
+
+	if reason := d.observe(0, 1200); reason != "" {
+		t.Fatalf("unexpected stall at first high sample: %q", reason)
+	}
+	if reason := d.observe(60*time.Millisecond, 800); reason != "" {
+		t.Fatalf("unexpected stall after current dropped: %q", reason)
+	}
+	if reason := d.observe(80*time.Millisecond, 1200); reason != "" {
+		t.Fatalf("unexpected stall after restart of high period: %q", reason)
+	}
+	if reason := d.observe(150*time.Millisecond, 1200); reason != "" {
+		t.Fatalf("unexpected stall before duration elapsed: %q", reason)
+	}
+	if reason := d.observe(180*time.Millisecond, 1200); reason != StallReasonStall {
+		t.Fatalf("expected stall, got %q", reason)
+	}
+}
+
+func TestSummarizeCurrentProfile(t *testing.T) {
+	samples := []currentSample{
+		{at: 0, ma: 100},
+		{at: 10 * time.Millisecond, ma: 300},
+		{at: 20 * time.Millisecond, ma: -200},
+	}
+	got := summarizeCurrentProfile(samples)
+	for _, want := range []string{"samples=3", "duration=20ms", "mean=200mA", "peak=300mA", "profile_ma=[100 300 200]"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in %q", want, got)
+		}
+	}
+	if got := summarizeCurrentProfile(nil); got != "samples=0" {
+		t.Fatalf("unexpected empty summary: %q", got)
+	}
+}
+
+func newTestHardwareActuator(reader CurrentReader) *GPIO {
+	cfg := Config{
+		MovementTime:      2,
+		CurrentSensor:     reader,
+		StallCurrentMa:    1000,
+		StallDurationMs:   50,
+		OverloadCurrentMa: 2000,
+		InrushIgnoreMs:    20,
+		CurrentSampleMs:   5,
+		StallReverseMs:    30,
+	}
+	return newGPIO(cfg, &gpiotest.Pin{N: "ENA"}, &gpiotest.Pin{N: "IN1"}, &gpiotest.Pin{N: "IN2"})
+}
+
+func TestDriveAbortsAndReversesOnOverload(t *testing.T) {
+	reader := &fakeCurrentReader{at: func(elapsed time.Duration) float64 {
+		if elapsed > 100*time.Millisecond {
+			return 2500
+		}
+		return 500
+	}}
+	a := newTestHardwareActuator(reader)
+
+	start := time.Now()
+	err := a.drive(context.Background(), "extend", gpio.High, gpio.Low, rampProfile{speed: 1, total: 2 * time.Second})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrStall) {
+		t.Fatalf("expected ErrStall, got %v", err)
+	}
+	var stall *StallError
+	if !errors.As(err, &stall) || stall.Reason != StallReasonOverload || stall.Direction != "extend" {
+		t.Fatalf("unexpected stall error: %+v", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("movement was not aborted early: %v", elapsed)
+	}
+	if a.in1Pin.(*gpiotest.Pin).L != gpio.Low || a.in2Pin.(*gpiotest.Pin).L != gpio.Low || a.enaPin.(*gpiotest.Pin).L != gpio.Low {
+		t.Fatal("expected motor stopped after reverse")
+	}
+}
+
+func TestDriveCompletesWithNormalCurrent(t *testing.T) {
+	reader := &fakeCurrentReader{at: func(time.Duration) float64 { return 400 }}
+	a := newTestHardwareActuator(reader)
+
+	start := time.Now()
+	if err := a.drive(context.Background(), "retract", gpio.Low, gpio.High, rampProfile{speed: 1, total: 150 * time.Millisecond}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("movement ended early: %v", elapsed)
+	}
+}
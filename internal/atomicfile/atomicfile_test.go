@@ -0,0 +1,33 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := Write(path, []byte(content)); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("expected %q, got %q (%v)", content, data, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the target file to remain, got %v (%v)", entries, err)
+	}
+}
+
+func TestWriteMissingDirectory(t *testing.T) {
+	if err := Write(filepath.Join(t.TempDir(), "missing", "state.json"), []byte("x")); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,119 @@
+package camera
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// backend captures images from one kind of camera.
+type backend interface {
+	// name describes the backend in logs.
+	name() string
+	// capture takes one image and returns the raw JPEG.
+	capture(ctx context.Context, opts Options) ([]byte, error)
+	// software returns the part of opts the backend cannot apply itself; postProcess
+	// applies it to the captured image.
+	software(opts Options) Options
+	// stream sends MJPEG preview frames until ctx is done. orient holds the configured
+	// rotation, flips and crop, which the preview should match.
+	stream(ctx context.Context, opts StreamOptions, orient Options, frame func([]byte) error) error
+}
+
+// selectBackend returns the backend configured in cfg, or nil for simulation mode.
+func selectBackend(cfg Config) (backend, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Backend)) {
+	case "", BackendAuto:
+		if b := newLibcameraBackend(); b != nil {
+			return b, nil
+		}
+		if b := newV4L2Backend(cfg.Device); b != nil {
+			if _, err := os.Stat(b.device); err == nil {
+				return b, nil
+			}
+		}
+		log.Println("Warning: no libcamera tool and no V4L2 camera with ffmpeg or fswebcam found, running in simulation mode")
+		return nil, nil
+	case BackendLibcamera:
+		if b := newLibcameraBackend(); b != nil {
+			return b, nil
+		}
+		return nil, fmt.Errorf("camera: neither rpicam-still nor libcamera-still found")
+	case BackendV4L2:
+		b := newV4L2Backend(cfg.Device)
+		if b == nil {
+			return nil, fmt.Errorf("camera: neither ffmpeg nor fswebcam found for the v4l2 backend")
+		}
+		if _, err := os.Stat(b.device); err != nil {
+			// USB cameras may be plugged in later; captures fail until then.
+			log.Printf("Warning: camera device %s not available yet: %v", b.device, err)
+		}
+		return b, nil
+	case BackendFile:
+		return newFileBackend(cfg.FilePath)
+	case BackendSimulation:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("camera: unknown backend %q (expected auto, libcamera, v4l2, file or simulation)", cfg.Backend)
+	}
+}
+
+// lookTool returns the path of the first candidate found on PATH, or "".
+func lookTool(candidates ...string) string {
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// runTool runs a capture tool and returns its stdout, with stderr in the error.
+func runTool(ctx context.Context, bin string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, bin, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr := strings.TrimSpace(string(exitErr.Stderr))
+			if stderr != "" {
+				if len(stderr) > 500 {
+					stderr = stderr[:500]
+				}
+				return nil, fmt.Errorf("camera capture failed: %v: %s", err, stderr)
+			}
+		}
+		return nil, fmt.Errorf("camera capture failed: %w", err)
+	}
+	return out, nil
+}
+
+// streamTool runs a tool that writes MJPEG to stdout and sends each frame to frame.
+func streamTool(ctx context.Context, frame func([]byte) error, bin string, args ...string) error {
+	cmd := exec.CommandContext(ctx, bin, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("camera: preview stream failed: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("camera: preview stream failed: %w", err)
+	}
+	defer cmd.Wait()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 512*1024), maxJPEGBytes)
+	scanner.Split(splitJPEG)
+	for scanner.Scan() {
+		if err := frame(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("camera: preview stream failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package camera
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// markedJPEG returns a w×h JPEG that is black except for a white top-left quadrant.
+func markedJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h/2; y++ {
+		for x := 0; x < w/2; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSelectBackend(t *testing.T) {
+	dir := t.TempDir()
+	if b, err := selectBackend(Config{Backend: BackendSimulation}); err != nil || b != nil {
+		t.Fatalf("expected simulation mode, got %v (err=%v)", b, err)
+	}
+	if b, err := selectBackend(Config{Backend: "File", FilePath: dir}); err != nil || b.(*fileBackend).path != dir {
+		t.Fatalf("expected the file backend, got %v (err=%v)", b, err)
+	}
+	for _, bad := range []Config{
+		{Backend: BackendFile},
+		{Backend: BackendFile, FilePath: filepath.Join(dir, "missing")},
+		{Backend: "webcam"},
+	} {
+		if _, err := selectBackend(bad); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestV4L2Args(t *testing.T) {
+	opts := Options{Width: 1280, Height: 720, Quality: 100}
+	ffmpeg := &v4l2Backend{device: "/dev/video2", tool: "/usr/bin/ffmpeg"}
+	got := strings.Join(ffmpeg.captureArgs(opts), " ")
+	want := "-hide_banner -loglevel error -f v4l2 -video_size 1280x720 -i /dev/video2 -frames:v 1 -q:v 2 -f image2pipe -c:v mjpeg -"
+	if got != want {
+		t.Fatalf("unexpected ffmpeg args:\n got %s\nwant %s", got, want)
+	}
+
+	fswebcam := &v4l2Backend{device: "/dev/video2", tool: "/usr/bin/fswebcam"}
+	got = strings.Join(fswebcam.captureArgs(opts), " ")
+	want = "--quiet --no-banner --device /dev/video2 --skip 5 --resolution 1280x720 --jpeg 100 -"
+	if got != want {
+		t.Fatalf("unexpected fswebcam args:\n got %s\nwant %s", got, want)
+	}
+
+	orient := Options{Rotation: 90, HFlip: Flag(true), Crop: &Crop{X: 0.25, Y: 0, W: 0.5, H: 1}}
+	got = strings.Join(ffmpeg.streamArgs(StreamOptions{Width: 640, Height: 480, FPS: 10}, orient), " ")
+	want = "-hide_banner -loglevel error -f v4l2 -framerate 10 -video_size 640x480 -i /dev/video2 -vf crop=iw*0.5:ih*1:iw*0.25:ih*0,hflip,transpose=clock -f mjpeg -q:v 5 -"
+	if got != want {
+		t.Fatalf("unexpected ffmpeg stream args:\n got %s\nwant %s", got, want)
+	}
+}
+
+// ensure the file backend cycles through a directory and orients images in software
+func TestFileBackendCapture(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.jpg", "a.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), markedJPEG(t, 40, 20), 0o644); err != nil {
+			t.Fatalf("failed to write test image: %v", err)
+		}
+	}
+	b, err := newFileBackend(dir)
+	if err != nil {
+		t.Fatalf("newFileBackend failed: %v", err)
+	}
+	c = &cam{backend: b}
+	defer func() { c = nil }()
+
+	images, err := CaptureWithOptions(Options{BurstCount: 3, BurstIntervalMs: 1, Rotation: 180, Crop: &Crop{X: 0, Y: 0, W: 0.5, H: 1}})
+	if err != nil {
+		t.Fatalf("CaptureWithOptions returned error: %v", err)
+	}
+	if len(images) != 3 || b.next != 1 {
+		t.Fatalf("expected 3 images cycling through 2 files, got %d (next=%d)", len(images), b.next)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(images[0]))
+	if err != nil {
+		t.Fatalf("captured image is not a valid JPEG: %v", err)
+	}
+	// The left half is cropped and turned upside down: the white quadrant ends up at the bottom.
+	if bounds := img.Bounds(); bounds.Dx() != 20 || bounds.Dy() != 20 {
+		t.Fatalf("expected a 20x20 image, got %v", bounds)
+	}
+	top, _, _, _ := img.At(10, 2).RGBA()
+	bottom, _, _, _ := img.At(10, 17).RGBA()
+	if top > 0x4000 || bottom < 0xc000 {
+		t.Fatalf("expected a dark top and a white bottom, got %#x / %#x", top, bottom)
+	}
+}
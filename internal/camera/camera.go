@@ -1,12 +1,14 @@
 package camera
 
 import (
+	"bytes"
 	"context"
-	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
 	"log"
-	"os/exec"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,133 +18,195 @@ const maxJPEGBytes = 8 * 1024 * 1024
 // captureTimeout is the maximum time allowed for a single capture operation.
 const captureTimeout = 15 * time.Second
 
+// Backend names for Config.Backend.
+const (
+	BackendAuto       = "auto"
+	BackendLibcamera  = "libcamera"
+	BackendV4L2       = "v4l2"
+	BackendFile       = "file"
+	BackendSimulation = "simulation"
+)
+
 // Config holds camera configuration.
 type Config struct {
 	Enabled bool
+	// Defaults are the capture options used by Capture and filled into the zero
+	// fields of the options passed to CaptureWithOptions.
+	Defaults Options
+	// Backend selects how images are captured: auto (libcamera, then V4L2, then
+	// simulation), libcamera, v4l2, file or simulation. Empty means auto.
+	Backend string
+	// Device is the V4L2 device used by the v4l2 backend, e.g. /dev/video0.
+	Device string
+	// FilePath is a JPEG file or a directory of JPEGs served by the file backend.
+	FilePath string
 }
 
 type cam struct {
-	bin string // path to libcamera-still / rpicam-still; empty means sim mode
-	sim bool
+	backend  backend // nil in simulation mode
+	sim      bool
+	defaults Options
+	mu       sync.Mutex // the camera serves one capture or stream at a time
+
+	streamMu     sync.Mutex
+	streamCancel context.CancelCauseFunc // non-nil while a preview stream runs
 }
 
 var c *cam
 
-// CheckTools logs whether the required capture tool is available on this system.
+// CheckTools logs whether the supported capture tools are available on this system.
 // Call this once at startup so operators can see immediately if libcamera is missing.
 func CheckTools() {
-	for _, candidate := range []string{"rpicam-still", "libcamera-still"} {
-		if path, err := exec.LookPath(candidate); err == nil {
-			log.Printf("Camera: capture tool found: %s", path)
-			return
-		}
+	found := false
+	if path := lookTool("rpicam-still", "libcamera-still"); path != "" {
+		log.Printf("Camera: capture tool found: %s", path)
+		found = true
+	}
+	if path := lookTool("ffmpeg", "fswebcam"); path != "" {
+		log.Printf("Camera: V4L2 capture tool found: %s", path)
+		found = true
+	}
+	if !found {
+		log.Println("Camera: WARNING: none of libcamera-still, rpicam-still, ffmpeg or fswebcam found on PATH — take_picture commands will not work on this host")
 	}
-	log.Println("Camera: WARNING: neither libcamera-still nor rpicam-still found on PATH — take_picture commands will not work on this host")
 }
 
-// Init initialises the camera. Falls back to simulation mode if no capture tool is found.
+// Init initialises the camera with the configured backend. In auto mode it falls back
+// to simulation if no capture tool is found; an explicitly selected backend that
+// cannot be used returns an error.
 func Init(cfg Config) error {
 	if !cfg.Enabled {
 		log.Println("Camera disabled")
 		return nil
 	}
 
-	for _, candidate := range []string{"rpicam-still", "libcamera-still"} {
-		if path, err := exec.LookPath(candidate); err == nil {
-			c = &cam{bin: path}
-			log.Printf("Camera initialised using %s", path)
-			return nil
-		}
+	b, err := selectBackend(cfg)
+	if err != nil {
+		return err
 	}
-
-	log.Println("Warning: neither libcamera-still nor rpicam-still found, running in simulation mode")
-	c = &cam{sim: true}
+	if b == nil {
+		c = &cam{sim: true, defaults: cfg.Defaults}
+		log.Println("Camera initialised in simulation mode")
+		return nil
+	}
+	c = &cam{backend: b, defaults: cfg.Defaults}
+	log.Printf("Camera initialised using %s", b.name())
 	return nil
 }
 
+// Source describes where images come from: the capture tool of the backend in use,
+// "simulation", or "" when the camera is not initialised.
+func Source() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.backend == nil:
+		return "simulation"
+	default:
+		return c.backend.name()
+	}
+}
+
 // Cleanup releases the camera singleton.
 func Cleanup() {
 	c = nil
 }
 
-// Capture takes a photo and returns the raw JPEG bytes.
-// Returns an error if the camera is not initialised, the capture tool fails,
-// the image is empty, or the image exceeds the 8 MB API limit.
+// Capture takes a photo with the configured default options and returns the raw JPEG
+// bytes. Returns an error if the camera is not initialised, the capture tool fails or
+// the image is empty. Images over the 8 MB API limit are re-encoded at a lower quality.
 func Capture() ([]byte, error) {
 	if c == nil {
 		return nil, fmt.Errorf("camera not initialised or disabled")
 	}
+	opts := c.defaults
+	opts.BurstCount = 1
+	images, err := CaptureWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return images[0], nil
+}
+
+// CaptureWithOptions takes BurstCount photos (at least one) with opts, filling zero
+// fields from the configured defaults, and returns the JPEG bytes of each.
+func CaptureWithOptions(opts Options) ([][]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("camera not initialised or disabled")
+	}
+	opts = opts.Merge(c.defaults)
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	count := max(opts.BurstCount, 1)
+	interval := time.Duration(opts.BurstIntervalMs) * time.Millisecond
+
+	c.preemptStream()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	images := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		image, err := c.captureOne(opts)
+		if err != nil {
+			if count > 1 {
+				return nil, fmt.Errorf("burst image %d/%d: %w", i+1, count, err)
+			}
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func (c *cam) captureOne(opts Options) ([]byte, error) {
 	if c.sim {
 		log.Println("Camera: simulation mode, returning placeholder image")
-		return simulatedJPEG(), nil
+		return postProcess(simulatedFrame(opts), opts, maxJPEGBytes)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), captureTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), captureTimeout+time.Duration(opts.ShutterUs)*time.Microsecond)
 	defer cancel()
 
-	// --timeout 5000: camera warm-up / capture window in ms
-	// --nopreview:    skip display output
-	// --output -:     write JPEG to stdout
-	out, err := exec.CommandContext(ctx, c.bin,
-		"--output", "-",
-		"--timeout", "5000",
-		"--nopreview",
-	).Output()
+	out, err := c.backend.capture(ctx, opts)
 	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			stderr := strings.TrimSpace(string(exitErr.Stderr))
-			if stderr != "" {
-				if len(stderr) > 500 {
-					stderr = stderr[:500]
-				}
-				return nil, fmt.Errorf("camera capture failed: %v: %s", err, stderr)
-			}
-		}
-		return nil, fmt.Errorf("camera capture failed: %w", err)
+		return nil, err
 	}
-
 	if len(out) == 0 {
 		return nil, fmt.Errorf("camera returned empty image")
 	}
 
-	if len(out) > maxJPEGBytes {
-		return nil, fmt.Errorf("captured image exceeds maximum allowed size of 8 MB (%d bytes)", len(out))
+	fitted, err := postProcess(out, c.backend.software(opts), maxJPEGBytes)
+	if err != nil {
+		return nil, err
 	}
-
-	return out, nil
+	if len(fitted) < len(out) && len(out) > maxJPEGBytes {
+		log.Printf("Camera: image of %d bytes exceeded the 8 MB limit, re-encoded to %d bytes", len(out), len(fitted))
+	}
+	return fitted, nil
 }
 
-// simulatedJPEG returns the smallest valid 1×1 white JPEG, used in simulation mode.
-func simulatedJPEG() []byte {
-	return []byte{
-		0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 0x4a, 0x46, 0x49, 0x46, 0x00, 0x01,
-		0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0xff, 0xdb, 0x00, 0x43,
-		0x00, 0x08, 0x06, 0x06, 0x07, 0x06, 0x05, 0x08, 0x07, 0x07, 0x07, 0x09,
-		0x09, 0x08, 0x0a, 0x0c, 0x14, 0x0d, 0x0c, 0x0b, 0x0b, 0x0c, 0x19, 0x12,
-		0x13, 0x0f, 0x14, 0x1d, 0x1a, 0x1f, 0x1e, 0x1d, 0x1a, 0x1c, 0x1c, 0x20,
-		0x24, 0x2e, 0x27, 0x20, 0x22, 0x2c, 0x23, 0x1c, 0x1c, 0x28, 0x37, 0x29,
-		0x2c, 0x30, 0x31, 0x34, 0x34, 0x34, 0x1f, 0x27, 0x39, 0x3d, 0x38, 0x32,
-		0x3c, 0x2e, 0x33, 0x34, 0x32, 0xff, 0xc0, 0x00, 0x0b, 0x08, 0x00, 0x01,
-		0x00, 0x01, 0x01, 0x01, 0x11, 0x00, 0xff, 0xc4, 0x00, 0x1f, 0x00, 0x00,
-		0x01, 0x05, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
-		0x09, 0x0a, 0x0b, 0xff, 0xc4, 0x00, 0xb5, 0x10, 0x00, 0x02, 0x01, 0x03,
-		0x03, 0x02, 0x04, 0x03, 0x05, 0x05, 0x04, 0x04, 0x00, 0x00, 0x01, 0x7d,
-		0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12, 0x21, 0x31, 0x41, 0x06,
-		0x13, 0x51, 0x61, 0x07, 0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
-		0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0, 0x24, 0x33, 0x62, 0x72,
-		0x82, 0x09, 0x0a, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
-		0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3a, 0x43, 0x44, 0x45,
-		0x46, 0x47, 0x48, 0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
-		0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x73, 0x74, 0x75,
-		0x76, 0x77, 0x78, 0x79, 0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
-		0x8a, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4,
-		0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7,
-		0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca,
-		0xd2, 0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2, 0xe3,
-		0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5,
-		0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xff, 0xda, 0x00, 0x08, 0x01, 0x01, 0x00,
-		0x00, 0x3f, 0x00, 0xfb, 0xd2, 0x8a, 0x28, 0x03, 0xff, 0xd9,
+// simulatedFrame returns a gradient test frame at the requested resolution (64×48 by
+// default), used in simulation mode.
+func simulatedFrame(opts Options) []byte {
+	width, height := opts.Width, opts.Height
+	if width == 0 || height == 0 {
+		width, height = 64, 48
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(255 * (x + y) / (width + height))})
+		}
+	}
+	quality := opts.Quality
+	if quality == 0 {
+		quality = defaultQuality
 	}
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	return buf.Bytes()
 }
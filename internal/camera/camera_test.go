@@ -92,10 +92,10 @@ func TestCleanup(t *testing.T) {
 	}
 }
 
-func TestSimulatedJPEGSize(t *testing.T) {
-	data := simulatedJPEG()
+func TestSimulatedFrameSize(t *testing.T) {
+	data := simulatedFrame(Options{})
 	if len(data) == 0 {
-		t.Fatal("simulatedJPEG must not be empty")
+		t.Fatal("simulatedFrame must not be empty")
 	}
 	if len(data) > maxJPEGBytes {
 		t.Errorf("simulated JPEG exceeds max size: %d > %d", len(data), maxJPEGBytes)
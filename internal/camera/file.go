@@ -0,0 +1,89 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileBackend serves test images from a JPEG file or, in name order, from the JPEGs
+// in a directory, so the capture paths can be exercised without a camera.
+type fileBackend struct {
+	path string
+
+	mu   sync.Mutex
+	next int // index of the next image in a directory
+}
+
+func newFileBackend(path string) (*fileBackend, error) {
+	if path == "" {
+		return nil, fmt.Errorf("camera: the file backend needs a file or directory path")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("camera: file backend path unavailable: %w", err)
+	}
+	return &fileBackend{path: path}, nil
+}
+
+func (b *fileBackend) name() string { return "test images from " + b.path }
+
+func (b *fileBackend) capture(ctx context.Context, opts Options) ([]byte, error) {
+	return b.nextImage()
+}
+
+// nextImage returns the file, or the next image of the directory, wrapping around.
+func (b *fileBackend) nextImage() ([]byte, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return nil, fmt.Errorf("camera capture failed: %w", err)
+	}
+	if !info.IsDir() {
+		return os.ReadFile(b.path)
+	}
+
+	var images []string
+	for _, pattern := range []string{"*.jpg", "*.jpeg", "*.JPG", "*.JPEG"} {
+		matches, _ := filepath.Glob(filepath.Join(b.path, pattern))
+		images = append(images, matches...)
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("camera capture failed: no JPEG images in %s", b.path)
+	}
+	sort.Slice(images, func(i, j int) bool { return strings.ToLower(images[i]) < strings.ToLower(images[j]) })
+
+	b.mu.Lock()
+	image := images[b.next%len(images)]
+	b.next = (b.next + 1) % len(images)
+	b.mu.Unlock()
+	return os.ReadFile(image)
+}
+
+// software applies everything except the resolution; test images are served as stored.
+func (b *fileBackend) software(opts Options) Options {
+	return Options{Quality: opts.Quality, Rotation: opts.Rotation, HFlip: opts.HFlip, VFlip: opts.VFlip, Crop: opts.Crop}
+}
+
+// stream repeats the test images at the stream frame rate, without re-orienting them.
+func (b *fileBackend) stream(ctx context.Context, opts StreamOptions, orient Options, frame func([]byte) error) error {
+	ticker := time.NewTicker(time.Second / time.Duration(opts.FPS))
+	defer ticker.Stop()
+	for {
+		image, err := b.nextImage()
+		if err != nil {
+			return err
+		}
+		if err := frame(image); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// libcameraBackend captures with rpicam-still/libcamera-still and streams with
+// rpicam-vid/libcamera-vid (Raspberry Pi camera modules).
+type libcameraBackend struct {
+	still string
+	vid   string // empty when no video tool is installed
+}
+
+// newLibcameraBackend returns nil if no still capture tool is installed.
+func newLibcameraBackend() *libcameraBackend {
+	still := lookTool("rpicam-still", "libcamera-still")
+	if still == "" {
+		return nil
+	}
+	return &libcameraBackend{still: still, vid: lookTool("rpicam-vid", "libcamera-vid")}
+}
+
+func (b *libcameraBackend) name() string { return b.still }
+
+func (b *libcameraBackend) capture(ctx context.Context, opts Options) ([]byte, error) {
+	return runTool(ctx, b.still, opts.args()...)
+}
+
+// software keeps the 90 and 270 degree rotations, which libcamera does not support.
+func (b *libcameraBackend) software(opts Options) Options {
+	remaining := Options{Quality: opts.Quality}
+	if opts.Rotation == 90 || opts.Rotation == 270 {
+		remaining.Rotation = opts.Rotation
+	}
+	return remaining
+}
+
+func (b *libcameraBackend) stream(ctx context.Context, opts StreamOptions, orient Options, frame func([]byte) error) error {
+	if b.vid == "" {
+		return fmt.Errorf("camera: neither rpicam-vid nor libcamera-vid found, preview stream unavailable")
+	}
+	args := []string{"--codec", "mjpeg", "--timeout", "0", "--nopreview", "--output", "-", "--framerate", strconv.Itoa(opts.FPS)}
+	if opts.Width > 0 && opts.Height > 0 {
+		args = append(args, "--width", strconv.Itoa(opts.Width), "--height", strconv.Itoa(opts.Height))
+	}
+	// Keep the preview oriented like the stills so alignment matches.
+	if orient.Rotation == 180 {
+		args = append(args, "--rotation", "180")
+	}
+	if orient.hflip() {
+		args = append(args, "--hflip")
+	}
+	if orient.vflip() {
+		args = append(args, "--vflip")
+	}
+	return streamTool(ctx, frame, b.vid, args...)
+}
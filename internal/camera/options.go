@@ -0,0 +1,314 @@
+package camera
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits for capture options. The resolution limit is the Camera Module 3 sensor size.
+const (
+	maxWidth             = 4608
+	maxHeight            = 2592
+	maxBurstCount        = 10
+	maxBurstInterval     = 10 * time.Second
+	maxShutter           = 10 * time.Second
+	defaultQuality       = 90
+	minStepDownQuality   = 30
+	stepDownQualityDelta = 15
+)
+
+// Crop is a region of the sensor in fractions (0-1) of its width and height.
+type Crop struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Options controls a capture. Zero fields use the camera defaults; an unset flip uses
+// the default flip, while an explicit false turns it off.
+type Options struct {
+	Width           int     `json:"width,omitempty"`             // pixels, 0 = sensor default
+	Height          int     `json:"height,omitempty"`            // pixels, 0 = sensor default
+	Quality         int     `json:"quality,omitempty"`           // JPEG quality 1-100
+	Rotation        int     `json:"rotation,omitempty"`          // 0, 90, 180 or 270 degrees clockwise
+	HFlip           *bool   `json:"hflip,omitempty"`             // mirror horizontally
+	VFlip           *bool   `json:"vflip,omitempty"`             // mirror vertically
+	Crop            *Crop   `json:"crop,omitempty"`              // sensor region, applied before scaling
+	ShutterUs       int     `json:"shutter_us,omitempty"`        // fixed exposure time in microseconds
+	EV              float64 `json:"ev,omitempty"`                // exposure compensation in stops (-10 to 10)
+	BurstCount      int     `json:"burst_count,omitempty"`       // number of images, 1-10
+	BurstIntervalMs int     `json:"burst_interval_ms,omitempty"` // pause between burst images
+}
+
+// ParseCrop parses "x,y,w,h" in fractions of the sensor size. An empty string means no crop.
+func ParseCrop(s string) (*Crop, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("camera: crop must be \"x,y,w,h\", got %q", s)
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("camera: invalid crop value %q in %q", part, s)
+		}
+		v[i] = f
+	}
+	crop := &Crop{X: v[0], Y: v[1], W: v[2], H: v[3]}
+	return crop, crop.validate()
+}
+
+func (c *Crop) validate() error {
+	if c.X < 0 || c.Y < 0 || c.W <= 0 || c.H <= 0 || c.X+c.W > 1 || c.Y+c.H > 1 {
+		return fmt.Errorf("camera: crop %.3f,%.3f,%.3f,%.3f must lie within 0-1", c.X, c.Y, c.W, c.H)
+	}
+	return nil
+}
+
+// Validate checks the options against the camera limits.
+func (o Options) Validate() error {
+	if o.Width < 0 || o.Width > maxWidth || o.Height < 0 || o.Height > maxHeight {
+		return fmt.Errorf("camera: resolution %dx%d exceeds %dx%d", o.Width, o.Height, maxWidth, maxHeight)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("camera: quality must be between 1 and 100 (0 uses the default), got %d", o.Quality)
+	}
+	switch o.Rotation {
+	case 0, 90, 180, 270:
+	default:
+		return fmt.Errorf("camera: rotation must be 0, 90, 180 or 270, got %d", o.Rotation)
+	}
+	if o.Crop != nil {
+		if err := o.Crop.validate(); err != nil {
+			return err
+		}
+	}
+	if o.ShutterUs < 0 || time.Duration(o.ShutterUs)*time.Microsecond > maxShutter {
+		return fmt.Errorf("camera: shutter_us must be between 0 and %d, got %d", maxShutter.Microseconds(), o.ShutterUs)
+	}
+	if o.EV < -10 || o.EV > 10 {
+		return fmt.Errorf("camera: ev must be between -10 and 10, got %v", o.EV)
+	}
+	if o.BurstCount < 0 || o.BurstCount > maxBurstCount {
+		return fmt.Errorf("camera: burst_count must be between 1 and %d (0 uses the default), got %d", maxBurstCount, o.BurstCount)
+	}
+	if o.BurstIntervalMs < 0 || time.Duration(o.BurstIntervalMs)*time.Millisecond > maxBurstInterval {
+		return fmt.Errorf("camera: burst_interval_ms must be between 0 and %d, got %d", maxBurstInterval.Milliseconds(), o.BurstIntervalMs)
+	}
+	return nil
+}
+
+// Merge returns o with its zero and unset fields taken from defaults.
+func (o Options) Merge(defaults Options) Options {
+	if o.Width == 0 && o.Height == 0 {
+		o.Width, o.Height = defaults.Width, defaults.Height
+	}
+	if o.Quality == 0 {
+		o.Quality = defaults.Quality
+	}
+	if o.Rotation == 0 {
+		o.Rotation = defaults.Rotation
+	}
+	if o.HFlip == nil {
+		o.HFlip = defaults.HFlip
+	}
+	if o.VFlip == nil {
+		o.VFlip = defaults.VFlip
+	}
+	if o.Crop == nil {
+		o.Crop = defaults.Crop
+	}
+	if o.ShutterUs == 0 {
+		o.ShutterUs = defaults.ShutterUs
+	}
+	if o.EV == 0 {
+		o.EV = defaults.EV
+	}
+	if o.BurstCount == 0 {
+		o.BurstCount = defaults.BurstCount
+	}
+	if o.BurstIntervalMs == 0 {
+		o.BurstIntervalMs = defaults.BurstIntervalMs
+	}
+	return o
+}
+
+// Flag returns a pointer to v for the flip options.
+func Flag(v bool) *bool { return &v }
+
+func (o Options) hflip() bool { return o.HFlip != nil && *o.HFlip }
+
+func (o Options) vflip() bool { return o.VFlip != nil && *o.VFlip }
+
+// args returns the rpicam-still arguments for one image. Rotations of 90 and 270
+// degrees are not supported by libcamera and are applied after the capture.
+func (o Options) args() []string {
+	// --timeout 5000: camera warm-up / capture window in ms
+	// --nopreview:    skip display output
+	// --output -:     write JPEG to stdout
+	args := []string{"--output", "-", "--timeout", "5000", "--nopreview"}
+	if o.Width > 0 {
+		args = append(args, "--width", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		args = append(args, "--height", strconv.Itoa(o.Height))
+	}
+	if o.Quality > 0 {
+		args = append(args, "--quality", strconv.Itoa(o.Quality))
+	}
+	if o.Rotation == 180 {
+		args = append(args, "--rotation", "180")
+	}
+	if o.hflip() {
+		args = append(args, "--hflip")
+	}
+	if o.vflip() {
+		args = append(args, "--vflip")
+	}
+	if o.Crop != nil {
+		args = append(args, "--roi", fmt.Sprintf("%g,%g,%g,%g", o.Crop.X, o.Crop.Y, o.Crop.W, o.Crop.H))
+	}
+	if o.ShutterUs > 0 {
+		args = append(args, "--shutter", strconv.Itoa(o.ShutterUs))
+	}
+	if o.EV != 0 {
+		args = append(args, "--ev", strconv.FormatFloat(o.EV, 'f', -1, 64))
+	}
+	return args
+}
+
+// postProcess applies the crop, flips and rotation in o, which the backend could not
+// apply itself, and steps the JPEG quality down until the image fits into limit bytes.
+func postProcess(data []byte, o Options, limit int) ([]byte, error) {
+	transform := o.Crop != nil || o.hflip() || o.vflip() || o.Rotation != 0
+	if !transform && len(data) <= limit {
+		return data, nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("camera: failed to decode image: %w", err)
+	}
+	if o.Crop != nil {
+		img = crop(img, *o.Crop)
+	}
+	if o.hflip() || o.vflip() {
+		img = flip(img, o.hflip(), o.vflip())
+	}
+	if o.Rotation != 0 {
+		img = rotate(img, o.Rotation)
+	}
+
+	quality := o.Quality
+	if quality == 0 {
+		quality = defaultQuality
+	}
+	for {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("camera: failed to encode image: %w", err)
+		}
+		if buf.Len() <= limit {
+			return buf.Bytes(), nil
+		}
+		if quality > minStepDownQuality {
+			quality = max(quality-stepDownQualityDelta, minStepDownQuality)
+			continue
+		}
+		// Still too large at the lowest quality: halve the resolution.
+		bounds := img.Bounds()
+		if bounds.Dx() < 2 || bounds.Dy() < 2 {
+			return nil, fmt.Errorf("captured image exceeds maximum allowed size of %d bytes (%d bytes)", limit, buf.Len())
+		}
+		img = halve(img)
+	}
+}
+
+// halve scales img down to half its width and height by averaging 2x2 blocks.
+func halve(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()/2, b.Dy()/2))
+	for y := 0; y < b.Dy()/2; y++ {
+		for x := 0; x < b.Dx()/2; x++ {
+			var r, g, bl uint32
+			for _, p := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb, _ := img.At(b.Min.X+2*x+p[0], b.Min.Y+2*y+p[1]).RGBA()
+				r, g, bl = r+pr, g+pg, bl+pb
+			}
+			out.Set(x, y, color.RGBA64{R: uint16(r / 4), G: uint16(g / 4), B: uint16(bl / 4), A: 0xffff})
+		}
+	}
+	return out
+}
+
+// rotate turns img clockwise by 90, 180 or 270 degrees.
+func rotate(img image.Image, degrees int) image.Image {
+	if degrees == 180 {
+		return flip(img, true, true)
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			px, py := x-b.Min.X, y-b.Min.Y
+			if degrees == 90 {
+				out.Set(b.Dy()-1-py, px, img.At(x, y))
+			} else {
+				out.Set(py, b.Dx()-1-px, img.At(x, y))
+			}
+		}
+	}
+	return out
+}
+
+// flip mirrors img horizontally and/or vertically.
+func flip(img image.Image, horizontal, vertical bool) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			sx, sy := x, y
+			if horizontal {
+				sx = b.Dx() - 1 - x
+			}
+			if vertical {
+				sy = b.Dy() - 1 - y
+			}
+			out.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return out
+}
+
+// crop cuts the region r, in fractions of the image size, out of img.
+func crop(img image.Image, r Crop) image.Image {
+	b := img.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(r.X*float64(b.Dx())),
+		b.Min.Y+int(r.Y*float64(b.Dy())),
+		b.Min.X+int((r.X+r.W)*float64(b.Dx())),
+		b.Min.Y+int((r.Y+r.H)*float64(b.Dy())),
+	)
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			out.Set(x, y, img.At(rect.Min.X+x, rect.Min.Y+y))
+		}
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package camera
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// noisyJPEG returns a w×h JPEG of random pixels, which compresses badly.
+func noisyJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOptionsArgs(t *testing.T) {
+	opts := Options{
+		Width: 1920, Height: 1080, Quality: 80, Rotation: 180, HFlip: Flag(true),
+		Crop: &Crop{X: 0.25, Y: 0.25, W: 0.5, H: 0.5}, ShutterUs: 20000, EV: -0.5,
+	}
+	got := strings.Join(opts.args(), " ")
+	want := "--output - --timeout 5000 --nopreview --width 1920 --height 1080 --quality 80 --rotation 180 --hflip --roi 0.25,0.25,0.5,0.5 --shutter 20000 --ev -0.5"
+	if got != want {
+		t.Fatalf("unexpected args:\n got %s\nwant %s", got, want)
+	}
+	if args := (Options{Rotation: 90}).args(); slices.Contains(args, "--rotation") {
+		t.Fatalf("90° rotation must be applied after capture, got %v", args)
+	}
+}
+
+func TestOptionsValidateAndMerge(t *testing.T) {
+	for _, bad := range []Options{
+		{Width: 5000},
+		{Quality: 101},
+		{Rotation: 45},
+		{Crop: &Crop{X: 0.5, Y: 0, W: 0.6, H: 1}},
+		{ShutterUs: 20_000_000},
+		{EV: 11},
+		{BurstCount: 11},
+		{BurstIntervalMs: 20000},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("expected validation error for %+v", bad)
+		}
+	}
+
+	defaults := Options{Width: 1280, Height: 720, Quality: 85, VFlip: Flag(true), BurstCount: 1}
+	merged := Options{Quality: 60, HFlip: Flag(true)}.Merge(defaults)
+	if merged.Width != 1280 || merged.Height != 720 || merged.Quality != 60 || !merged.hflip() || !merged.vflip() || merged.BurstCount != 1 {
+		t.Fatalf("unexpected merged options %+v", merged)
+	}
+	// An explicit false turns a configured flip off.
+	if merged := (Options{VFlip: Flag(false)}).Merge(defaults); merged.vflip() {
+		t.Fatalf("expected vflip=false to override the default, got %+v", merged)
+	}
+
+	if crop, err := ParseCrop("0.1, 0.2, 0.5, 0.5"); err != nil || *crop != (Crop{X: 0.1, Y: 0.2, W: 0.5, H: 0.5}) {
+		t.Fatalf("unexpected crop %+v (err=%v)", crop, err)
+	}
+	if _, err := ParseCrop("0.8,0,0.5,1"); err == nil {
+		t.Fatal("expected error for a crop outside the sensor")
+	}
+}
+
+// ensure oversized images are stepped down in quality, then in size, instead of failing
+func TestPostProcessStepsDownToLimit(t *testing.T) {
+	data := noisyJPEG(t, 256, 192)
+	limit := len(data) / 8
+
+	fitted, err := postProcess(data, Options{}, limit)
+	if err != nil {
+		t.Fatalf("postProcess returned error: %v", err)
+	}
+	if len(fitted) > limit {
+		t.Fatalf("expected image under %d bytes, got %d", limit, len(fitted))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(fitted)); err != nil {
+		t.Fatalf("fitted image is not a valid JPEG: %v", err)
+	}
+
+	if same, _ := postProcess(data, Options{}, len(data)); !bytes.Equal(same, data) {
+		t.Fatal("expected images within the limit to be returned unchanged")
+	}
+}
+
+func TestPostProcessRotates(t *testing.T) {
+	data := noisyJPEG(t, 40, 20)
+	for _, rotation := range []int{90, 270} {
+		rotated, err := postProcess(data, Options{Rotation: rotation}, maxJPEGBytes)
+		if err != nil {
+			t.Fatalf("postProcess returned error: %v", err)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(rotated))
+		if err != nil || cfg.Width != 20 || cfg.Height != 40 {
+			t.Fatalf("expected a 20x40 image after rotating %d°, got %dx%d (err=%v)", rotation, cfg.Width, cfg.Height, err)
+		}
+	}
+}
+
+func TestCaptureWithOptionsBurst(t *testing.T) {
+	c = &cam{sim: true}
+	defer func() { c = nil }()
+
+	images, err := CaptureWithOptions(Options{BurstCount: 3, BurstIntervalMs: 1})
+	if err != nil {
+		t.Fatalf("CaptureWithOptions returned error: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("expected 3 burst images, got %d", len(images))
+	}
+	if _, err := CaptureWithOptions(Options{Rotation: 45}); err == nil {
+		t.Fatal("expected invalid options to be rejected")
+	}
+}
@@ -0,0 +1,122 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrStreamBusy is returned by Stream while another preview stream is running.
+var ErrStreamBusy = errors.New("camera: a preview stream is already running")
+
+// ErrStreamPreempted ends a preview stream when a capture needs the camera.
+var ErrStreamPreempted = errors.New("camera: preview stream stopped for a capture")
+
+// StreamOptions controls the preview stream.
+type StreamOptions struct {
+	Width  int
+	Height int
+	FPS    int
+}
+
+// Stream sends preview frames to frame until ctx is done, frame returns an error or a
+// capture preempts the stream. It uses the MJPEG output of the camera backend, or
+// repeated test frames in simulation mode. Only one stream runs at a time; a capture
+// stops it instead of waiting for the viewer to disconnect.
+func Stream(ctx context.Context, opts StreamOptions, frame func([]byte) error) error {
+	cam := c
+	if cam == nil {
+		return fmt.Errorf("camera not initialised or disabled")
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = 10
+	}
+
+	cam.streamMu.Lock()
+	if cam.streamCancel != nil {
+		cam.streamMu.Unlock()
+		return ErrStreamBusy
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	cam.streamCancel = cancel
+	cam.streamMu.Unlock()
+	defer func() {
+		cam.streamMu.Lock()
+		cam.streamCancel = nil
+		cam.streamMu.Unlock()
+		cancel(nil)
+	}()
+
+	cam.mu.Lock()
+	defer cam.mu.Unlock()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	var err error
+	if cam.sim {
+		err = cam.streamSimulated(ctx, opts, frame)
+	} else {
+		err = cam.backend.stream(ctx, opts, cam.defaults, frame)
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// preemptStream stops a running preview stream so a capture can use the camera.
+func (c *cam) preemptStream() {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+	if c.streamCancel != nil {
+		log.Println("Camera: stopping preview stream for a capture")
+		c.streamCancel(ErrStreamPreempted)
+	}
+}
+
+func (c *cam) streamSimulated(ctx context.Context, opts StreamOptions, frame func([]byte) error) error {
+	ticker := time.NewTicker(time.Second / time.Duration(opts.FPS))
+	defer ticker.Stop()
+	still := simulatedFrame(Options{Width: opts.Width, Height: opts.Height})
+	for {
+		if err := frame(still); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+var (
+	jpegSOI = []byte{0xff, 0xd8}
+	jpegEOI = []byte{0xff, 0xd9}
+)
+
+// splitJPEG is a bufio.SplitFunc that yields the JPEG frames of an MJPEG byte stream,
+// skipping anything between frames.
+func splitJPEG(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := bytes.Index(data, jpegSOI)
+	if start < 0 {
+		if atEOF {
+			return len(data), nil, nil
+		}
+		// Keep a trailing 0xff that may start the next SOI.
+		return max(len(data)-1, 0), nil, nil
+	}
+	end := bytes.Index(data[start+len(jpegSOI):], jpegEOI)
+	if end < 0 {
+		if atEOF {
+			return len(data), nil, nil
+		}
+		return start, nil, nil
+	}
+	end += start + len(jpegSOI) + len(jpegEOI)
+	return end, data[start:end], nil
+}
@@ -0,0 +1,75 @@
+package camera
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSplitJPEGYieldsFrames(t *testing.T) {
+	frameA := []byte{0xff, 0xd8, 0x01, 0x02, 0xff, 0xd9}
+	frameB := []byte{0xff, 0xd8, 0x03, 0xff, 0xd9}
+	stream := append(append(append([]byte{0x00, 0x11}, frameA...), 0x22), frameB...)
+
+	// A tiny reader buffer forces frames to be split across reads.
+	scanner := bufio.NewScanner(bufio.NewReaderSize(bytes.NewReader(stream), 16))
+	scanner.Buffer(make([]byte, 0, 2), 1024)
+	scanner.Split(splitJPEG)
+
+	var frames [][]byte
+	for scanner.Scan() {
+		frames = append(frames, append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scanner error: %v", err)
+	}
+	if len(frames) != 2 || !bytes.Equal(frames[0], frameA) || !bytes.Equal(frames[1], frameB) {
+		t.Fatalf("unexpected frames %x", frames)
+	}
+}
+
+// ensure only one stream runs and a capture stops it instead of waiting
+func TestStreamSingleViewerAndPreemptedByCapture(t *testing.T) {
+	c = &cam{sim: true}
+	defer func() { c = nil }()
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		var once bool
+		done <- Stream(context.Background(), StreamOptions{FPS: 50}, func([]byte) error {
+			if !once {
+				once = true
+				close(started)
+			}
+			return nil
+		})
+	}()
+	<-started
+
+	if err := Stream(context.Background(), StreamOptions{}, func([]byte) error { return nil }); !errors.Is(err, ErrStreamBusy) {
+		t.Fatalf("expected ErrStreamBusy for a second viewer, got %v", err)
+	}
+
+	if _, err := Capture(); err != nil {
+		t.Fatalf("Capture returned error: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrStreamPreempted) {
+			t.Fatalf("expected ErrStreamPreempted, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream was not stopped by the capture")
+	}
+
+	// The camera is free for a new viewer afterwards.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Stream(ctx, StreamOptions{FPS: 50}, func([]byte) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the new stream to end with its context, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultV4L2Device is used when no device is configured.
+const defaultV4L2Device = "/dev/video0"
+
+// fswebcamSkipFrames are discarded before a fswebcam capture so auto exposure can settle.
+const fswebcamSkipFrames = 5
+
+// v4l2Backend captures from a V4L2 device such as a USB webcam, using ffmpeg or, as a
+// fallback, fswebcam. Orientation and crop are applied in software; shutter and EV
+// are left to the webcam's own auto exposure.
+type v4l2Backend struct {
+	device string
+	tool   string // path to ffmpeg or fswebcam
+}
+
+// newV4L2Backend returns nil if neither ffmpeg nor fswebcam is installed.
+func newV4L2Backend(device string) *v4l2Backend {
+	if device == "" {
+		device = defaultV4L2Device
+	}
+	tool := lookTool("ffmpeg", "fswebcam")
+	if tool == "" {
+		return nil
+	}
+	return &v4l2Backend{device: device, tool: tool}
+}
+
+func (b *v4l2Backend) name() string { return fmt.Sprintf("%s on %s", b.tool, b.device) }
+
+func (b *v4l2Backend) ffmpeg() bool {
+	return strings.HasPrefix(filepath.Base(b.tool), "ffmpeg")
+}
+
+func (b *v4l2Backend) capture(ctx context.Context, opts Options) ([]byte, error) {
+	return runTool(ctx, b.tool, b.captureArgs(opts)...)
+}
+
+func (b *v4l2Backend) captureArgs(opts Options) []string {
+	quality := opts.Quality
+	if quality == 0 {
+		quality = defaultQuality
+	}
+	if !b.ffmpeg() {
+		args := []string{"--quiet", "--no-banner", "--device", b.device, "--skip", strconv.Itoa(fswebcamSkipFrames)}
+		if opts.Width > 0 && opts.Height > 0 {
+			args = append(args, "--resolution", fmt.Sprintf("%dx%d", opts.Width, opts.Height))
+		}
+		return append(args, "--jpeg", strconv.Itoa(quality), "-")
+	}
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", "v4l2"}
+	if opts.Width > 0 && opts.Height > 0 {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", opts.Width, opts.Height))
+	}
+	return append(args, "-i", b.device, "-frames:v", "1", "-q:v", strconv.Itoa(ffmpegQScale(quality)), "-f", "image2pipe", "-c:v", "mjpeg", "-")
+}
+
+// software applies everything except the resolution, which the device delivers.
+func (b *v4l2Backend) software(opts Options) Options {
+	return Options{Quality: opts.Quality, Rotation: opts.Rotation, HFlip: opts.HFlip, VFlip: opts.VFlip, Crop: opts.Crop}
+}
+
+func (b *v4l2Backend) stream(ctx context.Context, opts StreamOptions, orient Options, frame func([]byte) error) error {
+	if !b.ffmpeg() {
+		return fmt.Errorf("camera: preview stream needs ffmpeg, fswebcam cannot stream")
+	}
+	return streamTool(ctx, frame, b.tool, b.streamArgs(opts, orient)...)
+}
+
+func (b *v4l2Backend) streamArgs(opts StreamOptions, orient Options) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", "v4l2", "-framerate", strconv.Itoa(opts.FPS)}
+	if opts.Width > 0 && opts.Height > 0 {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", opts.Width, opts.Height))
+	}
+	args = append(args, "-i", b.device)
+	if filters := ffmpegOrientFilters(orient); filters != "" {
+		args = append(args, "-vf", filters)
+	}
+	return append(args, "-f", "mjpeg", "-q:v", "5", "-")
+}
+
+// ffmpegQScale maps a JPEG quality of 1-100 to ffmpeg's -q:v scale of 31 (worst) to 2 (best).
+func ffmpegQScale(quality int) int {
+	return 2 + (100-quality)*29/100
+}
+
+// ffmpegOrientFilters returns the ffmpeg filter chain applying the crop, flips and
+// rotation of orient in the same order as postProcess.
+func ffmpegOrientFilters(orient Options) string {
+	var filters []string
+	if crop := orient.Crop; crop != nil {
+		filters = append(filters, fmt.Sprintf("crop=iw*%g:ih*%g:iw*%g:ih*%g", crop.W, crop.H, crop.X, crop.Y))
+	}
+	if orient.hflip() {
+		filters = append(filters, "hflip")
+	}
+	if orient.vflip() {
+		filters = append(filters, "vflip")
+	}
+	switch orient.Rotation {
+	case 90:
+		filters = append(filters, "transpose=clock")
+	case 180:
+		filters = append(filters, "hflip", "vflip")
+	case 270:
+		filters = append(filters, "transpose=cclock")
+	}
+	return strings.Join(filters, ",")
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/jsalamander/baendaeli-client/internal/config"
 	"periph.io/x/conn/v3/i2c"
@@ -38,6 +39,14 @@ type Sensor struct {
 	dev      reader
 	bus      i2c.BusCloser
 	simCount atomic.Uint64
+	last     atomic.Pointer[Sample]
+}
+
+// Sample is the outcome of one Read.
+type Sample struct {
+	C, R, G, B uint16
+	Err        error
+	At         time.Time
 }
 
 // New creates a Sensor from config. Call Init() to open hardware.
@@ -125,15 +134,35 @@ func (s *Sensor) Init(cfg *config.Config) error {
 	return nil
 }
 
-// Read returns the raw 16-bit C (clear), R, G, B values from the sensor.
+// Read returns the raw 16-bit C (clear), R, G, B values from the sensor and keeps the
+// outcome for LastSample.
 func (s *Sensor) Read() (c, r, g, b uint16, err error) {
 	if !s.enabled {
 		return 0, 0, 0, 0, nil
 	}
+	c, r, g, b, err = s.read()
+	s.last.Store(&Sample{C: c, R: r, G: g, B: b, Err: err, At: time.Now()})
+	return c, r, g, b, err
+}
+
+// LastSample returns the outcome of the last Read, so status pages can show the sensor
+// without an extra I2C transaction. ok is false before the first Read.
+func (s *Sensor) LastSample() (sample Sample, ok bool) {
+	last := s.last.Load()
+	if last == nil {
+		return Sample{}, false
+	}
+	return *last, true
+}
+
+func (s *Sensor) read() (c, r, g, b uint16, err error) {
 	if s.sim {
 		v := s.simCount.Add(1)
 		return uint16(v % 200), 0, 0, 0, nil
 	}
+	if s.dev == nil {
+		return 0, 0, 0, 0, fmt.Errorf("color sensor not initialised")
+	}
 	buf := make([]byte, 8)
 	if err = s.dev.Tx([]byte{cmdBit | regCDATAL}, buf); err != nil {
 		return 0, 0, 0, 0, fmt.Errorf("color sensor read failed: %w", err)
@@ -1,6 +1,7 @@
 package colorsensor
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -58,6 +59,26 @@ func TestSimModeIncrements(t *testing.T) {
 	}
 }
 
+func TestLastSampleKeepsReadOutcome(t *testing.T) {
+	fake := &fakeReader{data: []byte{0x02, 0x01, 0x04, 0x03, 0x06, 0x05, 0x08, 0x07}}
+	s := &Sensor{enabled: true, dev: fake}
+	if _, ok := s.LastSample(); ok {
+		t.Fatal("expected no sample before the first read")
+	}
+
+	s.Read()
+	sample, ok := s.LastSample()
+	if !ok || sample.C != 0x0102 || sample.B != 0x0708 || sample.Err != nil || sample.At.IsZero() {
+		t.Fatalf("unexpected sample %+v", sample)
+	}
+
+	fake.err = errors.New("bus error")
+	s.Read()
+	if sample, _ := s.LastSample(); sample.Err == nil {
+		t.Fatal("expected the failed read to be kept")
+	}
+}
+
 func TestParseAddr(t *testing.T) {
 	cases := []struct {
 		in   string
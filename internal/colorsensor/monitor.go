@@ -1,12 +1,14 @@
 package colorsensor
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
-	"math"
 	"time"
 
 	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/jamclear"
 )
 
 // ErrNoBallDetected is returned when no ball drop is detected after all attempts are exhausted.
@@ -17,6 +19,14 @@ type vibratorBuzzer interface {
 	Buzz(intensity float64, duration time.Duration) error
 }
 
+// jamClearer is implemented by jam-clearing sessions (see internal/jamclear) that pick
+// the pattern to run after a failed attempt instead of the default escalation, and
+// learn from whether the following window detects the ball.
+type jamClearer interface {
+	ClearJam(attempt int) error
+	JamResult(cleared bool)
+}
+
 // AttemptObserver is called at the beginning of each detection attempt.
 // attempt is 1-based and maxAttempts is the configured total.
 type AttemptObserver func(attempt int, maxAttempts int)
@@ -24,6 +34,7 @@ type AttemptObserver func(attempt int, maxAttempts int)
 type detectOptions struct {
 	referenceBaseline *uint16
 	detectMode        detectMode
+	ctx               context.Context
 }
 
 type detectMode int
@@ -66,6 +77,18 @@ func WaitForBallWithPresenceReferenceBaseline(s *Sensor, vib vibratorBuzzer, cfg
 	return waitForBallWithOptions(s, vib, cfg, logger, observer, detectOptions{referenceBaseline: &referenceBaseline, detectMode: detectModePresenceReference})
 }
 
+// WaitForBallContext is WaitForBall, or WaitForBallWithReferenceBaseline when
+// referenceBaseline is set, that gives up before the next attempt once ctx is done.
+// It then returns an error wrapping the context error.
+func WaitForBallContext(ctx context.Context, s *Sensor, vib vibratorBuzzer, cfg *config.Config, logger *log.Logger, observer AttemptObserver, referenceBaseline *uint16) error {
+	opts := detectOptions{ctx: ctx}
+	if referenceBaseline != nil {
+		opts.referenceBaseline = referenceBaseline
+		opts.detectMode = detectModeHybridReference
+	}
+	return waitForBallWithOptions(s, vib, cfg, logger, observer, opts)
+}
+
 func waitForBallWithOptions(s *Sensor, vib vibratorBuzzer, cfg *config.Config, logger *log.Logger, observer AttemptObserver, opts detectOptions) error {
 	if !s.IsEnabled() {
 		logger.Println("Color sensor disabled, skipping ball detection")
@@ -95,10 +118,20 @@ func waitForBallWithOptions(s *Sensor, vib vibratorBuzzer, cfg *config.Config, l
 		referenceResampleAfterAttempts = 2
 	}
 
+	clearer, _ := vib.(jamClearer)
+	reportJam := func(cleared bool) {
+		if clearer != nil {
+			clearer.JamResult(cleared)
+		}
+	}
+
 	activeReference := opts.referenceBaseline
 	failedReferenceAttempts := 0
 	forceMovementOnly := false
 	for attempt := 1; attempt <= cfg.ColorSensorMaxAttempts; attempt++ {
+		if opts.ctx != nil && opts.ctx.Err() != nil {
+			return fmt.Errorf("ball detection aborted before attempt %d: %w", attempt, opts.ctx.Err())
+		}
 		if observer != nil {
 			observer(attempt, cfg.ColorSensorMaxAttempts)
 		}
@@ -120,6 +153,7 @@ func waitForBallWithOptions(s *Sensor, vib vibratorBuzzer, cfg *config.Config, l
 				switch pollForClearBandPresence(s, cfg.ColorSensorClearJamMax, cfg.ColorSensorClearBallMin, stableSamples, clearBandWindow, pollInterval, cfg.ColorSensorDebugLogging, logger) {
 				case clearBandBallPresent:
 					logger.Printf("Color sensor: ball detected on attempt %d by clear-band precheck", attempt)
+					reportJam(true)
 					return nil
 				case clearBandJamConfirmed:
 					// The clear-band classifier is certain no ball is present; do not let
@@ -164,6 +198,7 @@ func waitForBallWithOptions(s *Sensor, vib vibratorBuzzer, cfg *config.Config, l
 		if !skipMovementFallback {
 			if detected := pollForMovement(s, baselineValue, referenceForAttempt, attemptMode, cfg.ColorSensorMovementThreshold, cfg.ColorSensorPresenceTolerance, cfg.ColorSensorHybridCGuardMargin, stableSamples, checkDuration, pollInterval, cfg.ColorSensorDebugLogging, logger); detected {
 				logger.Printf("Color sensor: ball detected on attempt %d", attempt)
+				reportJam(true)
 				return nil
 			}
 		}
@@ -197,23 +232,26 @@ func waitForBallWithOptions(s *Sensor, vib vibratorBuzzer, cfg *config.Config, l
 			}
 		}
 
-		bursts := vibrationBurstsForAttempt(cfg.ColorSensorVibrateBursts, attempt)
-		logger.Printf("Color sensor: no ball detected in window (attempt %d/%d), vibrating %d bursts", attempt, cfg.ColorSensorMaxAttempts, bursts)
+		reportJam(false)
+		if clearer != nil {
+			logger.Printf("Color sensor: no ball detected in window (attempt %d/%d), clearing jam", attempt, cfg.ColorSensorMaxAttempts)
+			if err := clearer.ClearJam(attempt); err != nil {
+				logger.Printf("Color sensor: jam clearing failed: %v", err)
+			}
+			continue
+		}
+
+		steps := jamclear.Escalating.Steps(jamclear.ParamsFromConfig(cfg), attempt)
+		logger.Printf("Color sensor: no ball detected in window (attempt %d/%d), vibrating %d bursts", attempt, cfg.ColorSensorMaxAttempts, len(steps))
 		if vib != nil {
-			for burst := 0; burst < bursts; burst++ {
-				intensity := scaledVibrationIntensity(cfg.ColorSensorVibrateIntensity, attempt, burst)
-				duration := scaledVibrationDuration(time.Duration(cfg.ColorSensorVibrateDurationMs)*time.Millisecond, attempt, burst)
-				pauseBetweenBursts := scaledVibrationPause(attempt)
-				if err := vib.Buzz(intensity, duration); err != nil {
-					logger.Printf("Color sensor: vibration burst %d failed: %v", burst+1, err)
-				} else if cfg.ColorSensorDebugLogging {
-					logger.Printf("Color sensor: vibration burst %d intensity=%.2f duration_ms=%d pause_ms=%d", burst+1, intensity, duration.Milliseconds(), pauseBetweenBursts.Milliseconds())
-				}
-				time.Sleep(pauseBetweenBursts)
+			if err := jamclear.Execute(vib, steps, logger, cfg.ColorSensorDebugLogging); err != nil {
+				logger.Printf("Color sensor: vibration failed: %v", err)
 			}
 		}
 	}
 
+	// The last pattern ran without a ball showing up in this cycle.
+	reportJam(false)
 	return ErrNoBallDetected
 }
 
@@ -224,44 +262,6 @@ func absInt(v int) int {
 	return v
 }
 
-func vibrationBurstsForAttempt(base int, attempt int) int {
-	if base <= 0 {
-		return 0
-	}
-	bursts := base + (attempt-1)/2
-	if bursts > 5 {
-		return 5
-	}
-	return bursts
-}
-
-func scaledVibrationIntensity(base float64, attempt int, burst int) float64 {
-	if base <= 0 {
-		return 0
-	}
-	return math.Min(1.0, base+0.08*float64(attempt-1)+0.03*float64(burst))
-}
-
-func scaledVibrationDuration(base time.Duration, attempt int, burst int) time.Duration {
-	if base <= 0 {
-		base = 100 * time.Millisecond
-	}
-	duration := base + time.Duration(90*(attempt-1)+40*burst)*time.Millisecond
-	maxDuration := 700 * time.Millisecond
-	if duration > maxDuration {
-		return maxDuration
-	}
-	return duration
-}
-
-func scaledVibrationPause(attempt int) time.Duration {
-	pause := 350 + 20*(attempt-1)
-	if pause > 500 {
-		pause = 500
-	}
-	return time.Duration(pause) * time.Millisecond
-}
-
 // SampleBaseline returns the average clear-channel reading over 3 samples.
 func SampleBaseline(s *Sensor, logger *log.Logger) (uint16, error) {
 	return baseline(s, logger)
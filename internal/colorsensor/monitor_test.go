@@ -2,6 +2,9 @@ package colorsensor
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math"
@@ -485,3 +488,61 @@ func TestWaitForBallWithReferenceBaselineHybridCGuardPreventsLowCFalsePositive(t
 		t.Fatalf("expected ErrNoBallDetected when below C guard floor, got %v", err)
 	}
 }
+
+type stubJamClearer struct {
+	stubBuzzer
+	calls []string
+}
+
+func (c *stubJamClearer) ClearJam(attempt int) error {
+	c.calls = append(c.calls, fmt.Sprintf("clear%d", attempt))
+	return nil
+}
+
+func (c *stubJamClearer) JamResult(cleared bool) {
+	c.calls = append(c.calls, fmt.Sprintf("result=%t", cleared))
+}
+
+func TestWaitForBallDelegatesToJamClearer(t *testing.T) {
+	s := &Sensor{enabled: true, sim: true}
+	c := &stubJamClearer{}
+	cfg := &config.Config{
+		ColorSensorEnabled:           true,
+		ColorSensorMovementThreshold: 10000,
+		ColorSensorCheckDurationMs:   1,
+		ColorSensorVibrateBursts:     1,
+		ColorSensorMaxAttempts:       2,
+	}
+
+	if err := WaitForBall(s, c, cfg, silentLogger(), nil); err != ErrNoBallDetected {
+		t.Fatalf("expected ErrNoBallDetected, got %v", err)
+	}
+	if c.count != 0 {
+		t.Fatalf("expected no direct bursts when a jam clearer is used, got %d", c.count)
+	}
+	want := "result=false clear1 result=false clear2 result=false"
+	if got := strings.Join(c.calls, " "); got != want {
+		t.Fatalf("unexpected jam clearer calls %q, want %q", got, want)
+	}
+}
+
+func TestWaitForBallContextStopsBeforeNextAttempt(t *testing.T) {
+	s := &Sensor{enabled: true, sim: true}
+	cfg := &config.Config{
+		ColorSensorEnabled:           true,
+		ColorSensorMovementThreshold: 1,
+		ColorSensorCheckDurationMs:   400,
+		ColorSensorMaxAttempts:       3,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := WaitForBallContext(ctx, s, nil, cfg, silentLogger(), func(int, int) { attempts++ }, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled detection, got %v", err)
+	}
+	if attempts != 0 {
+		t.Fatalf("expected no attempt after cancellation, got %d", attempts)
+	}
+}
@@ -19,12 +19,36 @@ type Config struct {
 	LogShippingMaxRequestBytes                int     `yaml:"LOG_SHIPPING_MAX_REQUEST_BYTES"`
 	DefaultAmount                             int     `yaml:"DEFAULT_AMOUNT_CENTS"`
 	SuccessOverlayMs                          int     `yaml:"SUCCESS_OVERLAY_MILLIS"`
+	PaymentCurrency                           string  `yaml:"PAYMENT_CURRENCY"`
+	PaymentRedirectURL                        string  `yaml:"PAYMENT_REDIRECT_URL"`
+	PaymentDescription                        string  `yaml:"PAYMENT_DESCRIPTION"`
+	PaymentAmountOptionsCents                 string  `yaml:"PAYMENT_AMOUNT_OPTIONS_CENTS"` // Comma-separated, e.g. "500,1000,2000"
+	PaymentMetadata                           string  `yaml:"PAYMENT_METADATA"`             // Comma-separated key=value pairs
+	KioskLocation                             string  `yaml:"KIOSK_LOCATION"`
+	KioskEvent                                string  `yaml:"KIOSK_EVENT"`
+	PaymentAmountSelectionGraceSeconds        int     `yaml:"PAYMENT_AMOUNT_SELECTION_GRACE_SECONDS"`
+	PaymentExpiryGraceSeconds                 int     `yaml:"PAYMENT_EXPIRY_GRACE_SECONDS"`
 	ActuatorEnabled                           bool    `yaml:"ACTUATOR_ENABLED"`
 	ActuatorENAPin                            string  `yaml:"ACTUATOR_ENA_PIN"`
 	ActuatorIN1Pin                            string  `yaml:"ACTUATOR_IN1_PIN"`
 	ActuatorIN2Pin                            string  `yaml:"ACTUATOR_IN2_PIN"`
 	ActuatorMovement                          int     `yaml:"ACTUATOR_MOVEMENT_SECONDS"` // Used for both extend and retract
 	ActuatorPause                             int     `yaml:"ACTUATOR_PAUSE_SECONDS"`
+	ActuatorExtendSpeedPercent                int     `yaml:"ACTUATOR_EXTEND_SPEED_PERCENT"`
+	ActuatorRetractSpeedPercent               int     `yaml:"ACTUATOR_RETRACT_SPEED_PERCENT"`
+	ActuatorRampUpMs                          int     `yaml:"ACTUATOR_RAMP_UP_MS"`
+	ActuatorRampDownMs                        int     `yaml:"ACTUATOR_RAMP_DOWN_MS"`
+	ActuatorStallCurrentMa                    int     `yaml:"ACTUATOR_STALL_CURRENT_MA"`
+	ActuatorStallDurationMs                   int     `yaml:"ACTUATOR_STALL_DURATION_MS"`
+	ActuatorOverloadCurrentMa                 int     `yaml:"ACTUATOR_OVERLOAD_CURRENT_MA"`
+	ActuatorCurrentInrushIgnoreMs             int     `yaml:"ACTUATOR_CURRENT_INRUSH_IGNORE_MS"`
+	ActuatorCurrentSampleIntervalMs           int     `yaml:"ACTUATOR_CURRENT_SAMPLE_INTERVAL_MS"`
+	ActuatorStallReverseMs                    int     `yaml:"ACTUATOR_STALL_REVERSE_MS"`
+	CurrentSensorEnabled                      bool    `yaml:"CURRENT_SENSOR_ENABLED"`
+	CurrentSensorChip                         string  `yaml:"CURRENT_SENSOR_CHIP"`
+	CurrentSensorI2CBus                       int     `yaml:"CURRENT_SENSOR_I2C_BUS"`
+	CurrentSensorI2CAddress                   string  `yaml:"CURRENT_SENSOR_I2C_ADDRESS"`
+	CurrentSensorShuntMilliohms               int     `yaml:"CURRENT_SENSOR_SHUNT_MILLIOHMS"`
 	ColorSensorEnabled                        bool    `yaml:"COLOR_SENSOR_ENABLED"`
 	ColorSensorI2CBus                         int     `yaml:"COLOR_SENSOR_I2C_BUS"`
 	ColorSensorI2CAddress                     string  `yaml:"COLOR_SENSOR_I2C_ADDRESS"`
@@ -55,7 +79,65 @@ type Config struct {
 	VibrationIN3Pin                           string  `yaml:"VIBRATOR_IN3_PIN"`
 	VibrationIN4Pin                           string  `yaml:"VIBRATOR_IN4_PIN"`
 	VibrationENBPin                           string  `yaml:"VIBRATOR_ENB_PIN"`
+	VibrationDutyMaxOnSeconds                 int     `yaml:"VIBRATOR_DUTY_MAX_ON_SECONDS"`
+	VibrationDutyWindowSeconds                int     `yaml:"VIBRATOR_DUTY_WINDOW_SECONDS"`
+	EStopButtonEnabled                        bool    `yaml:"ESTOP_BUTTON_ENABLED"`
+	EStopButtonPin                            string  `yaml:"ESTOP_BUTTON_PIN"`
+	WearCountersFile                          string  `yaml:"WEAR_COUNTERS_FILE"`
+	MaintenanceActuatorCycles                 int     `yaml:"MAINTENANCE_ACTUATOR_CYCLES"`
+	MaintenanceActuatorMotorOnSeconds         int     `yaml:"MAINTENANCE_ACTUATOR_MOTOR_ON_SECONDS"`
+	MaintenanceVibratorOnSeconds              int     `yaml:"MAINTENANCE_VIBRATOR_ON_SECONDS"`
+	JamClearStrategy                          string  `yaml:"JAM_CLEAR_STRATEGY"`
+	JamClearStatsFile                         string  `yaml:"JAM_CLEAR_STATS_FILE"`
 	CameraEnabled                             bool    `yaml:"CAMERA_ENABLED"`
+	CameraBackend                             string  `yaml:"CAMERA_BACKEND"`
+	CameraDevice                              string  `yaml:"CAMERA_DEVICE"`
+	CameraFilePath                            string  `yaml:"CAMERA_FILE_PATH"`
+	CameraWidth                               int     `yaml:"CAMERA_WIDTH"`
+	CameraHeight                              int     `yaml:"CAMERA_HEIGHT"`
+	CameraQuality                             int     `yaml:"CAMERA_QUALITY"`
+	CameraRotation                            int     `yaml:"CAMERA_ROTATION"`
+	CameraHFlip                               bool    `yaml:"CAMERA_HFLIP"`
+	CameraVFlip                               bool    `yaml:"CAMERA_VFLIP"`
+	CameraCrop                                string  `yaml:"CAMERA_CROP"`
+	CameraShutterUs                           int     `yaml:"CAMERA_SHUTTER_US"`
+	CameraEV                                  float64 `yaml:"CAMERA_EV"`
+	CameraBurstCount                          int     `yaml:"CAMERA_BURST_COUNT"`
+	CameraBurstIntervalMs                     int     `yaml:"CAMERA_BURST_INTERVAL_MS"`
+	CameraStreamWidth                         int     `yaml:"CAMERA_STREAM_WIDTH"`
+	CameraStreamHeight                        int     `yaml:"CAMERA_STREAM_HEIGHT"`
+	CameraStreamFPS                           int     `yaml:"CAMERA_STREAM_FPS"`
+	AdminUsername                             string  `yaml:"ADMIN_USERNAME"`
+	AdminPassword                             string  `yaml:"ADMIN_PASSWORD"`
+	ListenAddress                             string  `yaml:"LISTEN_ADDRESS"`
+	LocalAPIToken                             string  `yaml:"LOCAL_API_TOKEN"`
+	LocalAPIAllowedIPs                        string  `yaml:"LOCAL_API_ALLOWED_IPS"`
+	TLSCertFile                               string  `yaml:"TLS_CERT_FILE"`
+	TLSKeyFile                                string  `yaml:"TLS_KEY_FILE"`
+	TLSSelfSigned                             bool    `yaml:"TLS_SELF_SIGNED"`
+	HTTPReadTimeoutSeconds                    int     `yaml:"HTTP_READ_TIMEOUT_SECONDS"`
+	HTTPWriteTimeoutSeconds                   int     `yaml:"HTTP_WRITE_TIMEOUT_SECONDS"`
+	HTTPIdleTimeoutSeconds                    int     `yaml:"HTTP_IDLE_TIMEOUT_SECONDS"`
+	HealthMaxPollAgeSeconds                   int     `yaml:"HEALTH_MAX_POLL_AGE_SECONDS"`
+	WatchdogMaxPollAgeSeconds                 int     `yaml:"WATCHDOG_MAX_POLL_AGE_SECONDS"`
+	OfflineAfterFailures                      int     `yaml:"OFFLINE_AFTER_FAILURES"`
+	OfflineProbeMaxIntervalSeconds            int     `yaml:"OFFLINE_PROBE_MAX_INTERVAL_SECONDS"`
+	EventSnapshotIntervalSeconds              int     `yaml:"EVENT_SNAPSHOT_INTERVAL_SECONDS"`
+	EventSnapshotDir                          string  `yaml:"EVENT_SNAPSHOT_DIR"`
+	EventSnapshotMaxFiles                     int     `yaml:"EVENT_SNAPSHOT_MAX_FILES"`
+	VisionEnabled                             bool    `yaml:"VISION_ENABLED"`
+	VisionReferenceDir                        string  `yaml:"VISION_REFERENCE_DIR"`
+	VisionROI                                 string  `yaml:"VISION_ROI"`
+	VisionFunnelCapacity                      int     `yaml:"VISION_FUNNEL_CAPACITY"`
+	VisionMinConfidence                       float64 `yaml:"VISION_MIN_CONFIDENCE"`
+	TimelapseEnabled                          bool    `yaml:"TIMELAPSE_ENABLED"`
+	TimelapseIntervalMinutes                  int     `yaml:"TIMELAPSE_INTERVAL_MINUTES"`
+	TimelapseHours                            string  `yaml:"TIMELAPSE_HOURS"`
+	TimelapseDir                              string  `yaml:"TIMELAPSE_DIR"`
+	TimelapseMaxFiles                         int     `yaml:"TIMELAPSE_MAX_FILES"`
+	TimelapseMaxMB                            int     `yaml:"TIMELAPSE_MAX_MB"`
+	TimelapseMaxAgeDays                       int     `yaml:"TIMELAPSE_MAX_AGE_DAYS"`
+	TimelapseUpload                           bool    `yaml:"TIMELAPSE_UPLOAD"`
 }
 
 func Load(filename string) (*Config, error) {
@@ -79,6 +161,19 @@ func (c *Config) SetDefaults() {
 	if c.SuccessOverlayMs == 0 {
 		c.SuccessOverlayMs = 10000 // 10 seconds by default
 	}
+	if c.PaymentCurrency == "" {
+		c.PaymentCurrency = "CHF"
+	}
+	if c.PaymentRedirectURL == "" {
+		c.PaymentRedirectURL = "https://example.com/payments/complete"
+	}
+	// Local payment deadlines: the backend's expiry plus this grace; negative disables.
+	if c.PaymentAmountSelectionGraceSeconds == 0 {
+		c.PaymentAmountSelectionGraceSeconds = 15
+	}
+	if c.PaymentExpiryGraceSeconds == 0 {
+		c.PaymentExpiryGraceSeconds = 60
+	}
 	// HTTPRequestLogging defaults to false to reduce browser request log noise.
 	if !c.LogShippingEnabled {
 		c.LogShippingEnabled = true
@@ -102,6 +197,44 @@ func (c *Config) SetDefaults() {
 		c.ActuatorMovement = 2 // 2 seconds by default (for both extend and retract)
 	}
 	// ActuatorPause is intentionally left at 0 (deprecated/ignored by actuator trigger cycle).
+	if c.ActuatorExtendSpeedPercent == 0 {
+		c.ActuatorExtendSpeedPercent = 100
+	}
+	if c.ActuatorRetractSpeedPercent == 0 {
+		c.ActuatorRetractSpeedPercent = 100
+	}
+	// ActuatorRampUpMs/ActuatorRampDownMs default to 0 (no ramp, ENA switched hard on/off).
+	if c.ActuatorStallCurrentMa == 0 {
+		c.ActuatorStallCurrentMa = 2500
+	}
+	if c.ActuatorStallDurationMs == 0 {
+		c.ActuatorStallDurationMs = 300
+	}
+	if c.ActuatorOverloadCurrentMa == 0 {
+		c.ActuatorOverloadCurrentMa = 3000
+	}
+	if c.ActuatorCurrentInrushIgnoreMs == 0 {
+		c.ActuatorCurrentInrushIgnoreMs = 200
+	}
+	if c.ActuatorCurrentSampleIntervalMs == 0 {
+		c.ActuatorCurrentSampleIntervalMs = 20
+	}
+	if c.ActuatorStallReverseMs == 0 {
+		c.ActuatorStallReverseMs = 300
+	}
+	// CurrentSensorEnabled defaults to false (stall detection needs an INA219/INA226 on the motor supply).
+	if c.CurrentSensorChip == "" {
+		c.CurrentSensorChip = "INA219"
+	}
+	if c.CurrentSensorI2CBus == 0 {
+		c.CurrentSensorI2CBus = 1
+	}
+	if c.CurrentSensorI2CAddress == "" {
+		c.CurrentSensorI2CAddress = "0x40"
+	}
+	if c.CurrentSensorShuntMilliohms == 0 {
+		c.CurrentSensorShuntMilliohms = 100
+	}
 	if !c.ColorSensorEnabled {
 		c.ColorSensorEnabled = true
 	}
@@ -177,7 +310,141 @@ func (c *Config) SetDefaults() {
 	if c.VibrationENBPin == "" {
 		c.VibrationENBPin = "GPIO18"
 	}
+	// Duty-cycle budget: a negative on-time disables it.
+	if c.VibrationDutyMaxOnSeconds == 0 {
+		c.VibrationDutyMaxOnSeconds = 120
+	}
+	if c.VibrationDutyWindowSeconds == 0 {
+		c.VibrationDutyWindowSeconds = 600
+	}
+	if c.EStopButtonPin == "" {
+		c.EStopButtonPin = "GPIO26"
+	}
+	if c.WearCountersFile == "" {
+		c.WearCountersFile = "wear_counters.json"
+	}
+	// Maintenance thresholds: a negative value disables the threshold.
+	if c.MaintenanceActuatorCycles == 0 {
+		c.MaintenanceActuatorCycles = 50000
+	}
+	if c.MaintenanceActuatorMotorOnSeconds == 0 {
+		c.MaintenanceActuatorMotorOnSeconds = 250000
+	}
+	if c.MaintenanceVibratorOnSeconds == 0 {
+		c.MaintenanceVibratorOnSeconds = 180000
+	}
+	if c.JamClearStrategy == "" {
+		c.JamClearStrategy = "adaptive"
+	}
+	if c.JamClearStatsFile == "" {
+		c.JamClearStatsFile = "jam_clear_stats.json"
+	}
 	if !c.CameraEnabled {
 		c.CameraEnabled = true
 	}
+	if c.CameraBackend == "" {
+		c.CameraBackend = "auto"
+	}
+	if c.CameraDevice == "" {
+		c.CameraDevice = "/dev/video0"
+	}
+	// CameraFilePath has no default; it is only used by the file backend.
+	// Camera capture options: width, height, rotation, flips, crop, shutter and EV
+	// default to the camera's own settings.
+	if c.CameraQuality == 0 {
+		c.CameraQuality = 90
+	}
+	if c.CameraBurstCount == 0 {
+		c.CameraBurstCount = 1
+	}
+	if c.CameraBurstIntervalMs == 0 {
+		c.CameraBurstIntervalMs = 500
+	}
+	if c.CameraStreamWidth == 0 {
+		c.CameraStreamWidth = 640
+	}
+	if c.CameraStreamHeight == 0 {
+		c.CameraStreamHeight = 480
+	}
+	if c.CameraStreamFPS == 0 {
+		c.CameraStreamFPS = 10
+	}
+	// AdminPassword has no default: admin endpoints stay disabled until it is set.
+	if c.AdminUsername == "" {
+		c.AdminUsername = "admin"
+	}
+	// The local API is only reachable from the kiosk browser on the device by default.
+	if c.ListenAddress == "" {
+		c.ListenAddress = "127.0.0.1:8000"
+	}
+	// TLS stays off until a certificate is configured or self-signed generation is on.
+	if c.TLSSelfSigned && c.TLSCertFile == "" && c.TLSKeyFile == "" {
+		c.TLSCertFile = "tls/cert.pem"
+		c.TLSKeyFile = "tls/key.pem"
+	}
+	// HTTP timeouts: -1 disables one. Event and camera streams extend the write
+	// deadline per frame.
+	if c.HTTPReadTimeoutSeconds == 0 {
+		c.HTTPReadTimeoutSeconds = 15
+	}
+	if c.HTTPWriteTimeoutSeconds == 0 {
+		c.HTTPWriteTimeoutSeconds = 60
+	}
+	if c.HTTPIdleTimeoutSeconds == 0 {
+		c.HTTPIdleTimeoutSeconds = 120
+	}
+	// /readyz fails once the backend has not answered for this long.
+	if c.HealthMaxPollAgeSeconds <= 0 {
+		c.HealthMaxPollAgeSeconds = 60
+	}
+	// The systemd watchdog stops being fed once the poll loop is stuck for this long;
+	// generous because a load test or jam clearing keeps one iteration busy for minutes.
+	if c.WatchdogMaxPollAgeSeconds <= 0 {
+		c.WatchdogMaxPollAgeSeconds = 300
+	}
+	// Offline mode: a negative failure count disables it.
+	if c.OfflineAfterFailures == 0 {
+		c.OfflineAfterFailures = 3
+	}
+	if c.OfflineProbeMaxIntervalSeconds <= 0 {
+		c.OfflineProbeMaxIntervalSeconds = 120
+	}
+	// Jam/error snapshots: a negative interval disables them.
+	if c.EventSnapshotIntervalSeconds == 0 {
+		c.EventSnapshotIntervalSeconds = 120
+	}
+	if c.EventSnapshotDir == "" {
+		c.EventSnapshotDir = "event_snapshots"
+	}
+	if c.EventSnapshotMaxFiles == 0 {
+		c.EventSnapshotMaxFiles = 50
+	}
+	// VisionEnabled defaults to false (needs reference frames from vision_calibrate).
+	// VisionROI defaults to the whole frame.
+	if c.VisionReferenceDir == "" {
+		c.VisionReferenceDir = "vision_references"
+	}
+	if c.VisionFunnelCapacity == 0 {
+		c.VisionFunnelCapacity = 20
+	}
+	if c.VisionMinConfidence == 0 {
+		c.VisionMinConfidence = 0.2
+	}
+	// TimelapseEnabled and TimelapseUpload default to false; TimelapseHours defaults to
+	// all day. Negative retention limits disable that limit.
+	if c.TimelapseIntervalMinutes == 0 {
+		c.TimelapseIntervalMinutes = 10
+	}
+	if c.TimelapseDir == "" {
+		c.TimelapseDir = "timelapse"
+	}
+	if c.TimelapseMaxFiles == 0 {
+		c.TimelapseMaxFiles = 2000
+	}
+	if c.TimelapseMaxMB == 0 {
+		c.TimelapseMaxMB = 500
+	}
+	if c.TimelapseMaxAgeDays == 0 {
+		c.TimelapseMaxAgeDays = 14
+	}
 }
@@ -12,6 +12,15 @@ func TestSetDefaultsAppliesValues(t *testing.T) {
 	if cfg.SuccessOverlayMs != 10000 {
 		t.Fatalf("SuccessOverlayMs not set, got %d", cfg.SuccessOverlayMs)
 	}
+	if cfg.PaymentCurrency != "CHF" || cfg.PaymentRedirectURL != "https://example.com/payments/complete" {
+		t.Fatalf("Payment defaults not set: currency=%q redirect=%q", cfg.PaymentCurrency, cfg.PaymentRedirectURL)
+	}
+	if cfg.PaymentAmountSelectionGraceSeconds != 15 || cfg.PaymentExpiryGraceSeconds != 60 {
+		t.Fatalf("Payment grace defaults not set: %d/%d", cfg.PaymentAmountSelectionGraceSeconds, cfg.PaymentExpiryGraceSeconds)
+	}
+	if cfg.PaymentDescription != "" || cfg.PaymentAmountOptionsCents != "" || cfg.PaymentMetadata != "" || cfg.KioskLocation != "" || cfg.KioskEvent != "" {
+		t.Fatalf("Optional payment fields should stay empty: %+v", cfg)
+	}
 	if cfg.HTTPRequestLogging {
 		t.Fatal("HTTPRequestLogging should be disabled by default")
 	}
@@ -36,6 +45,24 @@ func TestSetDefaultsAppliesValues(t *testing.T) {
 	if cfg.ActuatorMovement != 2 || cfg.ActuatorPause != 0 {
 		t.Fatalf("Actuator defaults not set: movement=%d pause=%d", cfg.ActuatorMovement, cfg.ActuatorPause)
 	}
+	if cfg.ActuatorExtendSpeedPercent != 100 || cfg.ActuatorRetractSpeedPercent != 100 {
+		t.Fatalf("Actuator speed defaults not set: extend=%d retract=%d", cfg.ActuatorExtendSpeedPercent, cfg.ActuatorRetractSpeedPercent)
+	}
+	if cfg.ActuatorRampUpMs != 0 || cfg.ActuatorRampDownMs != 0 {
+		t.Fatalf("Actuator ramps should default to 0: up=%d down=%d", cfg.ActuatorRampUpMs, cfg.ActuatorRampDownMs)
+	}
+	if cfg.ActuatorStallCurrentMa != 2500 || cfg.ActuatorStallDurationMs != 300 || cfg.ActuatorOverloadCurrentMa != 3000 {
+		t.Fatalf("Actuator stall defaults not set: stall=%dmA/%dms overload=%dmA", cfg.ActuatorStallCurrentMa, cfg.ActuatorStallDurationMs, cfg.ActuatorOverloadCurrentMa)
+	}
+	if cfg.ActuatorCurrentInrushIgnoreMs != 200 || cfg.ActuatorCurrentSampleIntervalMs != 20 || cfg.ActuatorStallReverseMs != 300 {
+		t.Fatalf("Actuator current sampling defaults not set: inrush=%dms sample=%dms reverse=%dms", cfg.ActuatorCurrentInrushIgnoreMs, cfg.ActuatorCurrentSampleIntervalMs, cfg.ActuatorStallReverseMs)
+	}
+	if cfg.CurrentSensorEnabled {
+		t.Fatal("CurrentSensorEnabled should default to false")
+	}
+	if cfg.CurrentSensorChip != "INA219" || cfg.CurrentSensorI2CBus != 1 || cfg.CurrentSensorI2CAddress != "0x40" || cfg.CurrentSensorShuntMilliohms != 100 {
+		t.Fatalf("Current sensor defaults not set: chip=%q bus=%d addr=%q shunt=%dmΩ", cfg.CurrentSensorChip, cfg.CurrentSensorI2CBus, cfg.CurrentSensorI2CAddress, cfg.CurrentSensorShuntMilliohms)
+	}
 	if !cfg.ColorSensorEnabled {
 		t.Fatal("ColorSensorEnabled default not set")
 	}
@@ -87,6 +114,68 @@ func TestSetDefaultsAppliesValues(t *testing.T) {
 	if cfg.BreakBeamPollIntervalMs != 10 {
 		t.Fatalf("BreakBeamPollIntervalMs default not set, got %d", cfg.BreakBeamPollIntervalMs)
 	}
+	if cfg.EStopButtonEnabled {
+		t.Fatal("EStopButtonEnabled should default to false")
+	}
+	if cfg.EStopButtonPin != "GPIO26" {
+		t.Fatalf("EStopButtonPin default not set, got %q", cfg.EStopButtonPin)
+	}
+	if cfg.WearCountersFile != "wear_counters.json" {
+		t.Fatalf("WearCountersFile default not set, got %q", cfg.WearCountersFile)
+	}
+	if cfg.MaintenanceActuatorCycles != 50000 || cfg.MaintenanceActuatorMotorOnSeconds != 250000 || cfg.MaintenanceVibratorOnSeconds != 180000 {
+		t.Fatalf("Maintenance threshold defaults not set: cycles=%d actuator_on=%ds vibrator_on=%ds", cfg.MaintenanceActuatorCycles, cfg.MaintenanceActuatorMotorOnSeconds, cfg.MaintenanceVibratorOnSeconds)
+	}
+	if cfg.VibrationDutyMaxOnSeconds != 120 || cfg.VibrationDutyWindowSeconds != 600 {
+		t.Fatalf("Vibrator duty-cycle defaults not set: max_on=%ds window=%ds", cfg.VibrationDutyMaxOnSeconds, cfg.VibrationDutyWindowSeconds)
+	}
+	if cfg.JamClearStrategy != "adaptive" {
+		t.Fatalf("JamClearStrategy default not set, got %q", cfg.JamClearStrategy)
+	}
+	if cfg.JamClearStatsFile != "jam_clear_stats.json" {
+		t.Fatalf("JamClearStatsFile default not set, got %q", cfg.JamClearStatsFile)
+	}
+	if cfg.EventSnapshotIntervalSeconds != 120 || cfg.EventSnapshotDir != "event_snapshots" || cfg.EventSnapshotMaxFiles != 50 {
+		t.Fatalf("Event snapshot defaults not set: interval=%ds dir=%q max_files=%d", cfg.EventSnapshotIntervalSeconds, cfg.EventSnapshotDir, cfg.EventSnapshotMaxFiles)
+	}
+	if cfg.CameraQuality != 90 || cfg.CameraBurstCount != 1 || cfg.CameraBurstIntervalMs != 500 || cfg.CameraWidth != 0 || cfg.CameraCrop != "" {
+		t.Fatalf("Camera capture defaults not set: quality=%d burst=%d interval=%dms width=%d crop=%q", cfg.CameraQuality, cfg.CameraBurstCount, cfg.CameraBurstIntervalMs, cfg.CameraWidth, cfg.CameraCrop)
+	}
+	if cfg.CameraBackend != "auto" || cfg.CameraDevice != "/dev/video0" || cfg.CameraFilePath != "" {
+		t.Fatalf("Camera backend defaults not set: backend=%q device=%q file=%q", cfg.CameraBackend, cfg.CameraDevice, cfg.CameraFilePath)
+	}
+	if cfg.CameraStreamWidth != 640 || cfg.CameraStreamHeight != 480 || cfg.CameraStreamFPS != 10 {
+		t.Fatalf("Camera stream defaults not set: %dx%d@%d", cfg.CameraStreamWidth, cfg.CameraStreamHeight, cfg.CameraStreamFPS)
+	}
+	if cfg.AdminUsername != "admin" || cfg.AdminPassword != "" {
+		t.Fatalf("Admin defaults not set: username=%q password set=%t", cfg.AdminUsername, cfg.AdminPassword != "")
+	}
+	if cfg.ListenAddress != "127.0.0.1:8000" || cfg.LocalAPIToken != "" || cfg.LocalAPIAllowedIPs != "" {
+		t.Fatalf("Local API defaults not set: listen=%q token set=%t allowed=%q", cfg.ListenAddress, cfg.LocalAPIToken != "", cfg.LocalAPIAllowedIPs)
+	}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" || cfg.TLSSelfSigned || cfg.HTTPReadTimeoutSeconds != 15 || cfg.HTTPWriteTimeoutSeconds != 60 || cfg.HTTPIdleTimeoutSeconds != 120 {
+		t.Fatalf("HTTP server defaults not set: tls=%q/%q self_signed=%t timeouts=%d/%d/%d", cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSSelfSigned, cfg.HTTPReadTimeoutSeconds, cfg.HTTPWriteTimeoutSeconds, cfg.HTTPIdleTimeoutSeconds)
+	}
+	if cfg.HealthMaxPollAgeSeconds != 60 {
+		t.Fatalf("Health poll age default not set: %d", cfg.HealthMaxPollAgeSeconds)
+	}
+	if cfg.WatchdogMaxPollAgeSeconds != 300 {
+		t.Fatalf("Watchdog poll age default not set: %d", cfg.WatchdogMaxPollAgeSeconds)
+	}
+	if cfg.OfflineAfterFailures != 3 || cfg.OfflineProbeMaxIntervalSeconds != 120 {
+		t.Fatalf("Offline defaults not set: %d/%d", cfg.OfflineAfterFailures, cfg.OfflineProbeMaxIntervalSeconds)
+	}
+	selfSigned := &Config{TLSSelfSigned: true}
+	selfSigned.SetDefaults()
+	if selfSigned.TLSCertFile != "tls/cert.pem" || selfSigned.TLSKeyFile != "tls/key.pem" {
+		t.Fatalf("Self-signed TLS paths not set: %q/%q", selfSigned.TLSCertFile, selfSigned.TLSKeyFile)
+	}
+	if cfg.VisionEnabled || cfg.VisionReferenceDir != "vision_references" || cfg.VisionFunnelCapacity != 20 || cfg.VisionMinConfidence != 0.2 {
+		t.Fatalf("Vision defaults not set: enabled=%t dir=%q capacity=%d min_confidence=%v", cfg.VisionEnabled, cfg.VisionReferenceDir, cfg.VisionFunnelCapacity, cfg.VisionMinConfidence)
+	}
+	if cfg.TimelapseEnabled || cfg.TimelapseUpload || cfg.TimelapseIntervalMinutes != 10 || cfg.TimelapseDir != "timelapse" || cfg.TimelapseMaxFiles != 2000 || cfg.TimelapseMaxMB != 500 || cfg.TimelapseMaxAgeDays != 14 {
+		t.Fatalf("Timelapse defaults not set: interval=%dm dir=%q max_files=%d max_mb=%d max_age_days=%d", cfg.TimelapseIntervalMinutes, cfg.TimelapseDir, cfg.TimelapseMaxFiles, cfg.TimelapseMaxMB, cfg.TimelapseMaxAgeDays)
+	}
 }
 
 func TestSetDefaultsPreservesValues(t *testing.T) {
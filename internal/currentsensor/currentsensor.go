@@ -0,0 +1,185 @@
+package currentsensor
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
+	"periph.io/x/host/v3"
+)
+
+// Supported chips
+const (
+	ChipINA219 = "INA219"
+	ChipINA226 = "INA226"
+)
+
+// INA219/INA226 register addresses (16-bit big-endian registers)
+const (
+	regConfig       = 0x00
+	regShuntVoltage = 0x01
+)
+
+// Default configuration written on init.
+const (
+	// INA219: 32V bus range, PGA /8 (±320mV), 12-bit ADCs, continuous shunt+bus
+	ina219Config = 0x399F
+	// INA226: 4 averages, 1.1ms conversion times, continuous shunt+bus
+	ina226Config = 0x4327
+)
+
+// Shunt voltage LSB in volts.
+const (
+	ina219ShuntLSB = 10e-6
+	ina226ShuntLSB = 2.5e-6
+)
+
+// reader abstracts the I2C device for testing.
+type reader interface {
+	Tx(w, r []byte) error
+}
+
+// Sensor reads the motor supply current from an INA219 or INA226 via I2C.
+type Sensor struct {
+	enabled   bool
+	sim       bool
+	shuntLSB  float64 // volts per shunt register LSB
+	shuntOhms float64
+	dev       reader
+	bus       i2c.BusCloser
+}
+
+// New creates a Sensor from config. Call Init() to open hardware.
+func New(cfg *config.Config) *Sensor {
+	shuntOhms := float64(cfg.CurrentSensorShuntMilliohms) / 1000.0
+	if shuntOhms <= 0 {
+		shuntOhms = 0.1
+	}
+	return &Sensor{
+		enabled:   cfg.CurrentSensorEnabled,
+		shuntLSB:  ina219ShuntLSB,
+		shuntOhms: shuntOhms,
+	}
+}
+
+// Init opens the I2C bus and configures the INA219/INA226.
+// Falls back to simulation mode if hardware is unavailable.
+func (s *Sensor) Init(cfg *config.Config) error {
+	if !s.enabled {
+		log.Println("Current sensor disabled")
+		return nil
+	}
+
+	chip := strings.ToUpper(strings.TrimSpace(cfg.CurrentSensorChip))
+	var configWord uint16
+	switch chip {
+	case ChipINA219:
+		s.shuntLSB = ina219ShuntLSB
+		configWord = ina219Config
+	case ChipINA226:
+		s.shuntLSB = ina226ShuntLSB
+		configWord = ina226Config
+	default:
+		return fmt.Errorf("current sensor: unsupported chip %q (use %s or %s)", cfg.CurrentSensorChip, ChipINA219, ChipINA226)
+	}
+
+	if _, err := host.Init(); err != nil {
+		log.Printf("Current sensor: periph host init failed, running in simulation mode: %v", err)
+		s.sim = true
+		return nil
+	}
+
+	busNames := []string{
+		fmt.Sprintf("/dev/i2c-%d", cfg.CurrentSensorI2CBus),
+		fmt.Sprintf("I2C%d", cfg.CurrentSensorI2CBus),
+		fmt.Sprintf("%d", cfg.CurrentSensorI2CBus),
+	}
+
+	var (
+		bus     i2c.BusCloser
+		err     error
+		busName string
+	)
+	for _, candidate := range busNames {
+		bus, err = i2creg.Open(candidate)
+		if err == nil {
+			busName = candidate
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("Current sensor: failed to open I2C bus (tried %q), running in simulation mode: %v", strings.Join(busNames, ", "), err)
+		s.sim = true
+		return nil
+	}
+
+	addr, err := parseAddr(cfg.CurrentSensorI2CAddress)
+	if err != nil {
+		bus.Close()
+		return fmt.Errorf("current sensor: invalid I2C address %q: %w", cfg.CurrentSensorI2CAddress, err)
+	}
+
+	dev := &i2c.Dev{Bus: bus, Addr: addr}
+
+	if err := writeReg(dev, regConfig, configWord); err != nil {
+		bus.Close()
+		log.Printf("Current sensor: failed to configure %s, running in simulation mode: %v", chip, err)
+		s.sim = true
+		return nil
+	}
+
+	s.bus = bus
+	s.dev = dev
+	log.Printf("Current sensor %s initialised on %s addr %#x (shunt %.0fmΩ)", chip, busName, addr, s.shuntOhms*1000)
+	return nil
+}
+
+// ReadCurrentMilliamps returns the current through the shunt in milliamps.
+// Negative values mean current flows in reverse through the shunt.
+func (s *Sensor) ReadCurrentMilliamps() (float64, error) {
+	if !s.enabled || s.sim {
+		return 0, nil
+	}
+	buf := make([]byte, 2)
+	if err := s.dev.Tx([]byte{regShuntVoltage}, buf); err != nil {
+		return 0, fmt.Errorf("current sensor read failed: %w", err)
+	}
+	raw := int16(uint16(buf[0])<<8 | uint16(buf[1]))
+	shuntVolts := float64(raw) * s.shuntLSB
+	return shuntVolts / s.shuntOhms * 1000.0, nil
+}
+
+// IsEnabled reports whether the sensor is enabled.
+func (s *Sensor) IsEnabled() bool { return s.enabled }
+
+// IsSimulation reports whether the sensor is currently using simulation mode.
+func (s *Sensor) IsSimulation() bool { return s.sim }
+
+// Close releases the I2C bus.
+func (s *Sensor) Close() error {
+	if s.bus != nil {
+		return s.bus.Close()
+	}
+	return nil
+}
+
+// writeReg writes a 16-bit big-endian value to an INA2xx register.
+func writeReg(dev reader, reg byte, val uint16) error {
+	return dev.Tx([]byte{reg, byte(val >> 8), byte(val)}, nil)
+}
+
+// parseAddr parses a hex string like "0x40" or "40" into a uint16.
+func parseAddr(s string) (uint16, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "0x")
+	s = strings.TrimPrefix(s, "0X")
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(v), nil
+}
@@ -0,0 +1,73 @@
+package currentsensor
+
+import (
+	"math"
+	"testing"
+)
+
+// fakeReader implements reader for testing without hardware.
+type fakeReader struct {
+	data    []byte
+	err     error
+	written []byte
+}
+
+func (f *fakeReader) Tx(w, r []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.written = append([]byte(nil), w...)
+	copy(r, f.data)
+	return nil
+}
+
+func TestReadCurrentINA219(t *testing.T) {
+	// 0x2710 = 10000 LSB * 10µV = 100mV across 0.1Ω → 1000mA
+	fake := &fakeReader{data: []byte{0x27, 0x10}}
+	s := &Sensor{enabled: true, dev: fake, shuntLSB: ina219ShuntLSB, shuntOhms: 0.1}
+
+	ma, err := s.ReadCurrentMilliamps()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(ma-1000) > 1e-6 {
+		t.Fatalf("want 1000mA, got %.3f", ma)
+	}
+	if len(fake.written) != 1 || fake.written[0] != regShuntVoltage {
+		t.Fatalf("expected shunt voltage register read, wrote %v", fake.written)
+	}
+}
+
+func TestReadCurrentINA226Negative(t *testing.T) {
+	// 0xF830 = -2000 LSB * 2.5µV = -5mV across 0.01Ω → -500mA
+	fake := &fakeReader{data: []byte{0xF8, 0x30}}
+	s := &Sensor{enabled: true, dev: fake, shuntLSB: ina226ShuntLSB, shuntOhms: 0.01}
+
+	ma, err := s.ReadCurrentMilliamps()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(ma+500) > 1e-6 {
+		t.Fatalf("want -500mA, got %.3f", ma)
+	}
+}
+
+func TestReadCurrentDisabledOrSimReturnsZero(t *testing.T) {
+	for _, s := range []*Sensor{{enabled: false}, {enabled: true, sim: true}} {
+		ma, err := s.ReadCurrentMilliamps()
+		if err != nil || ma != 0 {
+			t.Fatalf("expected 0mA, got %.3f err=%v", ma, err)
+		}
+	}
+}
+
+func TestWriteRegBigEndian(t *testing.T) {
+	fake := &fakeReader{}
+	if err := writeReg(fake, regConfig, ina219Config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []byte{regConfig, 0x39, 0x9F}
+	if string(fake.written) != string(want) {
+		t.Fatalf("want %v, got %v", want, fake.written)
+	}
+}
@@ -0,0 +1,128 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/wear"
+)
+
+// stateHistoryLimit is the number of state transitions kept for the admin console.
+const stateHistoryLimit = 50
+
+// localCommands are the commands the admin console may run.
+var localCommands = map[string]bool{
+	"home":         true,
+	"extend":       true,
+	"retract":      true,
+	"vibrate":      true,
+	"load_test":    true,
+	"take_picture": true,
+	"restart":      true,
+}
+
+// ErrCommandNotAllowed is returned by ExecuteLocalCommand when the runtime state does not
+// permit the command, e.g. an actuator command during a payment.
+var ErrCommandNotAllowed = errors.New("command not allowed in the current state")
+
+// StateChange is one runtime state transition.
+type StateChange struct {
+	State   string    `json:"state"`
+	Message string    `json:"message,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// SensorReadings are live sensor values for the admin console.
+type SensorReadings struct {
+	ColorSensor          *ColorReading `json:"color_sensor,omitempty"`
+	BreakBeamInterrupted *bool         `json:"break_beam_interrupted,omitempty"`
+	Wear                 *wear.Report  `json:"wear,omitempty"`
+	Errors               []string      `json:"errors,omitempty"`
+}
+
+// ColorReading is one raw TCS34725 sample.
+type ColorReading struct {
+	Clear     uint16    `json:"clear"`
+	Red       uint16    `json:"red"`
+	Green     uint16    `json:"green"`
+	Blue      uint16    `json:"blue"`
+	SampledAt time.Time `json:"sampled_at"`
+}
+
+// recordStateChangeLocked appends a transition to the history. Callers hold statusMutex.
+func (c *Client) recordStateChangeLocked(state RuntimeState, message string) {
+	c.history = append(c.history, StateChange{State: string(state), Message: message, At: time.Now().UTC()})
+	if len(c.history) > stateHistoryLimit {
+		c.history = c.history[len(c.history)-stateHistoryLimit:]
+	}
+}
+
+// StateHistory returns the most recent state transitions, newest first.
+func (c *Client) StateHistory() []StateChange {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	history := make([]StateChange, len(c.history))
+	for i, change := range c.history {
+		history[len(c.history)-1-i] = change
+	}
+	return history
+}
+
+// SensorReadings returns the last colour sensor sample of the poll loop, the break-beam
+// state and the wear counters. The colour sensor is not read again, so the console
+// cannot disturb a running detection.
+func (c *Client) SensorReadings() SensorReadings {
+	var readings SensorReadings
+	if c.colorSensor.IsEnabled() {
+		if sample, ok := c.colorSensor.LastSample(); ok {
+			if sample.Err != nil {
+				readings.Errors = append(readings.Errors, sample.Err.Error())
+			} else {
+				readings.ColorSensor = &ColorReading{Clear: sample.C, Red: sample.R, Green: sample.G, Blue: sample.B, SampledAt: sample.At.UTC()}
+			}
+		}
+	}
+	if c.breakBeamSensor.IsEnabled() {
+		interrupted, err := c.breakBeamSensor.ReadInterrupted()
+		if err != nil {
+			readings.Errors = append(readings.Errors, err.Error())
+		} else {
+			readings.BreakBeamInterrupted = &interrupted
+		}
+	}
+	if c.wear != nil {
+		report := c.wear.Report()
+		readings.Wear = &report
+	}
+	return readings
+}
+
+// ExecuteLocalCommand runs a command from the admin console through the same execution
+// path and state checks as remote commands and returns the outcome as it would be
+// acknowledged. Commands the state does not permit fail with ErrCommandNotAllowed
+// instead of being deferred.
+func (c *Client) ExecuteLocalCommand(cmd CommandResponse) (AckRequest, error) {
+	cmd.Command = strings.ToLower(strings.TrimSpace(cmd.Command))
+	if !localCommands[cmd.Command] {
+		return AckRequest{}, fmt.Errorf("command %q is not available from the admin console", cmd.Command)
+	}
+	// Local commands have no server-side ID.
+	cmd.ID = 0
+	// Wait for the running poll cycle so the state cannot change between the check and
+	// the command.
+	c.pollMutex.Lock()
+	defer c.pollMutex.Unlock()
+	if !c.canExecuteCommandNow(&cmd) {
+		c.statusMutex.Lock()
+		state := c.state
+		c.statusMutex.Unlock()
+		return AckRequest{}, fmt.Errorf("%w (%s): %s", ErrCommandNotAllowed, state, cmd.Command)
+	}
+
+	log.Printf("Device client: running %s from the admin console", cmd.Command)
+	result, execErr := c.runCommand(&cmd, nil)
+	return newAckRequest(execErr, result), nil
+}
@@ -0,0 +1,101 @@
+package device
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/colorsensor"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+)
+
+func TestStateHistoryRecordsTransitions(t *testing.T) {
+	client := newTestClient("http://unused")
+	client.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	client.setRuntimeState(StateDetectingBall, "still waiting") // no transition
+	client.setRuntimeState(StateJam, "Stau detektiert")
+
+	history := client.StateHistory()
+	if len(history) != 2 || history[0].State != string(StateJam) || history[1].State != string(StateDetectingBall) {
+		t.Fatalf("expected jam then detecting_ball, newest first, got %+v", history)
+	}
+
+	for i := 0; i < stateHistoryLimit+10; i++ {
+		client.setRuntimeState(StateIdle, "")
+		client.setRuntimeState(StateDetectingBall, "")
+	}
+	if n := len(client.StateHistory()); n != stateHistoryLimit {
+		t.Fatalf("expected the history to be capped at %d, got %d", stateHistoryLimit, n)
+	}
+}
+
+// ensure admin console commands use the remote command policy and execution path
+func TestExecuteLocalCommand(t *testing.T) {
+	defer initSimCamera(t)()
+	client := newTestClient("http://unused")
+	client.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+
+	ack, err := client.ExecuteLocalCommand(CommandResponse{Command: "Take_Picture"})
+	if err != nil {
+		t.Fatalf("ExecuteLocalCommand failed: %v", err)
+	}
+	if ack.Status != "success" || ack.ImageBase64 == "" {
+		t.Fatalf("expected a successful picture, got status=%q error=%q", ack.Status, ack.ErrorMessage)
+	}
+	if snapshot := client.GetStateSnapshot(); snapshot.State != string(StateDetectingBall) || snapshot.ExecutingCommand != nil {
+		t.Fatalf("expected the state to be restored after the command, got %+v", snapshot)
+	}
+
+	if _, err := client.ExecuteLocalCommand(CommandResponse{Command: "ball_dispenser"}); err == nil || errors.Is(err, ErrCommandNotAllowed) {
+		t.Fatalf("expected ball_dispenser to be unavailable locally, got %v", err)
+	}
+
+	client.jammed.Store(true)
+	if _, err := client.ExecuteLocalCommand(CommandResponse{Command: "extend"}); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Fatalf("expected extend to be refused while jammed, got %v", err)
+	}
+}
+
+// ensure a local command waits for the running poll cycle before checking the state
+func TestExecuteLocalCommandWaitsForPollCycle(t *testing.T) {
+	defer initSimCamera(t)()
+	client := newTestClient("http://unused")
+	client.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+
+	client.pollMutex.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.ExecuteLocalCommand(CommandResponse{Command: "extend"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected the command to wait for the poll cycle, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The cycle latches a jam before it ends; the command must see it.
+	client.jammed.Store(true)
+	client.pollMutex.Unlock()
+	if err := <-done; !errors.Is(err, ErrCommandNotAllowed) {
+		t.Fatalf("expected extend to be refused after the cycle jammed, got %v", err)
+	}
+}
+
+// ensure the console shows the poll loop's last colour sample without reading the sensor
+func TestSensorReadingsUseLastSample(t *testing.T) {
+	client := newTestClient("http://unused")
+	client.colorSensor = colorsensor.New(&config.Config{ColorSensorEnabled: true})
+
+	if readings := client.SensorReadings(); readings.ColorSensor != nil || len(readings.Errors) != 0 {
+		t.Fatalf("expected no colour reading before the poll loop sampled, got %+v", readings)
+	}
+	if _, ok := client.colorSensor.LastSample(); ok {
+		t.Fatal("expected SensorReadings not to read the sensor")
+	}
+
+	client.colorSensor.Read()
+	if readings := client.SensorReadings(); len(readings.Errors) != 1 {
+		t.Fatalf("expected the failed sample to be reported, got %+v", readings)
+	}
+}
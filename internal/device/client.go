@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,15 +21,22 @@ import (
 	"github.com/jsalamander/baendaeli-client/internal/camera"
 	"github.com/jsalamander/baendaeli-client/internal/colorsensor"
 	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/jamclear"
 	"github.com/jsalamander/baendaeli-client/internal/version"
 	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+	"github.com/jsalamander/baendaeli-client/internal/vision"
+	"github.com/jsalamander/baendaeli-client/internal/wear"
 )
 
 // StatusRequest is sent to the server
 type StatusRequest struct {
-	PaymentID      *string `json:"payment_id,omitempty"`
-	ClientVersion  string  `json:"client_version"`
-	DispensedCount *int    `json:"dispensed_count,omitempty"`
+	PaymentID      *string              `json:"payment_id,omitempty"`
+	ClientVersion  string               `json:"client_version"`
+	DispensedCount *int                 `json:"dispensed_count,omitempty"`
+	Wear           *wear.Report         `json:"wear,omitempty"`
+	JamClear       *jamclear.Report     `json:"jam_clear,omitempty"`
+	VibratorBudget *vibrator.DutyStatus `json:"vibrator_budget,omitempty"`
+	Vision         *VisionReport        `json:"vision,omitempty"`
 }
 
 // StatusResponse is received from the server
@@ -40,19 +48,37 @@ type StatusResponse struct {
 
 // CommandResponse is received from the server
 type CommandResponse struct {
-	ID          int    `json:"id"`
-	Command     string `json:"command"`
-	DurationMs  *int   `json:"duration_ms,omitempty"`  // Optional duration in milliseconds
-	RepeatCount *int   `json:"repeat_count,omitempty"` // Optional repeat count for load_test cycles
-	Message     string `json:"message,omitempty"`      // Message text for message command
-	Percent     *int   `json:"percent,omitempty"`      // Vibration intensity (1-100) for vibrate command
+	ID          int             `json:"id"`
+	Command     string          `json:"command"`
+	DurationMs  *int            `json:"duration_ms,omitempty"`  // Optional duration in milliseconds
+	RepeatCount *int            `json:"repeat_count,omitempty"` // Optional repeat count for load_test cycles
+	Message     string          `json:"message,omitempty"`      // Message text for message command
+	Percent     *int            `json:"percent,omitempty"`      // Vibration intensity (1-100) for vibrate command
+	Component   string          `json:"component,omitempty"`    // Part serviced by maintenance_reset (actuator, vibrator; empty for both)
+	Strategy    string          `json:"strategy,omitempty"`     // Jam-clearing strategy for jam_strategy (adaptive or a pattern name)
+	Pattern     json.RawMessage `json:"pattern,omitempty"`      // Optional vibrate pattern: preset name or list of VibrationStep
+	Reference   string          `json:"reference,omitempty"`    // Reference frame for vision_calibrate (empty, full, jammed)
+	Capture     *camera.Options `json:"capture,omitempty"`      // Optional take_picture capture options; zero fields use the config
+	Picture     string          `json:"picture,omitempty"`      // Stored picture ID for get_picture
+	Limit       *int            `json:"limit,omitempty"`        // Maximum number of pictures returned by list_pictures
 }
 
 // AckRequest is sent to the server
 type AckRequest struct {
-	Status       string `json:"status"`                  // "success" or "failed"
-	ErrorMessage string `json:"error_message,omitempty"` // max 1000 chars, only for failed status
-	ImageBase64  string `json:"image_base64,omitempty"`  // base64-encoded JPEG, required on success for take_picture
+	Status       string           `json:"status"`                  // "success" or "failed"
+	ErrorMessage string           `json:"error_message,omitempty"` // max 1000 chars, only for failed status
+	ImageBase64  string           `json:"image_base64,omitempty"`  // base64-encoded JPEG, required on success for take_picture
+	Images       []string         `json:"images_base64,omitempty"` // all base64-encoded JPEGs of a take_picture burst
+	Vibration    *VibrationReport `json:"vibration,omitempty"`     // executed vibrate pattern, also on failure
+	Pictures     []PictureInfo    `json:"pictures,omitempty"`      // stored pictures, newest first, for list_pictures
+}
+
+// commandResult holds the command-specific fields sent with the ack.
+type commandResult struct {
+	imageBase64 string
+	images      []string
+	vibration   *VibrationReport
+	pictures    []PictureInfo
 }
 
 // AckResponse is received from the server
@@ -66,8 +92,11 @@ type pendingDispense struct {
 }
 
 type paymentCreateRequest struct {
-	Currency           string `json:"currency"`
-	PaymentRedirectURL string `json:"payment_redirect_url"`
+	Currency           string            `json:"currency"`
+	PaymentRedirectURL string            `json:"payment_redirect_url"`
+	Description        string            `json:"description,omitempty"`
+	AmountOptionsCents []int             `json:"amount_options_cents,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
 }
 
 type paymentCreateResponse struct {
@@ -91,19 +120,38 @@ const (
 	StateDispensing       RuntimeState = "dispensing"
 	StatePaymentFailed    RuntimeState = "payment_failed"
 	StateJam              RuntimeState = "jam"
+	StateActuatorStall    RuntimeState = "actuator_stall"
+	StateStopped          RuntimeState = "stopped"
 	StateIdle             RuntimeState = "idle"
 	StateCommandExecuting RuntimeState = "command_executing"
 	StateError            RuntimeState = "error"
+	StateOffline          RuntimeState = "offline"
 )
 
+// actuatorStallMessage is shown while the actuator_stall state is latched.
+const actuatorStallMessage = "Aktuator blockiert - Neustart erforderlich"
+
+// emergencyStopMessage is shown while the stopped state is latched.
+const emergencyStopMessage = "Not-Halt aktiv - Freigabe erforderlich"
+
+// emergencyStopWatchInterval is how often the command endpoint is checked for a queued
+// estop command while the hardware moves.
+const emergencyStopWatchInterval = time.Second
+
 type StateSnapshot struct {
-	State            string           `json:"state"`
-	Message          string           `json:"message,omitempty"`
-	PaymentID        string           `json:"payment_id,omitempty"`
-	Payment          map[string]any   `json:"payment,omitempty"`
-	Jammed           bool             `json:"jammed"`
-	ExecutingCommand *CommandResponse `json:"executing_command,omitempty"`
-	PendingCommand   *CommandResponse `json:"pending_command,omitempty"`
+	State            string               `json:"state"`
+	Message          string               `json:"message,omitempty"`
+	PaymentID        string               `json:"payment_id,omitempty"`
+	Payment          map[string]any       `json:"payment,omitempty"`
+	Jammed           bool                 `json:"jammed"`
+	ActuatorStall    bool                 `json:"actuator_stall"`
+	Stopped          bool                 `json:"stopped"`
+	MaintenanceDue   bool                 `json:"maintenance_due"`
+	MaintenanceItems []string             `json:"maintenance_items,omitempty"`
+	VibratorBudget   *vibrator.DutyStatus `json:"vibrator_budget,omitempty"`
+	Vision           *VisionReport        `json:"vision,omitempty"`
+	ExecutingCommand *CommandResponse     `json:"executing_command,omitempty"`
+	PendingCommand   *CommandResponse     `json:"pending_command,omitempty"`
 }
 
 type breakBeamSensor interface {
@@ -129,6 +177,9 @@ type Client struct {
 	colorSensor      *colorsensor.Sensor
 	breakBeamSensor  breakBeamSensor
 	jammed           atomic.Bool
+	actuatorStalled  atomic.Bool  // latched on motor stall/overload until restart
+	stopped          atomic.Bool  // latched by EmergencyStop until ResetEmergencyStop
+	lastProgress     atomic.Int64 // unix nanoseconds of the last finished poll or command progress
 
 	// Command execution status
 	statusMutex      sync.Mutex
@@ -141,9 +192,62 @@ type Client struct {
 	dispenseMutex    sync.Mutex
 	pendingDispense  *pendingDispense
 	logShipper       *logShipper
+	eventSnapshots   *eventSnapshotter
+	timelapse        *timelapse
+	stateEvents      *stateEvents
+
+	// Held for a whole poll cycle, and by ExecuteLocalCommand across its state check and
+	// execution, so a local command runs between cycles like a remote one
+	pollMutex sync.Mutex
+
+	// Actuator lock to prevent concurrent commands; a dispense job waits for it with
+	// LockContext so the wait can be cancelled
+	actuatorMutex semaphore
+	actuator      actuator.Actuator
+
+	// Running actuator movement (nil when idle)
+	movementMutex sync.Mutex
+	movement      *movement
+
+	// Wear counters (nil when wear tracking is unavailable)
+	wear *wear.Tracker
+
+	// Jam-clearing strategy engine (nil uses the default escalation)
+	jamClear *jamclear.Engine
+
+	// Funnel image analyzer (nil when vision is disabled) and its latest result,
+	// guarded by statusMutex
+	visionAnalyzer *vision.Analyzer
+	lastVision     *VisionReport
 
-	// Actuator lock to prevent concurrent commands
-	actuatorMutex sync.Mutex
+	// Recent state transitions for the admin console, oldest first, guarded by statusMutex
+	history []StateChange
+
+	// Last successful backend request and the latest error for /readyz, guarded by statusMutex
+	lastBackendContact time.Time
+	lastBackendError   string
+
+	// Serialises payment creation by the state machine and the local API; ballReady is
+	// set while a detected ball waits for its payment
+	paymentCreateMutex sync.Mutex
+	ballReady          atomic.Bool
+
+	// Body of every POST /api/v1/payment, built from the config in New
+	paymentRequest paymentCreateRequest
+
+	// Backend connectivity for offline mode and the command acks and payment report
+	// waiting to be replayed, guarded by statusMutex
+	backendFailures int
+	offlineSince    time.Time
+	probeBackoff    time.Duration
+	nextProbe       time.Time
+	queuedAcks      []queuedAck
+	unsentReport    *StatusRequest
+}
+
+// movement tracks one running actuator movement so it can be cancelled.
+type movement struct {
+	cancel context.CancelFunc
 }
 
 // New creates a new device client
@@ -158,11 +262,35 @@ func New(cfg *config.Config) *Client {
 		colorSensor:     colorsensor.New(cfg),
 		breakBeamSensor: breakbeam.New(cfg),
 		state:           StateStarting,
+		actuator:        actuator.NewSimulated(actuator.Config{MovementTime: cfg.ActuatorMovement}),
+		actuatorMutex:   newSemaphore(),
 	}
 	c.logShipper = newLogShipper(ctx, c, c.httpClient, io.Discard)
+	c.eventSnapshots = newEventSnapshotter(ctx, c, c.httpClient)
+	c.timelapse = newTimelapse(ctx, c, c.httpClient)
+	c.stateEvents = newStateEvents(c)
+	c.paymentRequest = newPaymentCreateRequest(cfg)
 	return c
 }
 
+// SetActuator replaces the actuator used for all movements. Call before Start.
+func (c *Client) SetActuator(a actuator.Actuator) {
+	c.actuatorMutex.Lock()
+	defer c.actuatorMutex.Unlock()
+	c.actuator = a
+}
+
+// SetWearTracker sets the wear counters reported with every status update. Call before Start.
+func (c *Client) SetWearTracker(t *wear.Tracker) {
+	c.wear = t
+}
+
+// SetJamClearEngine sets the engine that picks jam-clearing patterns after a missed
+// ball. Call before Start.
+func (c *Client) SetJamClearEngine(e *jamclear.Engine) {
+	c.jamClear = e
+}
+
 // SetLogShippingDiagnosticsWriter configures where shipper diagnostics are written.
 func (c *Client) SetLogShippingDiagnosticsWriter(w io.Writer) {
 	if c.logShipper == nil {
@@ -189,6 +317,7 @@ func (c *Client) SetPaymentID(paymentID string) {
 		c.lastPaymentDebug = ""
 	}
 	c.dropStalePendingDispense(paymentID)
+	c.notifyStateChange()
 }
 
 // GetPaymentID returns the current payment ID
@@ -211,6 +340,7 @@ func (c *Client) setCurrentPayment(paymentID string, payment map[string]any) {
 	}
 	c.currentPayment = merged
 	c.dropStalePendingDispense(paymentID)
+	c.notifyStateChange()
 }
 
 func (c *Client) getCurrentPayment() map[string]any {
@@ -251,12 +381,20 @@ func (c *Client) GetStateSnapshot() StateSnapshot {
 	c.paymentIDMutex.Unlock()
 	c.statusMutex.Unlock()
 
+	maintenanceItems := c.wear.MaintenanceDue()
+
 	return StateSnapshot{
 		State:            string(state),
 		Message:          message,
 		PaymentID:        paymentID,
 		Payment:          payment,
 		Jammed:           c.jammed.Load(),
+		ActuatorStall:    c.actuatorStalled.Load(),
+		Stopped:          c.stopped.Load(),
+		MaintenanceDue:   len(maintenanceItems) > 0,
+		MaintenanceItems: maintenanceItems,
+		VibratorBudget:   vibratorBudget(),
+		Vision:           c.visionReport(),
 		ExecutingCommand: cmdCopy,
 		PendingCommand:   pendingCopy,
 	}
@@ -267,13 +405,27 @@ func (c *Client) setExecutingCommand(cmd *CommandResponse) {
 	c.statusMutex.Lock()
 	defer c.statusMutex.Unlock()
 	c.executingCommand = cmd
+	c.notifyStateChange()
 }
 
 func (c *Client) setRuntimeState(state RuntimeState, message string) {
+	// A latched emergency stop must stay visible while aborted flows unwind.
+	if c.stopped.Load() && state != StateStopped {
+		return
+	}
 	c.statusMutex.Lock()
-	defer c.statusMutex.Unlock()
+	entered := c.state != state
 	c.state = state
 	c.stateMessage = message
+	if entered {
+		c.recordStateChangeLocked(state, message)
+	}
+	c.statusMutex.Unlock()
+	c.notifyStateChange()
+
+	if entered && c.eventSnapshots != nil {
+		c.eventSnapshots.trigger(state, message)
+	}
 }
 
 func (c *Client) updateExecutingCommandMessage(message string) {
@@ -283,6 +435,8 @@ func (c *Client) updateExecutingCommandMessage(message string) {
 		return
 	}
 	c.executingCommand.Message = message
+	c.markProgress()
+	c.notifyStateChange()
 }
 
 // clearExecutingCommand clears the executing command
@@ -290,6 +444,7 @@ func (c *Client) clearExecutingCommand() {
 	c.statusMutex.Lock()
 	defer c.statusMutex.Unlock()
 	c.executingCommand = nil
+	c.notifyStateChange()
 }
 
 func (c *Client) getPendingCommand() *CommandResponse {
@@ -305,6 +460,7 @@ func (c *Client) getPendingCommand() *CommandResponse {
 func (c *Client) setPendingCommand(cmd *CommandResponse) {
 	c.statusMutex.Lock()
 	defer c.statusMutex.Unlock()
+	defer c.notifyStateChange()
 	if cmd == nil {
 		c.pendingCommand = nil
 		return
@@ -317,6 +473,7 @@ func (c *Client) clearPendingCommand() {
 	c.statusMutex.Lock()
 	defer c.statusMutex.Unlock()
 	c.pendingCommand = nil
+	c.notifyStateChange()
 }
 
 func (c *Client) setPendingBallReference(baseline *uint16) {
@@ -362,7 +519,7 @@ func (c *Client) Start() {
 	if c.config.ActuatorEnabled {
 		log.Println("Device client: homing actuator before startup ball check")
 		c.setRuntimeState(StateStarting, "Homing actuator")
-		actuator.Home()
+		c.homeActuator()
 	}
 
 	if err := c.colorSensor.Init(c.config); err != nil {
@@ -374,17 +531,28 @@ func (c *Client) Start() {
 
 	if c.config.ActuatorEnabled {
 		if err := c.runStartupExtractorCycle(); err != nil {
-			c.setRuntimeState(StateError, "Startup cycle failed")
+			if !c.markActuatorStall(err) {
+				c.setRuntimeState(StateError, "Startup cycle failed")
+			}
 			log.Printf("Device client: startup extractor cycle failed: %v", err)
 		}
 	}
 
-	c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	if !c.actuatorStalled.Load() {
+		c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	}
 
 	if c.logShipper != nil {
 		c.logShipper.start()
 	}
+	if c.eventSnapshots != nil {
+		c.eventSnapshots.start()
+	}
+	if c.timelapse != nil {
+		c.timelapse.start()
+	}
 
+	c.markProgress()
 	c.wg.Add(1)
 	go c.pollLoop()
 	log.Println("Device client started")
@@ -395,8 +563,19 @@ func (c *Client) Stop() {
 	if !c.running.CompareAndSwap(true, false) {
 		return
 	}
+	// Cut motor power first so nothing stays energised while the poll loop unwinds.
+	c.EmergencyStop("shutdown")
 	c.cancel()
 	c.wg.Wait()
+	if c.stateEvents != nil {
+		c.stateEvents.stop()
+	}
+	if c.timelapse != nil {
+		c.timelapse.stop()
+	}
+	if c.eventSnapshots != nil {
+		c.eventSnapshots.stop()
+	}
 	if c.logShipper != nil {
 		c.logShipper.stopAndFlush(3 * time.Second)
 	}
@@ -439,33 +618,83 @@ func (c *Client) pollLoop() {
 			return
 		case <-ticker.C:
 			c.poll()
+			c.markProgress()
 		}
 	}
 }
 
+func (c *Client) markProgress() {
+	c.lastProgress.Store(time.Now().UnixNano())
+}
+
+// progressTick is how often a long wait marks progress for the watchdog.
+var progressTick = 5 * time.Second
+
+// sleepWithProgress waits for d and marks progress every progressTick, so a long
+// wait inside a command does not look like a hung poll loop. It returns early when
+// the client stops.
+func (c *Client) sleepWithProgress(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	ticker := time.NewTicker(progressTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.markProgress()
+		}
+	}
+}
+
+// LastProgress is when the poll loop last finished an iteration or a long-running
+// command last reported progress. It is zero before Start. A poll loop stuck on a
+// hardware call or a lock stops advancing it, which the systemd watchdog relies on.
+func (c *Client) LastProgress() time.Time {
+	nanos := c.lastProgress.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
 // poll performs one iteration of the polling cycle
 func (c *Client) poll() {
-	// 1. Report status
-	paymentID := c.GetPaymentID()
-	if err := c.reportStatus(paymentID); err != nil {
-		log.Printf("Device client: failed to report status: %v", err)
+	c.pollMutex.Lock()
+	defer c.pollMutex.Unlock()
+
+	// While offline the backend is only contacted when a probe is due.
+	if c.IsOffline() && !c.offlineProbeDue() {
+		c.pollOffline()
+		return
 	}
 
-	// If a jam was detected, keep showing the message and skip command execution.
-	if c.jammed.Load() {
-		referenceBaseline := c.consumePendingBallReference()
-		if err := c.waitForBallReady(false, false, referenceBaseline); err != nil {
-			c.setRuntimeState(StateJam, "Stau detektiert")
-		} else {
-			// Jam cleared, continue normal polling and command handling.
-			c.setRuntimeState(StateDetectingBall, "Stau behoben")
-			log.Printf("Device client: jam cleared by passive ball detection")
-		}
+	// 1. Report status; while offline this is the probe. The last undelivered report
+	// of a payment that has been reset since goes first.
+	paymentID := c.GetPaymentID()
+	statusErr := c.replayUnsentReport(paymentID)
+	if statusErr == nil {
+		statusErr = c.reportStatus(paymentID)
+	}
+	c.recordBackendResult(statusErr)
+	if statusErr != nil {
+		log.Printf("Device client: failed to report status: %v", statusErr)
 	}
+	if c.IsOffline() {
+		c.pollOffline()
+		return
+	}
+	c.replayQueuedAcks()
+
+	c.refreshLatchedStates()
 
-	// While jam is active we still allow command polling (e.g. restart), but skip
-	// autonomous state-machine actions until jam is cleared.
-	if !c.jammed.Load() {
+	// While jam, stall or emergency stop is active we still allow command polling
+	// (e.g. restart, estop_reset), but skip autonomous state-machine actions until it
+	// is cleared.
+	if !c.jammed.Load() && !c.actuatorStalled.Load() && !c.stopped.Load() {
 		c.runStateMachineCycle()
 	}
 
@@ -474,11 +703,16 @@ func (c *Client) poll() {
 	var err error
 	if cmd == nil {
 		cmd, err = c.getCommand()
+		c.recordBackendResult(err)
 	}
 	if err != nil {
 		log.Printf("Device client: failed to get command: %v", err)
 		return
 	}
+	if cmd != nil && c.ackQueued(cmd.ID) {
+		// Executed already; the server hands it out until the queued ack arrives.
+		return
+	}
 
 	// 3. If command is not null, execute it
 	if cmd != nil && cmd.Command != "" {
@@ -488,27 +722,70 @@ func (c *Client) poll() {
 		}
 
 		c.clearPendingCommand()
-		c.setRuntimeState(StateCommandExecuting, "Operator-Befehl wird ausgefuhrt")
-		imageData, execErr := c.executeCommand(cmd)
-		if execErr != nil {
-			c.setRuntimeState(StateError, execErr.Error())
-			log.Printf("Device client: failed to execute command %d (%s): %v", cmd.ID, cmd.Command, execErr)
-		}
-
-		// 4. Acknowledge the command with success/failure status
-		if err := c.ackCommand(cmd.ID, execErr, imageData); err != nil {
-			log.Printf("Device client: failed to acknowledge command %d: %v", cmd.ID, err)
-		}
+		c.runCommand(cmd, func(result commandResult, execErr error) {
+			// 4. Acknowledge the command with success/failure status
+			if err := c.ackCommand(cmd.ID, execErr, result); err != nil {
+				log.Printf("Device client: failed to acknowledge command %d: %v", cmd.ID, err)
+			}
+		})
+	}
+}
 
-		c.clearExecutingCommand()
-		if c.jammed.Load() {
+// refreshLatchedStates shows a latched jam, actuator stall or emergency stop. A jam is
+// rechecked passively, without vibration, so a ball that arrives clears it.
+func (c *Client) refreshLatchedStates() {
+	// If a jam was detected, keep showing the message and skip command execution.
+	if c.jammed.Load() {
+		referenceBaseline := c.consumePendingBallReference()
+		if err := c.waitForBallReady(false, false, referenceBaseline); err != nil {
 			c.setRuntimeState(StateJam, "Stau detektiert")
-			return
+		} else {
+			// Jam cleared, continue normal polling and command handling.
+			c.setRuntimeState(StateDetectingBall, "Stau behoben")
+			log.Printf("Device client: jam cleared by passive ball detection")
 		}
-		if c.GetPaymentID() == "" {
-			c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	}
+
+	// A stalled actuator stays latched until an operator restart.
+	if c.actuatorStalled.Load() {
+		c.setRuntimeState(StateActuatorStall, actuatorStallMessage)
+	}
+
+	// An emergency stop stays latched until an explicit estop_reset.
+	if c.stopped.Load() {
+		c.setRuntimeState(StateStopped, emergencyStopMessage)
+	}
+}
+
+// runCommand executes cmd in the command_executing state and restores the runtime state
+// afterwards. done, if set, is called with the outcome while the command is still shown
+// as executing.
+func (c *Client) runCommand(cmd *CommandResponse, done func(commandResult, error)) (commandResult, error) {
+	c.setRuntimeState(StateCommandExecuting, "Operator-Befehl wird ausgefuhrt")
+	endWatch := c.watchRemoteEmergencyStop(cmd.ID)
+	result, execErr := c.executeCommand(cmd)
+	endWatch()
+	if execErr != nil {
+		if !c.markActuatorStall(execErr) {
+			c.setRuntimeState(StateError, execErr.Error())
 		}
+		log.Printf("Device client: failed to execute command %d (%s): %v", cmd.ID, cmd.Command, execErr)
+	}
+	if done != nil {
+		done(result, execErr)
 	}
+
+	c.clearExecutingCommand()
+	switch {
+	case c.stopped.Load():
+	case c.actuatorStalled.Load():
+		c.setRuntimeState(StateActuatorStall, actuatorStallMessage)
+	case c.jammed.Load():
+		c.setRuntimeState(StateJam, "Stau detektiert")
+	case c.GetPaymentID() == "":
+		c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	}
+	return result, execErr
 }
 
 // runStateMachineCycle returns true when the state-driven flow handled this cycle.
@@ -516,23 +793,49 @@ func (c *Client) runStateMachineCycle() bool {
 	paymentID := c.GetPaymentID()
 	if paymentID == "" {
 		c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+		c.ballReady.Store(false)
 		referenceBaseline := c.consumePendingBallReference()
 		if err := c.waitForBallReady(true, true, referenceBaseline); err != nil {
 			log.Printf("Device client: ball detection failed: %v", err)
 			return true
 		}
 		c.setRuntimeState(StateBallOnSensor, "Ball auf Sensor erkannt")
-		if _, err := c.createPayment(); err != nil {
+		c.ballReady.Store(true)
+		if _, err := c.startPayment(); err != nil {
+			if errors.Is(err, ErrPaymentInProgress) {
+				// The local API created the payment first.
+				return true
+			}
+			if c.IsOffline() {
+				c.setRuntimeState(StateOffline, offlineMessage)
+				log.Printf("Device client: failed to create payment after ball detection: %v", err)
+				return true
+			}
 			c.setRuntimeState(StateError, "Payment konnte nicht erstellt werden")
 			log.Printf("Device client: failed to create payment after ball detection: %v", err)
 			return false
 		}
-		c.setRuntimeState(StateBallDetected, "Bitte QR-Code scannen und Betrag wählen")
 		return true
 	}
 
+	// Past the local deadline this fetch is the final recheck: a payment the backend
+	// still cannot resolve is reset instead of blocking the kiosk.
+	overdue := c.paymentOverdue(c.getCurrentPayment())
+	if overdue != "" {
+		log.Printf("Device client: payment %s passed its local deadline (%s), rechecking status", paymentID, overdue)
+	}
 	status, payment, err := c.getPaymentStatus(paymentID)
+	c.recordBackendResult(err)
 	if err != nil {
+		if overdue != "" {
+			c.expirePayment(paymentID, overdue, "", err)
+			return true
+		}
+		if c.IsOffline() {
+			c.setRuntimeState(StateOffline, offlineMessage)
+			log.Printf("Device client: failed to fetch payment status for %s: %v", paymentID, err)
+			return true
+		}
 		c.setRuntimeState(StateError, "Payment-Status nicht verfugbar")
 		log.Printf("Device client: failed to fetch payment status for %s: %v", paymentID, err)
 		return true
@@ -543,6 +846,11 @@ func (c *Client) runStateMachineCycle() bool {
 
 	switch status {
 	case "waiting", "pending", "open":
+		// The backend may have moved the deadline, so check the fresh payment.
+		if overdue := c.paymentOverdue(c.getCurrentPayment()); overdue != "" {
+			c.expirePayment(paymentID, overdue, status, nil)
+			return true
+		}
 		if phase == "waiting_for_payment" {
 			c.setRuntimeState(StateAwaitingPayment, "Warten auf Zahlung")
 			c.setExecutingCommand(&CommandResponse{
@@ -564,8 +872,13 @@ func (c *Client) runStateMachineCycle() bool {
 		// If this payment was already dispensed in a prior cycle, do not dispense again.
 		// Just keep retrying status report until the dispense count is successfully sent.
 		if pending := c.pendingDispensedCount(paymentID); pending == nil {
-			if _, err := c.DispenseAndWaitForBall(); err != nil {
-				c.setRuntimeState(StateError, "Ausgabe fehlgeschlagen")
+			endWatch := c.watchRemoteEmergencyStop(0)
+			_, err := c.DispenseAndWaitForBall()
+			endWatch()
+			if err != nil {
+				if !c.markActuatorStall(err) {
+					c.setRuntimeState(StateError, "Ausgabe fehlgeschlagen")
+				}
 				log.Printf("Device client: dispense failed after successful payment: %v", err)
 				return true
 			}
@@ -585,15 +898,7 @@ func (c *Client) runStateMachineCycle() bool {
 		c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
 		return true
 	case "failure", "failed", "cancelled", "canceled", "expired", "timeout":
-		c.setRuntimeState(StatePaymentFailed, "Zahlung fehlgeschlagen")
-		c.setExecutingCommand(&CommandResponse{
-			Command: "message",
-			Message: "Zahlung abgebrochen - zurückgesetzt",
-		})
-		c.SetPaymentID("")
-		time.Sleep(500 * time.Millisecond)
-		c.clearExecutingCommand()
-		c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+		c.failPayment()
 		return true
 	default:
 		c.setRuntimeState(StateError, "Unbekannter Payment-Status")
@@ -604,7 +909,6 @@ func (c *Client) runStateMachineCycle() bool {
 
 // reportStatus sends the current payment ID to the server
 func (c *Client) reportStatus(paymentID string) error {
-	url := c.buildURL("/api/v1/device/status")
 	dispensedCount := c.pendingDispensedCount(paymentID)
 	if dispensedCount == nil {
 		// Always send dispensed_count: backend requires the field.
@@ -621,6 +925,16 @@ func (c *Client) reportStatus(paymentID string) error {
 		ClientVersion:  version.AppVersion,
 		DispensedCount: dispensedCount,
 	}
+	if c.wear != nil {
+		report := c.wear.Report()
+		req.Wear = &report
+	}
+	if c.jamClear != nil {
+		report := c.jamClear.Report()
+		req.JamClear = &report
+	}
+	req.VibratorBudget = vibratorBudget()
+	req.Vision = c.visionReport()
 
 	paymentLabel := "<none>"
 	if requestPaymentID != nil {
@@ -628,6 +942,15 @@ func (c *Client) reportStatus(paymentID string) error {
 	}
 	log.Printf("Device client: reporting status payment_id=%s dispensed_count=%d", paymentLabel, *dispensedCount)
 
+	err := c.postStatus(req)
+	c.trackUnsentReport(req, err)
+	return err
+}
+
+// postStatus sends one status report.
+func (c *Client) postStatus(req StatusRequest) error {
+	url := c.buildURL("/api/v1/device/status")
+
 	body, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal status request: %w", err)
@@ -654,7 +977,7 @@ func (c *Client) reportStatus(paymentID string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -776,7 +1099,7 @@ func (c *Client) getCommand() (*CommandResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return nil, &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -801,11 +1124,7 @@ func (c *Client) createPayment() (string, error) {
 	url := c.buildURL("/api/v1/payment")
 	c.setRuntimeState(StateBallDetected, "Erstelle Zahlung")
 
-	req := paymentCreateRequest{
-		Currency:           "CHF",
-		PaymentRedirectURL: "https://example.com/payments/complete",
-	}
-	body, err := json.Marshal(req)
+	body, err := json.Marshal(c.paymentRequest)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal payment request: %w", err)
 	}
@@ -831,7 +1150,7 @@ func (c *Client) createPayment() (string, error) {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return "", &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -888,7 +1207,7 @@ func (c *Client) getPaymentStatus(paymentID string) (string, map[string]any, err
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return "", nil, &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -911,13 +1230,25 @@ func (c *Client) getPaymentStatus(paymentID string) (string, map[string]any, err
 
 // executeCommand executes the command using the actuator.
 // Returns an optional base64-encoded JPEG image (non-empty only for take_picture on success) and an error.
-func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
+func (c *Client) executeCommand(cmd *CommandResponse) (commandResult, error) {
 	if cmd == nil || cmd.Command == "" {
-		return "", nil
+		return commandResult{}, nil
 	}
 
 	const cancelHoldDuration = 300 * time.Millisecond
 
+	// A cancel stops a running movement (e.g. a local dispense) before waiting for the lock.
+	if strings.EqualFold(strings.TrimSpace(cmd.Command), "cancel") {
+		c.cancelMovement()
+	}
+
+	// An estop must never wait for the lock held by the movement it has to stop.
+	if strings.EqualFold(strings.TrimSpace(cmd.Command), "estop") {
+		c.setExecutingCommand(cmd)
+		c.EmergencyStop(fmt.Sprintf("remote command %d", cmd.ID))
+		return commandResult{}, nil
+	}
+
 	// Acquire lock - blocks if another command is executing
 	c.actuatorMutex.Lock()
 	defer c.actuatorMutex.Unlock()
@@ -936,45 +1267,82 @@ func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
 
 	switch strings.ToLower(cmd.Command) {
 	case "extend":
-		err := actuator.Extend(duration)
+		ctx, done := c.movementContext()
+		err := c.actuator.Extend(ctx, duration)
+		done()
 		if err != nil {
 			log.Printf("Device client: failed to execute command %d (%s): %v", cmd.ID, cmd.Command, err)
+			c.retractAfterCancel(err)
 		}
-		return "", err
+		return commandResult{}, err
 	case "retract":
-		err := actuator.Retract(duration)
+		ctx, done := c.movementContext()
+		err := c.actuator.Retract(ctx, duration)
+		done()
 		if err != nil {
 			log.Printf("Device client: failed to execute command %d (%s): %v", cmd.ID, cmd.Command, err)
 		}
-		return "", err
+		return commandResult{}, err
 	case "home":
-		actuator.Home()
-		return "", nil
+		ctx, done := c.movementContext()
+		err := c.actuator.Home(ctx)
+		done()
+		if err != nil {
+			log.Printf("Device client: failed to execute command %d (%s): %v", cmd.ID, cmd.Command, err)
+		}
+		return commandResult{}, err
 	case "message":
 		// Message command: display in UI for specified duration
 		log.Printf("Device client: displaying message: %s for %v", cmd.Message, duration)
-		// Sleep for the duration to keep the message visible in UI
-		time.Sleep(duration)
-		return "", nil
+		// Keep the message visible in the UI for the duration
+		c.sleepWithProgress(duration)
+		return commandResult{}, nil
 	case "cancel":
 		log.Printf("Device client: cancel command received, clearing current payment")
 		c.SetPaymentID("")
 		// Keep the command visible to the UI briefly.
 		time.Sleep(cancelHoldDuration)
-		return "", nil
+		return commandResult{}, nil
+	case "estop_reset":
+		log.Printf("Device client: estop_reset command received")
+		if err := c.resetEmergencyStopLocked(); err != nil {
+			log.Printf("Device client: estop_reset command failed: %v", err)
+			return commandResult{}, err
+		}
+		return commandResult{}, nil
+	case "maintenance_reset":
+		log.Printf("Device client: maintenance_reset command received (component=%q)", cmd.Component)
+		if err := c.wear.Reset(strings.ToLower(strings.TrimSpace(cmd.Component))); err != nil {
+			log.Printf("Device client: maintenance_reset command failed: %v", err)
+			return commandResult{}, err
+		}
+		return commandResult{}, nil
+	case "jam_strategy":
+		log.Printf("Device client: jam_strategy command received (strategy=%q)", cmd.Strategy)
+		if c.jamClear == nil {
+			err := errors.New("jam clearing engine unavailable")
+			log.Printf("Device client: jam_strategy command failed: %v", err)
+			return commandResult{}, err
+		}
+		if err := c.jamClear.SetStrategy(strings.ToLower(strings.TrimSpace(cmd.Strategy))); err != nil {
+			log.Printf("Device client: jam_strategy command failed: %v", err)
+			return commandResult{}, err
+		}
+		log.Printf("Device client: jam-clearing strategy set to %s (order %v)", c.jamClear.Strategy(), c.jamClear.Order())
+		return commandResult{}, nil
 	case "restart":
 		log.Printf("Device client: restart command received, resetting state machine")
 		if err := c.restartStateMachine(); err != nil {
 			log.Printf("Device client: restart command failed: %v", err)
-			return "", err
+			return commandResult{}, err
 		}
-		return "", nil
+		return commandResult{}, nil
 	case "ball_dispenser":
 		log.Printf("Device client: ball dispenser cycle requested")
-		_, err := c.dispenseAndWaitForBallLocked()
+		_, err := c.dispenseAndWaitForBallLocked(c.ctx, nil)
 		if err != nil {
 			log.Printf("Device client: ball dispenser failed: %v", err)
-			return "", err
+			return commandResult{}, err
 		}
 		paymentID := c.GetPaymentID()
 		if paymentID != "" {
@@ -982,13 +1350,13 @@ func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
 		} else {
 			log.Printf("Device client: ball dispenser cycle complete (no active payment)")
 		}
-		return "", nil
+		return commandResult{}, nil
 	case "load_test":
 		const defaultLoadTestCycles = 15
 		loadTestCycles := defaultLoadTestCycles
 		if cmd.RepeatCount != nil {
 			if *cmd.RepeatCount < 0 {
-				return "", fmt.Errorf("load_test command: repeat_count must be >= 0, got %d", *cmd.RepeatCount)
+				return commandResult{}, fmt.Errorf("load_test command: repeat_count must be >= 0, got %d", *cmd.RepeatCount)
 			}
 			loadTestCycles = *cmd.RepeatCount
 		}
@@ -1013,10 +1381,11 @@ func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
 			referenceBaseline := c.sampleBallReferenceBaseline("load_test")
 
 			log.Printf("Device client: load test cycle %d/%d starting dispense", i, loadTestCycles)
-			cycleActuatorMs, beamCuts, err := c.triggerWithBreakBeamCount()
+			cycleActuatorMs, beamCuts, err := c.triggerWithBreakBeamCount(c.ctx)
 			if err != nil {
 				log.Printf("Device client: load test failed on cycle %d during dispense: %v", i, err)
-				return "", err
+				c.retractAfterCancel(err)
+				return commandResult{}, err
 			}
 
 			totalActuatorMs += cycleActuatorMs
@@ -1042,9 +1411,9 @@ func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
 
 			c.recordDispensedCount(paymentID, beamCuts)
 
-			if err := c.waitForBallReady(true, true, referenceBaseline); err != nil {
+			if err := c.waitForBallReadyContext(withActuatorLock(c.ctx), true, true, referenceBaseline, nil); err != nil {
 				log.Printf("Device client: load test failed on cycle %d after dispense verification: %v (beam_cuts=%d total_ms=%d)", i, err, beamCuts, cycleActuatorMs)
-				return "", err
+				return commandResult{}, err
 			}
 
 			log.Printf("Device client: load test cycle %d/%d verification complete", i, loadTestCycles)
@@ -1059,20 +1428,23 @@ func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
 			avgActuatorMs = float64(totalActuatorMs) / float64(loadTestCycles)
 		}
 		log.Printf("Device client: load test complete cycles=%d total_beam_cuts=%d zero_cut_cycles=%d avg_beam_cuts=%.2f min_beam_cuts=%d max_beam_cuts=%d avg_actuator_ms=%.1f min_actuator_ms=%d max_actuator_ms=%d", loadTestCycles, totalBeamCuts, zeroCutCycles, avgBeamCuts, minBeamCuts, maxBeamCuts, avgActuatorMs, minActuatorMs, maxActuatorMs)
-		return "", nil
+		return commandResult{}, nil
 	case "vibrate":
+		if hasVibrationPattern(cmd) {
+			return c.executeVibrationPattern(cmd)
+		}
 		// Vibrate command: validate percent and duration_ms, then buzz vibrator
 		if cmd.Percent == nil {
-			return "", fmt.Errorf("vibrate command missing required field: percent")
+			return commandResult{}, fmt.Errorf("vibrate command missing required field: percent")
 		}
 		if *cmd.Percent < 1 || *cmd.Percent > 100 {
-			return "", fmt.Errorf("vibrate command: percent must be between 1 and 100, got %d", *cmd.Percent)
+			return commandResult{}, fmt.Errorf("vibrate command: percent must be between 1 and 100, got %d", *cmd.Percent)
 		}
 		if cmd.DurationMs == nil {
-			return "", fmt.Errorf("vibrate command missing required field: duration_ms")
+			return commandResult{}, fmt.Errorf("vibrate command missing required field: duration_ms")
 		}
 		if *cmd.DurationMs < 100 || *cmd.DurationMs > 60000 {
-			return "", fmt.Errorf("vibrate command: duration_ms must be between 100 and 60000, got %d", *cmd.DurationMs)
+			return commandResult{}, fmt.Errorf("vibrate command: duration_ms must be between 100 and 60000, got %d", *cmd.DurationMs)
 		}
 		intensity := float64(*cmd.Percent) / 100.0
 		dur := time.Duration(*cmd.DurationMs) * time.Millisecond
@@ -1081,53 +1453,76 @@ func (c *Client) executeCommand(cmd *CommandResponse) (string, error) {
 		if err != nil {
 			log.Printf("Device client: failed to execute command %d (%s): %v", cmd.ID, cmd.Command, err)
 		}
-		return "", err
+		return commandResult{}, err
+	case "vision_calibrate":
+		log.Printf("Device client: vision_calibrate command received (reference=%q)", cmd.Reference)
+		result, err := c.executeVisionCalibrate(cmd)
+		if err != nil {
+			log.Printf("Device client: vision_calibrate command failed: %v", err)
+		}
+		return result, err
+	case "list_pictures":
+		result, err := c.executeListPictures(cmd)
+		if err != nil {
+			log.Printf("Device client: list_pictures command failed: %v", err)
+		}
+		return result, err
+	case "get_picture":
+		log.Printf("Device client: get_picture command received (picture=%q)", cmd.Picture)
+		result, err := c.executeGetPicture(cmd)
+		if err != nil {
+			log.Printf("Device client: get_picture command failed: %v", err)
+		}
+		return result, err
 	case "take_picture":
 		log.Printf("Device client: take_picture command received")
-		imgBytes, err := camera.Capture()
+		var opts camera.Options
+		if cmd.Capture != nil {
+			opts = *cmd.Capture
+		}
+		images, err := camera.CaptureWithOptions(opts)
 		if err != nil {
 			log.Printf("Device client: failed to capture image: %v", err)
-			return "", err
+			return commandResult{}, err
 		}
-		imageBase64 := base64.StdEncoding.EncodeToString(imgBytes)
-		log.Printf("Device client: image captured (%d bytes)", len(imgBytes))
-		return imageBase64, nil
+		result := commandResult{imageBase64: base64.StdEncoding.EncodeToString(images[0])}
+		if len(images) > 1 {
+			for _, image := range images {
+				result.images = append(result.images, base64.StdEncoding.EncodeToString(image))
+			}
+		}
+		log.Printf("Device client: %d image(s) captured (%d bytes first)", len(images), len(images[0]))
+		return result, nil
 	default:
-		return "", fmt.Errorf("unknown command: %s", cmd.Command)
+		return commandResult{}, fmt.Errorf("unknown command: %s", cmd.Command)
 	}
 }
 
 // ackCommand acknowledges a command to the server.
-// imageData is the base64-encoded JPEG image, required for a successful take_picture ack.
-func (c *Client) ackCommand(commandID int, execErr error, imageData string) error {
-	url := c.buildURL(fmt.Sprintf("/api/v1/device/commands/%d/ack", commandID))
-
-	// Determine status and error message
-	status := "success"
-	errorMsg := ""
-	if execErr != nil {
-		status = "failed"
-		errorMsg = execErr.Error()
-		// Truncate to 1000 chars max
-		if len(errorMsg) > 1000 {
-			errorMsg = errorMsg[:1000]
-		}
+// result carries command-specific ack fields such as the take_picture image.
+// An ack the backend could not take is queued and replayed once it answers again.
+func (c *Client) ackCommand(commandID int, execErr error, result commandResult) error {
+	ack := newAckRequest(execErr, result)
+	retry, err := c.postAck(commandID, ack)
+	if err != nil && retry {
+		c.queueAck(commandID, ack)
 	}
+	return err
+}
 
-	req := AckRequest{
-		Status:       status,
-		ErrorMessage: errorMsg,
-		ImageBase64:  imageData,
-	}
+// postAck sends one ack. retry reports whether the failure was a network error or a
+// server error, after which the same ack may be sent again.
+func (c *Client) postAck(commandID int, ack AckRequest) (retry bool, err error) {
+	url := c.buildURL(fmt.Sprintf("/api/v1/device/commands/%d/ack", commandID))
 
-	body, err := json.Marshal(req)
+	body, err := json.Marshal(ack)
 	if err != nil {
-		return fmt.Errorf("failed to marshal ack request: %w", err)
+		return false, fmt.Errorf("failed to marshal ack request: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(c.ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setAuthHeader(httpReq)
@@ -1136,39 +1531,63 @@ func (c *Client) ackCommand(commandID int, execErr error, imageData string) erro
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return true, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("unauthorized: invalid or missing API key")
+		return false, fmt.Errorf("unauthorized: invalid or missing API key")
 	}
 
 	if resp.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("command not found or belongs to different device")
+		return false, fmt.Errorf("command not found or belongs to different device")
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		err := &statusError{code: resp.StatusCode, body: string(body)}
+		return isConnectivityError(err), err
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return true, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	var ackResp AckResponse
 	if err := decodeJSONResponse(respBody, &ackResp, resp.Header.Get("Content-Type")); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		return false, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if !ackResp.Success {
-		return fmt.Errorf("server returned success=false")
+		return false, fmt.Errorf("server returned success=false")
 	}
 
 	log.Printf("Device client: acknowledged command %d", commandID)
-	return nil
+	return false, nil
+}
+
+// newAckRequest builds the ack payload for a command outcome.
+func newAckRequest(execErr error, result commandResult) AckRequest {
+	status := "success"
+	errorMsg := ""
+	if execErr != nil {
+		status = "failed"
+		errorMsg = execErr.Error()
+		// Truncate to 1000 chars max
+		if len(errorMsg) > 1000 {
+			errorMsg = errorMsg[:1000]
+		}
+	}
+
+	return AckRequest{
+		Status:       status,
+		ErrorMessage: errorMsg,
+		ImageBase64:  result.imageBase64,
+		Images:       result.images,
+		Vibration:    result.vibration,
+		Pictures:     result.pictures,
+	}
 }
 
 // buildURL constructs the full API URL
@@ -1218,6 +1637,13 @@ func mapKeyList(payload map[string]any) string {
 // When showWaitingMessage is true, it displays a waiting overlay while scanning.
 // When allowVibration is false, scanning is passive and never triggers vibrator bursts.
 func (c *Client) waitForBallReady(showWaitingMessage bool, allowVibration bool, referenceBaseline *uint16) error {
+	return c.waitForBallReadyContext(c.ctx, showWaitingMessage, allowVibration, referenceBaseline, nil)
+}
+
+// waitForBallReadyContext is waitForBallReady for a caller that can give up: ctx ends
+// the colour sensor detection before its next attempt, and onAttempt sees each attempt.
+// A cancelled detection is neither a jam nor an empty funnel.
+func (c *Client) waitForBallReadyContext(ctx context.Context, showWaitingMessage bool, allowVibration bool, referenceBaseline *uint16, onAttempt colorsensor.AttemptObserver) error {
 	if c.config != nil && c.config.DebugBypassBallDetection {
 		log.Printf("Device client: DEBUG_BYPASS_BALL_DETECTION enabled - skipping physical ball detection")
 		c.jammed.Store(false)
@@ -1244,21 +1670,41 @@ func (c *Client) waitForBallReady(showWaitingMessage bool, allowVibration bool,
 		})
 	}
 
-	var observer colorsensor.AttemptObserver
-	if showWaitingMessage {
-		observer = func(attempt int, maxAttempts int) {
+	observer := func(attempt int, maxAttempts int) {
+		if showWaitingMessage {
 			c.updateExecutingCommandMessage(fmt.Sprintf("Waiting for Ball Release (%d/%d)", attempt, maxAttempts))
 		}
+		if onAttempt != nil {
+			onAttempt(attempt, maxAttempts)
+		}
 	}
 
-	detectionSource, err := c.waitForBallReadyAttempt(allowVibration, referenceBaseline, observer)
+	detectionSource, err := c.waitForBallReadyAttempt(ctx, allowVibration, referenceBaseline, observer)
+	if err != nil && ctx.Err() != nil {
+		if referenceBaseline != nil {
+			c.setPendingBallReference(referenceBaseline)
+		}
+		if showWaitingMessage {
+			c.clearExecutingCommand()
+		}
+		return err
+	}
 	if err != nil {
 		if referenceBaseline != nil {
 			// Keep a viable reference around for jam recovery scans.
 			c.setPendingBallReference(referenceBaseline)
 		}
-		log.Printf("Device client: ball not detected — showing jam message")
 		c.jammed.Store(true)
+		if errors.Is(err, errFunnelEmpty) {
+			log.Printf("Device client: ball not detected, funnel empty — showing refill message")
+			c.setRuntimeState(StateBallStuckFunnel, "Trichter leer")
+			c.setExecutingCommand(&CommandResponse{
+				Command: "message",
+				Message: "Trichter leer. Rufe eine Techniker*in zum Nachfüllen.",
+			})
+			return err
+		}
+		log.Printf("Device client: ball not detected — showing jam message")
 		c.setRuntimeState(StateBallStuckFunnel, "Ball steckt im Trichter")
 		c.setExecutingCommand(&CommandResponse{
 			Command: "message",
@@ -1287,7 +1733,7 @@ func (c *Client) waitForBallReady(showWaitingMessage bool, allowVibration bool,
 	return nil
 }
 
-func (c *Client) waitForBallReadyAttempt(allowVibration bool, referenceBaseline *uint16, observer colorsensor.AttemptObserver) (string, error) {
+func (c *Client) waitForBallReadyAttempt(ctx context.Context, allowVibration bool, referenceBaseline *uint16, observer colorsensor.AttemptObserver) (string, error) {
 	if c.detectBreakBeamDuringWindow() {
 		if c.config.BreakBeamDebugLogging {
 			log.Println("Break-beam: interrupted during detect window, confirming ball presence")
@@ -1295,17 +1741,22 @@ func (c *Client) waitForBallReadyAttempt(allowVibration bool, referenceBaseline
 		return "break-beam", nil
 	}
 
-	if allowVibration {
-		if referenceBaseline != nil {
-			return "color-sensor", colorsensor.WaitForBallWithReferenceBaseline(c.colorSensor, vibratorAdapter{}, c.config, log.Default(), observer, *referenceBaseline)
-		}
-		return "color-sensor", colorsensor.WaitForBall(c.colorSensor, vibratorAdapter{}, c.config, log.Default(), observer)
+	if c.visionAnalyzer != nil && (c.colorSensor == nil || !c.colorSensor.IsEnabled()) {
+		return "vision", c.visionBallReady()
 	}
 
-	if referenceBaseline != nil {
-		return "color-sensor", colorsensor.WaitForBallWithReferenceBaseline(c.colorSensor, nil, c.config, log.Default(), observer, *referenceBaseline)
+	err := c.waitForBallOnColorSensor(ctx, allowVibration, referenceBaseline, observer)
+	if err != nil && ctx.Err() == nil && c.visionAnalyzer != nil {
+		err = c.visionSecondOpinion(err)
+	}
+	return "color-sensor", err
+}
+
+func (c *Client) waitForBallOnColorSensor(ctx context.Context, allowVibration bool, referenceBaseline *uint16, observer colorsensor.AttemptObserver) error {
+	if allowVibration {
+		return colorsensor.WaitForBallContext(ctx, c.colorSensor, c.jamClearer(ctx), c.config, log.Default(), observer, referenceBaseline)
 	}
-	return "color-sensor", colorsensor.WaitForBall(c.colorSensor, nil, c.config, log.Default(), observer)
+	return colorsensor.WaitForBallContext(ctx, c.colorSensor, nil, c.config, log.Default(), observer, referenceBaseline)
 }
 
 func (c *Client) detectBreakBeamDuringWindow() bool {
@@ -1370,9 +1821,12 @@ func (c *Client) isBreakBeamInterrupted() bool {
 	return interrupted
 }
 
-func (c *Client) triggerWithBreakBeamCount() (int, int, error) {
+func (c *Client) triggerWithBreakBeamCount(parent context.Context) (int, int, error) {
+	ctx, done := c.movementContextFrom(parent)
+	defer done()
+
 	if c.breakBeamSensor == nil || !c.breakBeamSensor.IsEnabled() {
-		totalMs, err := actuator.Trigger()
+		totalMs, err := c.actuator.Trigger(ctx)
 		return totalMs, 1, err
 	}
 
@@ -1383,7 +1837,7 @@ func (c *Client) triggerWithBreakBeamCount() (int, int, error) {
 
 	resultCh := make(chan triggerResult, 1)
 	go func() {
-		totalMs, err := actuator.Trigger()
+		totalMs, err := c.actuator.Trigger(ctx)
 		resultCh <- triggerResult{totalMs: totalMs, err: err}
 	}()
 
@@ -1427,6 +1881,13 @@ func (c *Client) triggerWithBreakBeamCount() (int, int, error) {
 }
 
 func (c *Client) runStartupExtractorCycle() error {
+	if c.stopped.Load() {
+		return fmt.Errorf("%w: skipping startup cycle", actuator.ErrEmergencyStop)
+	}
+	if c.actuatorStalled.Load() {
+		return fmt.Errorf("%w: skipping startup cycle", actuator.ErrStall)
+	}
+
 	c.setRuntimeState(StateStartupCycle, "Initialzyklus laeuft")
 	c.setExecutingCommand(&CommandResponse{
 		Command: "message",
@@ -1438,7 +1899,10 @@ func (c *Client) runStartupExtractorCycle() error {
 	// used to detect the same settled-presence state without requiring motion.
 	c.captureStartupBallReferenceBaseline()
 
-	if _, err := actuator.Trigger(); err != nil {
+	ctx, done := c.movementContext()
+	_, err := c.actuator.Trigger(ctx)
+	done()
+	if err != nil {
 		c.clearExecutingCommand()
 		return err
 	}
@@ -1447,19 +1911,85 @@ func (c *Client) runStartupExtractorCycle() error {
 	return nil
 }
 
+// movementContext returns a context for one actuator movement. It is cancelled by
+// Stop, by cancelMovement or when the returned done func is called.
+func (c *Client) movementContext() (context.Context, func()) {
+	return c.movementContextFrom(c.ctx)
+}
+
+// movementContextFrom is movementContext for a movement that also ends with parent.
+func (c *Client) movementContextFrom(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	m := &movement{cancel: cancel}
+	c.movementMutex.Lock()
+	c.movement = m
+	c.movementMutex.Unlock()
+
+	return ctx, func() {
+		c.movementMutex.Lock()
+		if c.movement == m {
+			c.movement = nil
+		}
+		c.movementMutex.Unlock()
+		cancel()
+	}
+}
+
+// cancelMovement stops the running actuator movement, if any, and reports whether
+// one was running.
+func (c *Client) cancelMovement() bool {
+	c.movementMutex.Lock()
+	defer c.movementMutex.Unlock()
+	if c.movement == nil {
+		return false
+	}
+	log.Printf("Device client: cancelling running actuator movement")
+	c.movement.cancel()
+	c.movement = nil
+	return true
+}
+
+// homeActuator homes the actuator; a stall or overload latches actuator_stall.
+func (c *Client) homeActuator() {
+	ctx, done := c.movementContext()
+	defer done()
+	if err := c.actuator.Home(ctx); err != nil {
+		log.Printf("Device client: actuator homing failed: %v", err)
+		c.markActuatorStall(err)
+	}
+}
+
+// retractAfterCancel homes the actuator after a cancelled movement, so a cancelled
+// dispense never leaves the plunger extended. Nothing moves after an emergency stop or
+// while the client stops. The caller holds actuatorMutex.
+func (c *Client) retractAfterCancel(err error) {
+	if !errors.Is(err, context.Canceled) || c.stopped.Load() || c.ctx.Err() != nil {
+		return
+	}
+	log.Printf("Device client: movement cancelled, retracting the actuator")
+	c.homeActuator()
+}
+
 func (c *Client) restartStateMachine() error {
+	if c.stopped.Load() {
+		return fmt.Errorf("%w: estop_reset required before restart", actuator.ErrEmergencyStop)
+	}
+
 	// Clear runtime/session state before running startup steps again.
 	c.SetPaymentID("")
 	c.clearPendingCommand()
 	c.setPendingBallReference(nil)
 	c.jammed.Store(false)
+	c.actuatorStalled.Store(false)
 
 	if c.config.ActuatorEnabled {
 		c.setRuntimeState(StateStarting, "Homing actuator")
-		actuator.Home()
+		c.homeActuator()
 
 		if err := c.runStartupExtractorCycle(); err != nil {
-			c.setRuntimeState(StateError, "Startup cycle failed")
+			if !c.markActuatorStall(err) {
+				c.setRuntimeState(StateError, "Startup cycle failed")
+			}
 			return err
 		}
 	}
@@ -1515,16 +2045,30 @@ func (c *Client) DispenseAndWaitForBall() (int, error) {
 	c.actuatorMutex.Lock()
 	defer c.actuatorMutex.Unlock()
 
-	return c.dispenseAndWaitForBallLocked()
+	return c.dispenseAndWaitForBallLocked(c.ctx, nil)
 }
 
-func (c *Client) dispenseAndWaitForBallLocked() (int, error) {
+func (c *Client) dispenseAndWaitForBallLocked(ctx context.Context, progress func(DispenseProgress)) (int, error) {
+	if progress == nil {
+		progress = func(DispenseProgress) {}
+	}
+	if c.stopped.Load() {
+		return 0, fmt.Errorf("%w: estop_reset required before dispensing", actuator.ErrEmergencyStop)
+	}
+	if c.actuatorStalled.Load() {
+		return 0, fmt.Errorf("%w: restart required before dispensing", actuator.ErrStall)
+	}
 
 	paymentID := c.GetPaymentID()
 	referenceBaseline := c.sampleBallReferenceBaseline("post-dispense")
 
-	totalMs, beamCuts, err := c.triggerWithBreakBeamCount()
+	movementCtx := actuator.WithPhaseObserver(ctx, func(phase string) {
+		progress(DispenseProgress{Stage: phase})
+	})
+	totalMs, beamCuts, err := c.triggerWithBreakBeamCount(movementCtx)
 	if err != nil {
+		c.markActuatorStall(err)
+		c.retractAfterCancel(err)
 		return 0, err
 	}
 
@@ -1536,7 +2080,11 @@ func (c *Client) dispenseAndWaitForBallLocked() (int, error) {
 		log.Printf("Device client: dispense beam cut count=%d", beamCuts)
 	}
 
-	if err := c.waitForBallReady(true, true, referenceBaseline); err != nil {
+	progress(DispenseProgress{Stage: DispenseDetecting})
+	onAttempt := func(attempt, maxAttempts int) {
+		progress(DispenseProgress{Stage: DispenseDetecting, Attempt: attempt, MaxAttempts: maxAttempts})
+	}
+	if err := c.waitForBallReadyContext(withActuatorLock(ctx), true, true, referenceBaseline, onAttempt); err != nil {
 		return totalMs, err
 	}
 
@@ -1616,7 +2164,7 @@ func (c *Client) canExecuteCommandNow(cmd *CommandResponse) bool {
 
 	// These commands are always safe to execute immediately.
 	switch command {
-	case "message", "take_picture", "cancel", "restart":
+	case "message", "take_picture", "cancel", "restart", "estop", "estop_reset", "maintenance_reset", "jam_strategy", "vision_calibrate", "list_pictures", "get_picture":
 		return true
 	}
 
@@ -1633,7 +2181,7 @@ func (c *Client) canExecuteCommandNow(cmd *CommandResponse) bool {
 }
 
 func (c *Client) isCleanCommandState() bool {
-	if c.jammed.Load() {
+	if c.jammed.Load() || c.actuatorStalled.Load() || c.stopped.Load() {
 		return false
 	}
 
@@ -1648,7 +2196,7 @@ func (c *Client) isCleanCommandState() bool {
 }
 
 func (c *Client) isActuationCommandState() bool {
-	if c.jammed.Load() {
+	if c.jammed.Load() || c.actuatorStalled.Load() || c.stopped.Load() {
 		return false
 	}
 
@@ -1666,13 +2214,104 @@ func (c *Client) isActuationCommandState() bool {
 	defer c.statusMutex.Unlock()
 
 	switch c.state {
-	case StateStarting, StateStartupCycle, StateDispensing, StateCommandExecuting, StateError, StateJam:
+	case StateStarting, StateStartupCycle, StateDispensing, StateCommandExecuting, StateError, StateJam, StateActuatorStall, StateStopped:
 		return false
 	default:
 		return true
 	}
 }
 
+// markActuatorStall latches the actuator_stall state when err reports a motor stall or
+// overload and reports whether it did. Only a restart clears the latch.
+func (c *Client) markActuatorStall(err error) bool {
+	if !errors.Is(err, actuator.ErrStall) {
+		return false
+	}
+	if c.actuatorStalled.CompareAndSwap(false, true) {
+		log.Printf("Device client: %v, halting actuator until restart", err)
+	}
+	c.setRuntimeState(StateActuatorStall, actuatorStallMessage)
+	return true
+}
+
+// EmergencyStop cuts power to the actuator and vibrator immediately, aborts the running
+// movement and latches the stopped state until ResetEmergencyStop or an estop_reset
+// command. It never waits for the actuator lock, so it is safe to call while a
+// movement runs.
+func (c *Client) EmergencyStop(reason string) {
+	if err := c.actuator.EmergencyStop(); err != nil {
+		log.Printf("Device client: actuator emergency stop failed: %v", err)
+	}
+	vibrator.EmergencyStop()
+	c.cancelMovement()
+
+	if c.stopped.CompareAndSwap(false, true) {
+		log.Printf("Device client: EMERGENCY STOP (%s), halting until estop_reset", reason)
+	}
+	c.setRuntimeState(StateStopped, emergencyStopMessage)
+}
+
+// ResetEmergencyStop releases a latched emergency stop and restarts the state machine.
+// The actuator position is unknown after a stop, so the restart homes it first.
+func (c *Client) ResetEmergencyStop() error {
+	c.actuatorMutex.Lock()
+	defer c.actuatorMutex.Unlock()
+
+	return c.resetEmergencyStopLocked()
+}
+
+func (c *Client) resetEmergencyStopLocked() error {
+	if !c.stopped.Load() {
+		return nil
+	}
+	c.actuator.ResetEmergencyStop()
+	vibrator.ResetEmergencyStop()
+	c.stopped.Store(false)
+	log.Printf("Device client: emergency stop reset, restarting state machine")
+	return c.restartStateMachine()
+}
+
+// watchRemoteEmergencyStop checks the command endpoint for a queued estop command while
+// the hardware moves: a dispense after payment, an operator command or a jam-clearing
+// pattern. Idle ball detection is not watched, the next poll picks the command up.
+// Commands stay queued on the server until acknowledged, so anything else, including
+// busyCommandID itself, is left for the next poll. The returned func ends the watch.
+func (c *Client) watchRemoteEmergencyStop(busyCommandID int) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(emergencyStopWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-c.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// Not recorded: one failed check a second would take the client offline
+			// within seconds of a backend hiccup during a long dispense.
+			cmd, err := c.getCommand()
+			if err != nil || cmd == nil || cmd.ID == busyCommandID || !strings.EqualFold(strings.TrimSpace(cmd.Command), "estop") {
+				continue
+			}
+			c.EmergencyStop(fmt.Sprintf("remote command %d", cmd.ID))
+			if err := c.ackCommand(cmd.ID, nil, commandResult{}); err != nil {
+				log.Printf("Device client: failed to acknowledge command %d: %v", cmd.ID, err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
 func (c *Client) currentPaymentPhase() string {
 	return paymentPhase(c.getCurrentPayment())
 }
@@ -1682,3 +2321,95 @@ type vibratorAdapter struct{}
 func (vibratorAdapter) Buzz(intensity float64, duration time.Duration) error {
 	return vibrator.Buzz(intensity, duration)
 }
+
+// vibratorBudget returns the vibrator duty-cycle budget, or nil when none is enforced.
+func vibratorBudget() *vibrator.DutyStatus {
+	budget := vibrator.DutyBudget()
+	if !budget.Enabled {
+		return nil
+	}
+	return &budget
+}
+
+// jamClearer returns the vibrator used between detection attempts: a jam-clearing
+// session when an engine is configured, otherwise the plain vibrator with the default
+// escalation. ctx is the detection's context; wiggles end with it and take
+// actuatorMutex unless it is marked withActuatorLock. Both watch for a remote emergency
+// stop while they run.
+func (c *Client) jamClearer(ctx context.Context) jamclear.Buzzer {
+	if c.jamClear == nil {
+		return watchedVibrator{c: c}
+	}
+	return watchedJamClearSession{
+		Session: c.jamClear.Session(jamClearHardware{c: c, ctx: ctx}, log.Default(), c.config.ColorSensorDebugLogging),
+		c:       c,
+	}
+}
+
+// watchedJamClearSession watches for a remote emergency stop while a pattern runs.
+type watchedJamClearSession struct {
+	*jamclear.Session
+	c *Client
+}
+
+func (s watchedJamClearSession) ClearJam(attempt int) error {
+	endWatch := s.c.watchRemoteEmergencyStop(0)
+	defer endWatch()
+	return s.Session.ClearJam(attempt)
+}
+
+// watchedVibrator watches for a remote emergency stop while a burst runs.
+type watchedVibrator struct {
+	c *Client
+}
+
+func (v watchedVibrator) Buzz(intensity float64, duration time.Duration) error {
+	endWatch := v.c.watchRemoteEmergencyStop(0)
+	defer endWatch()
+	return vibrator.Buzz(intensity, duration)
+}
+
+// actuatorLockKey marks a context whose caller holds actuatorMutex.
+type actuatorLockKey struct{}
+
+// withActuatorLock marks ctx as running under actuatorMutex, so a jam-clearing wiggle
+// started from it does not take the lock again.
+func withActuatorLock(ctx context.Context) context.Context {
+	return context.WithValue(ctx, actuatorLockKey{}, true)
+}
+
+func holdsActuatorLock(ctx context.Context) bool {
+	held, _ := ctx.Value(actuatorLockKey{}).(bool)
+	return held
+}
+
+// jamClearHardware drives the vibrator in both directions and the actuator for
+// jam-clearing patterns.
+type jamClearHardware struct {
+	vibratorAdapter
+	c   *Client
+	ctx context.Context
+}
+
+func (jamClearHardware) BuzzReverse(intensity float64, duration time.Duration) error {
+	return vibrator.BuzzReverse(intensity, duration)
+}
+
+func (h jamClearHardware) Wiggle(extend time.Duration) error {
+	if h.c.actuator == nil {
+		return errors.New("actuator unavailable")
+	}
+	if !holdsActuatorLock(h.ctx) {
+		// Ball detection in the poll loop runs without the lock; wait for a dispense
+		// or command movement to finish before moving the actuator.
+		h.c.actuatorMutex.Lock()
+		defer h.c.actuatorMutex.Unlock()
+	}
+	ctx, done := h.c.movementContextFrom(h.ctx)
+	defer done()
+	if err := h.c.actuator.Extend(ctx, extend); err != nil {
+		h.c.retractAfterCancel(err)
+		return err
+	}
+	return h.c.actuator.Retract(ctx, extend)
+}
@@ -1,18 +1,25 @@
 package device
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
 	"github.com/jsalamander/baendaeli-client/internal/colorsensor"
 	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/jamclear"
+	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+	"github.com/jsalamander/baendaeli-client/internal/wear"
 )
 
 type stubBreakBeamSensor struct {
@@ -185,6 +192,166 @@ func TestReportStatusIncludesDispensedCountAndKeepsIt(t *testing.T) {
 	}
 }
 
+func TestReportStatusIncludesWearCounters(t *testing.T) {
+	var received StatusRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	tracker, err := wear.Open(filepath.Join(t.TempDir(), "wear.json"), wear.Thresholds{ActuatorCycles: 1})
+	if err != nil {
+		t.Fatalf("failed to open wear counters: %v", err)
+	}
+	tracker.RecordCycle()
+	tracker.RecordVibration(2 * time.Second)
+
+	client := New(&config.Config{BaendaeliURL: server.URL, BaendaeliAPIKey: "test-key"})
+	client.SetWearTracker(tracker)
+
+	if err := client.reportStatus(""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if received.Wear == nil {
+		t.Fatal("expected wear counters in status request")
+	}
+	if received.Wear.Total.ActuatorCycles != 1 || received.Wear.Total.VibratorOnSeconds != 2 || received.Wear.Total.VibratorBursts != 1 {
+		t.Fatalf("unexpected wear totals: %+v", received.Wear.Total)
+	}
+	if len(received.Wear.MaintenanceDue) != 1 || received.Wear.MaintenanceDue[0] != wear.ReasonActuatorCycles {
+		t.Fatalf("expected actuator_cycles maintenance due, got %v", received.Wear.MaintenanceDue)
+	}
+}
+
+func TestVibratorBudgetInSnapshotAndStatus(t *testing.T) {
+	var received StatusRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	client := New(&config.Config{BaendaeliURL: server.URL, BaendaeliAPIKey: "test-key"})
+	if client.GetStateSnapshot().VibratorBudget != nil {
+		t.Fatal("expected no budget without an initialised vibrator")
+	}
+
+	// Unknown pins force simulation mode, which still enforces the budget.
+	if err := vibrator.Init(vibrator.Config{Enabled: true, IN3Pin: "NO_IN3", IN4Pin: "NO_IN4", ENBPin: "NO_ENB", MaxOnTime: 150 * time.Millisecond, DutyWindow: time.Minute}); err != nil {
+		t.Fatalf("vibrator init failed: %v", err)
+	}
+	t.Cleanup(vibrator.Cleanup)
+
+	percent, durationMs := 50, 100
+	if _, err := client.executeCommand(&CommandResponse{ID: 1, Command: "vibrate", Percent: &percent, DurationMs: &durationMs}); err != nil {
+		t.Fatalf("first vibrate failed: %v", err)
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 2, Command: "vibrate", Percent: &percent, DurationMs: &durationMs}); !errors.Is(err, vibrator.ErrDutyBudgetExceeded) {
+		t.Fatalf("expected duty-cycle budget error, got %v", err)
+	}
+
+	budget := client.GetStateSnapshot().VibratorBudget
+	if budget == nil || !budget.Exhausted || budget.MaxOnSeconds != 0.15 {
+		t.Fatalf("expected exhausted budget in snapshot, got %+v", budget)
+	}
+	if err := client.reportStatus(""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if received.VibratorBudget == nil || !received.VibratorBudget.Exhausted {
+		t.Fatalf("expected exhausted budget in status request, got %+v", received.VibratorBudget)
+	}
+}
+
+func TestCommandMaintenanceResetClearsMaintenanceDue(t *testing.T) {
+	tracker, err := wear.Open(filepath.Join(t.TempDir(), "wear.json"), wear.Thresholds{ActuatorCycles: 1, VibratorOnSeconds: 1})
+	if err != nil {
+		t.Fatalf("failed to open wear counters: %v", err)
+	}
+	tracker.RecordCycle()
+	tracker.RecordVibration(time.Second)
+
+	client := New(&config.Config{})
+	client.SetWearTracker(tracker)
+
+	snapshot := client.GetStateSnapshot()
+	if !snapshot.MaintenanceDue || len(snapshot.MaintenanceItems) != 2 {
+		t.Fatalf("expected maintenance due for actuator and vibrator, got %+v", snapshot)
+	}
+	if !client.canExecuteCommandNow(&CommandResponse{Command: "maintenance_reset"}) {
+		t.Fatal("expected maintenance_reset to be always executable")
+	}
+
+	if _, err := client.executeCommand(&CommandResponse{ID: 80, Command: "maintenance_reset", Component: "vibrator"}); err != nil {
+		t.Fatalf("maintenance_reset failed: %v", err)
+	}
+	if items := client.GetStateSnapshot().MaintenanceItems; len(items) != 1 || items[0] != wear.ReasonActuatorCycles {
+		t.Fatalf("expected only actuator maintenance left, got %v", items)
+	}
+
+	if _, err := client.executeCommand(&CommandResponse{ID: 81, Command: "maintenance_reset"}); err != nil {
+		t.Fatalf("maintenance_reset failed: %v", err)
+	}
+	if client.GetStateSnapshot().MaintenanceDue {
+		t.Fatal("expected maintenance_due cleared after full reset")
+	}
+
+	if _, err := client.executeCommand(&CommandResponse{ID: 82, Command: "maintenance_reset", Component: "hopper"}); err == nil {
+		t.Fatal("expected error for unknown component")
+	}
+}
+
+func TestCommandJamStrategySelectsPattern(t *testing.T) {
+	engine, err := jamclear.Open(filepath.Join(t.TempDir(), "jam.json"), jamclear.StrategyAdaptive, jamclear.Params{})
+	if err != nil {
+		t.Fatalf("failed to open jam clearing engine: %v", err)
+	}
+	client := New(&config.Config{})
+	client.SetJamClearEngine(engine)
+
+	if !client.canExecuteCommandNow(&CommandResponse{Command: "jam_strategy"}) {
+		t.Fatal("expected jam_strategy to be always executable")
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 90, Command: "jam_strategy", Strategy: "Alternating"}); err != nil {
+		t.Fatalf("jam_strategy failed: %v", err)
+	}
+	if engine.Strategy() != jamclear.PatternAlternating {
+		t.Fatalf("expected alternating strategy, got %s", engine.Strategy())
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 91, Command: "jam_strategy", Strategy: "shake"}); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 92, Command: "jam_strategy"}); err != nil || engine.Strategy() != jamclear.StrategyAdaptive {
+		t.Fatalf("expected empty strategy to restore adaptive, got %s (err=%v)", engine.Strategy(), err)
+	}
+
+	if _, err := New(&config.Config{}).executeCommand(&CommandResponse{ID: 93, Command: "jam_strategy", Strategy: "ramp"}); err == nil {
+		t.Fatal("expected error without a jam clearing engine")
+	}
+}
+
+func TestJamClearHardwareWigglesActuator(t *testing.T) {
+	client := New(&config.Config{})
+	recorder := actuator.NewRecorder()
+	client.SetActuator(recorder)
+
+	if err := (jamClearHardware{c: client, ctx: client.ctx}).Wiggle(150 * time.Millisecond); err != nil {
+		t.Fatalf("Wiggle failed: %v", err)
+	}
+	want := []actuator.Call{
+		{Method: "extend", Duration: 150 * time.Millisecond},
+		{Method: "retract", Duration: 150 * time.Millisecond},
+	}
+	if calls := recorder.Calls(); len(calls) != 2 || calls[0] != want[0] || calls[1] != want[1] {
+		t.Fatalf("expected extend/retract wiggle, got %+v", calls)
+	}
+}
+
 func TestReportStatusAlwaysSendsDispensedCount(t *testing.T) {
 	// dispensed_count is required by the backend — must always be present.
 	// Pre-dispense (no pending): send 0. Post-dispense (pending cleared): still
@@ -393,7 +560,7 @@ func TestAckCommand(t *testing.T) {
 			}
 			client := New(cfg)
 
-			err := client.ackCommand(42, tt.execErr, "")
+			err := client.ackCommand(42, tt.execErr, commandResult{})
 			if (err != nil) != tt.expectError {
 				t.Errorf("expected error=%v, got error=%v", tt.expectError, err)
 			}
@@ -1075,6 +1242,198 @@ func TestCommandCancelClearsPayment(t *testing.T) {
 	}
 }
 
+func TestExecuteCommandUsesInjectedActuator(t *testing.T) {
+	client := New(&config.Config{ActuatorMovement: 2})
+	recorder := actuator.NewRecorder()
+	client.SetActuator(recorder)
+
+	durationMs := 1500
+	if _, err := client.executeCommand(&CommandResponse{ID: 60, Command: "extend", DurationMs: &durationMs}); err != nil {
+		t.Fatalf("extend failed: %v", err)
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 61, Command: "retract"}); err != nil {
+		t.Fatalf("retract failed: %v", err)
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 62, Command: "home"}); err != nil {
+		t.Fatalf("home failed: %v", err)
+	}
+
+	want := []actuator.Call{
+		{Method: "extend", Duration: 1500 * time.Millisecond},
+		{Method: "retract", Duration: 2 * time.Second},
+		{Method: "home"},
+	}
+	calls := recorder.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("expected %d calls, got %+v", len(want), calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("call %d: expected %+v, got %+v", i, want[i], calls[i])
+		}
+	}
+}
+
+func TestCommandCancelStopsRunningMovement(t *testing.T) {
+	client := New(&config.Config{BaendaeliURL: "http://example.com", BaendaeliAPIKey: "test-key"})
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 10 * time.Second
+	client.SetActuator(recorder)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.DispenseAndWaitForBall()
+		errCh <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Calls()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("dispense movement did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := client.executeCommand(&CommandResponse{ID: 63, Command: "cancel"}); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected dispense to be cancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancel command did not stop the running movement")
+	}
+}
+
+func TestCommandEstopStopsRunningMovementAndLatches(t *testing.T) {
+	client := New(&config.Config{BaendaeliURL: "http://example.com", BaendaeliAPIKey: "test-key"})
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 10 * time.Second
+	client.SetActuator(recorder)
+	t.Cleanup(vibrator.ResetEmergencyStop)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.DispenseAndWaitForBall()
+		errCh <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Calls()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("dispense movement did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := client.executeCommand(&CommandResponse{ID: 70, Command: "estop"}); err != nil {
+		t.Fatalf("estop failed: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, actuator.ErrEmergencyStop) {
+			t.Fatalf("expected dispense to be aborted by emergency stop, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("estop command did not stop the running movement")
+	}
+
+	snapshot := client.GetStateSnapshot()
+	if snapshot.State != string(StateStopped) || !snapshot.Stopped {
+		t.Fatalf("expected latched stopped state, got %+v", snapshot)
+	}
+	if client.canExecuteCommandNow(&CommandResponse{Command: "extend"}) {
+		t.Fatal("expected actuation commands to be deferred while stopped")
+	}
+	if _, err := client.DispenseAndWaitForBall(); !errors.Is(err, actuator.ErrEmergencyStop) {
+		t.Fatalf("expected dispense to be refused while stopped, got %v", err)
+	}
+	if _, err := client.executeCommand(&CommandResponse{ID: 71, Command: "restart"}); !errors.Is(err, actuator.ErrEmergencyStop) {
+		t.Fatalf("expected restart to be refused while stopped, got %v", err)
+	}
+
+	if _, err := client.executeCommand(&CommandResponse{ID: 72, Command: "estop_reset"}); err != nil {
+		t.Fatalf("estop_reset failed: %v", err)
+	}
+	if client.stopped.Load() {
+		t.Fatal("expected estop_reset to clear the stopped latch")
+	}
+	if got := client.GetStateSnapshot().State; got != string(StateDetectingBall) {
+		t.Fatalf("expected state %q after estop_reset, got %q", StateDetectingBall, got)
+	}
+
+	calls := recorder.Calls()
+	if last := calls[len(calls)-1].Method; last != "estop_reset" {
+		t.Fatalf("expected actuator emergency stop to be reset, got calls %+v", calls)
+	}
+}
+
+func TestWatchRemoteEmergencyStopHandlesQueuedEstop(t *testing.T) {
+	var acked atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/ack") {
+			acked.Store(r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"command":"estop","id":7}`))
+	}))
+	defer server.Close()
+
+	client := New(&config.Config{BaendaeliURL: server.URL, BaendaeliAPIKey: "test-key"})
+	t.Cleanup(vibrator.ResetEmergencyStop)
+
+	endWatch := client.watchRemoteEmergencyStop(6)
+	deadline := time.Now().Add(3 * time.Second)
+	for !client.stopped.Load() {
+		if time.Now().After(deadline) {
+			endWatch()
+			t.Fatal("queued estop was not picked up while busy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	endWatch()
+
+	if path, _ := acked.Load().(string); !strings.HasSuffix(path, "/commands/7/ack") {
+		t.Fatalf("expected estop command 7 to be acknowledged, got %q", path)
+	}
+}
+
+func TestJamClearingWatchesRemoteEmergencyStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.HasSuffix(r.URL.Path, "/ack") {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"command":"estop","id":7}`))
+	}))
+	defer server.Close()
+
+	engine, err := jamclear.Open(filepath.Join(t.TempDir(), "jam.json"), jamclear.PatternActuatorWiggle, jamclear.Params{Bursts: 1})
+	if err != nil {
+		t.Fatalf("failed to open jam clearing engine: %v", err)
+	}
+	client := New(&config.Config{BaendaeliURL: server.URL, BaendaeliAPIKey: "test-key"})
+	client.SetJamClearEngine(engine)
+	recorder := actuator.NewRecorder()
+	recorder.Delay = time.Second
+	client.SetActuator(recorder)
+	t.Cleanup(vibrator.ResetEmergencyStop)
+
+	clearer := client.jamClearer(client.ctx).(interface{ ClearJam(attempt int) error })
+	clearer.ClearJam(1)
+	if !client.stopped.Load() {
+		t.Fatal("queued estop was not picked up while clearing a jam")
+	}
+}
+
 func TestCommandRestartResetsStateMachineToDetectingBall(t *testing.T) {
 	cfg := &config.Config{}
 	cfg.SetDefaults()
@@ -1189,7 +1548,7 @@ func TestWaitForBallReadyAttemptBreakBeamDetectsWithinWindow(t *testing.T) {
 		reads:   []bool{false, false, true},
 	}
 
-	source, err := client.waitForBallReadyAttempt(false, nil, nil)
+	source, err := client.waitForBallReadyAttempt(client.ctx, false, nil, nil)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -1221,7 +1580,7 @@ func TestWaitForBallReadyAttemptFallsBackToColorSensorWhenBreakBeamWindowMisses(
 		reads:   []bool{false},
 	}
 
-	source, err := client.waitForBallReadyAttempt(false, nil, nil)
+	source, err := client.waitForBallReadyAttempt(client.ctx, false, nil, nil)
 	if err != nil {
 		t.Fatalf("expected color-sensor fallback success, got %v", err)
 	}
@@ -1508,6 +1867,83 @@ func TestPollJammedRecoversAndFetchesCommand(t *testing.T) {
 	}
 }
 
+func TestMarkActuatorStallLatchesState(t *testing.T) {
+	client := New(&config.Config{})
+
+	if client.markActuatorStall(fmt.Errorf("extend failed: gpio error")) {
+		t.Fatal("expected non-stall error to be ignored")
+	}
+	if client.actuatorStalled.Load() {
+		t.Fatal("expected stall latch to stay clear")
+	}
+
+	stall := &actuator.StallError{Direction: "extend", Reason: actuator.StallReasonOverload, CurrentMa: 3200}
+	if !client.markActuatorStall(fmt.Errorf("extend failed: %w", stall)) {
+		t.Fatal("expected wrapped stall error to be recognised")
+	}
+
+	snapshot := client.GetStateSnapshot()
+	if snapshot.State != string(StateActuatorStall) || !snapshot.ActuatorStall {
+		t.Fatalf("expected latched actuator_stall state, got state=%q actuator_stall=%v", snapshot.State, snapshot.ActuatorStall)
+	}
+	if _, err := client.DispenseAndWaitForBall(); !errors.Is(err, actuator.ErrStall) {
+		t.Fatalf("expected dispense to be refused while stalled, got %v", err)
+	}
+}
+
+func TestPollStalledDefersActuationAndRestartClears(t *testing.T) {
+	command := `{"command":"extend","id":1}`
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/commands") {
+			mu.Lock()
+			body := command
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(body))
+			return
+		}
+		if strings.Contains(r.URL.Path, "/payment") {
+			t.Errorf("state machine must not run while stalled: %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{BaendaeliURL: server.URL, BaendaeliAPIKey: "test-key"}
+	cfg.SetDefaults()
+	cfg.ActuatorEnabled = false
+	cfg.DebugBypassBallDetection = true
+	client := New(cfg)
+	client.actuatorStalled.Store(true)
+
+	client.poll()
+
+	pending := client.getPendingCommand()
+	if pending == nil || pending.Command != "extend" {
+		t.Fatalf("expected extend command to be deferred while stalled, got %+v", pending)
+	}
+	if got := client.GetStateSnapshot().State; got != string(StateActuatorStall) {
+		t.Fatalf("expected state %q, got %q", StateActuatorStall, got)
+	}
+
+	client.clearPendingCommand()
+	mu.Lock()
+	command = `{"command":"restart","id":2}`
+	mu.Unlock()
+
+	client.poll()
+
+	if client.actuatorStalled.Load() {
+		t.Fatal("expected restart command to clear actuator stall")
+	}
+	if got := client.GetStateSnapshot().State; got != string(StateDetectingBall) {
+		t.Fatalf("expected state %q after restart, got %q", StateDetectingBall, got)
+	}
+}
+
 func TestGetStateSnapshotIncludesRuntimeFields(t *testing.T) {
 	client := New(&config.Config{})
 	client.SetPaymentID("payment-42")
@@ -1746,3 +2182,132 @@ func TestStartSetsDetectingState(t *testing.T) {
 		t.Fatalf("expected start state detecting_ball, got %q", snapshot.State)
 	}
 }
+
+// ensure the watchdog progress marker advances with poll iterations and command progress
+func TestLastProgress(t *testing.T) {
+	client := newHealthTestClient()
+	client.config.LogShippingEnabled = false
+	if !client.LastProgress().IsZero() {
+		t.Fatalf("expected no progress before Start, got %v", client.LastProgress())
+	}
+
+	client.updateExecutingCommandMessage("Payment 1/3")
+	if !client.LastProgress().IsZero() {
+		t.Fatal("expected a message without a running command not to count as progress")
+	}
+	client.setExecutingCommand(&CommandResponse{Command: "load_test"})
+	client.updateExecutingCommandMessage("Payment 1/3")
+	commandProgress := client.LastProgress()
+	if commandProgress.IsZero() {
+		t.Fatal("expected command progress to be recorded")
+	}
+	client.clearExecutingCommand()
+
+	// A latched stop skips the state machine, so an iteration is only the backend calls.
+	client.stopped.Store(true)
+	client.pollInterval = 10 * time.Millisecond
+	client.wg.Add(1)
+	go client.pollLoop()
+	defer func() {
+		client.cancel()
+		client.wg.Wait()
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.LastProgress().After(commandProgress) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a finished poll iteration to be recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// ensure a long message command keeps the watchdog fed and ends when the client stops
+func TestMessageCommandMarksProgress(t *testing.T) {
+	prevTick := progressTick
+	progressTick = 10 * time.Millisecond
+	defer func() { progressTick = prevTick }()
+
+	client := newHealthTestClient()
+	client.config.LogShippingEnabled = false
+	before := time.Now()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		client.cancel()
+	}()
+
+	durationMs := 60000
+	start := time.Now()
+	if _, err := client.executeCommand(&CommandResponse{ID: 1, Command: "message", Message: "Pause", DurationMs: &durationMs}); err != nil {
+		t.Fatalf("message command failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the message to end when the client stops, took %v", elapsed)
+	}
+	if !client.LastProgress().After(before) {
+		t.Fatalf("expected progress during the message, got %v", client.LastProgress())
+	}
+}
+
+// overlapActuator fails the test when two movements run at the same time.
+type overlapActuator struct {
+	*actuator.Recorder
+	t      *testing.T
+	moving atomic.Int32
+}
+
+func (a *overlapActuator) move(run func() error) error {
+	if a.moving.Add(1) > 1 {
+		a.t.Error("expected actuator movements not to overlap")
+	}
+	defer a.moving.Add(-1)
+	return run()
+}
+
+func (a *overlapActuator) Extend(ctx context.Context, d time.Duration) error {
+	return a.move(func() error { return a.Recorder.Extend(ctx, d) })
+}
+
+func (a *overlapActuator) Retract(ctx context.Context, d time.Duration) error {
+	return a.move(func() error { return a.Recorder.Retract(ctx, d) })
+}
+
+func (a *overlapActuator) Trigger(ctx context.Context) (int, error) {
+	var totalMs int
+	err := a.move(func() (err error) {
+		totalMs, err = a.Recorder.Trigger(ctx)
+		return err
+	})
+	return totalMs, err
+}
+
+// ensure a jam-clearing wiggle from the poll loop waits for a running dispense
+func TestJamClearWiggleWaitsForDispense(t *testing.T) {
+	client := newHealthTestClient()
+	client.config.LogShippingEnabled = false
+	client.config.DebugBypassBallDetection = true
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 50 * time.Millisecond
+	client.SetActuator(&overlapActuator{Recorder: recorder, t: t})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, err := client.DispenseWithProgress(context.Background(), nil); err != nil {
+			t.Errorf("dispense failed: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+		if err := (jamClearHardware{c: client, ctx: client.ctx}).Wiggle(20 * time.Millisecond); err != nil {
+			t.Errorf("wiggle failed: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	calls := recorder.Calls()
+	if len(calls) != 3 || calls[0].Method != "trigger" {
+		t.Fatalf("expected the wiggle after the dispense, got %+v", calls)
+	}
+}
@@ -0,0 +1,47 @@
+package device
+
+import (
+	"context"
+
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
+)
+
+// Stages of a dispense reported by DispenseWithProgress.
+const (
+	DispenseWaiting    = "waiting" // for the actuator, held by another command
+	DispenseExtending  = actuator.PhaseExtending
+	DispenseRetracting = actuator.PhaseRetracting
+	DispenseDetecting  = "detecting"
+)
+
+// DispenseProgress is the step a dispense is in. Attempt and MaxAttempts count the
+// colour sensor detection attempts while detecting.
+type DispenseProgress struct {
+	Stage       string `json:"stage"`
+	Attempt     int    `json:"attempt,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+}
+
+// DispenseWithProgress is DispenseAndWaitForBall for callers that track and cancel the
+// dispense. progress is called on every step. Cancelling ctx ends the wait for the
+// actuator, stops a running movement at once and the ball detection before its next
+// attempt; the returned error then wraps the context error.
+func (c *Client) DispenseWithProgress(ctx context.Context, progress func(DispenseProgress)) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Stop ends the dispense like it ends every other movement.
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+
+	if progress != nil {
+		progress(DispenseProgress{Stage: DispenseWaiting})
+	}
+	if err := c.actuatorMutex.LockContext(ctx); err != nil {
+		return 0, err
+	}
+	defer c.actuatorMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.dispenseAndWaitForBallLocked(ctx, progress)
+}
@@ -0,0 +1,95 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
+)
+
+func TestDispenseWithProgressReportsStages(t *testing.T) {
+	c := newHealthTestClient()
+	c.config.LogShippingEnabled = false
+	c.SetActuator(actuator.NewRecorder())
+
+	var stages []string
+	if _, err := c.DispenseWithProgress(context.Background(), func(p DispenseProgress) {
+		stages = append(stages, p.Stage)
+	}); err != nil {
+		t.Fatalf("dispense failed: %v", err)
+	}
+	want := []string{DispenseWaiting, DispenseExtending, DispenseDetecting}
+	if len(stages) != len(want) {
+		t.Fatalf("expected stages %v, got %v", want, stages)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Fatalf("expected stages %v, got %v", want, stages)
+		}
+	}
+}
+
+// ensure cancelling stops the movement and retracts without latching a stall
+func TestDispenseWithProgressCancelsMovement(t *testing.T) {
+	c := newHealthTestClient()
+	c.config.LogShippingEnabled = false
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 200 * time.Millisecond
+	c.SetActuator(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	start := time.Now()
+	_, err := c.DispenseWithProgress(ctx, func(p DispenseProgress) {
+		if p.Stage == DispenseExtending {
+			once.Do(func() { time.AfterFunc(50*time.Millisecond, cancel) })
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled dispense, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the movement to stop at once, took %v", elapsed)
+	}
+	if c.actuatorStalled.Load() || c.stopped.Load() {
+		t.Fatal("expected a cancel not to latch a stall or stop")
+	}
+	if calls := recorder.Calls(); len(calls) != 2 || calls[1].Method != "home" {
+		t.Fatalf("expected the cancelled dispense to home the actuator, got %+v", calls)
+	}
+}
+
+// ensure a cancel command retracts the actuator of the dispense it stops
+func TestCancelCommandRetractsDispense(t *testing.T) {
+	c := newHealthTestClient()
+	c.config.LogShippingEnabled = false
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 200 * time.Millisecond
+	c.SetActuator(recorder)
+
+	extending := make(chan struct{})
+	var once sync.Once
+	dispensed := make(chan error, 1)
+	go func() {
+		_, err := c.DispenseWithProgress(context.Background(), func(p DispenseProgress) {
+			if p.Stage == DispenseExtending {
+				once.Do(func() { close(extending) })
+			}
+		})
+		dispensed <- err
+	}()
+
+	<-extending
+	if _, err := c.executeCommand(&CommandResponse{ID: 1, Command: "cancel"}); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if err := <-dispensed; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancel to stop the dispense, got %v", err)
+	}
+	if calls := recorder.Calls(); len(calls) != 2 || calls[0].Method != "trigger" || calls[1].Method != "home" {
+		t.Fatalf("expected the cancelled dispense to home the actuator, got %+v", calls)
+	}
+}
@@ -0,0 +1,285 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+)
+
+// eventSnapshotRetryInterval is how often failed uploads are retried.
+const eventSnapshotRetryInterval = 30 * time.Second
+
+// eventSnapshotStates are the states whose entry captures a camera snapshot.
+var eventSnapshotStates = map[RuntimeState]bool{
+	StateBallStuckFunnel: true,
+	StateJam:             true,
+	StateError:           true,
+}
+
+// EventSnapshotRequest is sent to the server with a snapshot of a jam or error event.
+type EventSnapshotRequest struct {
+	EventID     string `json:"event_id"`
+	State       string `json:"state"`
+	Message     string `json:"message,omitempty"`
+	PaymentID   string `json:"payment_id,omitempty"`
+	OccurredAt  string `json:"occurred_at"`
+	ImageBase64 string `json:"image_base64"` // base64-encoded JPEG
+}
+
+type snapshotEvent struct {
+	id         string
+	state      RuntimeState
+	message    string
+	paymentID  string
+	occurredAt time.Time
+}
+
+// eventSnapshotter captures a camera image when the device enters a jam or error
+// state, keeps it in a bounded ring directory and uploads it with an event ID so
+// remote staff can see the funnel before driving out.
+type eventSnapshotter struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	httpClient *http.Client
+	client     *Client
+
+	dir         string
+	maxFiles    int
+	minInterval time.Duration
+	capture     func() ([]byte, error)
+
+	mu          sync.Mutex
+	lastCapture time.Time
+	pending     []snapshotEvent
+
+	events chan snapshotEvent
+}
+
+func newEventSnapshotter(parent context.Context, c *Client, httpClient *http.Client) *eventSnapshotter {
+	ctx, cancel := context.WithCancel(parent)
+	return &eventSnapshotter{
+		ctx:         ctx,
+		cancel:      cancel,
+		httpClient:  httpClient,
+		client:      c,
+		dir:         c.config.EventSnapshotDir,
+		maxFiles:    c.config.EventSnapshotMaxFiles,
+		minInterval: time.Duration(c.config.EventSnapshotIntervalSeconds) * time.Second,
+		capture:     camera.Capture,
+		events:      make(chan snapshotEvent, 4),
+	}
+}
+
+// enabled reports whether snapshots are configured. A negative interval disables them.
+func (s *eventSnapshotter) enabled() bool {
+	return s.client.config.CameraEnabled && s.client.config.EventSnapshotIntervalSeconds >= 0 && s.dir != ""
+}
+
+func (s *eventSnapshotter) start() {
+	if !s.enabled() {
+		return
+	}
+	s.wg.Add(1)
+	go s.run()
+}
+
+func (s *eventSnapshotter) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// trigger queues a snapshot for entering state. It never blocks the state machine:
+// events within the rate limit or while the queue is full are dropped.
+func (s *eventSnapshotter) trigger(state RuntimeState, message string) {
+	if !eventSnapshotStates[state] || !s.enabled() {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if !s.lastCapture.IsZero() && now.Sub(s.lastCapture) < s.minInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCapture = now
+	s.mu.Unlock()
+
+	event := snapshotEvent{
+		id:         newEventID(now, state),
+		state:      state,
+		message:    message,
+		paymentID:  s.client.GetPaymentID(),
+		occurredAt: now.UTC(),
+	}
+	select {
+	case s.events <- event:
+	default:
+		log.Printf("Device client: event snapshot queue full, dropped %s", event.id)
+	}
+}
+
+func (s *eventSnapshotter) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(eventSnapshotRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case event := <-s.events:
+			if err := s.captureAndStore(event); err != nil {
+				log.Printf("Device client: event snapshot %s failed: %v", event.id, err)
+				continue
+			}
+			s.mu.Lock()
+			s.pending = append(s.pending, event)
+			s.mu.Unlock()
+			s.uploadPending()
+		case <-ticker.C:
+			s.uploadPending()
+		}
+	}
+}
+
+func (s *eventSnapshotter) captureAndStore(event snapshotEvent) error {
+	image, err := s.capture()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := os.WriteFile(s.imagePath(event.id), image, 0o644); err != nil {
+		return fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	log.Printf("Device client: event snapshot %s stored (%d bytes, state=%s)", event.id, len(image), event.state)
+	s.pruneRing()
+	return nil
+}
+
+// pruneRing deletes the oldest snapshots beyond maxFiles. Event IDs start with a UTC
+// timestamp, so lexical order is chronological.
+func (s *eventSnapshotter) pruneRing() {
+	if s.maxFiles <= 0 {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.jpg"))
+	if err != nil || len(files) <= s.maxFiles {
+		return
+	}
+	sort.Strings(files)
+	for _, file := range files[:len(files)-s.maxFiles] {
+		if err := os.Remove(file); err != nil {
+			log.Printf("Device client: failed to remove old event snapshot %s: %v", file, err)
+		}
+	}
+}
+
+// uploadPending uploads queued snapshots oldest first and stops at the first transient
+// failure so they are retried on the next tick.
+func (s *eventSnapshotter) uploadPending() {
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		event := s.pending[0]
+		s.mu.Unlock()
+
+		retry := false
+		image, err := os.ReadFile(s.imagePath(event.id))
+		if err != nil {
+			// Evicted from the ring before it could be uploaded.
+			log.Printf("Device client: dropping event snapshot %s: %v", event.id, err)
+		} else if statusCode, err := s.upload(event, image); err != nil {
+			log.Printf("Device client: failed to upload event snapshot %s: %v", event.id, err)
+			retry = retryableUpload(statusCode)
+		} else {
+			log.Printf("Device client: event snapshot %s uploaded", event.id)
+		}
+		if retry {
+			return
+		}
+
+		s.mu.Lock()
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+	}
+}
+
+func (s *eventSnapshotter) upload(event snapshotEvent, image []byte) (int, error) {
+	return s.client.postEventImage(s.ctx, s.httpClient, EventSnapshotRequest{
+		EventID:     event.id,
+		State:       string(event.state),
+		Message:     event.message,
+		PaymentID:   event.paymentID,
+		OccurredAt:  event.occurredAt.Format(time.RFC3339),
+		ImageBase64: base64.StdEncoding.EncodeToString(image),
+	})
+}
+
+// postEventImage sends an image to the events endpoint. The returned status code is 0
+// when the request did not reach the server.
+func (c *Client) postEventImage(ctx context.Context, httpClient *http.Client, event EventSnapshotRequest) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event snapshot: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.buildURL("/api/v1/device/events"), bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setAuthHeader(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return resp.StatusCode, nil
+}
+
+// retryableUpload reports whether an image upload that failed with statusCode should
+// be retried later.
+func retryableUpload(statusCode int) bool {
+	return statusCode == 0 || statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+func (s *eventSnapshotter) imagePath(eventID string) string {
+	return filepath.Join(s.dir, eventID+".jpg")
+}
+
+// newEventID returns a sortable, unique ID such as "20261018T162740Z-jam-1a2b3c4d".
+func newEventID(at time.Time, state RuntimeState) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%s-%d", at.UTC().Format("20060102T150405Z"), state, at.UnixNano())
+	}
+	return fmt.Sprintf("%s-%s-%s", at.UTC().Format("20060102T150405Z"), state, hex.EncodeToString(suffix))
+}
@@ -0,0 +1,147 @@
+package device
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSnapshotter(t *testing.T, client *Client) *eventSnapshotter {
+	t.Helper()
+	s := newEventSnapshotter(client.ctx, client, client.httpClient)
+	s.dir = t.TempDir()
+	s.capture = func() ([]byte, error) { return []byte("jpeg"), nil }
+	client.eventSnapshots = s
+	return s
+}
+
+// ensure entering the jam state captures, stores and uploads one rate-limited snapshot
+func TestEventSnapshotUploadedOnJam(t *testing.T) {
+	var mu sync.Mutex
+	var received []EventSnapshotRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/device/events" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("expected bearer auth, got %q", r.Header.Get("Authorization"))
+		}
+		var req EventSnapshotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		mu.Lock()
+		received = append(received, req)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	s := newTestSnapshotter(t, client)
+	s.minInterval = time.Hour
+	s.start()
+	defer s.stop()
+
+	client.setRuntimeState(StateJam, "Ball klemmt")
+	client.setRuntimeState(StateJam, "Ball klemmt noch") // no transition
+	client.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	client.setRuntimeState(StateError, "rate limited")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("expected exactly 1 upload, got %d", len(received))
+	}
+	got := received[0]
+	if got.State != string(StateJam) || got.Message != "Ball klemmt" || !strings.Contains(got.EventID, "-jam-") {
+		t.Fatalf("unexpected event %+v", got)
+	}
+	if image, _ := base64.StdEncoding.DecodeString(got.ImageBase64); string(image) != "jpeg" {
+		t.Fatalf("unexpected image payload %q", got.ImageBase64)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, got.EventID+".jpg")); err != nil {
+		t.Fatalf("expected stored snapshot: %v", err)
+	}
+}
+
+// ensure failed uploads stay queued for retry and the ring directory stays bounded
+func TestEventSnapshotRingAndRetry(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	s := newTestSnapshotter(t, client)
+	s.maxFiles = 2
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		event := snapshotEvent{id: newEventID(base.Add(time.Duration(i)*time.Second), StateJam), state: StateJam}
+		if err := s.captureAndStore(event); err != nil {
+			t.Fatalf("captureAndStore failed: %v", err)
+		}
+		s.pending = append(s.pending, event)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*.jpg"))
+	if len(files) != 2 {
+		t.Fatalf("expected ring bounded to 2 files, got %d", len(files))
+	}
+	if strings.HasPrefix(filepath.Base(files[0]), base.Format("20060102T150405Z")) {
+		t.Fatalf("expected oldest snapshot evicted, got %v", files)
+	}
+
+	// The evicted snapshot is dropped; the next one fails transiently and is kept.
+	s.uploadPending()
+	if len(s.pending) != 2 {
+		t.Fatalf("expected 2 pending snapshots after transient failure, got %d", len(s.pending))
+	}
+
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	s.uploadPending()
+	if len(s.pending) != 0 {
+		t.Fatalf("expected pending snapshots uploaded, got %d left", len(s.pending))
+	}
+}
+
+// ensure capture failures are reported and nothing is queued
+func TestEventSnapshotCaptureFailure(t *testing.T) {
+	client := newTestClient("http://example.invalid")
+	s := newTestSnapshotter(t, client)
+	s.capture = func() ([]byte, error) { return nil, fmt.Errorf("camera not initialised or disabled") }
+
+	if err := s.captureAndStore(snapshotEvent{id: newEventID(time.Now(), StateError), state: StateError}); err == nil {
+		t.Fatal("expected capture error")
+	}
+	if files, _ := filepath.Glob(filepath.Join(s.dir, "*.jpg")); len(files) != 0 {
+		t.Fatalf("expected no stored snapshots, got %v", files)
+	}
+}
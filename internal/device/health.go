@@ -0,0 +1,212 @@
+package device
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+)
+
+// Component health states. Only HealthFailed makes the client unready; a degraded
+// component still lets balls be sold.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFailed   = "failed"
+	HealthDisabled = "disabled"
+)
+
+// logBacklogDegradedPercent is the log queue fill level reported as degraded.
+const logBacklogDegradedPercent = 90
+
+// ComponentHealth is the state of one subsystem in a HealthReport.
+type ComponentHealth struct {
+	Status string `json:"status"`
+	// Mode is "hardware" or "simulation" for GPIO and I2C devices, or the camera source.
+	Mode   string `json:"mode,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// LastSuccessAgeSeconds is the time since the last successful backend request.
+	LastSuccessAgeSeconds *float64 `json:"last_success_age_seconds,omitempty"`
+	// Backlog and BacklogLimit are the queued and maximum log lines.
+	Backlog      *int `json:"backlog,omitempty"`
+	BacklogLimit int  `json:"backlog_limit,omitempty"`
+	// LastReading is the colour sensor sample the poll loop took last, and
+	// LastReadingAgeSeconds the time since.
+	LastReading           *ColorReading `json:"last_reading,omitempty"`
+	LastReadingAgeSeconds *float64      `json:"last_reading_age_seconds,omitempty"`
+}
+
+// HealthReport is the readiness of the client and its subsystems.
+type HealthReport struct {
+	Ready      bool                       `json:"ready"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// recordBackendResult tracks the last successful backend request for the readiness
+// check and offline mode. The status report, the poll loop's command fetch and the
+// payment requests count. Only connectivity errors count towards offline mode.
+func (c *Client) recordBackendResult(err error) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	if isConnectivityError(err) {
+		c.trackConnectivityLocked(err)
+	} else {
+		c.trackConnectivityLocked(nil)
+	}
+	if err != nil {
+		c.lastBackendError = err.Error()
+		return
+	}
+	c.lastBackendContact = time.Now()
+	c.lastBackendError = ""
+}
+
+// Health checks every subsystem. Enabled hardware that fell back to simulation counts
+// as failed, as does a backend not reached within HEALTH_MAX_POLL_AGE_SECONDS.
+func (c *Client) Health() HealthReport {
+	report := HealthReport{Ready: true, Components: map[string]ComponentHealth{
+		"backend":      c.backendHealth(),
+		"color_sensor": c.colorSensorHealth(),
+		"break_beam":   c.breakBeamHealth(),
+		"actuator":     c.actuatorHealth(),
+		"vibrator":     vibratorHealth(c.config.VibrationEnabled),
+		"camera":       cameraHealth(c.config.CameraEnabled, c.config.CameraBackend),
+		"log_shipper":  c.logShipperHealth(),
+	}}
+	for _, component := range report.Components {
+		if component.Status == HealthFailed {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func (c *Client) backendHealth() ComponentHealth {
+	c.statusMutex.Lock()
+	lastContact := c.lastBackendContact
+	lastError := c.lastBackendError
+	offline := !c.offlineSince.IsZero()
+	failures := c.backendFailures
+	c.statusMutex.Unlock()
+
+	if offline {
+		lastError = fmt.Sprintf("offline after %d consecutive failures: %s", failures, lastError)
+	}
+
+	if lastContact.IsZero() {
+		detail := "no successful request yet"
+		if lastError != "" {
+			detail = lastError
+		}
+		return ComponentHealth{Status: HealthFailed, Detail: detail}
+	}
+	age := time.Since(lastContact)
+	ageSeconds := age.Seconds()
+	health := ComponentHealth{Status: HealthOK, LastSuccessAgeSeconds: &ageSeconds, Detail: lastError}
+	if age > time.Duration(c.config.HealthMaxPollAgeSeconds)*time.Second {
+		health.Status = HealthFailed
+	}
+	return health
+}
+
+func (c *Client) colorSensorHealth() ComponentHealth {
+	if !c.colorSensor.IsEnabled() {
+		return ComponentHealth{Status: HealthDisabled}
+	}
+	if c.colorSensor.IsSimulation() {
+		return ComponentHealth{Status: HealthFailed, Mode: "simulation", Detail: "sensor not found, running in simulation"}
+	}
+	// The sensor is shared with ball detection; report the poll loop's last sample
+	// instead of reading it again.
+	sample, ok := c.colorSensor.LastSample()
+	if !ok {
+		return ComponentHealth{Status: HealthOK, Mode: "hardware", Detail: "no reading yet"}
+	}
+	ageSeconds := time.Since(sample.At).Seconds()
+	health := ComponentHealth{Status: HealthOK, Mode: "hardware", LastReadingAgeSeconds: &ageSeconds}
+	if sample.Err != nil {
+		health.Status, health.Detail = HealthFailed, sample.Err.Error()
+		return health
+	}
+	health.LastReading = &ColorReading{Clear: sample.C, Red: sample.R, Green: sample.G, Blue: sample.B, SampledAt: sample.At.UTC()}
+	return health
+}
+
+func (c *Client) breakBeamHealth() ComponentHealth {
+	if c.breakBeamSensor == nil || !c.breakBeamSensor.IsEnabled() {
+		return ComponentHealth{Status: HealthDisabled}
+	}
+	if sim, ok := c.breakBeamSensor.(interface{ IsSimulation() bool }); ok && sim.IsSimulation() {
+		return ComponentHealth{Status: HealthFailed, Mode: "simulation", Detail: "GPIO unavailable, running in simulation"}
+	}
+	if _, err := c.breakBeamSensor.ReadInterrupted(); err != nil {
+		return ComponentHealth{Status: HealthFailed, Mode: "hardware", Detail: err.Error()}
+	}
+	return ComponentHealth{Status: HealthOK, Mode: "hardware"}
+}
+
+func (c *Client) actuatorHealth() ComponentHealth {
+	if !c.config.ActuatorEnabled {
+		return ComponentHealth{Status: HealthDisabled, Mode: "simulation"}
+	}
+	// actuatorMutex is held for whole movements; the actuator is only replaced before Start.
+	if _, simulated := c.actuator.(*actuator.Simulated); simulated {
+		return ComponentHealth{Status: HealthFailed, Mode: "simulation", Detail: "GPIO unavailable, running in simulation"}
+	}
+	health := ComponentHealth{Status: HealthOK, Mode: "hardware"}
+	switch {
+	case c.stopped.Load():
+		health.Status, health.Detail = HealthDegraded, emergencyStopMessage
+	case c.actuatorStalled.Load():
+		health.Status, health.Detail = HealthDegraded, actuatorStallMessage
+	}
+	return health
+}
+
+func vibratorHealth(enabled bool) ComponentHealth {
+	switch {
+	case !enabled:
+		return ComponentHealth{Status: HealthDisabled}
+	case !vibrator.IsInitialised():
+		return ComponentHealth{Status: HealthFailed, Detail: "not initialised"}
+	case vibrator.IsSimulation():
+		return ComponentHealth{Status: HealthFailed, Mode: "simulation", Detail: "GPIO unavailable, running in simulation"}
+	}
+	return ComponentHealth{Status: HealthOK, Mode: "hardware"}
+}
+
+// cameraHealth never fails readiness: without a camera, pictures and the vision vote
+// are missing but the machine still works.
+func cameraHealth(enabled bool, backend string) ComponentHealth {
+	if !enabled {
+		return ComponentHealth{Status: HealthDisabled}
+	}
+	source := camera.Source()
+	switch {
+	case source == "":
+		return ComponentHealth{Status: HealthDegraded, Detail: "not initialised"}
+	case source == "simulation" && backend != camera.BackendSimulation:
+		return ComponentHealth{Status: HealthDegraded, Mode: source, Detail: "no capture tool found"}
+	}
+	return ComponentHealth{Status: HealthOK, Mode: source}
+}
+
+func (c *Client) logShipperHealth() ComponentHealth {
+	s := c.logShipper
+	if s == nil || !c.config.LogShippingEnabled {
+		return ComponentHealth{Status: HealthDisabled}
+	}
+	s.queueMu.Lock()
+	backlog := len(s.queue)
+	s.queueMu.Unlock()
+	health := ComponentHealth{Status: HealthOK, Backlog: &backlog, BacklogLimit: s.maxQueueLines}
+	switch {
+	case s.isDisabled():
+		health.Status, health.Detail = HealthDegraded, "stopped after an authentication error"
+	case s.maxQueueLines > 0 && backlog*100 >= s.maxQueueLines*logBacklogDegradedPercent:
+		health.Status, health.Detail = HealthDegraded, "log queue almost full"
+	}
+	return health
+}
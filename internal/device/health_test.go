@@ -0,0 +1,95 @@
+package device
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/colorsensor"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+)
+
+// newHealthTestClient returns a client with every hardware component disabled.
+func newHealthTestClient() *Client {
+	c := newTestClient("http://127.0.0.1:1")
+	c.colorSensor = colorsensor.New(&config.Config{})
+	c.config.CameraEnabled = false
+	return c
+}
+
+func TestHealthBackendReadiness(t *testing.T) {
+	c := newHealthTestClient()
+	c.config.LogShippingEnabled = false
+
+	report := c.Health()
+	if report.Ready || report.Components["backend"].Status != HealthFailed {
+		t.Fatalf("expected not ready before the first backend request, got %+v", report)
+	}
+	for _, name := range []string{"color_sensor", "break_beam", "actuator", "vibrator", "camera", "log_shipper"} {
+		if status := report.Components[name].Status; status != HealthDisabled {
+			t.Fatalf("expected %s to be disabled, got %s", name, status)
+		}
+	}
+
+	c.recordBackendResult(nil)
+	c.recordBackendResult(errors.New("request failed: timeout"))
+	report = c.Health()
+	backend := report.Components["backend"]
+	if !report.Ready || backend.Status != HealthOK || backend.LastSuccessAgeSeconds == nil || backend.Detail != "request failed: timeout" {
+		t.Fatalf("expected ready with the last error as detail, got %+v", report)
+	}
+
+	c.statusMutex.Lock()
+	c.lastBackendContact = time.Now().Add(-time.Duration(c.config.HealthMaxPollAgeSeconds+1) * time.Second)
+	c.statusMutex.Unlock()
+	if report = c.Health(); report.Ready || report.Components["backend"].Status != HealthFailed {
+		t.Fatalf("expected a stale backend to fail readiness, got %+v", report.Components["backend"])
+	}
+}
+
+func TestHealthHardwareFallbacks(t *testing.T) {
+	c := newHealthTestClient()
+	c.recordBackendResult(nil)
+
+	// An enabled actuator that fell back to simulation is not ready.
+	c.config.ActuatorEnabled = true
+	if report := c.Health(); report.Ready || report.Components["actuator"].Status != HealthFailed || report.Components["actuator"].Mode != "simulation" {
+		t.Fatalf("expected the simulated actuator to fail, got %+v", report.Components["actuator"])
+	}
+	c.config.ActuatorEnabled = false
+
+	// A missing camera only degrades the client.
+	c.config.CameraEnabled = true
+	if report := c.Health(); !report.Ready || report.Components["camera"].Status != HealthDegraded {
+		t.Fatalf("expected an uninitialised camera to be degraded, got %+v", report.Components["camera"])
+	}
+	c.config.CameraEnabled = false
+
+	c.logShipper.queueMu.Lock()
+	c.logShipper.queue = make([]string, c.logShipper.maxQueueLines)
+	c.logShipper.queueMu.Unlock()
+	log := c.Health().Components["log_shipper"]
+	if log.Status != HealthDegraded || log.Backlog == nil || *log.Backlog != c.logShipper.maxQueueLines {
+		t.Fatalf("expected a full log queue to be degraded, got %+v", log)
+	}
+}
+
+// ensure the colour sensor health reports the poll loop's last sample without reading
+func TestHealthColorSensorUsesLastSample(t *testing.T) {
+	c := newHealthTestClient()
+	c.colorSensor = colorsensor.New(&config.Config{ColorSensorEnabled: true})
+
+	health := c.Health().Components["color_sensor"]
+	if health.Status != HealthOK || health.LastReadingAgeSeconds != nil {
+		t.Fatalf("expected ok without a reading before the first sample, got %+v", health)
+	}
+	if _, ok := c.colorSensor.LastSample(); ok {
+		t.Fatal("expected the health check not to read the sensor")
+	}
+
+	c.colorSensor.Read()
+	health = c.Health().Components["color_sensor"]
+	if health.Status != HealthFailed || health.Detail == "" || health.LastReadingAgeSeconds == nil {
+		t.Fatalf("expected the failed sample with its age, got %+v", health)
+	}
+}
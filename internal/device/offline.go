@@ -0,0 +1,221 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrOffline is returned by CreatePayment while the backend is unreachable.
+var ErrOffline = errors.New("backend unreachable, device is offline")
+
+// offlineMessage is shown while the offline state is active.
+const offlineMessage = "Keine Verbindung - Zahlung zurzeit nicht möglich"
+
+// maxQueuedAcks bounds the command acks kept for replay; the oldest is dropped first.
+const maxQueuedAcks = 20
+
+// queuedAck is a command ack the backend could not take.
+type queuedAck struct {
+	commandID int
+	request   AckRequest
+}
+
+// statusError is a backend answer with an unexpected HTTP status code.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.code, e.body)
+}
+
+// isConnectivityError reports whether err means the backend could not serve the
+// request: a network error, a 5xx or a 429. Any other answer, such as a rejected API
+// key, shows the backend is reachable.
+func isConnectivityError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= http.StatusInternalServerError || statusErr.code == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// trackConnectivityLocked counts consecutive backend failures. After
+// OFFLINE_AFTER_FAILURES of them the client goes offline and probes the backend with
+// exponential backoff; the first success brings it back. The caller holds statusMutex.
+func (c *Client) trackConnectivityLocked(err error) {
+	now := time.Now()
+	if err == nil {
+		if !c.offlineSince.IsZero() {
+			log.Printf("Device client: backend reachable again after %s offline", now.Sub(c.offlineSince).Round(time.Second))
+		}
+		c.backendFailures = 0
+		c.offlineSince = time.Time{}
+		c.probeBackoff = 0
+		return
+	}
+
+	c.backendFailures++
+	if c.config.OfflineAfterFailures < 0 || c.backendFailures < c.config.OfflineAfterFailures {
+		return
+	}
+	if c.offlineSince.IsZero() {
+		log.Printf("Device client: backend unreachable after %d consecutive failures, going offline: %v", c.backendFailures, err)
+		c.offlineSince = now
+		c.probeBackoff = c.pollInterval
+	} else {
+		c.probeBackoff *= 2
+		if limit := time.Duration(c.config.OfflineProbeMaxIntervalSeconds) * time.Second; c.probeBackoff > limit {
+			c.probeBackoff = limit
+		}
+	}
+	c.nextProbe = now.Add(c.probeBackoff)
+}
+
+// IsOffline reports whether the client has given up on the backend until a probe
+// succeeds.
+func (c *Client) IsOffline() bool {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	return !c.offlineSince.IsZero()
+}
+
+func (c *Client) offlineProbeDue() bool {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	return !time.Now().Before(c.nextProbe)
+}
+
+// pollOffline runs one poll cycle without the backend. The sensors and jam detection
+// keep running and a ball is made ready, but no payment is created and no command is
+// fetched. A running payment is only reset once it is past its local deadline.
+func (c *Client) pollOffline() {
+	c.refreshLatchedStates()
+	if c.jammed.Load() || c.actuatorStalled.Load() || c.stopped.Load() {
+		return
+	}
+
+	if paymentID := c.GetPaymentID(); paymentID != "" {
+		if overdue := c.paymentOverdue(c.getCurrentPayment()); overdue != "" {
+			c.expirePayment(paymentID, overdue, "", ErrOffline)
+		}
+	} else if !c.ballReady.Load() {
+		referenceBaseline := c.consumePendingBallReference()
+		if err := c.waitForBallReady(false, true, referenceBaseline); err != nil {
+			log.Printf("Device client: ball detection failed while offline: %v", err)
+			return
+		}
+		c.ballReady.Store(true)
+	}
+	c.setRuntimeState(StateOffline, offlineMessage)
+}
+
+// queueAck keeps an ack for replayQueuedAcks.
+func (c *Client) queueAck(commandID int, ack AckRequest) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	if len(c.queuedAcks) >= maxQueuedAcks {
+		log.Printf("Device client: ack queue full, dropping ack for command %d", c.queuedAcks[0].commandID)
+		c.queuedAcks = c.queuedAcks[1:]
+	}
+	c.queuedAcks = append(c.queuedAcks, queuedAck{commandID: commandID, request: ack})
+	log.Printf("Device client: queued ack for command %d for replay (%d queued)", commandID, len(c.queuedAcks))
+}
+
+// ackQueued reports whether the ack for commandID is still waiting to be replayed.
+func (c *Client) ackQueued(commandID int) bool {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	for _, queued := range c.queuedAcks {
+		if queued.commandID == commandID {
+			return true
+		}
+	}
+	return false
+}
+
+// trackUnsentReport keeps the last report of a payment that failed to reach the
+// backend, so its dispensed_count still arrives after the payment was reset, e.g. by
+// expirePayment while offline. Reports without a payment only carry counters the next
+// report repeats, so they are not kept.
+func (c *Client) trackUnsentReport(req StatusRequest, err error) {
+	if req.PaymentID == nil {
+		return
+	}
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	switch {
+	case err == nil:
+		if c.unsentReport != nil && *c.unsentReport.PaymentID == *req.PaymentID {
+			c.unsentReport = nil
+		}
+	case isConnectivityError(err):
+		c.unsentReport = &req
+	}
+}
+
+// replayUnsentReport sends the report kept by trackUnsentReport once the payment it
+// belongs to is no longer current; while it is, the next report supersedes it. It
+// returns the error of a replay the backend could not take, and keeps the report for
+// the next attempt.
+func (c *Client) replayUnsentReport(paymentID string) error {
+	c.statusMutex.Lock()
+	report := c.unsentReport
+	c.statusMutex.Unlock()
+	if report == nil || *report.PaymentID == paymentID {
+		return nil
+	}
+
+	log.Printf("Device client: replaying the undelivered report for payment %s (dispensed_count=%d)", *report.PaymentID, *report.DispensedCount)
+	err := c.postStatus(*report)
+	if err != nil && isConnectivityError(err) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Device client: dropping the undelivered report for payment %s: %v", *report.PaymentID, err)
+	}
+	c.statusMutex.Lock()
+	if c.unsentReport == report {
+		c.unsentReport = nil
+	}
+	c.statusMutex.Unlock()
+	return nil
+}
+
+// replayQueuedAcks sends the queued acks oldest first and stops at the first one the
+// backend still cannot take. Acks it rejects outright are dropped.
+func (c *Client) replayQueuedAcks() {
+	for {
+		c.statusMutex.Lock()
+		if len(c.queuedAcks) == 0 {
+			c.statusMutex.Unlock()
+			return
+		}
+		next := c.queuedAcks[0]
+		c.statusMutex.Unlock()
+
+		retry, err := c.postAck(next.commandID, next.request)
+		if err != nil && retry {
+			log.Printf("Device client: replaying ack for command %d failed, keeping it queued: %v", next.commandID, err)
+			return
+		}
+		if err != nil {
+			log.Printf("Device client: dropping queued ack for command %d: %v", next.commandID, err)
+		}
+
+		c.statusMutex.Lock()
+		if len(c.queuedAcks) > 0 && c.queuedAcks[0].commandID == next.commandID {
+			c.queuedAcks = c.queuedAcks[1:]
+		}
+		c.statusMutex.Unlock()
+	}
+}
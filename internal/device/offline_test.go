@@ -0,0 +1,223 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOfflineBackoff(t *testing.T) {
+	c := newTestClient("http://127.0.0.1:1")
+	c.pollInterval = time.Second
+	c.config.OfflineProbeMaxIntervalSeconds = 3
+	failure := fmt.Errorf("request failed: %w", &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: errors.New("connection refused")})
+
+	c.recordBackendResult(failure)
+	c.recordBackendResult(failure)
+	if c.IsOffline() {
+		t.Fatal("expected the client to stay online below OFFLINE_AFTER_FAILURES")
+	}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		c.recordBackendResult(failure)
+		if !c.IsOffline() || c.probeBackoff != want {
+			t.Fatalf("expected offline with a %s probe backoff, got offline=%t backoff=%s", want, c.IsOffline(), c.probeBackoff)
+		}
+	}
+	if c.offlineProbeDue() {
+		t.Fatal("expected the next probe to wait for the backoff")
+	}
+
+	c.recordBackendResult(nil)
+	if c.IsOffline() || c.backendFailures != 0 {
+		t.Fatalf("expected a success to bring the client back online, failures=%d", c.backendFailures)
+	}
+
+	// Answers that show a reachable backend do not count; 5xx and 429 do.
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound} {
+		c.recordBackendResult(&statusError{code: code})
+	}
+	c.recordBackendResult(errors.New("server returned success=false"))
+	if c.backendFailures != 0 {
+		t.Fatalf("expected 4xx answers not to count, got %d failures", c.backendFailures)
+	}
+	c.recordBackendResult(&statusError{code: http.StatusServiceUnavailable})
+	c.recordBackendResult(&statusError{code: http.StatusTooManyRequests})
+	if c.backendFailures != 2 {
+		t.Fatalf("expected 503 and 429 to count, got %d failures", c.backendFailures)
+	}
+	c.recordBackendResult(&statusError{code: http.StatusUnauthorized})
+	if c.backendFailures != 0 {
+		t.Fatalf("expected a 401 to show the backend reachable, got %d failures", c.backendFailures)
+	}
+
+	c.config.OfflineAfterFailures = -1
+	for range 10 {
+		c.recordBackendResult(failure)
+	}
+	if c.IsOffline() {
+		t.Fatal("expected a negative OFFLINE_AFTER_FAILURES to disable offline mode")
+	}
+}
+
+// ensure an offline client keeps detecting balls without creating payments and replays
+// the queued command acks once a probe reaches the backend
+func TestOfflineModeKeepsSensorsAndReplaysAcks(t *testing.T) {
+	var backendDown atomic.Bool
+	var requests, payments, acks atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if backendDown.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/payment":
+			payments.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"pay-1","status":"waiting"}`))
+		case "/api/v1/device/commands/7/ack":
+			acks.Add(1)
+			w.Write([]byte(`{"success":true}`))
+		default:
+			w.Write([]byte(`{"success":true}`))
+		}
+	}))
+	defer backend.Close()
+
+	c := newTestClient(backend.URL)
+	c.config.LogShippingEnabled = false
+	c.config.DebugBypassBallDetection = true
+
+	backendDown.Store(true)
+	if err := c.ackCommand(7, nil, commandResult{}); err == nil || !c.ackQueued(7) {
+		t.Fatalf("expected the failed ack to be queued, got %v", err)
+	}
+	for range c.config.OfflineAfterFailures {
+		c.recordBackendResult(&statusError{code: http.StatusServiceUnavailable})
+	}
+
+	before := requests.Load()
+	c.poll()
+	if state := c.GetStateSnapshot().State; state != string(StateOffline) || !c.ballReady.Load() {
+		t.Fatalf("expected offline with a ready ball, got %s (ball ready %t)", state, c.ballReady.Load())
+	}
+	if requests.Load() != before {
+		t.Fatalf("expected no backend request before the probe is due, got %d", requests.Load()-before)
+	}
+	if _, err := c.CreatePayment(); !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline from the local API, got %v", err)
+	}
+
+	// A failed probe keeps the client offline and doubles the backoff.
+	c.statusMutex.Lock()
+	c.nextProbe = time.Now()
+	backoff := c.probeBackoff
+	c.statusMutex.Unlock()
+	c.poll()
+	if !c.IsOffline() || c.probeBackoff != 2*backoff || payments.Load() != 0 {
+		t.Fatalf("expected a failed probe to stay offline with backoff %s, got %s (payments %d)", 2*backoff, c.probeBackoff, payments.Load())
+	}
+
+	backendDown.Store(false)
+	c.statusMutex.Lock()
+	c.nextProbe = time.Now()
+	c.statusMutex.Unlock()
+	c.poll()
+	if c.IsOffline() || acks.Load() != 1 || c.ackQueued(7) {
+		t.Fatalf("expected the probe to reconnect and replay the ack, offline=%t acks=%d", c.IsOffline(), acks.Load())
+	}
+	if payments.Load() != 1 || c.GetPaymentID() != "pay-1" {
+		t.Fatalf("expected a payment once back online, got %d (%q)", payments.Load(), c.GetPaymentID())
+	}
+}
+
+// ensure the estop watch's command checks stay out of the offline bookkeeping
+func TestEmergencyStopWatchDoesNotCountFailures(t *testing.T) {
+	var fetches atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/device/commands" {
+			fetches.Add(1)
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	c := newTestClient(backend.URL)
+	c.config.LogShippingEnabled = false
+	endWatch := c.watchRemoteEmergencyStop(0)
+	time.Sleep(emergencyStopWatchInterval + 200*time.Millisecond)
+	endWatch()
+
+	if fetches.Load() == 0 {
+		t.Fatal("expected the watch to check the command endpoint")
+	}
+	c.statusMutex.Lock()
+	failures := c.backendFailures
+	c.statusMutex.Unlock()
+	if failures != 0 {
+		t.Fatalf("expected no counted failures, got %d", failures)
+	}
+}
+
+// ensure the last undelivered report of a payment reset while offline is sent before
+// the current report once the backend is back
+func TestOfflineReplaysUnsentPaymentReport(t *testing.T) {
+	var backendDown atomic.Bool
+	var mu sync.Mutex
+	var reports []StatusRequest
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if backendDown.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/api/v1/device/status" {
+			var report StatusRequest
+			json.NewDecoder(r.Body).Decode(&report)
+			mu.Lock()
+			reports = append(reports, report)
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer backend.Close()
+
+	c := newTestClient(backend.URL)
+	c.config.LogShippingEnabled = false
+	c.config.DebugBypassBallDetection = true
+
+	backendDown.Store(true)
+	c.SetPaymentID("pay-1")
+	c.recordDispensedCount("pay-1", 1)
+	for range c.config.OfflineAfterFailures {
+		c.recordBackendResult(c.reportStatus("pay-1"))
+	}
+	if !c.IsOffline() {
+		t.Fatal("expected the failed reports to take the client offline")
+	}
+	// The payment is reset locally while offline.
+	c.SetPaymentID("")
+
+	backendDown.Store(false)
+	c.statusMutex.Lock()
+	c.nextProbe = time.Now()
+	c.statusMutex.Unlock()
+	c.poll()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) < 2 || reports[0].PaymentID == nil || *reports[0].PaymentID != "pay-1" || *reports[0].DispensedCount != 1 || reports[1].PaymentID != nil {
+		t.Fatalf("expected the pay-1 report before the current one, got %+v", reports)
+	}
+	if c.IsOffline() || c.unsentReport != nil {
+		t.Fatalf("expected the replay to bring the client online and clear the report, offline=%t", c.IsOffline())
+	}
+}
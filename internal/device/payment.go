@@ -0,0 +1,118 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+)
+
+// Errors returned by CreatePayment when the machine cannot take a new payment.
+var (
+	ErrPaymentInProgress = errors.New("a payment is already in progress")
+	ErrNoBallReady       = errors.New("no ball ready on the sensor")
+)
+
+// CreatePayment creates a payment for the ball waiting on the sensor, as the state
+// machine does after ball detection. The local /api/payment endpoint uses it so a
+// kiosk can retry a payment the state machine failed to create.
+func (c *Client) CreatePayment() (map[string]any, error) {
+	log.Printf("Device client: creating payment from the local API")
+	return c.startPayment()
+}
+
+// startPayment creates at most one payment per detected ball. The state machine and
+// CreatePayment both go through it.
+func (c *Client) startPayment() (map[string]any, error) {
+	c.paymentCreateMutex.Lock()
+	defer c.paymentCreateMutex.Unlock()
+
+	if c.GetPaymentID() != "" {
+		return nil, ErrPaymentInProgress
+	}
+	if c.IsOffline() {
+		return nil, ErrOffline
+	}
+	if c.stopped.Load() || c.actuatorStalled.Load() || c.jammed.Load() {
+		c.statusMutex.Lock()
+		state := c.state
+		c.statusMutex.Unlock()
+		return nil, fmt.Errorf("%w (%s)", ErrNoBallReady, state)
+	}
+	if !c.ballReady.Load() {
+		return nil, ErrNoBallReady
+	}
+
+	_, err := c.createPayment()
+	c.recordBackendResult(err)
+	if err != nil {
+		return nil, err
+	}
+	c.ballReady.Store(false)
+	c.setRuntimeState(StateBallDetected, "Bitte QR-Code scannen und Betrag wählen")
+	return c.getCurrentPayment(), nil
+}
+
+// newPaymentCreateRequest builds the payment request body from the config. The kiosk
+// location and event are added to the metadata so the backend can attribute sales;
+// they take precedence over the same keys in PAYMENT_METADATA.
+func newPaymentCreateRequest(cfg *config.Config) paymentCreateRequest {
+	req := paymentCreateRequest{
+		Currency:           strings.ToUpper(strings.TrimSpace(cfg.PaymentCurrency)),
+		PaymentRedirectURL: strings.TrimSpace(cfg.PaymentRedirectURL),
+		Description:        strings.TrimSpace(cfg.PaymentDescription),
+		AmountOptionsCents: parseAmountOptions(cfg.PaymentAmountOptionsCents),
+	}
+
+	metadata := parsePaymentMetadata(cfg.PaymentMetadata)
+	if location := strings.TrimSpace(cfg.KioskLocation); location != "" {
+		metadata["location"] = location
+	}
+	if event := strings.TrimSpace(cfg.KioskEvent); event != "" {
+		metadata["event"] = event
+	}
+	if len(metadata) > 0 {
+		req.Metadata = metadata
+	}
+	return req
+}
+
+// parseAmountOptions parses a comma-separated list of amounts in cents, e.g. "500,1000".
+func parseAmountOptions(list string) []int {
+	var amounts []int
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		amount, err := strconv.Atoi(entry)
+		if err != nil || amount <= 0 {
+			log.Printf("Warning: ignoring invalid PAYMENT_AMOUNT_OPTIONS_CENTS entry %q", entry)
+			continue
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts
+}
+
+// parsePaymentMetadata parses comma-separated key=value pairs, e.g. "operator=verein,till=2".
+func parsePaymentMetadata(list string) map[string]string {
+	metadata := make(map[string]string)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			log.Printf("Warning: ignoring invalid PAYMENT_METADATA entry %q", entry)
+			continue
+		}
+		metadata[key] = strings.TrimSpace(value)
+	}
+	return metadata
+}
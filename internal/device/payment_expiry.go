@@ -0,0 +1,80 @@
+package device
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// paymentDeadline returns the local deadline of the payment's current phase: the
+// backend's amount_selection_expires_at or payment_expires_at plus the configured
+// grace period. ok is false when the payment carries no usable expiry for the phase
+// or its grace is negative (enforcement disabled).
+func (c *Client) paymentDeadline(payment map[string]any) (deadline time.Time, field string, ok bool) {
+	field, grace := "amount_selection_expires_at", c.config.PaymentAmountSelectionGraceSeconds
+	if paymentPhase(payment) == "waiting_for_payment" {
+		field, grace = "payment_expires_at", c.config.PaymentExpiryGraceSeconds
+	}
+	expiresAt, ok := parsePaymentTime(payment[field])
+	if !ok && field == "amount_selection_expires_at" {
+		// Without an amount selection expiry the payment expiry still bounds the wait.
+		field, grace = "payment_expires_at", c.config.PaymentExpiryGraceSeconds
+		expiresAt, ok = parsePaymentTime(payment[field])
+	}
+	if !ok || grace < 0 {
+		return time.Time{}, "", false
+	}
+	return expiresAt.Add(time.Duration(grace) * time.Second), field, true
+}
+
+// paymentOverdue describes the passed deadline, or returns "" while the payment is
+// still within its deadline or has none.
+func (c *Client) paymentOverdue(payment map[string]any) string {
+	deadline, field, ok := c.paymentDeadline(payment)
+	if !ok || time.Now().Before(deadline) {
+		return ""
+	}
+	return fmt.Sprintf("%s=%v, deadline with grace %s", field, payment[field], deadline.Format(time.RFC3339))
+}
+
+// parsePaymentTime parses a backend timestamp. RFC 3339 is expected; a timestamp
+// without a zone is taken as UTC.
+func parsePaymentTime(value any) (time.Time, bool) {
+	text, _ := value.(string)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05.999999999", text); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// expirePayment resets a payment the backend did not resolve by its local deadline.
+// statusErr is the failed recheck, or nil when the backend still reports it open.
+func (c *Client) expirePayment(paymentID, overdue, status string, statusErr error) {
+	if statusErr != nil {
+		log.Printf("Device client: payment %s expired locally (%s); status recheck failed: %v", paymentID, overdue, statusErr)
+	} else {
+		log.Printf("Device client: payment %s expired locally (%s) but the backend still reports status=%s payment_phase=%s", paymentID, overdue, status, c.currentPaymentPhase())
+	}
+	c.failPayment()
+}
+
+// failPayment shows payment_failed briefly and returns to ball detection.
+func (c *Client) failPayment() {
+	c.setRuntimeState(StatePaymentFailed, "Zahlung fehlgeschlagen")
+	c.setExecutingCommand(&CommandResponse{
+		Command: "message",
+		Message: "Zahlung abgebrochen - zurückgesetzt",
+	})
+	c.SetPaymentID("")
+	time.Sleep(500 * time.Millisecond)
+	c.clearExecutingCommand()
+	c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+}
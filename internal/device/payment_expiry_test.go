@@ -0,0 +1,113 @@
+package device
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParsePaymentTime(t *testing.T) {
+	cases := map[string]bool{
+		"2026-10-18T12:00:00Z":             true,
+		"2026-10-18T12:00:00.123456+02:00": true,
+		"2026-10-18T12:00:00.123456":       true,
+		"":                                 false,
+		"tomorrow":                         false,
+	}
+	for input, want := range cases {
+		if _, ok := parsePaymentTime(input); ok != want {
+			t.Fatalf("parsePaymentTime(%q) ok=%t, want %t", input, ok, want)
+		}
+	}
+	if parsed, _ := parsePaymentTime("2026-10-18T12:00:00"); !parsed.Equal(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected a zoneless timestamp to be UTC, got %s", parsed)
+	}
+	if _, ok := parsePaymentTime(1234); ok {
+		t.Fatal("expected a non-string expiry to be ignored")
+	}
+}
+
+func TestPaymentDeadlinePerPhase(t *testing.T) {
+	c := newTestClient("http://127.0.0.1:1")
+	expiresAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	payment := map[string]any{
+		"payment_phase":               "waiting_for_amount",
+		"amount_selection_expires_at": expiresAt.Format(time.RFC3339),
+		"payment_expires_at":          expiresAt.Add(10 * time.Minute).Format(time.RFC3339),
+	}
+
+	deadline, field, ok := c.paymentDeadline(payment)
+	if !ok || field != "amount_selection_expires_at" || !deadline.Equal(expiresAt.Add(15*time.Second)) {
+		t.Fatalf("unexpected amount selection deadline %s (%s, %t)", deadline, field, ok)
+	}
+
+	payment["payment_phase"] = "waiting_for_payment"
+	deadline, field, ok = c.paymentDeadline(payment)
+	if !ok || field != "payment_expires_at" || !deadline.Equal(expiresAt.Add(10*time.Minute+time.Minute)) {
+		t.Fatalf("unexpected payment deadline %s (%s, %t)", deadline, field, ok)
+	}
+
+	c.config.PaymentExpiryGraceSeconds = -1
+	if _, _, ok := c.paymentDeadline(payment); ok {
+		t.Fatal("expected a negative grace to disable the deadline")
+	}
+}
+
+// ensure an open payment past its deadline is reset after one recheck, whether the
+// backend still reports it open or cannot be reached
+func TestRunStateMachineCycleExpiresPaymentLocally(t *testing.T) {
+	var statusRequests atomic.Int32
+	var backendDown atomic.Bool
+	var expiresAt atomic.Value
+	expiresAt.Store(time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339))
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v1/payment/pay-1" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		statusRequests.Add(1)
+		if backendDown.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"pay-1","status":"waiting","payment_phase":"waiting_for_payment","payment_expires_at":"` + expiresAt.Load().(string) + `"}`))
+	}))
+	defer backend.Close()
+
+	c := newTestClient(backend.URL)
+	c.config.LogShippingEnabled = false
+
+	// Within the deadline the kiosk keeps waiting.
+	c.SetPaymentID("pay-1")
+	c.runStateMachineCycle()
+	if state := c.GetStateSnapshot().State; state != string(StateAwaitingPayment) || c.GetPaymentID() != "pay-1" {
+		t.Fatalf("expected awaiting_payment for pay-1, got %s (%q)", state, c.GetPaymentID())
+	}
+
+	// The backend keeps reporting the payment open past its expiry and grace.
+	expiresAt.Store(time.Now().Add(-2 * time.Minute).UTC().Format(time.RFC3339))
+	c.runStateMachineCycle()
+	if state := c.GetStateSnapshot().State; state != string(StateDetectingBall) || c.GetPaymentID() != "" {
+		t.Fatalf("expected the overdue payment to be reset, got %s (%q)", state, c.GetPaymentID())
+	}
+
+	// A known expiry also bounds the wait while the backend is unreachable.
+	c.SetPaymentID("pay-1")
+	c.setCurrentPayment("pay-1", map[string]any{
+		"status":             "waiting",
+		"payment_phase":      "waiting_for_payment",
+		"payment_expires_at": expiresAt.Load().(string),
+	})
+	backendDown.Store(true)
+	before := statusRequests.Load()
+	c.runStateMachineCycle()
+	if statusRequests.Load() != before+1 {
+		t.Fatalf("expected one status recheck, got %d", statusRequests.Load()-before)
+	}
+	if state := c.GetStateSnapshot().State; state != string(StateDetectingBall) || c.GetPaymentID() != "" {
+		t.Fatalf("expected the unreachable overdue payment to be reset, got %s (%q)", state, c.GetPaymentID())
+	}
+}
@@ -0,0 +1,132 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+)
+
+// ensure the state machine and the local API create one payment per ball between them
+func TestCreatePaymentSharesStateMachinePath(t *testing.T) {
+	var creations atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/payment":
+			var payload map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload["currency"] != "CHF" {
+				t.Errorf("unexpected payment request %v (%v)", payload, err)
+			}
+			// The state machine's attempt fails; the retry from the local API succeeds.
+			if creations.Add(1) == 1 {
+				http.Error(w, "backend busy", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"pay-1","status":"waiting"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/payment/pay-1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"pay-1","status":"waiting"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer backend.Close()
+
+	c := newTestClient(backend.URL)
+	c.config.LogShippingEnabled = false
+	c.config.DebugBypassBallDetection = true
+
+	if _, err := c.CreatePayment(); !errors.Is(err, ErrNoBallReady) {
+		t.Fatalf("expected ErrNoBallReady before ball detection, got %v", err)
+	}
+	if creations.Load() != 0 {
+		t.Fatal("expected no backend request without a ball")
+	}
+
+	c.runStateMachineCycle()
+	if state := c.GetStateSnapshot().State; state != string(StateError) || creations.Load() != 1 {
+		t.Fatalf("expected the failed creation to show an error, got %s after %d requests", state, creations.Load())
+	}
+
+	payment, err := c.CreatePayment()
+	if err != nil {
+		t.Fatalf("expected the local API to create the payment for the waiting ball, got %v", err)
+	}
+	if payment["id"] != "pay-1" || c.GetPaymentID() != "pay-1" {
+		t.Fatalf("expected payment pay-1, got %v (current %q)", payment, c.GetPaymentID())
+	}
+	if state := c.GetStateSnapshot().State; state != string(StateBallDetected) {
+		t.Fatalf("expected ball_detected, got %s", state)
+	}
+
+	if _, err := c.CreatePayment(); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("expected ErrPaymentInProgress, got %v", err)
+	}
+	c.runStateMachineCycle()
+	if creations.Load() != 2 {
+		t.Fatalf("expected the state machine to follow the existing payment, got %d creations", creations.Load())
+	}
+
+	c.SetPaymentID("")
+	c.ballReady.Store(true)
+	c.stopped.Store(true)
+	if _, err := c.CreatePayment(); !errors.Is(err, ErrNoBallReady) {
+		t.Fatalf("expected a latched stop to refuse payments, got %v", err)
+	}
+}
+
+func TestCreatePaymentSendsConfiguredRequest(t *testing.T) {
+	var payload paymentCreateRequest
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/payment" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode payment request: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"pay-1","status":"waiting"}`))
+	}))
+	defer backend.Close()
+
+	cfg := newTestClient(backend.URL).config
+	cfg.LogShippingEnabled = false
+	cfg.PaymentCurrency = " eur "
+	cfg.PaymentRedirectURL = "https://kiosk.example.org/done"
+	cfg.PaymentDescription = "Bändeli"
+	cfg.PaymentAmountOptionsCents = "500, 1000,abc,-1,2000"
+	cfg.PaymentMetadata = "operator=verein, location=ignored, broken"
+	cfg.KioskLocation = "Bern Eingang"
+	cfg.KioskEvent = "Gurten 2026"
+	c := New(cfg)
+
+	if _, err := c.createPayment(); err != nil {
+		t.Fatalf("createPayment failed: %v", err)
+	}
+	if payload.Currency != "EUR" || payload.PaymentRedirectURL != "https://kiosk.example.org/done" || payload.Description != "Bändeli" {
+		t.Fatalf("unexpected payment request %+v", payload)
+	}
+	if len(payload.AmountOptionsCents) != 3 || payload.AmountOptionsCents[0] != 500 || payload.AmountOptionsCents[2] != 2000 {
+		t.Fatalf("unexpected amount options %v", payload.AmountOptionsCents)
+	}
+	want := map[string]string{"operator": "verein", "location": "Bern Eingang", "event": "Gurten 2026"}
+	if len(payload.Metadata) != len(want) {
+		t.Fatalf("unexpected metadata %v", payload.Metadata)
+	}
+	for key, value := range want {
+		if payload.Metadata[key] != value {
+			t.Fatalf("expected metadata %s=%q, got %v", key, value, payload.Metadata)
+		}
+	}
+
+	if req := newPaymentCreateRequest(&config.Config{PaymentCurrency: "CHF"}); req.Metadata != nil || req.AmountOptionsCents != nil {
+		t.Fatalf("expected optional fields to be omitted, got %+v", req)
+	}
+}
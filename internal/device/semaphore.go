@@ -0,0 +1,35 @@
+package device
+
+import "context"
+
+// semaphore is a mutex whose wait can be abandoned: a channel with one slot. Make one
+// with newSemaphore; the zero value blocks forever.
+type semaphore chan struct{}
+
+func newSemaphore() semaphore {
+	return make(semaphore, 1)
+}
+
+// Lock takes the semaphore, waiting as long as it takes.
+func (s semaphore) Lock() {
+	s <- struct{}{}
+}
+
+// LockContext takes the semaphore unless ctx ends first, and then returns ctx.Err().
+func (s semaphore) LockContext(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unlock releases the semaphore taken by Lock or LockContext.
+func (s semaphore) Unlock() {
+	select {
+	case <-s:
+	default:
+		panic("device: unlock of unlocked semaphore")
+	}
+}
@@ -0,0 +1,150 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// stateEventSettle coalesces the setter calls of one transition (state, payment,
+	// command overlay) into a single event, so subscribers never see a half-applied change.
+	stateEventSettle = 50 * time.Millisecond
+	// stateEventBacklog is how many events a reconnecting subscriber can resume from.
+	stateEventBacklog = 64
+	// stateEventBuffer is how far a subscriber may fall behind before it is dropped.
+	stateEventBuffer = 16
+)
+
+// StateEvent is one published state snapshot. IDs increase by one per change and
+// restart at 1 with the process.
+type StateEvent struct {
+	ID uint64
+	// Data is the StateSnapshot encoded as JSON.
+	Data []byte
+}
+
+// stateEvents publishes a snapshot whenever the runtime state, the executing or pending
+// command or the payment changes.
+type stateEvents struct {
+	client *Client
+
+	mu          sync.Mutex
+	scheduled   bool
+	timer       *time.Timer // the scheduled publish, nil once it ran
+	stopped     bool
+	lastID      uint64
+	last        []byte
+	backlog     []StateEvent
+	subscribers map[chan StateEvent]struct{}
+}
+
+func newStateEvents(c *Client) *stateEvents {
+	return &stateEvents{client: c, subscribers: make(map[chan StateEvent]struct{})}
+}
+
+// notifyStateChange schedules a snapshot event. It only takes the publisher's own lock
+// and is safe to call while holding statusMutex or paymentIDMutex.
+func (c *Client) notifyStateChange() {
+	e := c.stateEvents
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.scheduled || e.stopped {
+		return
+	}
+	e.scheduled = true
+	e.timer = time.AfterFunc(stateEventSettle, e.publish)
+}
+
+// stop cancels a scheduled publish and schedules no more; Stop calls it once the poll
+// loop has ended.
+func (e *stateEvents) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopped = true
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.scheduled = false
+}
+
+// publish takes a snapshot and sends it to all subscribers unless it is unchanged.
+func (e *stateEvents) publish() {
+	e.mu.Lock()
+	e.scheduled = false
+	e.timer = nil
+	e.mu.Unlock()
+
+	data, err := json.Marshal(e.client.GetStateSnapshot())
+	if err != nil {
+		log.Printf("Device client: failed to encode state event: %v", err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if bytes.Equal(data, e.last) {
+		return
+	}
+	e.last = data
+	e.lastID++
+	event := StateEvent{ID: e.lastID, Data: data}
+	e.backlog = append(e.backlog, event)
+	if len(e.backlog) > stateEventBacklog {
+		e.backlog = e.backlog[len(e.backlog)-stateEventBacklog:]
+	}
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Too slow: close the stream so the subscriber reconnects and resumes.
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SubscribeState streams state snapshots. The returned replay holds the events after
+// lastEventID, or just the current snapshot when lastEventID is 0 or no longer in the
+// backlog. The events channel is closed when the subscriber falls behind; call cancel
+// when done.
+func (c *Client) SubscribeState(lastEventID uint64) (replay []StateEvent, events <-chan StateEvent, cancel func()) {
+	e := c.stateEvents
+	e.publish()
+
+	ch := make(chan StateEvent, stateEventBuffer)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	replay = e.replayLocked(lastEventID)
+	e.subscribers[ch] = struct{}{}
+
+	cancel = func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, ok := e.subscribers[ch]; ok {
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+	return replay, ch, cancel
+}
+
+func (e *stateEvents) replayLocked(lastEventID uint64) []StateEvent {
+	if len(e.backlog) == 0 {
+		return nil
+	}
+	if lastEventID > 0 {
+		for i, event := range e.backlog {
+			if event.ID == lastEventID {
+				return append([]StateEvent(nil), e.backlog[i+1:]...)
+			}
+		}
+	}
+	return []StateEvent{e.backlog[len(e.backlog)-1]}
+}
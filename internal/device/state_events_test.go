@@ -0,0 +1,102 @@
+package device
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func receiveStateEvent(t *testing.T, events <-chan StateEvent) (StateEvent, StateSnapshot) {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		var snapshot StateSnapshot
+		if err := json.Unmarshal(event.Data, &snapshot); err != nil {
+			t.Fatalf("invalid event data: %v", err)
+		}
+		return event, snapshot
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a state event")
+	}
+	return StateEvent{}, StateSnapshot{}
+}
+
+// ensure one transition made of several setter calls is published as a single event
+func TestStateEventsCoalesceTransition(t *testing.T) {
+	c := newTestClient("http://127.0.0.1:1")
+
+	replay, events, cancel := c.SubscribeState(0)
+	defer cancel()
+	if len(replay) != 1 || replay[0].ID != 1 {
+		t.Fatalf("expected the current snapshot as replay, got %+v", replay)
+	}
+
+	c.setCurrentPayment("pay-1", map[string]any{"payment_phase": "waiting_for_payment"})
+	c.setRuntimeState(StateAwaitingPayment, "Warten auf Zahlung")
+	c.setExecutingCommand(&CommandResponse{Command: "message", Message: "Warten auf Zahlung"})
+
+	event, snapshot := receiveStateEvent(t, events)
+	if event.ID != 2 || snapshot.State != string(StateAwaitingPayment) || snapshot.PaymentID != "pay-1" || snapshot.ExecutingCommand == nil {
+		t.Fatalf("expected one settled transition, got id=%d %+v", event.ID, snapshot)
+	}
+	select {
+	case extra := <-events:
+		t.Fatalf("expected no intermediate events, got %s", extra.Data)
+	case <-time.After(3 * stateEventSettle):
+	}
+
+	// An unchanged snapshot is not published again.
+	c.setRuntimeState(StateAwaitingPayment, "Warten auf Zahlung")
+	select {
+	case extra := <-events:
+		t.Fatalf("expected no event for an unchanged state, got %s", extra.Data)
+	case <-time.After(3 * stateEventSettle):
+	}
+}
+
+func TestStateEventsResumeAfterLastEventID(t *testing.T) {
+	c := newTestClient("http://127.0.0.1:1")
+	_, events, cancel := c.SubscribeState(0)
+
+	c.setRuntimeState(StateBallOnSensor, "Ball auf Sensor erkannt")
+	first, _ := receiveStateEvent(t, events)
+	c.setRuntimeState(StateBallDetected, "Erstelle Zahlung")
+	receiveStateEvent(t, events)
+	c.SetPaymentID("pay-2")
+	receiveStateEvent(t, events)
+	cancel()
+
+	replay, _, cancel := c.SubscribeState(first.ID)
+	defer cancel()
+	if len(replay) != 2 || replay[0].ID != first.ID+1 || replay[1].ID != first.ID+2 {
+		t.Fatalf("expected the two events after %d, got %+v", first.ID, replay)
+	}
+
+	replay, _, cancel = c.SubscribeState(9999)
+	defer cancel()
+	if len(replay) != 1 || replay[0].ID != first.ID+2 {
+		t.Fatalf("expected only the latest event for an unknown ID, got %+v", replay)
+	}
+}
+
+// ensure stopping the publisher cancels the scheduled event and schedules no more
+func TestStateEventsStop(t *testing.T) {
+	c := newTestClient("http://127.0.0.1:1")
+	_, events, cancel := c.SubscribeState(0)
+	defer cancel()
+
+	c.setRuntimeState(StateAwaitingPayment, "Warten auf Zahlung")
+	c.stateEvents.stop()
+	c.setRuntimeState(StateDetectingBall, "Warte auf Ball")
+	if c.stateEvents.timer != nil {
+		t.Fatal("expected no publish scheduled after stop")
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("expected no event after stop, got %s", event.Data)
+	case <-time.After(3 * stateEventSettle):
+	}
+}
@@ -36,16 +36,16 @@ func TestTakePictureCommandSuccess(t *testing.T) {
 		Command: "take_picture",
 	}
 
-	imageData, err := client.executeCommand(cmd)
+	result, err := client.executeCommand(cmd)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
-	if imageData == "" {
+	if result.imageBase64 == "" {
 		t.Fatal("expected non-empty base64 image data")
 	}
 
 	// Verify the returned value is valid base64
-	decoded, err := base64.StdEncoding.DecodeString(imageData)
+	decoded, err := base64.StdEncoding.DecodeString(result.imageBase64)
 	if err != nil {
 		t.Fatalf("returned image_data is not valid base64: %v", err)
 	}
@@ -70,12 +70,12 @@ func TestTakePictureCommandCameraNotInitialised(t *testing.T) {
 		Command: "take_picture",
 	}
 
-	imageData, err := client.executeCommand(cmd)
+	result, err := client.executeCommand(cmd)
 	if err == nil {
 		t.Fatal("expected error when camera not initialised, got nil")
 	}
-	if imageData != "" {
-		t.Errorf("expected empty image data on failure, got %q", imageData)
+	if result.imageBase64 != "" {
+		t.Errorf("expected empty image data on failure, got %q", result.imageBase64)
 	}
 }
 
@@ -100,7 +100,7 @@ func TestAckRequestIncludesImageBase64OnSuccess(t *testing.T) {
 	client := New(cfg)
 
 	fakeImage := base64.StdEncoding.EncodeToString([]byte("fake-jpeg-bytes"))
-	err := client.ackCommand(99, nil, fakeImage)
+	err := client.ackCommand(99, nil, commandResult{imageBase64: fakeImage})
 	if err != nil {
 		t.Fatalf("ackCommand returned unexpected error: %v", err)
 	}
@@ -141,7 +141,7 @@ func TestAckRequestOmitsImageBase64OnFailure(t *testing.T) {
 	client := New(cfg)
 
 	execErr := fmt.Errorf("camera unavailable")
-	err := client.ackCommand(100, execErr, "")
+	err := client.ackCommand(100, execErr, commandResult{})
 	if err != nil {
 		t.Fatalf("ackCommand returned unexpected error: %v", err)
 	}
@@ -158,3 +158,30 @@ func TestAckRequestOmitsImageBase64OnFailure(t *testing.T) {
 		t.Errorf("expected image_base64 to be absent on failure, got %q", req.ImageBase64)
 	}
 }
+
+func TestTakePictureCommandBurstWithOptions(t *testing.T) {
+	defer initSimCamera(t)()
+
+	cfg := &config.Config{
+		BaendaeliURL:    "http://example.com",
+		BaendaeliAPIKey: "test-key",
+	}
+	client := New(cfg)
+
+	var cmd CommandResponse
+	if err := json.Unmarshal([]byte(`{"id":101,"command":"take_picture","capture":{"quality":70,"rotation":90,"burst_count":2,"burst_interval_ms":1}}`), &cmd); err != nil {
+		t.Fatalf("failed to unmarshal command: %v", err)
+	}
+	result, err := client.executeCommand(&cmd)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(result.images) != 2 || result.imageBase64 != result.images[0] {
+		t.Fatalf("expected 2 burst images with the first one in image_base64, got %d", len(result.images))
+	}
+
+	cmd.Capture.Rotation = 45
+	if _, err := client.executeCommand(&cmd); err == nil || !strings.Contains(err.Error(), "rotation") {
+		t.Fatalf("expected invalid rotation to fail the command, got %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package device
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+)
+
+const (
+	// timelapseIDLayout names time-lapse pictures; lexical order is chronological.
+	timelapseIDLayout = "20060102T150405Z"
+	// timelapseCursorFile stores the ID of the last uploaded picture in the time-lapse directory.
+	timelapseCursorFile = ".uploaded"
+	// timelapseState is the state reported for time-lapse pictures on the events endpoint.
+	timelapseState = "timelapse"
+)
+
+// captureWindow is a daily time range in local time. The end may be earlier than the
+// start for windows that run past midnight.
+type captureWindow struct {
+	allDay     bool
+	start, end int // minutes after midnight
+}
+
+// parseCaptureWindow parses "HH:MM-HH:MM". An empty string means all day.
+func parseCaptureWindow(s string) (captureWindow, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return captureWindow{allDay: true}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return captureWindow{}, fmt.Errorf("capture hours must be \"HH:MM-HH:MM\", got %q", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return captureWindow{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return captureWindow{}, err
+	}
+	if start == end {
+		return captureWindow{}, fmt.Errorf("capture hours %q are empty; leave them unset to capture all day", s)
+	}
+	return captureWindow{start: start, end: end}, nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight. "24:00" is allowed as an end.
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	hh, mm, ok := strings.Cut(s, ":")
+	hours, errH := strconv.Atoi(hh)
+	minutes, errM := strconv.Atoi(mm)
+	if !ok || errH != nil || errM != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+func (w captureWindow) contains(t time.Time) bool {
+	if w.allDay {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// timelapse captures a camera image every interval during the configured hours for
+// vandalism and crowd analysis. Pictures are kept under a count, size and age limit
+// and optionally uploaded to the events endpoint like event snapshots.
+type timelapse struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	httpClient *http.Client
+	client     *Client
+
+	dir      string
+	interval time.Duration
+	window   captureWindow
+	maxFiles int
+	maxBytes int64
+	maxAge   time.Duration
+	upload   bool
+	capture  func() ([]byte, error)
+	now      func() time.Time
+}
+
+func newTimelapse(parent context.Context, c *Client, httpClient *http.Client) *timelapse {
+	ctx, cancel := context.WithCancel(parent)
+	cfg := c.config
+	window, err := parseCaptureWindow(cfg.TimelapseHours)
+	if err != nil {
+		log.Printf("Device client: invalid TIMELAPSE_HOURS, capturing all day: %v", err)
+		window = captureWindow{allDay: true}
+	}
+	return &timelapse{
+		ctx:        ctx,
+		cancel:     cancel,
+		httpClient: httpClient,
+		client:     c,
+		dir:        cfg.TimelapseDir,
+		interval:   time.Duration(cfg.TimelapseIntervalMinutes) * time.Minute,
+		window:     window,
+		maxFiles:   cfg.TimelapseMaxFiles,
+		maxBytes:   int64(cfg.TimelapseMaxMB) * 1024 * 1024,
+		maxAge:     time.Duration(cfg.TimelapseMaxAgeDays) * 24 * time.Hour,
+		upload:     cfg.TimelapseUpload,
+		capture:    camera.Capture,
+		now:        time.Now,
+	}
+}
+
+// enabled reports whether time-lapse capture is configured.
+func (t *timelapse) enabled() bool {
+	cfg := t.client.config
+	return cfg.TimelapseEnabled && cfg.CameraEnabled && t.interval > 0 && t.dir != ""
+}
+
+func (t *timelapse) start() {
+	if !t.enabled() {
+		return
+	}
+	log.Printf("Device client: time-lapse capture every %s (hours=%q, upload=%t)", t.interval, t.client.config.TimelapseHours, t.upload)
+	t.wg.Add(1)
+	go t.run()
+}
+
+func (t *timelapse) stop() {
+	t.cancel()
+	t.wg.Wait()
+}
+
+func (t *timelapse) run() {
+	defer t.wg.Done()
+
+	t.prune()
+	t.uploadPending()
+
+	captureTicker := time.NewTicker(t.interval)
+	defer captureTicker.Stop()
+	retryTicker := time.NewTicker(eventSnapshotRetryInterval)
+	defer retryTicker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-captureTicker.C:
+			if !t.window.contains(t.now()) {
+				continue
+			}
+			if err := t.captureAndStore(); err != nil {
+				log.Printf("Device client: time-lapse capture failed: %v", err)
+				continue
+			}
+			t.prune()
+			t.uploadPending()
+		case <-retryTicker.C:
+			t.uploadPending()
+		}
+	}
+}
+
+func (t *timelapse) captureAndStore() error {
+	image, err := t.capture()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create time-lapse directory: %w", err)
+	}
+	id := t.now().UTC().Format(timelapseIDLayout)
+	if err := os.WriteFile(filepath.Join(t.dir, id+".jpg"), image, 0o644); err != nil {
+		return fmt.Errorf("failed to store time-lapse picture: %w", err)
+	}
+	log.Printf("Device client: time-lapse picture %s stored (%d bytes)", id, len(image))
+	return nil
+}
+
+// prune deletes the oldest pictures until the directory is within the count, size and
+// age limits. A limit <= 0 is not enforced.
+func (t *timelapse) prune() {
+	pictures, err := listPictureDir(t.dir, timelapseState)
+	if err != nil {
+		return
+	}
+	// Oldest first.
+	sort.Slice(pictures, func(i, j int) bool { return pictures[i].ID < pictures[j].ID })
+
+	var total int64
+	for _, picture := range pictures {
+		total += picture.Bytes
+	}
+	count := len(pictures)
+	now := t.now()
+	for _, picture := range pictures {
+		expired := t.maxAge > 0 && now.Sub(picture.takenAt) > t.maxAge
+		if !expired && (t.maxFiles <= 0 || count <= t.maxFiles) && (t.maxBytes <= 0 || total <= t.maxBytes) {
+			break
+		}
+		if err := os.Remove(filepath.Join(t.dir, picture.ID+".jpg")); err != nil {
+			log.Printf("Device client: failed to remove old time-lapse picture %s: %v", picture.ID, err)
+			break
+		}
+		count--
+		total -= picture.Bytes
+	}
+}
+
+// uploadPending uploads pictures newer than the upload cursor oldest first. It stops at
+// the first transient failure so the picture is retried later; pictures the server
+// rejects are skipped.
+func (t *timelapse) uploadPending() {
+	if !t.upload {
+		return
+	}
+	pictures, err := listPictureDir(t.dir, timelapseState)
+	if err != nil {
+		return
+	}
+	sort.Slice(pictures, func(i, j int) bool { return pictures[i].ID < pictures[j].ID })
+
+	cursor := t.readCursor()
+	for _, picture := range pictures {
+		if t.ctx.Err() != nil {
+			return
+		}
+		if picture.ID <= cursor {
+			continue
+		}
+		image, err := os.ReadFile(filepath.Join(t.dir, picture.ID+".jpg"))
+		if err != nil {
+			// Pruned before it could be uploaded.
+			continue
+		}
+		statusCode, err := t.client.postEventImage(t.ctx, t.httpClient, EventSnapshotRequest{
+			EventID:     picture.ID,
+			State:       timelapseState,
+			OccurredAt:  picture.TakenAt,
+			ImageBase64: base64.StdEncoding.EncodeToString(image),
+		})
+		if err != nil {
+			log.Printf("Device client: failed to upload time-lapse picture %s: %v", picture.ID, err)
+			if retryableUpload(statusCode) {
+				return
+			}
+		}
+		cursor = picture.ID
+		t.writeCursor(cursor)
+	}
+}
+
+func (t *timelapse) readCursor() string {
+	data, err := os.ReadFile(filepath.Join(t.dir, timelapseCursorFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (t *timelapse) writeCursor(id string) {
+	if err := os.WriteFile(filepath.Join(t.dir, timelapseCursorFile), []byte(id+"\n"), 0o644); err != nil {
+		log.Printf("Device client: failed to store time-lapse upload cursor: %v", err)
+	}
+}
+
+// PictureInfo describes a stored picture in the list_pictures ack.
+type PictureInfo struct {
+	ID      string `json:"id"`
+	Source  string `json:"source"` // "timelapse" or "event"
+	TakenAt string `json:"taken_at"`
+	Bytes   int64  `json:"bytes"`
+
+	takenAt time.Time
+}
+
+const (
+	defaultListPicturesLimit = 100
+	maxListPicturesLimit     = 1000
+)
+
+// listPictureDir returns the JPEGs stored in dir. IDs of time-lapse pictures and event
+// snapshots both start with a UTC timestamp, which is used as the capture time.
+func listPictureDir(dir, source string) ([]PictureInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pictures := make([]PictureInfo, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".jpg")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		takenAt := info.ModTime()
+		if len(id) >= len(timelapseIDLayout) {
+			if parsed, err := time.Parse(timelapseIDLayout, id[:len(timelapseIDLayout)]); err == nil {
+				takenAt = parsed
+			}
+		}
+		pictures = append(pictures, PictureInfo{
+			ID:      id,
+			Source:  source,
+			TakenAt: takenAt.UTC().Format(time.RFC3339),
+			Bytes:   info.Size(),
+			takenAt: takenAt,
+		})
+	}
+	return pictures, nil
+}
+
+// pictureDirs returns the directories searched by list_pictures and get_picture.
+func (c *Client) pictureDirs() map[string]string {
+	dirs := make(map[string]string)
+	if c.config.TimelapseDir != "" {
+		dirs[timelapseState] = c.config.TimelapseDir
+	}
+	if c.config.EventSnapshotDir != "" {
+		dirs["event"] = c.config.EventSnapshotDir
+	}
+	return dirs
+}
+
+// executeListPictures returns the newest stored pictures, at most cmd.Limit of them.
+func (c *Client) executeListPictures(cmd *CommandResponse) (commandResult, error) {
+	limit := defaultListPicturesLimit
+	if cmd.Limit != nil {
+		if *cmd.Limit < 1 || *cmd.Limit > maxListPicturesLimit {
+			return commandResult{}, fmt.Errorf("list_pictures command: limit must be between 1 and %d, got %d", maxListPicturesLimit, *cmd.Limit)
+		}
+		limit = *cmd.Limit
+	}
+
+	var pictures []PictureInfo
+	for source, dir := range c.pictureDirs() {
+		found, err := listPictureDir(dir, source)
+		if err != nil && !os.IsNotExist(err) {
+			return commandResult{}, fmt.Errorf("list_pictures command: %w", err)
+		}
+		pictures = append(pictures, found...)
+	}
+	sort.Slice(pictures, func(i, j int) bool { return pictures[i].ID > pictures[j].ID })
+	if len(pictures) > limit {
+		pictures = pictures[:limit]
+	}
+	log.Printf("Device client: listing %d stored picture(s)", len(pictures))
+	return commandResult{pictures: pictures}, nil
+}
+
+// executeGetPicture returns the stored picture cmd.Picture, as listed by list_pictures.
+func (c *Client) executeGetPicture(cmd *CommandResponse) (commandResult, error) {
+	id := strings.TrimSpace(cmd.Picture)
+	if id == "" {
+		return commandResult{}, fmt.Errorf("get_picture command missing required field: picture")
+	}
+	if filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return commandResult{}, fmt.Errorf("get_picture command: invalid picture id %q", id)
+	}
+	for _, dir := range c.pictureDirs() {
+		image, err := os.ReadFile(filepath.Join(dir, id+".jpg"))
+		if err == nil {
+			log.Printf("Device client: returning stored picture %s (%d bytes)", id, len(image))
+			return commandResult{imageBase64: base64.StdEncoding.EncodeToString(image)}, nil
+		}
+	}
+	return commandResult{}, fmt.Errorf("get_picture command: picture %q not found", id)
+}
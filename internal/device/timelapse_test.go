@@ -0,0 +1,174 @@
+package device
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writePicture(t *testing.T, dir, id string, size int) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, id+".jpg"), make([]byte, size), 0o644); err != nil {
+		t.Fatalf("failed to write picture: %v", err)
+	}
+}
+
+func storedPictureIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	pictures, err := listPictureDir(dir, timelapseState)
+	if err != nil {
+		t.Fatalf("listPictureDir failed: %v", err)
+	}
+	ids := make([]string, 0, len(pictures))
+	for _, picture := range pictures {
+		ids = append(ids, picture.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestCaptureWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 10, 18, hour, minute, 0, 0, time.Local) }
+
+	day, err := parseCaptureWindow("08:00-23:30")
+	if err != nil {
+		t.Fatalf("parseCaptureWindow failed: %v", err)
+	}
+	if !day.contains(at(8, 0)) || !day.contains(at(23, 29)) || day.contains(at(23, 30)) || day.contains(at(7, 59)) {
+		t.Fatal("unexpected daytime window bounds")
+	}
+
+	night, err := parseCaptureWindow("18:00-02:00")
+	if err != nil {
+		t.Fatalf("parseCaptureWindow failed: %v", err)
+	}
+	if !night.contains(at(23, 0)) || !night.contains(at(1, 59)) || night.contains(at(12, 0)) {
+		t.Fatal("unexpected overnight window bounds")
+	}
+
+	if all, _ := parseCaptureWindow(""); !all.contains(at(4, 0)) {
+		t.Fatal("expected an empty window to cover the whole day")
+	}
+	for _, bad := range []string{"8-23", "08:00", "25:00-26:00", "10:00-10:00"} {
+		if _, err := parseCaptureWindow(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+// ensure the oldest pictures are removed to satisfy the count, size and age limits
+func TestTimelapseRetention(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	client := newTestClient("http://unused")
+	tl := newTimelapse(client.ctx, client, client.httpClient)
+	tl.dir = t.TempDir()
+	tl.now = func() time.Time { return now }
+	tl.maxFiles, tl.maxBytes, tl.maxAge = 3, 250, 48*time.Hour
+
+	writePicture(t, tl.dir, "20261015T120000Z", 10) // older than maxAge
+	writePicture(t, tl.dir, "20261017T100000Z", 100)
+	writePicture(t, tl.dir, "20261017T110000Z", 100)
+	writePicture(t, tl.dir, "20261018T100000Z", 100)
+	writePicture(t, tl.dir, "20261018T110000Z", 10)
+	tl.prune()
+
+	got := storedPictureIDs(t, tl.dir)
+	want := []string{"20261017T110000Z", "20261018T100000Z", "20261018T110000Z"}
+	if len(got) != len(want) || got[0] != want[0] || got[2] != want[2] {
+		t.Fatalf("expected %v after pruning, got %v", want, got)
+	}
+	tl.maxBytes = 150
+	tl.prune()
+	if got := storedPictureIDs(t, tl.dir); len(got) != 2 || got[0] != "20261018T100000Z" {
+		t.Fatalf("expected the size limit to prune the oldest picture, got %v", got)
+	}
+}
+
+// ensure pictures stay pending after a transient failure and are uploaded once, in order
+func TestTimelapseCaptureAndUpload(t *testing.T) {
+	var received []EventSnapshotRequest
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req EventSnapshotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		received = append(received, req)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	tl := newTimelapse(client.ctx, client, client.httpClient)
+	tl.dir = t.TempDir()
+	tl.upload = true
+	tl.capture = func() ([]byte, error) { return []byte("jpeg"), nil }
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tl.now = func() time.Time { return now }
+
+	if err := tl.captureAndStore(); err != nil {
+		t.Fatalf("captureAndStore failed: %v", err)
+	}
+	tl.uploadPending()
+	if len(received) != 0 || tl.readCursor() != "" {
+		t.Fatal("expected a transient failure to keep the picture pending")
+	}
+
+	fail = false
+	now = now.Add(10 * time.Minute)
+	if err := tl.captureAndStore(); err != nil {
+		t.Fatalf("captureAndStore failed: %v", err)
+	}
+	tl.uploadPending()
+	tl.uploadPending()
+	if len(received) != 2 || received[0].EventID != "20261018T120000Z" || received[1].State != timelapseState {
+		t.Fatalf("expected both pictures uploaded once in order, got %+v", received)
+	}
+	if image, _ := base64.StdEncoding.DecodeString(received[0].ImageBase64); string(image) != "jpeg" || received[0].OccurredAt != "2026-10-18T12:00:00Z" {
+		t.Fatalf("unexpected upload %+v", received[0])
+	}
+	if tl.readCursor() != "20261018T121000Z" {
+		t.Fatalf("unexpected upload cursor %q", tl.readCursor())
+	}
+}
+
+func TestListAndGetPictures(t *testing.T) {
+	client := newTestClient("http://unused")
+	client.config.TimelapseDir = t.TempDir()
+	client.config.EventSnapshotDir = t.TempDir()
+	writePicture(t, client.config.TimelapseDir, "20261018T100000Z", 3)
+	writePicture(t, client.config.TimelapseDir, "20261018T120000Z", 4)
+	writePicture(t, client.config.EventSnapshotDir, "20261018T110000Z-jam-1a2b3c4d", 5)
+
+	limit := 2
+	result, err := client.executeCommand(&CommandResponse{ID: 1, Command: "list_pictures", Limit: &limit})
+	if err != nil {
+		t.Fatalf("list_pictures failed: %v", err)
+	}
+	if len(result.pictures) != 2 || result.pictures[0].ID != "20261018T120000Z" || result.pictures[1].Source != "event" || result.pictures[1].TakenAt != "2026-10-18T11:00:00Z" {
+		t.Fatalf("unexpected pictures %+v", result.pictures)
+	}
+
+	result, err = client.executeCommand(&CommandResponse{ID: 2, Command: "get_picture", Picture: "20261018T110000Z-jam-1a2b3c4d"})
+	if err != nil {
+		t.Fatalf("get_picture failed: %v", err)
+	}
+	if image, _ := base64.StdEncoding.DecodeString(result.imageBase64); len(image) != 5 {
+		t.Fatalf("expected the 5-byte event snapshot, got %d bytes", len(image))
+	}
+	for _, bad := range []string{"", "../config", "missing"} {
+		if _, err := client.executeCommand(&CommandResponse{ID: 3, Command: "get_picture", Picture: bad}); err == nil {
+			t.Fatalf("expected get_picture %q to fail", bad)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/jamclear"
+	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+)
+
+// Limits for vibrate command patterns. Each step uses the same bounds as a single
+// vibrate command, and the whole pattern may not run longer than one either.
+const (
+	maxVibrationPatternSteps = 50
+	maxVibrationPauseMs      = 10000
+	maxVibrationPatternMs    = 60000
+)
+
+// VibrationStep is one step of a vibrate command pattern.
+type VibrationStep struct {
+	Percent    int    `json:"percent"`             // 1-100
+	DurationMs int    `json:"duration_ms"`         // 100-60000
+	PauseMs    int    `json:"pause_ms,omitempty"`  // 0-10000, off-time before the next step
+	Direction  string `json:"direction,omitempty"` // "forward" (default) or "reverse"
+}
+
+// VibrationReport describes an executed vibrate pattern in the command ack.
+type VibrationReport struct {
+	Preset         string `json:"preset,omitempty"`
+	Steps          int    `json:"steps"`
+	CompletedSteps int    `json:"completed_steps"`
+	OnMs           int64  `json:"on_ms"`
+	ElapsedMs      int64  `json:"elapsed_ms"`
+}
+
+func hasVibrationPattern(cmd *CommandResponse) bool {
+	raw := bytes.TrimSpace(cmd.Pattern)
+	return len(raw) > 0 && !bytes.Equal(raw, []byte("null"))
+}
+
+// executeVibrationPattern runs the pattern of a vibrate command. It can be stopped by a
+// cancel or estop command; the report lists how far it got either way.
+func (c *Client) executeVibrationPattern(cmd *CommandResponse) (commandResult, error) {
+	preset, steps, err := c.parseVibrationPattern(cmd.Pattern)
+	if err != nil {
+		return commandResult{}, err
+	}
+
+	label := preset
+	if label == "" {
+		label = "custom"
+	}
+	log.Printf("Device client: running vibration pattern %s (%d steps)", label, len(steps))
+
+	ctx, done := c.movementContext()
+	started := time.Now()
+	completed, err := vibrator.RunPattern(ctx, steps)
+	done()
+
+	report := &VibrationReport{
+		Preset:         preset,
+		Steps:          len(steps),
+		CompletedSteps: completed,
+		ElapsedMs:      time.Since(started).Milliseconds(),
+	}
+	for _, step := range steps[:completed] {
+		report.OnMs += step.Duration.Milliseconds()
+	}
+	if err != nil {
+		log.Printf("Device client: vibration pattern %s stopped after %d/%d steps: %v", label, completed, len(steps), err)
+		return commandResult{vibration: report}, err
+	}
+	log.Printf("Device client: vibration pattern %s completed (%d steps, %dms on)", label, len(steps), report.OnMs)
+	return commandResult{vibration: report}, nil
+}
+
+// parseVibrationPattern accepts either a preset name (a vibration-only jam-clearing
+// pattern, scaled from the configured vibration settings) or a list of steps.
+func (c *Client) parseVibrationPattern(raw json.RawMessage) (string, []vibrator.PatternStep, error) {
+	var preset string
+	if err := json.Unmarshal(raw, &preset); err == nil {
+		steps, err := c.vibrationPreset(strings.ToLower(strings.TrimSpace(preset)))
+		return strings.ToLower(strings.TrimSpace(preset)), steps, err
+	}
+
+	var wire []VibrationStep
+	if err := json.Unmarshal(raw, &wire); err != nil {
+		return "", nil, fmt.Errorf("vibrate command: pattern must be a preset name or a list of steps: %w", err)
+	}
+	if len(wire) == 0 || len(wire) > maxVibrationPatternSteps {
+		return "", nil, fmt.Errorf("vibrate command: pattern must have between 1 and %d steps, got %d", maxVibrationPatternSteps, len(wire))
+	}
+
+	steps := make([]vibrator.PatternStep, len(wire))
+	totalMs := 0
+	for i, step := range wire {
+		if step.Percent < 1 || step.Percent > 100 {
+			return "", nil, fmt.Errorf("vibrate command: step %d percent must be between 1 and 100, got %d", i+1, step.Percent)
+		}
+		if step.DurationMs < 100 || step.DurationMs > 60000 {
+			return "", nil, fmt.Errorf("vibrate command: step %d duration_ms must be between 100 and 60000, got %d", i+1, step.DurationMs)
+		}
+		if step.PauseMs < 0 || step.PauseMs > maxVibrationPauseMs {
+			return "", nil, fmt.Errorf("vibrate command: step %d pause_ms must be between 0 and %d, got %d", i+1, maxVibrationPauseMs, step.PauseMs)
+		}
+		var reverse bool
+		switch strings.ToLower(strings.TrimSpace(step.Direction)) {
+		case "", "forward":
+		case "reverse":
+			reverse = true
+		default:
+			return "", nil, fmt.Errorf("vibrate command: step %d direction must be forward or reverse, got %q", i+1, step.Direction)
+		}
+
+		totalMs += step.DurationMs
+		if i < len(wire)-1 {
+			totalMs += step.PauseMs
+		}
+		steps[i] = vibrator.PatternStep{
+			Intensity: float64(step.Percent) / 100.0,
+			Duration:  time.Duration(step.DurationMs) * time.Millisecond,
+			Pause:     time.Duration(step.PauseMs) * time.Millisecond,
+			Reverse:   reverse,
+		}
+	}
+	if totalMs > maxVibrationPatternMs {
+		return "", nil, fmt.Errorf("vibrate command: pattern must not run longer than %dms, got %dms", maxVibrationPatternMs, totalMs)
+	}
+	return "", steps, nil
+}
+
+// vibrationPreset returns the steps of a jam-clearing pattern as run after the first
+// missed attempt. Presets that move the actuator are rejected.
+func (c *Client) vibrationPreset(name string) ([]vibrator.PatternStep, error) {
+	var pattern jamclear.Pattern
+	var names []string
+	for _, p := range jamclear.Builtin() {
+		names = append(names, p.Name())
+		if p.Name() == name {
+			pattern = p
+		}
+	}
+	if pattern == nil {
+		return nil, fmt.Errorf("vibrate command: unknown pattern preset %q (want one of %v)", name, names)
+	}
+
+	// Presets are for trying sequences remotely, so they run even when jam-clearing
+	// bursts are disabled in the config.
+	params := jamclear.ParamsFromConfig(c.config)
+	if params.Bursts <= 0 {
+		params.Bursts = 1
+	}
+	if params.Intensity <= 0 {
+		params.Intensity = 0.8
+	}
+
+	var steps []vibrator.PatternStep
+	for _, step := range pattern.Steps(params, 1) {
+		if step.Action == jamclear.ActionWiggle {
+			return nil, fmt.Errorf("vibrate command: preset %q moves the actuator and cannot be used with vibrate", name)
+		}
+		steps = append(steps, vibrator.PatternStep{
+			Intensity: step.Intensity,
+			Duration:  step.Duration,
+			Pause:     step.Pause,
+			Reverse:   step.Action == jamclear.ActionBuzzReverse,
+		})
+	}
+	return steps, nil
+}
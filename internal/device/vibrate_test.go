@@ -1,6 +1,11 @@
 package device
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -248,3 +253,82 @@ func TestVibrateDurationBoundaries(t *testing.T) {
 		})
 	}
 }
+
+func TestVibratePatternValidation(t *testing.T) {
+	client := New(&config.Config{BaendaeliURL: "http://example.com", BaendaeliAPIKey: "test-key"})
+
+	tests := []struct {
+		name     string
+		pattern  string
+		errorMsg string
+	}{
+		{"not a list or name", `{"percent": 50}`, "preset name or a list of steps"},
+		{"empty list", `[]`, "between 1 and 50 steps"},
+		{"percent too low", `[{"percent": 0, "duration_ms": 500}]`, "step 1 percent must be between 1 and 100"},
+		{"duration too short", `[{"percent": 50, "duration_ms": 500}, {"percent": 50, "duration_ms": 99}]`, "step 2 duration_ms must be between 100 and 60000"},
+		{"pause too long", `[{"percent": 50, "duration_ms": 500, "pause_ms": 10001}]`, "pause_ms must be between 0 and 10000"},
+		{"unknown direction", `[{"percent": 50, "duration_ms": 500, "direction": "sideways"}]`, "direction must be forward or reverse"},
+		{"pattern too long", `[{"percent": 50, "duration_ms": 40000, "pause_ms": 1000}, {"percent": 50, "duration_ms": 20000}]`, "must not run longer than 60000ms"},
+		{"unknown preset", `"shake"`, "unknown pattern preset"},
+		{"actuator preset", `"actuator_wiggle"`, "moves the actuator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &CommandResponse{ID: 1, Command: "vibrate", Pattern: json.RawMessage(tt.pattern)}
+			_, err := client.executeCommand(cmd)
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Fatalf("expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
+
+func TestVibratePatternReportsSteps(t *testing.T) {
+	client := New(&config.Config{BaendaeliURL: "http://example.com", BaendaeliAPIKey: "test-key"})
+
+	cmd := &CommandResponse{ID: 1, Command: "vibrate", Pattern: json.RawMessage(`[
+		{"percent": 80, "duration_ms": 300, "pause_ms": 200},
+		{"percent": 60, "duration_ms": 400, "direction": "reverse"}
+	]`)}
+	result, err := client.executeCommand(cmd)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := VibrationReport{Steps: 2, CompletedSteps: 2, OnMs: 700}
+	if got := result.vibration; got == nil || got.Steps != want.Steps || got.CompletedSteps != want.CompletedSteps || got.OnMs != want.OnMs || got.Preset != "" {
+		t.Fatalf("unexpected vibration report %+v, want %+v", got, want)
+	}
+
+	cmd = &CommandResponse{ID: 2, Command: "vibrate", Pattern: json.RawMessage(`"Alternating"`)}
+	result, err = client.executeCommand(cmd)
+	if err != nil {
+		t.Fatalf("expected preset to run, got %v", err)
+	}
+	if result.vibration == nil || result.vibration.Preset != "alternating" || result.vibration.Steps != 2 {
+		t.Fatalf("unexpected preset report %+v", result.vibration)
+	}
+}
+
+func TestAckRequestIncludesVibrationReport(t *testing.T) {
+	var capturedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	client := New(&config.Config{BaendaeliURL: server.URL, BaendaeliAPIKey: "test-key"})
+	report := &VibrationReport{Preset: "ramp", Steps: 5, CompletedSteps: 3, OnMs: 1200, ElapsedMs: 1300}
+	if err := client.ackCommand(7, errors.New("command cancelled"), commandResult{vibration: report}); err != nil {
+		t.Fatalf("ackCommand returned unexpected error: %v", err)
+	}
+
+	var req AckRequest
+	if err := json.Unmarshal(capturedBody, &req); err != nil {
+		t.Fatalf("failed to unmarshal request body: %v", err)
+	}
+	if req.Status != "failed" || req.Vibration == nil || *req.Vibration != *report {
+		t.Fatalf("expected failed ack with vibration report, got %+v", req)
+	}
+}
@@ -0,0 +1,132 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+	"github.com/jsalamander/baendaeli-client/internal/vision"
+)
+
+// errFunnelEmpty marks a failed ball-ready check where the camera sees an empty funnel,
+// so the technician has to refill rather than clear a jam.
+var errFunnelEmpty = errors.New("vision: funnel empty")
+
+// errVisionJam is returned when the camera alone decides and votes for a jam.
+var errVisionJam = errors.New("vision: funnel jammed")
+
+// captureVisionFrame takes the frame analysed by the vision module.
+var captureVisionFrame = camera.Capture
+
+// VisionReport is the latest funnel analysis.
+type VisionReport struct {
+	vision.Result
+	AnalyzedAt string `json:"analyzed_at"`
+}
+
+// SetVisionAnalyzer sets the funnel image analyzer used as an extra vote in the
+// ball-ready decision. Call before Start.
+func (c *Client) SetVisionAnalyzer(a *vision.Analyzer) {
+	c.visionAnalyzer = a
+}
+
+// analyzeFunnel captures a frame and compares it against the calibrated references.
+// The result is kept for the status report.
+func (c *Client) analyzeFunnel() (*vision.Result, error) {
+	image, err := captureVisionFrame()
+	if err != nil {
+		return nil, fmt.Errorf("vision: capture failed: %w", err)
+	}
+	result, err := c.visionAnalyzer.Analyze(image)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Device client: vision nearest=%s fill=%.2f balls=%d jam_vote=%t confidence=%.2f", result.Nearest, result.FillLevel, result.BallEstimate, result.JamVote, result.Confidence)
+
+	c.statusMutex.Lock()
+	c.lastVision = &VisionReport{Result: result, AnalyzedAt: time.Now().UTC().Format(time.RFC3339)}
+	c.statusMutex.Unlock()
+	c.notifyStateChange()
+	return &result, nil
+}
+
+// visionVote analyses the funnel and returns the confident vote: errVisionJam for a
+// jam, errFunnelEmpty for an empty funnel, nil for a funnel with balls. ok is false
+// when the analysis failed or was not confident enough to count.
+func (c *Client) visionVote() (vote error, ok bool) {
+	result, err := c.analyzeFunnel()
+	if err != nil {
+		log.Printf("Device client: vision analysis unavailable: %v", err)
+		return nil, false
+	}
+	if result.Confidence < c.config.VisionMinConfidence {
+		log.Printf("Device client: vision abstains (confidence %.2f below %.2f)", result.Confidence, c.config.VisionMinConfidence)
+		return nil, false
+	}
+	switch {
+	case result.JamVote:
+		return errVisionJam, true
+	case result.Nearest == vision.ReferenceEmpty:
+		return errFunnelEmpty, true
+	default:
+		return nil, true
+	}
+}
+
+// visionBallReady decides with the camera alone when the colour sensor is disabled.
+// Without a confident vote the ball is assumed ready, as without any sensor.
+func (c *Client) visionBallReady() error {
+	vote, ok := c.visionVote()
+	if !ok {
+		return nil
+	}
+	return vote
+}
+
+// visionSecondOpinion is consulted after the colour sensor missed the ball. The miss
+// stands, but an empty funnel is reported as such instead of as a jam.
+func (c *Client) visionSecondOpinion(sensorErr error) error {
+	vote, ok := c.visionVote()
+	switch {
+	case !ok:
+		return sensorErr
+	case errors.Is(vote, errFunnelEmpty):
+		log.Printf("Device client: vision sees an empty funnel")
+		return fmt.Errorf("%w: %w", sensorErr, errFunnelEmpty)
+	case errors.Is(vote, errVisionJam):
+		log.Printf("Device client: vision confirms the jam")
+	default:
+		log.Printf("Device client: vision sees balls in the funnel and no jam, keeping the colour sensor result")
+	}
+	return sensorErr
+}
+
+// executeVisionCalibrate captures a frame and stores it as the requested reference.
+func (c *Client) executeVisionCalibrate(cmd *CommandResponse) (commandResult, error) {
+	if c.visionAnalyzer == nil {
+		return commandResult{}, errors.New("vision analysis unavailable (VISION_ENABLED)")
+	}
+	reference := strings.ToLower(strings.TrimSpace(cmd.Reference))
+	image, err := captureVisionFrame()
+	if err != nil {
+		return commandResult{}, fmt.Errorf("vision: capture failed: %w", err)
+	}
+	if err := c.visionAnalyzer.Calibrate(reference, image); err != nil {
+		return commandResult{}, err
+	}
+	log.Printf("Device client: vision reference %s calibrated (%d bytes, references %v)", reference, len(image), c.visionAnalyzer.Calibrated())
+	return commandResult{}, nil
+}
+
+func (c *Client) visionReport() *VisionReport {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	if c.lastVision == nil {
+		return nil
+	}
+	report := *c.lastVision
+	return &report
+}
@@ -0,0 +1,147 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/jsalamander/baendaeli-client/internal/colorsensor"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/vision"
+)
+
+// funnelFrame renders a funnel with a bright column of balls of the given height and
+// optionally a ball stuck in the neck.
+func funnelFrame(t *testing.T, balls int, stuck bool) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(30)
+			if x >= 16 && x < 48 {
+				v = 90
+				if 48-y <= balls {
+					v = 210
+				}
+			}
+			if stuck && x >= 28 && x < 36 && y >= 40 {
+				v = 250
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func stubVisionFrame(t *testing.T, frame []byte) {
+	t.Helper()
+	prev := captureVisionFrame
+	captureVisionFrame = func() ([]byte, error) { return frame, nil }
+	t.Cleanup(func() { captureVisionFrame = prev })
+}
+
+// newVisionTestClient returns a client with an analyzer calibrated on empty, full and
+// jammed funnel frames.
+func newVisionTestClient(t *testing.T, cfg *config.Config) *Client {
+	t.Helper()
+	analyzer, err := vision.Open(t.TempDir(), vision.ROI{}, 10)
+	if err != nil {
+		t.Fatalf("vision.Open failed: %v", err)
+	}
+	client := New(cfg)
+	client.SetVisionAnalyzer(analyzer)
+	for reference, frame := range map[string][]byte{
+		vision.ReferenceEmpty:  funnelFrame(t, 0, false),
+		vision.ReferenceFull:   funnelFrame(t, 48, false),
+		vision.ReferenceJammed: funnelFrame(t, 0, true),
+	} {
+		stubVisionFrame(t, frame)
+		if _, err := client.executeCommand(&CommandResponse{ID: 1, Command: "vision_calibrate", Reference: reference}); err != nil {
+			t.Fatalf("vision_calibrate %s failed: %v", reference, err)
+		}
+	}
+	return client
+}
+
+// ensure a colour-sensor miss with an empty funnel in view asks for a refill instead
+// of reporting a jam
+func TestWaitForBallReadyVisionReportsEmptyFunnel(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	cfg.ColorSensorMovementThreshold = 10000
+	cfg.ColorSensorCheckDurationMs = 1
+	cfg.ColorSensorVibrateBursts = 0
+	cfg.ColorSensorMaxAttempts = 1
+
+	client := newVisionTestClient(t, cfg)
+	if err := client.colorSensor.Init(cfg); err != nil {
+		t.Fatalf("failed to init color sensor in test: %v", err)
+	}
+	defer client.colorSensor.Close()
+	stubVisionFrame(t, funnelFrame(t, 1, false))
+
+	err := client.waitForBallReady(true, false, nil)
+	if !errors.Is(err, colorsensor.ErrNoBallDetected) || !errors.Is(err, errFunnelEmpty) {
+		t.Fatalf("expected ErrNoBallDetected with errFunnelEmpty, got %v", err)
+	}
+	snapshot := client.GetStateSnapshot()
+	if snapshot.State != string(StateBallStuckFunnel) || snapshot.Message != "Trichter leer" {
+		t.Fatalf("unexpected state %q (%q)", snapshot.State, snapshot.Message)
+	}
+	if exec := client.GetExecutingCommand(); exec == nil || exec.Message != "Trichter leer. Rufe eine Techniker*in zum Nachfüllen." {
+		t.Fatalf("expected refill message, got %+v", exec)
+	}
+	if snapshot.Vision == nil || snapshot.Vision.Nearest != vision.ReferenceEmpty || snapshot.Vision.BallEstimate > 2 {
+		t.Fatalf("expected vision result in snapshot, got %+v", snapshot.Vision)
+	}
+}
+
+// ensure the camera decides alone when the colour sensor is disabled
+func TestWaitForBallReadyAttemptVisionOnly(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	cfg.ColorSensorEnabled = false
+
+	client := newVisionTestClient(t, cfg)
+
+	stubVisionFrame(t, funnelFrame(t, 0, true))
+	source, err := client.waitForBallReadyAttempt(client.ctx, false, nil, nil)
+	if source != "vision" || !errors.Is(err, errVisionJam) {
+		t.Fatalf("expected vision jam vote, got source=%q err=%v", source, err)
+	}
+
+	stubVisionFrame(t, funnelFrame(t, 36, false))
+	if _, err := client.waitForBallReadyAttempt(client.ctx, false, nil, nil); err != nil {
+		t.Fatalf("expected ball ready with a filled funnel, got %v", err)
+	}
+	if report := client.visionReport(); report == nil || report.BallEstimate < 5 || report.JamVote {
+		t.Fatalf("unexpected vision report %+v", report)
+	}
+}
+
+// ensure vision_calibrate rejects unknown references and needs the analyzer
+func TestCommandVisionCalibrateValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	stubVisionFrame(t, funnelFrame(t, 0, false))
+
+	client := New(cfg)
+	if _, err := client.executeCommand(&CommandResponse{ID: 1, Command: "vision_calibrate", Reference: "empty"}); err == nil {
+		t.Fatal("expected error without a vision analyzer")
+	}
+
+	client = newVisionTestClient(t, cfg)
+	if _, err := client.executeCommand(&CommandResponse{ID: 2, Command: "vision_calibrate", Reference: "half"}); err == nil {
+		t.Fatal("expected error for an unknown reference")
+	}
+	if !client.canExecuteCommandNow(&CommandResponse{Command: "vision_calibrate"}) {
+		t.Fatal("expected vision_calibrate to be always executable")
+	}
+}
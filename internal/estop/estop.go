@@ -0,0 +1,130 @@
+package estop
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+)
+
+// pollInterval is how often the button input is sampled.
+const pollInterval = 20 * time.Millisecond
+
+// debounceSamples is the number of consecutive pressed samples required for a press.
+const debounceSamples = 2
+
+// Button watches an optional physical emergency stop button.
+// It is wired normally open to GND with the internal pull-up, so LOW means pressed.
+type Button struct {
+	enabled bool
+	pinName string
+	pin     gpio.PinIn
+	sim     bool
+}
+
+func New(cfg *config.Config) *Button {
+	if cfg == nil {
+		return &Button{}
+	}
+	return &Button{
+		enabled: cfg.EStopButtonEnabled,
+		pinName: cfg.EStopButtonPin,
+	}
+}
+
+func (b *Button) IsEnabled() bool {
+	return b != nil && b.enabled
+}
+
+func (b *Button) IsSimulation() bool {
+	return b != nil && b.sim
+}
+
+func (b *Button) Init(cfg *config.Config) error {
+	if b == nil {
+		return nil
+	}
+	if cfg != nil {
+		b.enabled = cfg.EStopButtonEnabled
+		if cfg.EStopButtonPin != "" {
+			b.pinName = cfg.EStopButtonPin
+		}
+	}
+	if !b.enabled {
+		return nil
+	}
+	if b.pinName == "" {
+		b.pinName = "GPIO26"
+	}
+
+	if _, err := host.Init(); err != nil {
+		log.Printf("E-stop button: GPIO unavailable, running in simulation mode: %v", err)
+		b.sim = true
+		return nil
+	}
+
+	pin := gpioreg.ByName(b.pinName)
+	if pin == nil {
+		log.Printf("E-stop button: failed to open pin %s, running in simulation mode", b.pinName)
+		b.sim = true
+		return nil
+	}
+
+	if err := pin.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		return fmt.Errorf("e-stop button: failed to configure pin %s: %w", b.pinName, err)
+	}
+
+	b.pin = pin
+	b.sim = false
+	log.Printf("E-stop button: initialized on %s", b.pinName)
+	return nil
+}
+
+// Watch samples the button until ctx is done and calls onPress once per debounced
+// press. It returns immediately when the button is disabled or simulated.
+func (b *Button) Watch(ctx context.Context, onPress func()) {
+	if !b.IsEnabled() || b.sim || b.pin == nil {
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	pressedSamples := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if b.pin.Read() != gpio.Low {
+			pressedSamples = 0
+			continue
+		}
+		pressedSamples++
+		// Fire exactly once per press; holding the button does not retrigger.
+		if pressedSamples == debounceSamples {
+			log.Printf("E-stop button: pressed on %s", b.pinName)
+			onPress()
+		}
+	}
+}
+
+func (b *Button) Close() error {
+	if b == nil || b.pin == nil {
+		return nil
+	}
+	if h, ok := b.pin.(interface{ Halt() error }); ok {
+		if err := h.Halt(); err != nil {
+			return fmt.Errorf("e-stop button: failed to halt pin %s: %w", b.pinName, err)
+		}
+	}
+	b.pin = nil
+	return nil
+}
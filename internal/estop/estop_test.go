@@ -0,0 +1,55 @@
+package estop
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
+)
+
+func TestNewUsesConfig(t *testing.T) {
+	b := New(&config.Config{EStopButtonEnabled: true, EStopButtonPin: "GPIO26"})
+
+	if !b.IsEnabled() {
+		t.Fatal("expected button to be enabled")
+	}
+	if b.pinName != "GPIO26" {
+		t.Fatalf("expected pin GPIO26, got %q", b.pinName)
+	}
+}
+
+func TestWatchDisabledReturnsImmediately(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		(&Button{}).Watch(context.Background(), func() { t.Error("unexpected press") })
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch on disabled button did not return")
+	}
+}
+
+func TestWatchFiresOncePerPress(t *testing.T) {
+	pin := &gpiotest.Pin{N: "GPIO26", L: gpio.High}
+	b := &Button{enabled: true, pinName: "GPIO26", pin: pin}
+
+	var presses atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Watch(ctx, func() { presses.Add(1) })
+
+	if err := pin.Out(gpio.Low); err != nil {
+		t.Fatalf("failed to press button: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := presses.Load(); got != 1 {
+		t.Fatalf("expected one press while held, got %d", got)
+	}
+}
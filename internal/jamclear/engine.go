@@ -0,0 +1,274 @@
+package jamclear
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/atomicfile"
+)
+
+// StrategyAdaptive orders all patterns by their success rate on this machine.
+// Any other strategy is the name of a single pattern that is always used.
+const StrategyAdaptive = "adaptive"
+
+// Stats counts how often a pattern ran and how often the ball was detected in the
+// detection window right after it.
+type Stats struct {
+	Attempts  int64 `json:"attempts"`
+	Successes int64 `json:"successes"`
+}
+
+// successRate is the Laplace-smoothed success rate, so untried patterns start at 0.5
+// and a single lucky run does not lock in a pattern.
+func (s Stats) successRate() float64 {
+	return float64(s.Successes+1) / float64(s.Attempts+2)
+}
+
+// Report is the strategy, the current adaptive order and the per-pattern statistics.
+type Report struct {
+	Strategy string           `json:"strategy"`
+	Order    []string         `json:"order"`
+	Patterns map[string]Stats `json:"patterns"`
+}
+
+type statsFile struct {
+	Patterns map[string]Stats `json:"patterns"`
+}
+
+// Engine picks jam-clearing patterns and learns which ones work. Statistics are
+// persisted to a JSON file after every recorded outcome.
+type Engine struct {
+	mu       sync.Mutex
+	path     string
+	params   Params
+	strategy string
+	patterns []Pattern
+	stats    map[string]Stats
+}
+
+// Open creates an engine with the built-in patterns, loading statistics stored at path.
+// A missing file starts from zero; an unknown strategy is an error.
+func Open(path string, strategy string, params Params) (*Engine, error) {
+	e := &Engine{path: path, params: params, patterns: Builtin(), stats: map[string]Stats{}}
+	if err := e.SetStrategy(strategy); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Jam clearing: %s not found, starting without statistics", path)
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jam clearing stats: %w", err)
+	}
+	var file statsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse jam clearing stats %s: %w", path, err)
+	}
+	for name, stats := range file.Patterns {
+		e.stats[name] = stats
+	}
+	log.Printf("Jam clearing: loaded stats from %s (strategy=%s order=%v)", path, e.strategy, e.Order())
+	return e, nil
+}
+
+// Register adds a pattern, e.g. one tuned for a specific hopper. Names must be unique.
+func (e *Engine) Register(p Pattern) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if p.Name() == StrategyAdaptive {
+		return fmt.Errorf("pattern name %q is reserved", StrategyAdaptive)
+	}
+	if e.patternLocked(p.Name()) != nil {
+		return fmt.Errorf("jam clearing pattern %q already registered", p.Name())
+	}
+	e.patterns = append(e.patterns, p)
+	return nil
+}
+
+// Strategy returns the active strategy.
+func (e *Engine) Strategy() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.strategy
+}
+
+// SetStrategy switches to "adaptive" or to a single named pattern. An empty strategy
+// selects "adaptive".
+func (e *Engine) SetStrategy(strategy string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if strategy == "" {
+		strategy = StrategyAdaptive
+	}
+	if strategy != StrategyAdaptive && e.patternLocked(strategy) == nil {
+		return fmt.Errorf("unknown jam clearing strategy %q (want %q or one of %v)", strategy, StrategyAdaptive, e.namesLocked())
+	}
+	e.strategy = strategy
+	return nil
+}
+
+// Order returns the pattern names sorted by success rate, best first. Ties keep the
+// registration order, so "escalating" is tried first on a fresh machine.
+func (e *Engine) Order() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ordered := e.orderedLocked()
+	names := make([]string, len(ordered))
+	for i, p := range ordered {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Report returns a copy of the strategy, order and statistics.
+func (e *Engine) Report() Report {
+	order := e.Order()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	patterns := make(map[string]Stats, len(e.patterns))
+	for _, p := range e.patterns {
+		patterns[p.Name()] = e.stats[p.Name()]
+	}
+	return Report{Strategy: e.strategy, Order: order, Patterns: patterns}
+}
+
+// Session binds the engine to hardware for one ball detection cycle.
+func (e *Engine) Session(hw Buzzer, logger *log.Logger, debug bool) *Session {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var plan []Pattern
+	if e.strategy == StrategyAdaptive {
+		plan = e.orderedLocked()
+	} else {
+		// A fixed strategy falls back to the default escalation when the hardware
+		// cannot run it (e.g. actuator_wiggle without an actuator).
+		plan = []Pattern{e.patternLocked(e.strategy), Escalating}
+	}
+	return &Session{engine: e, hw: hw, logger: logger, debug: debug, plan: plan, fixed: e.strategy != StrategyAdaptive}
+}
+
+func (e *Engine) record(name string, cleared bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := e.stats[name]
+	stats.Attempts++
+	if cleared {
+		stats.Successes++
+	}
+	e.stats[name] = stats
+	if err := e.saveLocked(); err != nil {
+		log.Printf("Jam clearing: %v", err)
+	}
+}
+
+func (e *Engine) orderedLocked() []Pattern {
+	ordered := append([]Pattern(nil), e.patterns...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return e.stats[ordered[i].Name()].successRate() > e.stats[ordered[j].Name()].successRate()
+	})
+	return ordered
+}
+
+func (e *Engine) patternLocked(name string) Pattern {
+	for _, p := range e.patterns {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (e *Engine) namesLocked() []string {
+	names := make([]string, len(e.patterns))
+	for i, p := range e.patterns {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// saveLocked writes the statistics with atomicfile.Write.
+func (e *Engine) saveLocked() error {
+	if e.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(statsFile{Patterns: e.stats}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode jam clearing stats: %w", err)
+	}
+	if err := atomicfile.Write(e.path, data); err != nil {
+		return fmt.Errorf("failed to save jam clearing stats: %w", err)
+	}
+	return nil
+}
+
+// Session runs patterns during one detection cycle. Failed attempt k runs the k-th
+// pattern of the plan (wrapping around), and the outcome of the next detection window
+// is credited to it.
+type Session struct {
+	engine  *Engine
+	hw      Buzzer
+	logger  *log.Logger
+	debug   bool
+	plan    []Pattern
+	fixed   bool
+	pending string
+}
+
+// Buzz forwards to the hardware so a Session can be used wherever a plain vibrator is.
+func (s *Session) Buzz(intensity float64, duration time.Duration) error {
+	return s.hw.Buzz(intensity, duration)
+}
+
+// ClearJam runs the pattern planned for the given failed attempt (1-based). Patterns
+// the hardware cannot run are skipped in favour of the next one in the plan.
+func (s *Session) ClearJam(attempt int) error {
+	s.pending = ""
+	params := s.engine.params
+	for i := 0; i < len(s.plan); i++ {
+		var pattern Pattern
+		if s.fixed {
+			pattern = s.plan[i]
+		} else {
+			pattern = s.plan[(attempt-1+i)%len(s.plan)]
+		}
+		steps := pattern.Steps(params, attempt)
+		if len(steps) == 0 {
+			return nil
+		}
+		s.logger.Printf("Jam clearing: attempt %d running pattern %s (%d steps)", attempt, pattern.Name(), len(steps))
+		err := Execute(s.hw, steps, s.logger, s.debug)
+		if errors.Is(err, ErrUnsupported) {
+			s.logger.Printf("Jam clearing: pattern %s not supported by hardware, trying next", pattern.Name())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("jam clearing pattern %s: %w", pattern.Name(), err)
+		}
+		s.pending = pattern.Name()
+		return nil
+	}
+	return ErrUnsupported
+}
+
+// JamResult credits the last pattern with the outcome of the detection window that
+// followed it. It is a no-op when no pattern ran since the last result.
+func (s *Session) JamResult(cleared bool) {
+	if s.pending == "" {
+		return
+	}
+	name := s.pending
+	s.pending = ""
+	s.engine.record(name, cleared)
+	if cleared {
+		s.logger.Printf("Jam clearing: pattern %s cleared the jam", name)
+	}
+}
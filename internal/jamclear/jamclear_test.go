@@ -0,0 +1,184 @@
+package jamclear
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+)
+
+type fakeHardware struct {
+	actions   []Action
+	buzzErr   error
+	wiggleErr error
+}
+
+func (h *fakeHardware) Buzz(intensity float64, duration time.Duration) error {
+	h.actions = append(h.actions, ActionBuzz)
+	return h.buzzErr
+}
+
+func (h *fakeHardware) BuzzReverse(intensity float64, duration time.Duration) error {
+	h.actions = append(h.actions, ActionBuzzReverse)
+	return h.buzzErr
+}
+
+func (h *fakeHardware) Wiggle(extend time.Duration) error {
+	h.actions = append(h.actions, ActionWiggle)
+	return h.wiggleErr
+}
+
+type buzzOnly struct{ count int }
+
+func (b *buzzOnly) Buzz(intensity float64, duration time.Duration) error {
+	b.count++
+	return nil
+}
+
+func noSleep(t *testing.T) {
+	prev := sleep
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = prev })
+}
+
+func silentLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+func TestEscalatingMatchesLegacySchedule(t *testing.T) {
+	params := Params{Intensity: 0.8, Duration: time.Millisecond, Bursts: 1}
+
+	var counts []int
+	for attempt := 1; attempt <= 5; attempt++ {
+		counts = append(counts, len(Escalating.Steps(params, attempt)))
+	}
+	if want := []int{1, 1, 2, 2, 3}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("unexpected bursts per attempt %v, want %v", counts, want)
+	}
+
+	steps := Escalating.Steps(Params{Intensity: 0.8, Duration: time.Millisecond, Bursts: 2}, 2)
+	if steps[1].Duration != 131*time.Millisecond || steps[0].Pause != 370*time.Millisecond {
+		t.Fatalf("unexpected escalation step %+v", steps)
+	}
+}
+
+func TestPatternsDisabledWithoutBursts(t *testing.T) {
+	for _, p := range Builtin() {
+		if steps := p.Steps(Params{Intensity: 0.8}, 3); len(steps) != 0 {
+			t.Fatalf("pattern %s produced %d steps with bursts disabled", p.Name(), len(steps))
+		}
+	}
+}
+
+func TestExecuteRejectsUnsupportedPatternWithoutMoving(t *testing.T) {
+	noSleep(t)
+	hw := &buzzOnly{}
+	steps := alternatingSteps(Params{Intensity: 0.5, Bursts: 1}, 1)
+
+	if err := Execute(hw, steps, silentLogger(), false); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if hw.count != 0 {
+		t.Fatalf("expected no bursts, got %d", hw.count)
+	}
+}
+
+func TestExecuteStopsOnEmergencyStop(t *testing.T) {
+	noSleep(t)
+	hw := &fakeHardware{buzzErr: vibrator.ErrEmergencyStop}
+	steps := pulseTrainSteps(Params{Intensity: 0.5, Bursts: 1}, 1)
+
+	if err := Execute(hw, steps, silentLogger(), false); !errors.Is(err, vibrator.ErrEmergencyStop) {
+		t.Fatalf("expected emergency stop error, got %v", err)
+	}
+	if len(hw.actions) != 1 {
+		t.Fatalf("expected execution to stop after the first step, got %v", hw.actions)
+	}
+}
+
+func TestAdaptiveOrderFollowsSuccessAndPersists(t *testing.T) {
+	noSleep(t)
+	path := filepath.Join(t.TempDir(), "stats.json")
+	params := Params{Intensity: 0.5, Duration: time.Millisecond, Bursts: 1}
+
+	engine, err := Open(path, StrategyAdaptive, params)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if got := engine.Order()[0]; got != PatternEscalating {
+		t.Fatalf("expected escalating first on a fresh machine, got %s", got)
+	}
+
+	// Attempt 1 runs escalating and fails, attempt 2 runs pulse_train and clears.
+	session := engine.Session(&fakeHardware{}, silentLogger(), false)
+	if err := session.ClearJam(1); err != nil {
+		t.Fatalf("ClearJam returned error: %v", err)
+	}
+	session.JamResult(false)
+	if err := session.ClearJam(2); err != nil {
+		t.Fatalf("ClearJam returned error: %v", err)
+	}
+	session.JamResult(true)
+	session.JamResult(true)
+
+	reopened, err := Open(path, StrategyAdaptive, params)
+	if err != nil {
+		t.Fatalf("reopen returned error: %v", err)
+	}
+	report := reopened.Report()
+	if report.Patterns[PatternEscalating] != (Stats{Attempts: 1}) || report.Patterns[PatternPulseTrain] != (Stats{Attempts: 1, Successes: 1}) {
+		t.Fatalf("unexpected persisted stats: %+v", report.Patterns)
+	}
+	if report.Order[0] != PatternPulseTrain || report.Order[len(report.Order)-1] != PatternEscalating {
+		t.Fatalf("expected pulse_train first and escalating last, got %v", report.Order)
+	}
+}
+
+func TestSessionSkipsPatternsTheHardwareCannotRun(t *testing.T) {
+	noSleep(t)
+	engine, err := Open("", PatternActuatorWiggle, Params{Intensity: 0.5, Bursts: 1})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	hw := &buzzOnly{}
+	session := engine.Session(hw, silentLogger(), false)
+	if err := session.ClearJam(1); err != nil {
+		t.Fatalf("ClearJam returned error: %v", err)
+	}
+	if hw.count != 1 || session.pending != PatternEscalating {
+		t.Fatalf("expected fallback to escalating, got %d bursts and pending %q", hw.count, session.pending)
+	}
+}
+
+func TestSetStrategyRejectsUnknownPattern(t *testing.T) {
+	engine, err := Open("", "", Params{})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if engine.Strategy() != StrategyAdaptive {
+		t.Fatalf("expected empty strategy to mean adaptive, got %s", engine.Strategy())
+	}
+	if err := engine.SetStrategy("shake_harder"); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+	if err := engine.SetStrategy(PatternRamp); err != nil || engine.Strategy() != PatternRamp {
+		t.Fatalf("expected ramp strategy, got %s (err=%v)", engine.Strategy(), err)
+	}
+}
+
+func TestOpenRejectsCorruptStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := Open(path, StrategyAdaptive, Params{}); err == nil {
+		t.Fatal("expected error for corrupt stats file")
+	}
+}
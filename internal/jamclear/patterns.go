@@ -0,0 +1,297 @@
+package jamclear
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+)
+
+// Pattern names of the built-in jam-clearing patterns.
+const (
+	PatternEscalating     = "escalating"
+	PatternPulseTrain     = "pulse_train"
+	PatternRamp           = "ramp"
+	PatternAlternating    = "alternating"
+	PatternActuatorWiggle = "actuator_wiggle"
+)
+
+// ErrUnsupported is returned when a pattern needs hardware the caller cannot drive,
+// e.g. reverse vibration or an actuator wiggle.
+var ErrUnsupported = errors.New("jam-clearing pattern not supported by hardware")
+
+// Action is what a single step of a pattern does.
+type Action int
+
+const (
+	// ActionBuzz runs the vibrator forward (IN3=HIGH, IN4=LOW).
+	ActionBuzz Action = iota
+	// ActionBuzzReverse runs the vibrator backwards (IN3=LOW, IN4=HIGH).
+	ActionBuzzReverse
+	// ActionWiggle extends the actuator for Duration and retracts it again.
+	ActionWiggle
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionBuzz:
+		return "buzz"
+	case ActionBuzzReverse:
+		return "buzz_reverse"
+	case ActionWiggle:
+		return "wiggle"
+	default:
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+}
+
+// Step is one movement of a pattern followed by a pause.
+type Step struct {
+	Action    Action
+	Intensity float64
+	Duration  time.Duration
+	Pause     time.Duration
+}
+
+// Params are the configured vibration settings that patterns scale from.
+// Bursts <= 0 disables jam clearing entirely.
+type Params struct {
+	Intensity float64
+	Duration  time.Duration
+	Bursts    int
+}
+
+// ParamsFromConfig returns the colour sensor vibration settings.
+func ParamsFromConfig(cfg *config.Config) Params {
+	return Params{
+		Intensity: cfg.ColorSensorVibrateIntensity,
+		Duration:  time.Duration(cfg.ColorSensorVibrateDurationMs) * time.Millisecond,
+		Bursts:    cfg.ColorSensorVibrateBursts,
+	}
+}
+
+// Pattern produces the steps to run after the given failed detection attempt (1-based).
+type Pattern interface {
+	Name() string
+	Steps(p Params, attempt int) []Step
+}
+
+// Buzzer is the minimum hardware every pattern needs.
+type Buzzer interface {
+	Buzz(intensity float64, duration time.Duration) error
+}
+
+// ReverseBuzzer is implemented by vibrators that can spin backwards.
+type ReverseBuzzer interface {
+	BuzzReverse(intensity float64, duration time.Duration) error
+}
+
+// Wiggler is implemented by callers that can briefly extend and retract the actuator.
+type Wiggler interface {
+	Wiggle(extend time.Duration) error
+}
+
+type stepFunc struct {
+	name  string
+	steps func(p Params, attempt int) []Step
+}
+
+func (f stepFunc) Name() string                       { return f.name }
+func (f stepFunc) Steps(p Params, attempt int) []Step { return f.steps(p, attempt) }
+
+// Escalating is the original fixed escalation: more, stronger and longer bursts on
+// every failed attempt.
+var Escalating Pattern = stepFunc{name: PatternEscalating, steps: escalatingSteps}
+
+// Builtin returns the built-in patterns in their default (tie-break) order.
+func Builtin() []Pattern {
+	return []Pattern{
+		Escalating,
+		stepFunc{name: PatternPulseTrain, steps: pulseTrainSteps},
+		stepFunc{name: PatternRamp, steps: rampSteps},
+		stepFunc{name: PatternAlternating, steps: alternatingSteps},
+		stepFunc{name: PatternActuatorWiggle, steps: actuatorWiggleSteps},
+	}
+}
+
+func escalatingSteps(p Params, attempt int) []Step {
+	bursts := vibrationBurstsForAttempt(p.Bursts, attempt)
+	steps := make([]Step, 0, bursts)
+	for burst := 0; burst < bursts; burst++ {
+		steps = append(steps, Step{
+			Action:    ActionBuzz,
+			Intensity: scaledVibrationIntensity(p.Intensity, attempt, burst),
+			Duration:  scaledVibrationDuration(p.Duration, attempt, burst),
+			Pause:     scaledVibrationPause(attempt),
+		})
+	}
+	return steps
+}
+
+// pulseTrainSteps fires a fast train of short, strong knocks.
+func pulseTrainSteps(p Params, attempt int) []Step {
+	if p.Bursts <= 0 {
+		return nil
+	}
+	pulses := 3*p.Bursts + (attempt - 1)
+	if pulses > 12 {
+		pulses = 12
+	}
+	intensity := math.Min(1.0, p.Intensity+0.1)
+	steps := make([]Step, pulses)
+	for i := range steps {
+		steps[i] = Step{Action: ActionBuzz, Intensity: intensity, Duration: 60 * time.Millisecond, Pause: 80 * time.Millisecond}
+	}
+	return steps
+}
+
+// rampSteps sweeps the intensity from half the configured level up to full power
+// without pausing, passing through the resonance of the hopper.
+func rampSteps(p Params, attempt int) []Step {
+	if p.Bursts <= 0 {
+		return nil
+	}
+	count := 4 + attempt
+	if count > 8 {
+		count = 8
+	}
+	start := math.Max(0.3, p.Intensity*0.5)
+	duration := baseDuration(p.Duration)
+	steps := make([]Step, count)
+	for i := range steps {
+		intensity := start + (1.0-start)*float64(i)/float64(count-1)
+		steps[i] = Step{Action: ActionBuzz, Intensity: intensity, Duration: duration, Pause: 20 * time.Millisecond}
+	}
+	return steps
+}
+
+// alternatingSteps reverses the motor between bursts so the eccentric mass kicks the
+// hopper in both directions.
+func alternatingSteps(p Params, attempt int) []Step {
+	pairs := vibrationBurstsForAttempt(p.Bursts, attempt)
+	steps := make([]Step, 0, 2*pairs)
+	for pair := 0; pair < pairs; pair++ {
+		intensity := scaledVibrationIntensity(p.Intensity, attempt, pair)
+		duration := baseDuration(p.Duration)
+		steps = append(steps,
+			Step{Action: ActionBuzz, Intensity: intensity, Duration: duration, Pause: 120 * time.Millisecond},
+			Step{Action: ActionBuzzReverse, Intensity: intensity, Duration: duration, Pause: 120 * time.Millisecond},
+		)
+	}
+	return steps
+}
+
+// actuatorWiggleSteps nudges the actuator a short way out and back, finishing with a
+// single burst to settle whatever was moved.
+func actuatorWiggleSteps(p Params, attempt int) []Step {
+	if p.Bursts <= 0 {
+		return nil
+	}
+	wiggles := 1 + (attempt-1)/2
+	if wiggles > 3 {
+		wiggles = 3
+	}
+	steps := make([]Step, 0, wiggles+1)
+	for i := 0; i < wiggles; i++ {
+		steps = append(steps, Step{Action: ActionWiggle, Duration: 150 * time.Millisecond, Pause: 200 * time.Millisecond})
+	}
+	steps = append(steps, Step{Action: ActionBuzz, Intensity: scaledVibrationIntensity(p.Intensity, attempt, 0), Duration: baseDuration(p.Duration), Pause: scaledVibrationPause(attempt)})
+	return steps
+}
+
+// Supports reports whether hw can run every step.
+func Supports(hw Buzzer, steps []Step) bool {
+	for _, step := range steps {
+		switch step.Action {
+		case ActionBuzzReverse:
+			if _, ok := hw.(ReverseBuzzer); !ok {
+				return false
+			}
+		case ActionWiggle:
+			if _, ok := hw.(Wiggler); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Execute runs steps on hw. A failed vibrator burst is logged and skipped like before;
+// an emergency stop, an exhausted duty-cycle budget or an actuator error aborts the
+// remaining steps.
+// It returns ErrUnsupported without moving anything when hw cannot run a step.
+func Execute(hw Buzzer, steps []Step, logger *log.Logger, debug bool) error {
+	if !Supports(hw, steps) {
+		return ErrUnsupported
+	}
+	for i, step := range steps {
+		var err error
+		switch step.Action {
+		case ActionBuzz:
+			err = hw.Buzz(step.Intensity, step.Duration)
+		case ActionBuzzReverse:
+			err = hw.(ReverseBuzzer).BuzzReverse(step.Intensity, step.Duration)
+		case ActionWiggle:
+			err = hw.(Wiggler).Wiggle(step.Duration)
+		}
+		if err != nil {
+			if step.Action == ActionWiggle || errors.Is(err, vibrator.ErrEmergencyStop) || errors.Is(err, vibrator.ErrDutyBudgetExceeded) {
+				return err
+			}
+			logger.Printf("Jam clearing: step %d (%s) failed: %v", i+1, step.Action, err)
+		} else if debug {
+			logger.Printf("Jam clearing: step %d %s intensity=%.2f duration_ms=%d pause_ms=%d", i+1, step.Action, step.Intensity, step.Duration.Milliseconds(), step.Pause.Milliseconds())
+		}
+		sleep(step.Pause)
+	}
+	return nil
+}
+
+func baseDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 100 * time.Millisecond
+	}
+	return d
+}
+
+func vibrationBurstsForAttempt(base int, attempt int) int {
+	if base <= 0 {
+		return 0
+	}
+	bursts := base + (attempt-1)/2
+	if bursts > 5 {
+		return 5
+	}
+	return bursts
+}
+
+func scaledVibrationIntensity(base float64, attempt int, burst int) float64 {
+	if base <= 0 {
+		return 0
+	}
+	return math.Min(1.0, base+0.08*float64(attempt-1)+0.03*float64(burst))
+}
+
+func scaledVibrationDuration(base time.Duration, attempt int, burst int) time.Duration {
+	duration := baseDuration(base) + time.Duration(90*(attempt-1)+40*burst)*time.Millisecond
+	maxDuration := 700 * time.Millisecond
+	if duration > maxDuration {
+		return maxDuration
+	}
+	return duration
+}
+
+func scaledVibrationPause(attempt int) time.Duration {
+	pause := 350 + 20*(attempt-1)
+	if pause > 500 {
+		pause = 500
+	}
+	return time.Duration(pause) * time.Millisecond
+}
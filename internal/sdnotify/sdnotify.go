@@ -0,0 +1,97 @@
+// Package sdnotify implements the systemd notification protocol (sd_notify) over the
+// NOTIFY_SOCKET datagram socket, without cgo or libsystemd.
+package sdnotify
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Messages understood by systemd.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Enabled reports whether the process was started with a notify socket.
+func Enabled() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// Notify sends state to the service manager. It reports false without error when the
+// process was not started by systemd with a notify socket.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Status formats a STATUS= message; systemd shows it in `systemctl status`. Newlines
+// would start a new assignment and are replaced.
+func Status(status string) string {
+	return "STATUS=" + strings.ReplaceAll(status, "\n", " ")
+}
+
+// WatchdogInterval returns the watchdog timeout systemd expects pings within, or 0 when
+// the watchdog is not enabled for this process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog pings the systemd watchdog at half its interval for as long as alive
+// reports true, until ctx is done. Skipping pings lets systemd restart a hung process.
+// It returns at once when the watchdog is not enabled.
+func RunWatchdog(ctx context.Context, alive func() bool) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	healthy := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !alive() {
+				if healthy {
+					log.Printf("Watchdog: no progress, withholding systemd watchdog pings")
+				}
+				healthy = false
+				continue
+			}
+			healthy = true
+			if _, err := Notify(Watchdog); err != nil {
+				log.Printf("Watchdog: failed to notify systemd: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package sdnotify
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Fatalf("expected a no-op without NOTIFY_SOCKET, got sent=%t err=%v", sent, err)
+	}
+}
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func TestNotifySendsDatagram(t *testing.T) {
+	conn := listenNotifySocket(t)
+
+	if sent, err := Notify(Status("detecting_ball: Warte\nauf Ball")); !sent || err != nil {
+		t.Fatalf("expected the message to be sent, got sent=%t err=%v", sent, err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if got := string(buf[:n]); got != "STATUS=detecting_ball: Warte auf Ball" {
+		t.Fatalf("unexpected datagram %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Fatalf("expected 30s, got %v", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := WatchdogInterval(); got != 0 {
+		t.Fatalf("expected the watchdog of another process to be ignored, got %v", got)
+	}
+	t.Setenv("WATCHDOG_USEC", "")
+	if got := WatchdogInterval(); got != 0 {
+		t.Fatalf("expected no watchdog, got %v", got)
+	}
+}
+
+// ensure pings stop while the process makes no progress
+func TestRunWatchdogPingsOnlyWhileAlive(t *testing.T) {
+	conn := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "40000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	var alive atomic.Bool
+	alive.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunWatchdog(ctx, alive.Load)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != Watchdog {
+		t.Fatalf("expected a watchdog ping, got %q %v", buf[:n], err)
+	}
+
+	alive.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	// Drain a ping that raced with the switch.
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := conn.Read(buf); err == nil {
+		t.Fatalf("expected no ping without progress, got %q", buf[:n])
+	}
+}
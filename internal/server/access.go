@@ -0,0 +1,188 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// kioskSessionCookie lets the kiosk page call token-protected endpoints without
+// embedding LOCAL_API_TOKEN in the page.
+const kioskSessionCookie = "baendaeli_kiosk"
+
+// parseAllowedClients parses LOCAL_API_ALLOWED_IPS, a comma-separated list of IPs and
+// CIDR ranges. Invalid entries are logged and skipped.
+func parseAllowedClients(list string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		log.Printf("Warning: ignoring invalid LOCAL_API_ALLOWED_IPS entry %q", entry)
+	}
+	return prefixes
+}
+
+// clientAddr returns the IP of the direct peer. Proxy headers are not trusted.
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// isLoopback reports whether the client is on the device itself: a loopback address or
+// the unix socket.
+func isLoopback(r *http.Request) bool {
+	if local, _ := r.Context().Value(localConnKey{}).(bool); local {
+		return true
+	}
+	addr, ok := clientAddr(r)
+	return ok && addr.IsLoopback()
+}
+
+func writeAccessError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": message,
+	})
+}
+
+// restrictClients rejects clients outside LOCAL_API_ALLOWED_IPS. Loopback clients, such
+// as the kiosk browser on the device, are always allowed; an empty list allows everyone.
+func (s *Server) restrictClients(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimSpace(s.config.LocalAPIAllowedIPs) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isLoopback(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if addr, ok := clientAddr(r); ok {
+			for _, prefix := range s.allowedClients {
+				if prefix.Contains(addr) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		log.Printf("Local API: rejected %s %s from %s (not in LOCAL_API_ALLOWED_IPS)", r.Method, r.URL.Path, r.RemoteAddr)
+		writeAccessError(w, http.StatusForbidden, "client not allowed")
+	})
+}
+
+// checkOrigin rejects state-changing browser requests from other sites, so a page on
+// the event Wi-Fi cannot make a visitor's browser call the local API.
+func (s *Server) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "", "same-origin", "none":
+		default:
+			writeAccessError(w, http.StatusForbidden, "cross-site request rejected")
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !strings.EqualFold(u.Host, r.Host) {
+				writeAccessError(w, http.StatusForbidden, "cross-origin request rejected")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireToken protects mutating endpoints with LOCAL_API_TOKEN, sent as a bearer token
+// or X-API-Token header, or the kiosk session cookie. Without a token the endpoints
+// are open to every allowed client.
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.LocalAPIToken == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := r.Header.Get("X-API-Token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+		if token != "" && s.validToken(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cookie, err := r.Cookie(kioskSessionCookie); err == nil &&
+			subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(s.kioskSession())) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("Local API: rejected %s %s from %s (missing or invalid token)", r.Method, r.URL.Path, r.RemoteAddr)
+		writeAccessError(w, http.StatusUnauthorized, "local API token required")
+	})
+}
+
+func (s *Server) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.LocalAPIToken)) == 1
+}
+
+// kioskSession derives the kiosk cookie value from the token, so it stays valid across
+// restarts and does not reveal the token.
+func (s *Server) kioskSession() string {
+	mac := hmac.New(sha256.New, []byte(s.config.LocalAPIToken))
+	mac.Write([]byte(kioskSessionCookie))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueKioskSession sets the kiosk cookie for the device's own browser, or for a
+// browser that opened the page once with ?token=<LOCAL_API_TOKEN>. It reports whether
+// the request should be redirected to drop the token from the URL.
+func (s *Server) issueKioskSession(w http.ResponseWriter, r *http.Request) (redirect bool) {
+	if s.config.LocalAPIToken == "" {
+		return false
+	}
+	queryToken := r.URL.Query().Get("token")
+	fromQuery := queryToken != "" && s.validToken(queryToken)
+	if !fromQuery && !isLoopback(r) {
+		return false
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     kioskSessionCookie,
+		Value:    s.kioskSession(),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   365 * 24 * 60 * 60,
+	})
+	if fromQuery {
+		log.Printf("Local API: kiosk session issued to %s", r.RemoteAddr)
+	}
+	return fromQuery
+}
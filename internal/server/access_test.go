@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+)
+
+func newAccessTestRouter(cfg *config.Config) http.Handler {
+	cfg.SetDefaults()
+	s := New(cfg)
+	s.SetActuator(actuator.NewRecorder())
+	return s.Router()
+}
+
+func accessRequest(router http.Handler, method, target, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAllowedClients(t *testing.T) {
+	router := newAccessTestRouter(&config.Config{LocalAPIAllowedIPs: "192.168.1.0/24, 10.0.0.7, bogus"})
+
+	cases := []struct {
+		remote string
+		want   int
+	}{
+		{"127.0.0.1:5000", http.StatusOK},
+		{"[::1]:5000", http.StatusOK},
+		{"192.168.1.42:5000", http.StatusOK},
+		{"10.0.0.7:5000", http.StatusOK},
+		{"10.0.0.8:5000", http.StatusForbidden},
+		{"[::ffff:192.168.1.9]:5000", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if rr := accessRequest(router, http.MethodGet, "/ui.js", tc.remote, nil); rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.remote, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestMutatingEndpointsRequireToken(t *testing.T) {
+	router := newAccessTestRouter(&config.Config{LocalAPIToken: "pin-1234"})
+	remote := "192.168.1.42:5000"
+
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, map[string]string{"X-API-Token": "wrong"}); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, map[string]string{"Authorization": "Bearer pin-1234"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected the bearer token to be accepted, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, map[string]string{"X-API-Token": "pin-1234"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected the token header to be accepted, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodPost, "/api/estop", remote, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected estop without a token, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodGet, "/api/device/status", remote, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected read-only endpoints without a token, got %d", rr.Code)
+	}
+}
+
+// ensure the kiosk page's own calls keep working when a token is configured
+func TestKioskSessionCookie(t *testing.T) {
+	router := newAccessTestRouter(&config.Config{LocalAPIToken: "pin-1234"})
+
+	rr := accessRequest(router, http.MethodGet, "/", "192.168.1.42:5000", nil)
+	if rr.Code != http.StatusOK || len(rr.Result().Cookies()) != 0 {
+		t.Fatalf("expected no session for a remote page load, got %d %v", rr.Code, rr.Result().Cookies())
+	}
+
+	rr = accessRequest(router, http.MethodGet, "/", "127.0.0.1:5000", nil)
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != kioskSessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("expected a kiosk session on the device, got %d %v", rr.Code, cookies)
+	}
+	if cookies[0].Value == "pin-1234" {
+		t.Fatal("expected the cookie not to contain the token")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/estop/reset", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.AddCookie(cookies[0])
+	req.Header.Set("Origin", "http://"+req.Host)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the kiosk session to be accepted, got %d", rr.Code)
+	}
+
+	rr = accessRequest(router, http.MethodGet, "/?token=pin-1234", "192.168.1.42:5000", nil)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" || len(rr.Result().Cookies()) != 1 {
+		t.Fatalf("expected a session and a redirect for a valid token link, got %d %v", rr.Code, rr.Result().Cookies())
+	}
+}
+
+func TestCrossOriginRequestsRejected(t *testing.T) {
+	router := newAccessTestRouter(&config.Config{})
+	remote := "127.0.0.1:5000"
+
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, map[string]string{"Origin": "http://evil.example"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a foreign origin, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, map[string]string{"Sec-Fetch-Site": "cross-site"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a cross-site fetch, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodPost, "/api/estop/reset", remote, map[string]string{"Origin": "http://example.com", "Sec-Fetch-Site": "same-origin"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected a same-origin call to pass, got %d", rr.Code)
+	}
+	if rr := accessRequest(router, http.MethodGet, "/api/device/status", remote, map[string]string{"Origin": "http://evil.example"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected reads to ignore the origin, got %d", rr.Code)
+	}
+}
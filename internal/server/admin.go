@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/device"
+	"github.com/jsalamander/baendaeli-client/internal/version"
+)
+
+// secretConfigKeys mark config keys whose values the admin console never shows.
+var secretConfigKeys = []string{"API_KEY", "PASSWORD", "TOKEN", "SECRET"}
+
+type adminPageData struct {
+	Version       string
+	CameraEnabled bool
+	Config        []configEntry
+}
+
+type configEntry struct {
+	Key   string
+	Value string
+}
+
+type adminStatusResponse struct {
+	DeviceAttached bool                   `json:"device_attached"`
+	Snapshot       *device.StateSnapshot  `json:"snapshot,omitempty"`
+	History        []device.StateChange   `json:"history,omitempty"`
+	Sensors        *device.SensorReadings `json:"sensors,omitempty"`
+}
+
+// handleAdmin renders the technician console.
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	data := adminPageData{
+		Version:       version.AppVersion,
+		CameraEnabled: s.config.CameraEnabled,
+		Config:        redactedConfig(s.config),
+	}
+	if err := adminTemplate.Execute(w, data); err != nil {
+		log.Printf("failed to render admin template: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleAdminScript(w http.ResponseWriter, r *http.Request) {
+	content, err := GetStaticFile("admin.js")
+	if err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	if _, err := w.Write(content); err != nil {
+		log.Printf("failed to write admin.js: %v", err)
+	}
+}
+
+// handleAdminStatus returns the live state, recent transitions and sensor readings.
+func (s *Server) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	resp := adminStatusResponse{}
+	if s.deviceClient != nil {
+		snapshot := s.deviceClient.GetStateSnapshot()
+		sensors := s.deviceClient.SensorReadings()
+		resp = adminStatusResponse{
+			DeviceAttached: true,
+			Snapshot:       &snapshot,
+			History:        s.deviceClient.StateHistory(),
+			Sensors:        &sensors,
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminCommand runs a command through the device client's command path. The body
+// uses the fields of a remote command, e.g. {"command":"vibrate","percent":50,"duration_ms":1000}.
+func (s *Server) handleAdminCommand(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Browsers cannot send a cross-site JSON POST without a preflight.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "expected application/json"})
+		return
+	}
+	if s.deviceClient == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "device client not attached"})
+		return
+	}
+
+	var cmd device.CommandResponse
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "invalid command: " + err.Error()})
+		return
+	}
+
+	// A load test runs for minutes; answer it even past HTTP_WRITE_TIMEOUT_SECONDS.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	ack, err := s.deviceClient.ExecuteLocalCommand(cmd)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, device.ErrCommandNotAllowed) {
+			status = http.StatusConflict
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+		return
+	}
+	log.Printf("Admin console: %s from %s finished with status %s", cmd.Command, r.RemoteAddr, ack.Status)
+	json.NewEncoder(w).Encode(ack)
+}
+
+// redactedConfig lists the config keys and values in declaration order, hiding secrets.
+func redactedConfig(cfg any) []configEntry {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	t := v.Type()
+	entries := make([]configEntry, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		value := fmt.Sprint(v.Field(i).Interface())
+		for _, secret := range secretConfigKeys {
+			if strings.Contains(key, secret) && value != "" {
+				value = "••••••"
+				break
+			}
+		}
+		entries = append(entries, configEntry{Key: key, Value: value})
+	}
+	return entries
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// requireAdmin protects technician endpoints with HTTP Basic auth against
+// ADMIN_USERNAME/ADMIN_PASSWORD. Without a configured password the endpoints are
+// disabled rather than left open.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.AdminPassword == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "admin access disabled: set ADMIN_PASSWORD",
+			})
+			return
+		}
+
+		username, password, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(username), []byte(s.config.AdminUsername)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.config.AdminPassword)) == 1
+		if !ok || !userOK || !passOK {
+			if ok {
+				log.Printf("Admin auth failed for %q from %s", username, r.RemoteAddr)
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="baendaeli admin", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
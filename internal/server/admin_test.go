@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+func newAdminTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	if err := camera.Init(camera.Config{Enabled: true, Backend: camera.BackendSimulation}); err != nil {
+		t.Fatalf("camera.Init failed: %v", err)
+	}
+	t.Cleanup(camera.Cleanup)
+
+	cfg := &config.Config{BaendaeliURL: "http://127.0.0.1:1", BaendaeliAPIKey: "super-secret-key", AdminPassword: "secret"}
+	cfg.SetDefaults()
+	s := New(cfg)
+	s.SetDeviceClient(device.New(cfg))
+	ts := httptest.NewServer(s.Router())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func adminRequest(t *testing.T, method, url, contentType, body string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.SetBasicAuth("admin", "secret")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestAdminConsoleRequiresAuthAndHidesSecrets(t *testing.T) {
+	ts := newAdminTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/admin/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", resp.StatusCode)
+	}
+
+	resp, page := adminRequest(t, http.MethodGet, ts.URL+"/admin/", "", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(page, "BAENDAELI_API_KEY") {
+		t.Fatalf("expected the console with the config, got %d", resp.StatusCode)
+	}
+	if strings.Contains(page, "super-secret-key") || strings.Contains(page, ">secret<") {
+		t.Fatal("expected secrets to be redacted")
+	}
+
+	resp, body := adminRequest(t, http.MethodGet, ts.URL+"/admin/api/status", "", "")
+	var status adminStatusResponse
+	if err := json.Unmarshal([]byte(body), &status); err != nil || resp.StatusCode != http.StatusOK || !status.DeviceAttached || status.Snapshot == nil {
+		t.Fatalf("unexpected status response %d %s", resp.StatusCode, body)
+	}
+}
+
+// ensure console commands go through the device command path and its state checks
+func TestAdminCommand(t *testing.T) {
+	ts := newAdminTestServer(t)
+	url := ts.URL + "/admin/api/command"
+
+	if resp, _ := adminRequest(t, http.MethodPost, url, "text/plain", `{"command":"take_picture"}`); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a non-JSON body, got %d", resp.StatusCode)
+	}
+
+	resp, body := adminRequest(t, http.MethodPost, url, "application/json", `{"command":"take_picture"}`)
+	var ack device.AckRequest
+	if err := json.Unmarshal([]byte(body), &ack); err != nil || resp.StatusCode != http.StatusOK || ack.Status != "success" || ack.ImageBase64 == "" {
+		t.Fatalf("expected a picture, got %d %s", resp.StatusCode, body)
+	}
+
+	// A latched emergency stop refuses actuator commands.
+	if resp, _ := adminRequest(t, http.MethodPost, ts.URL+"/api/estop", "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("estop failed: %d", resp.StatusCode)
+	}
+	if resp, body := adminRequest(t, http.MethodPost, url, "application/json", `{"command":"extend"}`); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for extend during an emergency stop, got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := adminRequest(t, http.MethodPost, url, "application/json", `{"command":"estop_reset"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a command the console does not offer, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+)
+
+// streamWriteTimeout bounds writing one frame, so a stalled viewer cannot keep the
+// camera from a take_picture that preempts the stream.
+const streamWriteTimeout = 5 * time.Second
+
+const mjpegBoundary = "frame"
+
+// handleCameraStream serves a multipart MJPEG preview for aligning the camera on site.
+// Only one viewer is served at a time; a capture ends the stream.
+func (s *Server) handleCameraStream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	ctx, stop := s.streamContext(r)
+	defer stop()
+	started := false
+	err := camera.Stream(ctx, camera.StreamOptions{
+		Width:  s.config.CameraStreamWidth,
+		Height: s.config.CameraStreamHeight,
+		FPS:    s.config.CameraStreamFPS,
+	}, func(frame []byte) error {
+		if !started {
+			started = true
+			log.Printf("Camera preview stream started for %s", r.RemoteAddr)
+			w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+			w.Header().Set("Cache-Control", "no-cache, no-store")
+			w.WriteHeader(http.StatusOK)
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(frame)); err != nil {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("\r\n")); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+
+	if started {
+		log.Printf("Camera preview stream for %s ended: %v", r.RemoteAddr, err)
+		return
+	}
+
+	status := http.StatusServiceUnavailable
+	if errors.Is(err, camera.ErrStreamBusy) {
+		status = http.StatusConflict
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
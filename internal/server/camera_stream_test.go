@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsalamander/baendaeli-client/internal/camera"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+)
+
+func newStreamTestServer(t *testing.T, password string) *httptest.Server {
+	t.Helper()
+	if err := camera.Init(camera.Config{Enabled: true}); err != nil {
+		t.Fatalf("camera.Init failed: %v", err)
+	}
+	t.Cleanup(camera.Cleanup)
+
+	cfg := &config.Config{AdminPassword: password}
+	cfg.SetDefaults()
+	ts := httptest.NewServer(New(cfg).Router())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func streamRequest(t *testing.T, ctx context.Context, url, username, password string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/api/camera/stream", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return resp
+}
+
+func TestCameraStreamRequiresAdmin(t *testing.T) {
+	disabled := newStreamTestServer(t, "")
+	resp := streamRequest(t, context.Background(), disabled.URL, "admin", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 without ADMIN_PASSWORD, got %d", resp.StatusCode)
+	}
+
+	ts := newStreamTestServer(t, "secret")
+	resp = streamRequest(t, context.Background(), ts.URL, "admin", "wrong")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected 401 with a Basic challenge, got %d", resp.StatusCode)
+	}
+}
+
+// ensure the stream serves MJPEG parts and turns away a second viewer
+func TestCameraStreamServesOneViewer(t *testing.T) {
+	ts := newStreamTestServer(t, "secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp := streamRequest(t, ctx, ts.URL, "admin", "secret")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "multipart/x-mixed-replace; boundary=frame" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	boundary, _ := reader.ReadString('\n')
+	partType, _ := reader.ReadString('\n')
+	if strings.TrimSpace(boundary) != "--frame" || strings.TrimSpace(partType) != "Content-Type: image/jpeg" {
+		t.Fatalf("unexpected part header %q %q", boundary, partType)
+	}
+
+	second := streamRequest(t, context.Background(), ts.URL, "admin", "secret")
+	second.Body.Close()
+	if second.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a second viewer, got %d", second.StatusCode)
+	}
+}
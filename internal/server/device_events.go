@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+// eventsHeartbeatInterval keeps idle connections alive through proxies and lets the
+// kiosk notice a dead stream.
+var eventsHeartbeatInterval = 15 * time.Second
+
+// handleDeviceEvents streams device state snapshots as Server-Sent Events. Each change
+// is a "state" event carrying the same JSON as /api/device/status; a reconnecting
+// browser resumes after its Last-Event-ID.
+func (s *Server) handleDeviceEvents(w http.ResponseWriter, r *http.Request) {
+	if s.deviceClient == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "device client not attached",
+		})
+		return
+	}
+
+	lastEventID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	replay, events, cancel := s.deviceClient.SubscribeState(lastEventID)
+	defer cancel()
+	ctx, stop := s.streamContext(r)
+	defer stop()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(event device.StateEvent) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: state\ndata: %s\n\n", event.ID, event.Data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for _, event := range replay {
+		if err := write(event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := write(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := fmt.Fprintf(w, "event: heartbeat\ndata: %d\n\n", time.Now().UnixMilli()); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
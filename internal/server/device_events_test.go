@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+// readSSEEvent reads the next event and returns its id, type and data.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) (id, event, data string) {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event != "" {
+				return id, event, data
+			}
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// readStateEvent skips heartbeats and returns the next state event.
+func readStateEvent(t *testing.T, reader *bufio.Reader) (id, data string) {
+	t.Helper()
+	for {
+		id, event, data := readSSEEvent(t, reader)
+		if event == "state" {
+			return id, data
+		}
+	}
+}
+
+func TestDeviceEventsStream(t *testing.T) {
+	defer func(interval time.Duration) { eventsHeartbeatInterval = interval }(eventsHeartbeatInterval)
+	eventsHeartbeatInterval = 200 * time.Millisecond
+
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	srv := New(cfg)
+	dc := device.New(cfg)
+	srv.SetDeviceClient(dc)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/device/events")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	id, data := readStateEvent(t, reader)
+	if id != "1" || !strings.Contains(data, `"state":"starting"`) {
+		t.Fatalf("expected the current snapshot first, got id=%s data=%s", id, data)
+	}
+
+	dc.SetPaymentID("pay-42")
+	id, data = readStateEvent(t, reader)
+	var snapshot device.StateSnapshot
+	if err := json.Unmarshal([]byte(data), &snapshot); err != nil || id != "2" || snapshot.PaymentID != "pay-42" {
+		t.Fatalf("expected a pushed payment change, got id=%s data=%s", id, data)
+	}
+
+	if _, event, _ := readSSEEvent(t, reader); event != "heartbeat" {
+		t.Fatalf("expected a heartbeat, got %s", event)
+	}
+
+	// A reconnect with Last-Event-ID resumes after it.
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/device/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resumed.Body.Close()
+	if id, _ := readStateEvent(t, bufio.NewReader(resumed.Body)); id != "2" {
+		t.Fatalf("expected to resume at event 2, got %s", id)
+	}
+}
+
+func TestDeviceEventsWithoutDeviceClient(t *testing.T) {
+	srv := newTestServer(&config.Config{}, nil)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/device/events", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 so the kiosk falls back to polling, got %d", rr.Code)
+	}
+}
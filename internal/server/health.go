@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/device"
+	"github.com/jsalamander/baendaeli-client/internal/version"
+)
+
+type healthResponse struct {
+	Status        string  `json:"status"`
+	Version       string  `json:"version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+type readyResponse struct {
+	device.HealthReport
+	Detail string `json:"detail,omitempty"`
+}
+
+// handleHealthz reports that the process is alive and serving HTTP.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(healthResponse{
+		Status:        "ok",
+		Version:       version.AppVersion,
+		UptimeSeconds: time.Since(s.started).Seconds(),
+	})
+}
+
+// handleReadyz reports every subsystem and answers 503 while any of them has failed.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if s.deviceClient == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(readyResponse{
+			HealthReport: device.HealthReport{Components: map[string]device.ComponentHealth{}},
+			Detail:       "device client not attached",
+		})
+		return
+	}
+
+	report := s.deviceClient.Health()
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readyResponse{HealthReport: report})
+}
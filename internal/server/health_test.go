@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+func TestHealthz(t *testing.T) {
+	srv := newTestServer(&config.Config{}, nil)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var body healthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || rr.Code != http.StatusOK || body.Status != "ok" {
+		t.Fatalf("unexpected healthz response: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReadyzReflectsComponents(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	srv := New(cfg)
+
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a device client, got %d", rr.Code)
+	}
+
+	srv.SetDeviceClient(device.New(cfg))
+	rr = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body readyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid readyz body: %v", err)
+	}
+	if rr.Code != http.StatusServiceUnavailable || body.Ready || body.Components["backend"].Status != device.HealthFailed {
+		t.Fatalf("expected 503 before the backend was reached, got %d %s", rr.Code, rr.Body.String())
+	}
+}
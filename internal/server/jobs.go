@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+// jobHistoryLimit is the number of finished dispense jobs kept for GET /api/jobs/{id}
+// and Idempotency-Key replays.
+const jobHistoryLimit = 20
+
+// Dispense job states.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// actuateJob is one dispense started by POST /api/actuate.
+type actuateJob struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	device.DispenseProgress
+	TotalTimeMs     int        `json:"total_time_ms"`
+	Error           string     `json:"error,omitempty"`
+	CancelRequested bool       `json:"cancel_requested,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+
+	idempotencyKey string
+	cancel         context.CancelFunc
+}
+
+// dispenseFunc runs one dispense, reporting its steps to progress until ctx ends.
+type dispenseFunc func(ctx context.Context, progress func(device.DispenseProgress)) (int, error)
+
+// jobStore runs at most one dispense at a time and remembers recent ones.
+type jobStore struct {
+	mu       sync.Mutex
+	jobs     map[string]*actuateJob
+	keys     map[string]string // Idempotency-Key to job ID
+	finished []string          // IDs of finished jobs, oldest first
+	active   *actuateJob
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*actuateJob), keys: make(map[string]string)}
+}
+
+type startResult int
+
+const (
+	jobStarted startResult = iota
+	jobReplayed
+	jobBusy
+)
+
+// start runs a new dispense job. A key that was seen before returns that job instead,
+// and while another job runs the running job is returned with jobBusy.
+func (js *jobStore) start(key string, run dispenseFunc) (actuateJob, startResult) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if id, ok := js.keys[key]; ok && key != "" {
+		if job, ok := js.jobs[id]; ok {
+			return *job, jobReplayed
+		}
+	}
+	if js.active != nil {
+		return *js.active, jobBusy
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &actuateJob{
+		ID:               newJobID(),
+		Status:           jobRunning,
+		DispenseProgress: device.DispenseProgress{Stage: device.DispenseWaiting},
+		CreatedAt:        time.Now(),
+		idempotencyKey:   key,
+		cancel:           cancel,
+	}
+	js.jobs[job.ID] = job
+	if key != "" {
+		js.keys[key] = job.ID
+	}
+	js.active = job
+
+	go func() {
+		totalMs, err := run(ctx, func(progress device.DispenseProgress) {
+			js.mu.Lock()
+			job.DispenseProgress = progress
+			js.mu.Unlock()
+		})
+		js.finish(job, totalMs, err)
+	}()
+	return *job, jobStarted
+}
+
+func (js *jobStore) finish(job *actuateJob, totalMs int, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job.cancel()
+	now := time.Now()
+	job.FinishedAt = &now
+	job.TotalTimeMs = totalMs
+	switch {
+	case err == nil:
+		job.Status = jobSucceeded
+	case job.CancelRequested && errors.Is(err, context.Canceled):
+		job.Status = jobCancelled
+	default:
+		job.Status = jobFailed
+		job.Error = err.Error()
+	}
+	log.Printf("Dispense job %s %s after %dms", job.ID, job.Status, totalMs)
+
+	if js.active == job {
+		js.active = nil
+	}
+	js.finished = append(js.finished, job.ID)
+	for len(js.finished) > jobHistoryLimit {
+		oldest := js.jobs[js.finished[0]]
+		delete(js.jobs, oldest.ID)
+		if oldest.idempotencyKey != "" && js.keys[oldest.idempotencyKey] == oldest.ID {
+			delete(js.keys, oldest.idempotencyKey)
+		}
+		js.finished = js.finished[1:]
+	}
+}
+
+func (js *jobStore) get(id string) (actuateJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	if !ok {
+		return actuateJob{}, false
+	}
+	return *job, true
+}
+
+// cancel asks a running job to stop; the job reports cancelled once it has.
+func (js *jobStore) cancel(id string) (actuateJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	if !ok {
+		return actuateJob{}, false
+	}
+	if job.Status == jobRunning && !job.CancelRequested {
+		job.CancelRequested = true
+		job.cancel()
+	}
+	return *job, true
+}
+
+// newJobID returns a random ID such as "a1b2c3d4e5f60718".
+func newJobID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// handleActuate starts a dispense and answers at once with its job; the client follows
+// it on GET /api/jobs/{id}. Retrying with the same Idempotency-Key returns the same job
+// instead of dispensing a second ball, and a request while a dispense runs gets 409.
+func (s *Server) handleActuate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	run := s.dispense
+	if s.deviceClient != nil {
+		run = s.deviceClient.DispenseWithProgress
+	}
+	job, result := s.jobs.start(r.Header.Get("Idempotency-Key"), run)
+	switch result {
+	case jobBusy:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "a dispense is already running",
+			"job":   job,
+		})
+		return
+	case jobStarted:
+		log.Printf("Dispense job %s started from %s", job.ID, r.RemoteAddr)
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// dispense runs one actuator cycle when no device client is attached.
+func (s *Server) dispense(ctx context.Context, progress func(device.DispenseProgress)) (int, error) {
+	ctx = actuator.WithPhaseObserver(ctx, func(phase string) {
+		progress(device.DispenseProgress{Stage: phase})
+	})
+	return s.actuator.Trigger(ctx)
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	job, ok := s.jobs.get(chi.URLParam(r, "id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "job not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleCancelJob stops a running dispense: the movement halts at once, the ball
+// detection before its next attempt.
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	job, ok := s.jobs.cancel(chi.URLParam(r, "id"))
+	switch {
+	case !ok:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "job not found"})
+		return
+	case job.Status != jobRunning:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "job already finished",
+			"job":   job,
+		})
+		return
+	}
+	log.Printf("Dispense job %s cancelled from %s", job.ID, r.RemoteAddr)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
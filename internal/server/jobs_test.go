@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsalamander/baendaeli-client/internal/actuator"
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+func postActuate(t *testing.T, router *chi.Mux, key string) (*httptest.ResponseRecorder, actuateJob) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/actuate", nil)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var job actuateJob
+	if rr.Code == http.StatusConflict {
+		var body struct {
+			Job actuateJob `json:"job"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr, body.Job
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatalf("invalid job response %q: %v", rr.Body.String(), err)
+	}
+	return rr, job
+}
+
+func getJob(t *testing.T, router *chi.Mux, id string) actuateJob {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected job status %d: %s", rr.Code, rr.Body.String())
+	}
+	var job actuateJob
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatalf("invalid job response: %v", err)
+	}
+	return job
+}
+
+// waitForJob polls the job until it has finished.
+func waitForJob(t *testing.T, router *chi.Mux, id string) actuateJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job := getJob(t, router, id); job.Status != jobRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return actuateJob{}
+}
+
+func TestActuateReturnsJobAndReplaysIdempotencyKey(t *testing.T) {
+	srv := newTestServer(&config.Config{}, nil)
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 200 * time.Millisecond
+	srv.SetActuator(recorder)
+	router := srv.Router()
+
+	rr, job := postActuate(t, router, "tap-1")
+	if rr.Code != http.StatusAccepted || job.ID == "" || rr.Header().Get("Location") != "/api/jobs/"+job.ID {
+		t.Fatalf("expected 202 with a job, got %d %s", rr.Code, rr.Body.String())
+	}
+	for running := getJob(t, router, job.ID); running.Stage != device.DispenseExtending; running = getJob(t, router, job.ID) {
+		if running.Status != jobRunning {
+			t.Fatalf("expected a running job while extending, got %+v", running)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A double tap with the same key must not dispense twice.
+	rr, replay := postActuate(t, router, "tap-1")
+	if rr.Code != http.StatusOK || replay.ID != job.ID {
+		t.Fatalf("expected the same job for a repeated key, got %d %s", rr.Code, rr.Body.String())
+	}
+	rr, busy := postActuate(t, router, "tap-2")
+	if rr.Code != http.StatusConflict || busy.ID != job.ID {
+		t.Fatalf("expected 409 with the running job, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if done := waitForJob(t, router, job.ID); done.Status != jobSucceeded || done.FinishedAt == nil {
+		t.Fatalf("expected the job to succeed, got %+v", done)
+	}
+	if _, replay = postActuate(t, router, "tap-1"); replay.ID != job.ID || replay.Status != jobSucceeded {
+		t.Fatalf("expected the finished job for a repeated key, got %+v", replay)
+	}
+	if calls := len(recorder.Calls()); calls != 1 {
+		t.Fatalf("expected one actuator cycle, got %d", calls)
+	}
+}
+
+func TestCancelJobStopsDispense(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	cfg.ColorSensorEnabled = false
+	srv := newTestServer(cfg, nil)
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 5 * time.Second
+	dc := device.New(cfg)
+	dc.SetActuator(recorder)
+	srv.SetDeviceClient(dc)
+	router := srv.Router()
+
+	_, job := postActuate(t, router, "")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/jobs/"+job.ID, nil))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a cancel, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	done := waitForJob(t, router, job.ID)
+	if done.Status != jobCancelled || !done.CancelRequested {
+		t.Fatalf("expected a cancelled job, got %+v", done)
+	}
+	if snapshot := dc.GetStateSnapshot(); snapshot.ActuatorStall || snapshot.Stopped {
+		t.Fatalf("expected a cancel not to latch the actuator, got %+v", snapshot)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/jobs/"+job.ID, nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a finished job, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown job, got %d", rr.Code)
+	}
+}
+
+// ensure a job still waiting for the actuator is cancelled at once, without waiting for
+// the movement that holds it
+func TestCancelWaitingJob(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	cfg.ColorSensorEnabled = false
+	srv := newTestServer(cfg, nil)
+	recorder := actuator.NewRecorder()
+	recorder.Delay = 2 * time.Second
+	dc := device.New(cfg)
+	dc.SetActuator(recorder)
+	srv.SetDeviceClient(dc)
+	router := srv.Router()
+
+	// Another dispense holds the actuator.
+	holding := make(chan struct{})
+	go func() {
+		defer close(holding)
+		dc.DispenseAndWaitForBall()
+	}()
+	for len(recorder.Calls()) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	start := time.Now()
+
+	_, job := postActuate(t, router, "")
+	if job.Stage != device.DispenseWaiting {
+		t.Fatalf("expected the job to wait for the actuator, got %+v", job)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/jobs/"+job.ID, nil))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a cancel, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	done := waitForJob(t, router, job.ID)
+	if done.Status != jobCancelled {
+		t.Fatalf("expected a cancelled job, got %+v", done)
+	}
+	if elapsed := time.Since(start); elapsed >= recorder.Delay {
+		t.Fatalf("expected the cancel before the actuator was released, took %v", elapsed)
+	}
+	if calls := recorder.Calls(); len(calls) != 1 {
+		t.Fatalf("expected the cancelled job not to move the actuator, got %+v", calls)
+	}
+	<-holding
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixAddressPrefix marks a LISTEN_ADDRESS entry as a unix socket path.
+const unixAddressPrefix = "unix:"
+
+// selfSignedValidity is how long a generated certificate is valid.
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+type localConnKey struct{}
+
+// HTTPServer serves the router on every configured listen address.
+type HTTPServer struct {
+	srv       *http.Server
+	listeners []net.Listener
+	tls       bool
+}
+
+// Listen opens the addresses in LISTEN_ADDRESS and prepares the HTTP server with the
+// configured timeouts. TCP addresses use TLS when a certificate is configured; unix
+// sockets are always plain HTTP and count as local clients, like loopback.
+func (s *Server) Listen() (*HTTPServer, error) {
+	h := &HTTPServer{
+		srv: &http.Server{
+			Handler:      s.Router(),
+			ReadTimeout:  timeoutSeconds(s.config.HTTPReadTimeoutSeconds),
+			WriteTimeout: timeoutSeconds(s.config.HTTPWriteTimeoutSeconds),
+			IdleTimeout:  timeoutSeconds(s.config.HTTPIdleTimeoutSeconds),
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				if c.LocalAddr().Network() == "unix" {
+					return context.WithValue(ctx, localConnKey{}, true)
+				}
+				return ctx
+			},
+		},
+	}
+	h.srv.RegisterOnShutdown(s.closeStreams)
+
+	var tlsConfig *tls.Config
+	if s.config.TLSCertFile != "" || s.config.TLSKeyFile != "" {
+		cert, err := loadCertificate(s.config.TLSCertFile, s.config.TLSKeyFile, s.config.TLSSelfSigned)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	for _, addr := range strings.Split(s.config.ListenAddress, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		ln, err := listen(addr)
+		if err != nil {
+			h.closeListeners()
+			return nil, err
+		}
+		if tlsConfig != nil && ln.Addr().Network() != "unix" {
+			ln = tls.NewListener(ln, tlsConfig)
+			h.tls = true
+		}
+		h.listeners = append(h.listeners, ln)
+	}
+	if len(h.listeners) == 0 {
+		return nil, errors.New("LISTEN_ADDRESS has no addresses")
+	}
+	return h, nil
+}
+
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixAddressPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	// Remove a socket left behind by a crash; never delete anything else.
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create socket directory: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// The kiosk browser runs as another user; the socket is as open as the loopback port.
+	if err := os.Chmod(path, 0o666); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	return ln, nil
+}
+
+// timeoutSeconds converts a timeout setting; negative values disable the timeout.
+func timeoutSeconds(seconds int) time.Duration {
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Addrs returns the addresses the server listens on.
+func (h *HTTPServer) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(h.listeners))
+	for i, ln := range h.listeners {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
+
+// TLS reports whether the TCP listeners serve HTTPS.
+func (h *HTTPServer) TLS() bool {
+	return h.tls
+}
+
+// Serve serves on all listeners until Shutdown. It returns the first error, or
+// http.ErrServerClosed after a shutdown.
+func (h *HTTPServer) Serve() error {
+	errs := make(chan error, len(h.listeners))
+	var wg sync.WaitGroup
+	for _, ln := range h.listeners {
+		wg.Add(1)
+		go func(ln net.Listener) {
+			defer wg.Done()
+			errs <- h.srv.Serve(ln)
+		}(ln)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return http.ErrServerClosed
+}
+
+// Shutdown stops accepting connections, ends the event and camera streams and waits
+// for in-flight requests until ctx expires; then the remaining connections are closed.
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	err := h.srv.Shutdown(ctx)
+	if err != nil {
+		h.srv.Close()
+	}
+	return err
+}
+
+func (h *HTTPServer) closeListeners() {
+	for _, ln := range h.listeners {
+		ln.Close()
+	}
+}
+
+// loadCertificate loads the TLS key pair, generating a self-signed one first when
+// selfSigned is set and the files do not exist yet.
+func loadCertificate(certFile, keyFile string, selfSigned bool) (tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must both be set")
+	}
+	if selfSigned {
+		if _, err := os.Stat(certFile); errors.Is(err, os.ErrNotExist) {
+			if err := generateSelfSigned(certFile, keyFile); err != nil {
+				return tls.Certificate{}, fmt.Errorf("generate self-signed certificate: %w", err)
+			}
+			log.Printf("Generated self-signed TLS certificate %s", certFile)
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func generateSelfSigned(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	names := []string{"localhost"}
+	if hostname != "" {
+		names = append(names, hostname, hostname+".local")
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "baendaeli-client"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     names,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/device"
+)
+
+func startHTTPServer(t *testing.T, cfg *config.Config) (*Server, *HTTPServer, chan error) {
+	t.Helper()
+	cfg.SetDefaults()
+	srv := New(cfg)
+	h, err := srv.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- h.Serve() }()
+	t.Cleanup(func() { h.Shutdown(context.Background()) })
+	return srv, h, served
+}
+
+func addrOf(h *HTTPServer, network string) string {
+	for _, addr := range h.Addrs() {
+		if addr.Network() == network {
+			return addr.String()
+		}
+	}
+	return ""
+}
+
+// ensure the unix socket counts as the device itself, like loopback
+func TestListenUnixSocketAndTCP(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "run", "kiosk.sock")
+	_, h, _ := startHTTPServer(t, &config.Config{
+		ListenAddress:      "127.0.0.1:0, unix:" + socket,
+		LocalAPIToken:      "pin-1234",
+		LocalAPIAllowedIPs: "192.0.2.1",
+	})
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := unixClient.Get("http://kiosk/")
+	if err != nil {
+		t.Fatalf("unix socket request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(resp.Cookies()) != 1 {
+		t.Fatalf("expected the page and a kiosk session over the socket, got %d %v", resp.StatusCode, resp.Cookies())
+	}
+
+	resp, err = http.Get("http://" + addrOf(h, "tcp") + "/ui.js")
+	if err != nil {
+		t.Fatalf("tcp request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected loopback TCP to be served, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenSelfSignedTLS(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		ListenAddress: "127.0.0.1:0",
+		TLSCertFile:   filepath.Join(dir, "tls", "cert.pem"),
+		TLSKeyFile:    filepath.Join(dir, "tls", "key.pem"),
+		TLSSelfSigned: true,
+	}
+	_, h, _ := startHTTPServer(t, cfg)
+	if !h.TLS() {
+		t.Fatal("expected TLS to be enabled")
+	}
+	if info, err := os.Stat(cfg.TLSKeyFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a private key file with mode 0600, got %v %v", info, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + addrOf(h, "tcp") + "/ui.js")
+	if err != nil {
+		t.Fatalf("https request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		t.Fatalf("unexpected https response: %d", resp.StatusCode)
+	}
+	if err := resp.TLS.PeerCertificates[0].VerifyHostname("localhost"); err != nil {
+		t.Fatalf("expected the certificate to cover localhost: %v", err)
+	}
+
+	if _, err := (&Server{config: &config.Config{ListenAddress: "127.0.0.1:0", TLSCertFile: cfg.TLSCertFile}}).Listen(); err == nil {
+		t.Fatal("expected an error for a certificate without a key")
+	}
+}
+
+// ensure event streams outlive the read timeout and end on shutdown
+func TestShutdownEndsEventStreams(t *testing.T) {
+	defer func(interval time.Duration) { eventsHeartbeatInterval = interval }(eventsHeartbeatInterval)
+	eventsHeartbeatInterval = 200 * time.Millisecond
+
+	cfg := &config.Config{ListenAddress: "127.0.0.1:0", HTTPReadTimeoutSeconds: 1, HTTPWriteTimeoutSeconds: 1}
+	cfg.SetDefaults()
+	srv := New(cfg)
+	srv.SetDeviceClient(device.New(cfg))
+	h, err := srv.Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- h.Serve() }()
+
+	resp, err := http.Get("http://" + addrOf(h, "tcp") + "/api/device/events")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readStateEvent(t, reader)
+
+	deadline := time.Now().Add(1500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if _, event, _ := readSSEEvent(t, reader); event != "heartbeat" {
+			t.Fatalf("expected heartbeats, got %s", event)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("expected ErrServerClosed, got %v", err)
+	}
+}
@@ -1,12 +1,15 @@
 package server
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jsalamander/baendaeli-client/internal/vibrator"
@@ -24,10 +27,15 @@ type Server struct {
 	config       *config.Config
 	httpClient   *http.Client
 	deviceClient *device.Client
-}
+	actuator     actuator.Actuator
+
+	allowedClients []netip.Prefix
+	started        time.Time
+	jobs           *jobStore
 
-type createPaymentPayload struct {
-	Currency string `json:"currency"`
+	// Closed on shutdown to end the long-lived event and camera streams
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 func New(cfg *config.Config) *Server {
@@ -36,35 +44,85 @@ func New(cfg *config.Config) *Server {
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
+		actuator:       actuator.NewSimulated(actuator.Config{MovementTime: cfg.ActuatorMovement}),
+		allowedClients: parseAllowedClients(cfg.LocalAPIAllowedIPs),
+		started:        time.Now(),
+		jobs:           newJobStore(),
+		shutdown:       make(chan struct{}),
 	}
 }
 
+func (s *Server) closeStreams() {
+	s.shutdownOnce.Do(func() { close(s.shutdown) })
+}
+
+// streamContext returns a context for a long-lived response that ends with the request
+// or when the server shuts down.
+func (s *Server) streamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	go func() {
+		select {
+		case <-s.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // SetDeviceClient sets the device client for updating payment IDs
 func (s *Server) SetDeviceClient(dc *device.Client) {
 	s.deviceClient = dc
 }
 
+// SetActuator sets the actuator used by /api/actuate when no device client is attached
+func (s *Server) SetActuator(a actuator.Actuator) {
+	s.actuator = a
+}
+
 func (s *Server) Router() *chi.Mux {
 	r := chi.NewRouter()
 	if s.config.HTTPRequestLogging {
 		r.Use(middleware.Logger)
 	}
 	r.Use(middleware.Recoverer)
+	r.Use(s.restrictClients, s.checkOrigin)
 
+	r.Get("/healthz", s.handleHealthz)
+	r.Get("/readyz", s.handleReadyz)
 	r.Get("/", s.handleIndex)
 	r.Get("/ui.js", s.handleServeFile("ui.js"))
 	r.Get("/api.js", s.handleServeFile("api.js"))
 	r.Get("/qr.js", s.handleServeFile("qr.js"))
 	r.Get("/main.js", s.handleServeFile("main.js"))
-	r.Post("/api/payment", s.handleCreatePayment)
+	r.With(s.requireToken).Post("/api/payment", s.handleCreatePayment)
 	r.Get("/api/payment/{id}", s.handleGetPaymentStatus)
-	r.Post("/api/actuate", s.handleActuate)
+	r.With(s.requireToken).Post("/api/actuate", s.handleActuate)
+	r.Get("/api/jobs/{id}", s.handleGetJob)
+	r.With(s.requireToken).Delete("/api/jobs/{id}", s.handleCancelJob)
+	// Stopping the machine must never need a token.
+	r.Post("/api/estop", s.handleEmergencyStop)
+	r.With(s.requireToken).Post("/api/estop/reset", s.handleEmergencyStopReset)
 	r.Get("/api/device/status", s.handleDeviceStatus)
+	r.Get("/api/device/events", s.handleDeviceEvents)
+	r.With(s.requireAdmin).Get("/api/camera/stream", s.handleCameraStream)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.requireAdmin)
+		r.Get("/", s.handleAdmin)
+		r.Get("/admin.js", s.handleAdminScript)
+		r.Get("/api/status", s.handleAdminStatus)
+		r.Post("/api/command", s.handleAdminCommand)
+	})
 
 	return r
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if s.issueKioskSession(w, r) {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	data := indexPageData{
 		DefaultAmount:    s.config.DefaultAmount,
@@ -108,64 +166,37 @@ func (s *Server) handleServeFile(filename string) http.HandlerFunc {
 	}
 }
 
+// handleCreatePayment creates a payment through the device client, under the same checks
+// as the state machine: a ball must be ready on the sensor and no payment may be running.
 func (s *Server) handleCreatePayment(w http.ResponseWriter, r *http.Request) {
-	var payload createPaymentPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		// Allow empty body; fall back to defaults
-		payload = createPaymentPayload{}
-	}
-
-	if payload.Currency == "" {
-		payload.Currency = "CHF"
-	}
-
-	if err := vibrator.Buzz(0.5, 2*time.Second); err != nil {
-		log.Printf("vibrator error (continuing): %v", err)
-	}
-
-	reqBody, err := json.Marshal(payload)
-	if err != nil {
-		http.Error(w, "failed to prepare payment request", http.StatusInternalServerError)
-		return
-	}
-
-	targetURL := strings.TrimRight(s.config.BaendaeliURL, "/") + "/api/v1/payment"
-	outbound, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewReader(reqBody))
-	if err != nil {
-		http.Error(w, "failed to create outbound request", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	if s.deviceClient == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "device client not attached",
+		})
 		return
 	}
-	outbound.Header.Set("Authorization", "Bearer "+s.config.BaendaeliAPIKey)
-	outbound.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(outbound)
+	payment, err := s.deviceClient.CreatePayment()
 	if err != nil {
-		http.Error(w, "failed to reach payment service", http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Read the response body to extract payment ID and forward it
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		http.Error(w, "failed to read response", http.StatusInternalServerError)
+		status := http.StatusBadGateway
+		if errors.Is(err, device.ErrPaymentInProgress) || errors.Is(err, device.ErrNoBallReady) {
+			status = http.StatusConflict
+		} else if errors.Is(err, device.ErrOffline) {
+			status = http.StatusServiceUnavailable
+		}
+		log.Printf("payment creation refused: %v", err)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": err.Error(),
+		})
 		return
 	}
 
-	// If successful, try to extract payment ID for device client
-	if (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated) && s.deviceClient != nil {
-		var paymentResp map[string]interface{}
-		if err := json.Unmarshal(respBody, &paymentResp); err == nil {
-			if id, ok := paymentResp["id"].(string); ok && id != "" {
-				s.deviceClient.SetPaymentID(id)
-			}
-		}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	if _, err := w.Write(respBody); err != nil {
-		log.Printf("failed to forward response: %v", err)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(payment); err != nil {
+		log.Printf("failed to write payment response: %v", err)
 	}
 }
 
@@ -198,48 +229,48 @@ func (s *Server) handleGetPaymentStatus(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-func (s *Server) handleActuate(w http.ResponseWriter, r *http.Request) {
+// handleEmergencyStop cuts actuator and vibrator power immediately. It does not wait
+// for a running movement, so it also stops a dispense started by /api/actuate.
+func (s *Server) handleEmergencyStop(w http.ResponseWriter, r *http.Request) {
 	if s.deviceClient != nil {
-		totalMs, err := s.deviceClient.DispenseAndWaitForBall()
-		w.Header().Set("Content-Type", "application/json")
+		s.deviceClient.EmergencyStop("local API")
+	} else {
+		if err := s.actuator.EmergencyStop(); err != nil {
+			log.Printf("actuator emergency stop error: %v", err)
+		}
+		vibrator.EmergencyStop()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "stopped",
+	})
+}
+
+// handleEmergencyStopReset releases a latched emergency stop. With a device client
+// attached this re-homes the actuator and blocks until the restart has finished.
+func (s *Server) handleEmergencyStopReset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		if err != nil {
-			log.Printf("actuator error: %v", err)
+	if s.deviceClient != nil {
+		if err := s.deviceClient.ResetEmergencyStop(); err != nil {
+			log.Printf("emergency stop reset error: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"status":        "error",
-				"error":         "Solibändeli konnte nicht ausgegeben werden",
-				"total_time_ms": totalMs,
+				"status": "error",
+				"error":  err.Error(),
 			})
 			return
 		}
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":        "ok",
-			"total_time_ms": totalMs,
-		})
-		return
-	}
-
-	totalMs, err := actuator.Trigger()
-	w.Header().Set("Content-Type", "application/json")
-
-	if err != nil {
-		log.Printf("actuator error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":        "error",
-			"error":         "Solibändeli konnte nicht ausgegeben werden",
-			"total_time_ms": 0,
-		})
-		return
+	} else {
+		s.actuator.ResetEmergencyStop()
+		vibrator.ResetEmergencyStop()
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":        "ok",
-		"total_time_ms": totalMs,
+		"status": "ok",
 	})
 }
 
@@ -253,6 +284,8 @@ func (s *Server) handleDeviceStatus(w http.ResponseWriter, r *http.Request) {
 			"message":          "Device client not attached",
 			"payment_id":       "",
 			"jammed":           false,
+			"stopped":          false,
+			"maintenance_due":  false,
 			"executing_command": nil,
 			"pending_command":  nil,
 		})
@@ -2,12 +2,12 @@ package server
 
 import (
     "encoding/json"
-    "io"
     "net/http"
     "net/http/httptest"
     "strings"
     "testing"
 
+    "github.com/jsalamander/baendaeli-client/internal/actuator"
     "github.com/jsalamander/baendaeli-client/internal/config"
     "github.com/jsalamander/baendaeli-client/internal/device"
 )
@@ -21,35 +21,13 @@ func newTestServer(cfg *config.Config, client *http.Client) *Server {
     return s
 }
 
-func TestHandleCreatePayment_ForwardsPayloadAndDefaults(t *testing.T) {
-    // backend mock
+func TestHandleCreatePayment_RoutesThroughDeviceClient(t *testing.T) {
+    var creations int
     backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if r.Method != http.MethodPost {
-            t.Fatalf("expected POST, got %s", r.Method)
+        if r.URL.Path == "/api/v1/payment" {
+            creations++
         }
-        if r.URL.Path != "/api/v1/payment" {
-            t.Fatalf("unexpected path: %s", r.URL.Path)
-        }
-        if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
-            t.Fatalf("expected bearer token, got %q", got)
-        }
-
-        body, _ := io.ReadAll(r.Body)
-        var payload map[string]any
-        if err := json.Unmarshal(body, &payload); err != nil {
-            t.Fatalf("payload not JSON: %v", err)
-        }
-
-        if payload["currency"] != "CHF" {
-            t.Fatalf("currency mismatch: %v", payload["currency"])
-        }
-        if payload["payment_redirect_url"] == "" {
-            t.Fatalf("redirect URL should not be empty")
-        }
-
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusCreated)
-        w.Write([]byte(`{"id":"abc123","status":"waiting"}`))
+        w.WriteHeader(http.StatusNoContent)
     }))
     defer backend.Close()
 
@@ -57,17 +35,34 @@ func TestHandleCreatePayment_ForwardsPayloadAndDefaults(t *testing.T) {
         BaendaeliAPIKey: "test-key",
         BaendaeliURL:    backend.URL,
     }
+    cfg.SetDefaults()
     srv := newTestServer(cfg, backend.Client())
 
-    rr := httptest.NewRecorder()
-    req := httptest.NewRequest(http.MethodPost, "/api/payment", nil)
-    srv.Router().ServeHTTP(rr, req)
+    post := func() *httptest.ResponseRecorder {
+        rr := httptest.NewRecorder()
+        req := httptest.NewRequest(http.MethodPost, "/api/payment", strings.NewReader(`{"currency":"CHF"}`))
+        srv.Router().ServeHTTP(rr, req)
+        return rr
+    }
 
-    if rr.Code != http.StatusCreated {
-        t.Fatalf("unexpected status: %d", rr.Code)
+    if rr := post(); rr.Code != http.StatusServiceUnavailable {
+        t.Fatalf("expected 503 without a device client, got %d", rr.Code)
+    }
+
+    deviceClient := device.New(cfg)
+    srv.SetDeviceClient(deviceClient)
+    rr := post()
+    if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), device.ErrNoBallReady.Error()) {
+        t.Fatalf("expected 409 without a ball on the sensor, got %d %s", rr.Code, rr.Body.String())
     }
-    if !strings.Contains(rr.Body.String(), "abc123") {
-        t.Fatalf("response body missing id: %s", rr.Body.String())
+
+    deviceClient.SetPaymentID("pay-1")
+    rr = post()
+    if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), device.ErrPaymentInProgress.Error()) {
+        t.Fatalf("expected 409 while a payment is in progress, got %d %s", rr.Code, rr.Body.String())
+    }
+    if creations != 0 {
+        t.Fatalf("expected no payment request to reach the backend, got %d", creations)
     }
 }
 
@@ -127,6 +122,12 @@ func TestServeMainTemplate_SubstitutesConfig(t *testing.T) {
     if !strings.Contains(body, "fetch('/api/device/status')") {
         t.Fatalf("main.js should poll device status endpoint, body: %s", body)
     }
+    if strings.Contains(body, "withPolledPaymentState") {
+        t.Fatalf("main.js should render polled snapshots as published, body: %s", body)
+    }
+    if !strings.Contains(body, "scheduleEventStreamRetry()") {
+        t.Fatalf("main.js should retry the event stream after falling back to polling, body: %s", body)
+    }
     if strings.Contains(body, "createPayment(") {
         t.Fatalf("main.js should not orchestrate payment creation anymore, body: %s", body)
     }
@@ -148,6 +149,9 @@ func TestServeMainTemplate_SubstitutesConfig(t *testing.T) {
     if strings.Contains(body, "renderQrPlaceholder('Betrag wird ausgewählt'") {
         t.Fatalf("main.js should not duplicate waiting-for-amount countdown text in qr placeholder, body: %s", body)
     }
+    if !strings.Contains(body, "offline: {") {
+        t.Fatalf("main.js should map the offline state, body: %s", body)
+    }
     if !strings.Contains(body, "state === 'awaiting_payment'") {
         t.Fatalf("main.js should handle awaiting_payment overlay branch, body: %s", body)
     }
@@ -240,3 +244,68 @@ func TestHandleDeviceStatusWithDeviceClientSnapshot(t *testing.T) {
         t.Fatal("expected jammed field in device snapshot")
     }
 }
+
+func TestHandleEmergencyStopLatchesActuatorUntilReset(t *testing.T) {
+    cfg := &config.Config{}
+    srv := newTestServer(cfg, nil)
+    recorder := actuator.NewRecorder()
+    srv.SetActuator(recorder)
+    router := srv.Router()
+
+    rr := httptest.NewRecorder()
+    router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/estop", nil))
+    if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"stopped"`) {
+        t.Fatalf("unexpected estop response: %d %s", rr.Code, rr.Body.String())
+    }
+
+    _, job := postActuate(t, router, "")
+    if done := waitForJob(t, router, job.ID); done.Status != jobFailed {
+        t.Fatalf("expected actuate to fail while stopped, got %+v", done)
+    }
+
+    rr = httptest.NewRecorder()
+    router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/estop/reset", nil))
+    if rr.Code != http.StatusOK {
+        t.Fatalf("unexpected reset status: %d", rr.Code)
+    }
+
+    _, job = postActuate(t, router, "")
+    if done := waitForJob(t, router, job.ID); done.Status != jobSucceeded {
+        t.Fatalf("expected actuate to succeed after reset, got %+v", done)
+    }
+
+    var methods []string
+    for _, call := range recorder.Calls() {
+        methods = append(methods, call.Method)
+    }
+    if got := strings.Join(methods, ","); got != "estop,trigger,estop_reset,trigger" {
+        t.Fatalf("unexpected actuator calls: %s", got)
+    }
+}
+
+func TestHandleEmergencyStopWithDeviceClient(t *testing.T) {
+    cfg := &config.Config{}
+    srv := newTestServer(cfg, nil)
+    dc := device.New(cfg)
+    dc.SetActuator(actuator.NewRecorder())
+    srv.SetDeviceClient(dc)
+    router := srv.Router()
+
+    rr := httptest.NewRecorder()
+    router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/estop", nil))
+    if rr.Code != http.StatusOK {
+        t.Fatalf("unexpected estop status: %d", rr.Code)
+    }
+    if snapshot := dc.GetStateSnapshot(); snapshot.State != string(device.StateStopped) || !snapshot.Stopped {
+        t.Fatalf("expected device client to latch stopped, got %+v", snapshot)
+    }
+
+    rr = httptest.NewRecorder()
+    router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/estop/reset", nil))
+    if rr.Code != http.StatusOK {
+        t.Fatalf("unexpected reset status: %d %s", rr.Code, rr.Body.String())
+    }
+    if dc.GetStateSnapshot().Stopped {
+        t.Fatal("expected reset to clear the stopped latch")
+    }
+}
@@ -8,11 +8,12 @@ import (
 )
 
 var (
-	//go:embed templates/index.html templates/main.js templates/api.js templates/ui.js templates/qr.js
+	//go:embed templates/index.html templates/main.js templates/api.js templates/ui.js templates/qr.js templates/admin.html templates/admin.js
 	templateFS embed.FS
 
 	indexTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/index.html"))
 	mainJS        = template.Must(template.ParseFS(templateFS, "templates/main.js"))
+	adminTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/admin.html"))
 )
 
 type indexPageData struct {
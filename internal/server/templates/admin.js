@@ -0,0 +1,117 @@
+// Technician console: polls the device state and runs commands through /admin/api/command.
+
+const statusIntervalMs = 2000;
+
+function text(id, value) {
+	document.getElementById(id).textContent = value;
+}
+
+function renderStatus(status) {
+	if (!status.device_attached) {
+		text('state', 'detached');
+		text('stateMessage', 'Device client not attached');
+		return;
+	}
+	const snapshot = status.snapshot;
+	text('state', snapshot.state);
+	text('stateMessage', snapshot.message || '');
+	text('snapshot', JSON.stringify(snapshot, null, 2));
+	text('sensors', JSON.stringify(status.sensors, null, 2));
+
+	const flags = document.getElementById('flags');
+	flags.replaceChildren();
+	for (const [key, label] of [['jammed', 'Jammed'], ['actuator_stall', 'Actuator stall'], ['stopped', 'E-stop'], ['maintenance_due', 'Maintenance due']]) {
+		if (snapshot[key]) {
+			const badge = document.createElement('span');
+			badge.className = 'badge badge-error';
+			badge.textContent = label;
+			flags.appendChild(badge);
+		}
+	}
+
+	const history = document.getElementById('history');
+	history.replaceChildren();
+	for (const change of status.history || []) {
+		const row = history.insertRow();
+		row.insertCell().textContent = new Date(change.at).toLocaleTimeString();
+		row.insertCell().textContent = change.state;
+		row.insertCell().textContent = change.message || '';
+	}
+}
+
+async function refreshStatus() {
+	try {
+		const res = await fetch('/admin/api/status', { cache: 'no-store' });
+		if (res.ok) {
+			renderStatus(await res.json());
+		}
+	} catch (err) {
+		console.error('status refresh failed', err);
+	}
+}
+
+function commandPayload(command) {
+	const payload = { command };
+	if (command === 'vibrate') {
+		payload.percent = Number(document.getElementById('vibratePercent').value);
+		payload.duration_ms = Number(document.getElementById('vibrateDuration').value);
+	}
+	if (command === 'load_test') {
+		payload.repeat_count = Number(document.getElementById('loadTestCycles').value);
+	}
+	return payload;
+}
+
+async function runCommand(button) {
+	const command = button.dataset.command;
+	const buttons = document.querySelectorAll('[data-command]');
+	buttons.forEach((b) => { b.disabled = true; });
+	text('commandResult', command + ' running…');
+	try {
+		const res = await fetch('/admin/api/command', {
+			method: 'POST',
+			headers: { 'Content-Type': 'application/json' },
+			body: JSON.stringify(commandPayload(command)),
+		});
+		const body = await res.json();
+		if (!res.ok) {
+			text('commandResult', command + ' refused: ' + body.error);
+			return;
+		}
+		text('commandResult', command + ': ' + body.status + (body.error_message ? ' – ' + body.error_message : ''));
+		const picture = document.getElementById('picture');
+		if (body.image_base64) {
+			picture.src = 'data:image/jpeg;base64,' + body.image_base64;
+			picture.classList.remove('hidden');
+		}
+	} catch (err) {
+		text('commandResult', command + ' failed: ' + err);
+	} finally {
+		buttons.forEach((b) => { b.disabled = false; });
+		refreshStatus();
+	}
+}
+
+function togglePreview() {
+	const preview = document.getElementById('preview');
+	const button = document.getElementById('togglePreview');
+	if (preview.classList.contains('hidden')) {
+		preview.src = '/api/camera/stream?ts=' + Date.now();
+		preview.classList.remove('hidden');
+		button.textContent = 'Stop preview';
+	} else {
+		preview.removeAttribute('src');
+		preview.classList.add('hidden');
+		button.textContent = 'Start preview';
+	}
+}
+
+document.querySelectorAll('[data-command]').forEach((button) => {
+	button.addEventListener('click', () => runCommand(button));
+});
+const previewButton = document.getElementById('togglePreview');
+if (previewButton) {
+	previewButton.addEventListener('click', togglePreview);
+}
+refreshStatus();
+setInterval(refreshStatus, statusIntervalMs);
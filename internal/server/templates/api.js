@@ -9,15 +9,9 @@ async function safeParseJson(res) {
 	}
 }
 
+// Currency, redirect URL and metadata come from the device config (PAYMENT_*).
 async function createPayment() {
-	const res = await fetch('/api/payment', {
-		method: 'POST',
-		headers: { 'Content-Type': 'application/json' },
-		body: JSON.stringify({
-			currency: 'CHF',
-			payment_redirect_url: 'https://example.com/payments/123/complete'
-		})
-	});
+	const res = await fetch('/api/payment', { method: 'POST' });
 
 	const data = await safeParseJson(res);
 
@@ -14,6 +14,15 @@ let deviceStatusTimer = null;
 let consecutiveStatusFailures = 0;
 const maxStatusFailuresBeforeOffline = 3;
 let pollingStarted = false;
+// The device pushes state over /api/device/events; polling is the fallback.
+const eventStreamTimeoutMs = 40000;
+let eventStreamWatchdog = null;
+let statusPollingActive = false;
+// After the stream fails the page polls and reopens the stream with backoff.
+const eventStreamRetryMinMs = 2000;
+const eventStreamRetryMaxMs = 60000;
+let eventStreamRetryMs = eventStreamRetryMinMs;
+let eventStreamRetryTimer = null;
 
 const stateUi = {
 	starting: {
@@ -96,6 +105,30 @@ const stateUi = {
 		placeholderTitle: 'Stau detektiert',
 		placeholderSubtitle: 'Bitte rufe eine Techniker*in.'
 	},
+	stopped: {
+		status: 'Not-Halt',
+		badge: 'badge-error',
+		title: 'Not-Halt aktiv',
+		description: 'Alle Motoren wurden gestoppt. Freigabe durch eine Techniker*in erforderlich.',
+		placeholderTitle: 'Not-Halt aktiv',
+		placeholderSubtitle: 'Bitte rufe eine Techniker*in.'
+	},
+	actuator_stall: {
+		status: 'Aktuator blockiert',
+		badge: 'badge-error',
+		title: 'Technik-Hinweis',
+		description: 'Der Aktuator wurde wegen Blockade oder Überlast gestoppt.',
+		placeholderTitle: 'Aktuator blockiert',
+		placeholderSubtitle: 'Bitte rufe eine Techniker*in.'
+	},
+	offline: {
+		status: 'Keine Verbindung',
+		badge: 'badge-warning',
+		title: 'Zurzeit keine Zahlung möglich',
+		description: 'Die Verbindung zum Zahlungsserver ist unterbrochen. Der Automat versucht es automatisch weiter.',
+		placeholderTitle: 'Keine Verbindung',
+		placeholderSubtitle: 'Bitte später erneut versuchen.'
+	},
 	error: {
 		status: 'Fehlerzustand',
 		badge: 'badge-error',
@@ -221,17 +254,7 @@ function formatRemainingLabel(expiryMs) {
 }
 
 function renderDeviceState(data) {
-	const rawState = (data.state || '').toLowerCase();
-	const hasActivePayment = Boolean((data.payment_id || '').trim());
-	const phase = String((data.payment && (data.payment.payment_phase || data.payment.paymentPhase)) || '').toLowerCase().trim();
-	let state = rawState;
-
-	// Guard against transient backend snapshot races: if payment_id is active,
-	// do not display the plain detecting_ball screen.
-	if (hasActivePayment && rawState === 'detecting_ball') {
-		state = phase === 'waiting_for_payment' ? 'awaiting_payment' : 'ball_detected';
-	}
-
+	const state = (data.state || '').toLowerCase();
 	const ui = mapStateUi(state);
 	const message = data.message || ui.status;
 	let statusMessage = message;
@@ -277,6 +300,89 @@ function renderDeviceState(data) {
 	setCommandOverlay(data.executing_command, message);
 }
 
+function recordStatusFailure() {
+	consecutiveStatusFailures += 1;
+	if (consecutiveStatusFailures >= maxStatusFailuresBeforeOffline) {
+		updateDiagnostics({ ok: false, latencyMs: null, at: Date.now() });
+		updateStatus('Gerätestatus nicht verfügbar', 'badge-error');
+		renderQrPlaceholder('Verbindung fehlt', 'Statusdaten konnten nicht geladen werden.');
+		deviceCommandOverlay.classList.add('hidden');
+	}
+}
+
+function startPolling() {
+	clearTimeout(eventStreamWatchdog);
+	if (statusPollingActive) {
+		return;
+	}
+	statusPollingActive = true;
+	checkDeviceStatus();
+}
+
+function stopPolling() {
+	statusPollingActive = false;
+	clearTimeout(deviceStatusTimer);
+}
+
+// scheduleEventStreamRetry reopens the stream after a failure, doubling the wait up to
+// eventStreamRetryMaxMs; polling keeps the page current meanwhile.
+function scheduleEventStreamRetry() {
+	clearTimeout(eventStreamRetryTimer);
+	eventStreamRetryTimer = setTimeout(startEventStream, eventStreamRetryMs);
+	eventStreamRetryMs = Math.min(eventStreamRetryMs * 2, eventStreamRetryMaxMs);
+}
+
+// startEventStream subscribes to pushed state. The browser reconnects on its own and
+// resumes with Last-Event-ID; if the stream is refused or goes silent past the
+// heartbeat, the page falls back to polling and retries the stream with backoff. The
+// first event on a working stream stops the polling again.
+function startEventStream() {
+	if (!window.EventSource) {
+		return false;
+	}
+	const source = new EventSource('/api/device/events');
+	let opened = false;
+	let failed = false;
+	const fallBack = () => {
+		if (failed) {
+			return;
+		}
+		failed = true;
+		source.close();
+		startPolling();
+		scheduleEventStreamRetry();
+	};
+	const resetWatchdog = () => {
+		clearTimeout(eventStreamWatchdog);
+		eventStreamWatchdog = setTimeout(fallBack, eventStreamTimeoutMs);
+	};
+	const markAlive = () => {
+		stopPolling();
+		eventStreamRetryMs = eventStreamRetryMinMs;
+		consecutiveStatusFailures = 0;
+		updateDiagnostics({ ok: true, latencyMs: null, at: Date.now() });
+		resetWatchdog();
+	};
+
+	source.addEventListener('open', () => {
+		opened = true;
+		resetWatchdog();
+	});
+	source.addEventListener('state', (event) => {
+		markAlive();
+		renderDeviceState(JSON.parse(event.data));
+	});
+	source.addEventListener('heartbeat', markAlive);
+	source.addEventListener('error', () => {
+		if (!opened || source.readyState === EventSource.CLOSED) {
+			fallBack();
+			return;
+		}
+		recordStatusFailure();
+	});
+	return true;
+}
+
 function checkDeviceStatus() {
 	const startedAt = performance.now();
 	const timestamp = Date.now();
@@ -287,6 +393,10 @@ function checkDeviceStatus() {
 			if (!res.ok) {
 				throw new Error('Device status unavailable');
 			}
+			if (!statusPollingActive) {
+				// The stream took over while this request was in flight.
+				return;
+			}
 
 			consecutiveStatusFailures = 0;
 			const latencyMs = Math.round(performance.now() - startedAt);
@@ -295,17 +405,12 @@ function checkDeviceStatus() {
 		})
 		.catch((err) => {
 			console.error('Failed to check device status:', err);
-			consecutiveStatusFailures += 1;
-
-			if (consecutiveStatusFailures >= maxStatusFailuresBeforeOffline) {
-				updateDiagnostics({ ok: false, latencyMs: null, at: Date.now() });
-				updateStatus('Gerätestatus nicht verfügbar', 'badge-error');
-				renderQrPlaceholder('Verbindung fehlt', 'Statusdaten konnten nicht geladen werden.');
-				deviceCommandOverlay.classList.add('hidden');
-			}
+			recordStatusFailure();
 		})
 		.finally(() => {
-			deviceStatusTimer = setTimeout(checkDeviceStatus, 2000);
+			if (statusPollingActive) {
+				deviceStatusTimer = setTimeout(checkDeviceStatus, 2000);
+			}
 		});
 }
 
@@ -315,7 +420,9 @@ function start() {
 	}
 	pollingStarted = true;
 	setDiagnosticsPending();
-	checkDeviceStatus();
+	if (!startEventStream()) {
+		startPolling();
+	}
 }
 
 document.addEventListener('DOMContentLoaded', () => {
@@ -0,0 +1,132 @@
+package vibrator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrDutyBudgetExceeded is returned by Buzz when the rolling on-time budget is used up.
+// The motor overheats when driven continuously, so bursts are refused until enough
+// on-time has left the window.
+var ErrDutyBudgetExceeded = errors.New("vibrator: duty-cycle budget exhausted")
+
+// minThrottledBurst is the shortest burst still worth running when the remaining budget
+// is smaller than the requested duration; below it the burst is refused.
+const minThrottledBurst = 100 * time.Millisecond
+
+// DutyStatus is the current duty-cycle budget.
+type DutyStatus struct {
+	Enabled          bool    `json:"enabled"`
+	MaxOnSeconds     float64 `json:"max_on_seconds"`
+	WindowSeconds    float64 `json:"window_seconds"`
+	UsedSeconds      float64 `json:"used_seconds"`
+	RemainingSeconds float64 `json:"remaining_seconds"`
+	Exhausted        bool    `json:"exhausted"`
+}
+
+type onInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+// dutyCycle limits the vibrator on-time per rolling window. A nil *dutyCycle grants
+// every burst in full.
+type dutyCycle struct {
+	mu     sync.Mutex
+	maxOn  time.Duration
+	window time.Duration
+	bursts []*onInterval
+	now    func() time.Time
+}
+
+func newDutyCycle(maxOn, window time.Duration) *dutyCycle {
+	if maxOn <= 0 || window <= 0 {
+		return nil
+	}
+	return &dutyCycle{maxOn: maxOn, window: window, now: time.Now}
+}
+
+// reserve books up to requested on-time starting now. It returns the granted duration,
+// which is shorter than requested when the budget is nearly used up, and a finish func
+// that trims the booking to the time actually spent buzzing.
+func (d *dutyCycle) reserve(requested time.Duration) (time.Duration, func(), error) {
+	if d == nil {
+		return requested, func() {}, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	remaining := d.maxOn - d.usedLocked(now)
+	granted := requested
+	if remaining < granted {
+		if remaining < minThrottledBurst {
+			return 0, nil, fmt.Errorf("%w (%v of %v used in the last %v)", ErrDutyBudgetExceeded, (d.maxOn - remaining).Round(time.Millisecond), d.maxOn, d.window)
+		}
+		granted = remaining
+	}
+
+	burst := &onInterval{start: now, end: now.Add(granted)}
+	d.bursts = append(d.bursts, burst)
+	return granted, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if end := d.now(); end.Before(burst.end) {
+			burst.end = end
+		}
+	}, nil
+}
+
+// usedLocked sums the on-time inside the window ending now, including bookings of
+// bursts still running, and forgets bursts that left the window.
+func (d *dutyCycle) usedLocked(now time.Time) time.Duration {
+	windowStart := now.Add(-d.window)
+	kept := d.bursts[:0]
+	var used time.Duration
+	for _, burst := range d.bursts {
+		if !burst.end.After(windowStart) {
+			continue
+		}
+		kept = append(kept, burst)
+		start := burst.start
+		if start.Before(windowStart) {
+			start = windowStart
+		}
+		used += burst.end.Sub(start)
+	}
+	d.bursts = kept
+	return used
+}
+
+func (d *dutyCycle) status() DutyStatus {
+	if d == nil {
+		return DutyStatus{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	used := d.usedLocked(d.now())
+	remaining := d.maxOn - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return DutyStatus{
+		Enabled:          true,
+		MaxOnSeconds:     d.maxOn.Seconds(),
+		WindowSeconds:    d.window.Seconds(),
+		UsedSeconds:      used.Seconds(),
+		RemainingSeconds: remaining.Seconds(),
+		Exhausted:        remaining < minThrottledBurst,
+	}
+}
+
+// DutyBudget returns the current duty-cycle budget. It reports a disabled budget when
+// the vibrator is not initialised or no budget is configured.
+func DutyBudget() DutyStatus {
+	v := vib.Load()
+	if v == nil {
+		return DutyStatus{}
+	}
+	return v.duty.status()
+}
@@ -0,0 +1,88 @@
+package vibrator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestDutyCycle(maxOn, window time.Duration) (*dutyCycle, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	d := newDutyCycle(maxOn, window)
+	d.now = clock.now
+	return d, clock
+}
+
+// ensure the budget grants, throttles and then refuses bursts, and recovers as the
+// window rolls on
+func TestDutyCycleThrottlesThenRejects(t *testing.T) {
+	d, clock := newTestDutyCycle(10*time.Second, time.Minute)
+
+	granted, finish, err := d.reserve(8 * time.Second)
+	if err != nil || granted != 8*time.Second {
+		t.Fatalf("expected full grant, got %v (err=%v)", granted, err)
+	}
+	clock.t = clock.t.Add(8 * time.Second)
+	finish()
+
+	granted, finish, err = d.reserve(5 * time.Second)
+	if err != nil || granted != 2*time.Second {
+		t.Fatalf("expected burst throttled to 2s, got %v (err=%v)", granted, err)
+	}
+	clock.t = clock.t.Add(2 * time.Second)
+	finish()
+
+	if _, _, err := d.reserve(time.Second); !errors.Is(err, ErrDutyBudgetExceeded) {
+		t.Fatalf("expected ErrDutyBudgetExceeded, got %v", err)
+	}
+	if status := d.status(); !status.Exhausted || status.UsedSeconds != 10 || status.RemainingSeconds != 0 {
+		t.Fatalf("unexpected exhausted status %+v", status)
+	}
+
+	// The first burst started 10s ago; 55s later only 3s of it remain in the window.
+	clock.t = clock.t.Add(55 * time.Second)
+	granted, _, err = d.reserve(5 * time.Second)
+	if err != nil || granted != 5*time.Second {
+		t.Fatalf("expected full grant after the window rolled on, got %v (err=%v)", granted, err)
+	}
+}
+
+// ensure a burst that ends early only uses the time it actually ran
+func TestDutyCycleFinishTrimsBooking(t *testing.T) {
+	d, clock := newTestDutyCycle(10*time.Second, time.Minute)
+
+	_, finish, err := d.reserve(10 * time.Second)
+	if err != nil {
+		t.Fatalf("reserve returned error: %v", err)
+	}
+	if status := d.status(); status.RemainingSeconds != 0 {
+		t.Fatalf("running burst must count in full, got %+v", status)
+	}
+	clock.t = clock.t.Add(3 * time.Second)
+	finish()
+
+	if status := d.status(); status.UsedSeconds != 3 || status.RemainingSeconds != 7 {
+		t.Fatalf("expected 3s used after early finish, got %+v", status)
+	}
+}
+
+// ensure Buzz refuses to run once the budget is exhausted
+func TestBuzzRejectedWhenBudgetExhausted(t *testing.T) {
+	prev := vib.Load()
+	vib.Store(&vibrator{sim: true, duty: newDutyCycle(150*time.Millisecond, time.Minute)})
+	defer vib.Store(prev)
+
+	if err := Buzz(0.5, 100*time.Millisecond); err != nil {
+		t.Fatalf("first Buzz returned error: %v", err)
+	}
+	if err := Buzz(0.5, 100*time.Millisecond); !errors.Is(err, ErrDutyBudgetExceeded) {
+		t.Fatalf("expected ErrDutyBudgetExceeded, got %v", err)
+	}
+	if !DutyBudget().Exhausted {
+		t.Fatalf("expected exhausted budget, got %+v", DutyBudget())
+	}
+}
@@ -0,0 +1,43 @@
+package vibrator
+
+import (
+	"context"
+	"time"
+)
+
+// PatternStep is one burst of a vibration pattern followed by a pause.
+type PatternStep struct {
+	Intensity float64       // 0.0–1.0
+	Duration  time.Duration // on-time of the burst
+	Pause     time.Duration // off-time before the next step
+	Reverse   bool          // spin backwards (IN3=LOW, IN4=HIGH)
+}
+
+// RunPattern runs steps in order and returns how many completed. It stops early when
+// ctx is cancelled (returning the context's cause) or on an emergency stop
+// (ErrEmergencyStop). The pause after the last step is skipped.
+// If the vibrator is not initialised or disabled, all steps complete immediately.
+func RunPattern(ctx context.Context, steps []PatternStep) (int, error) {
+	for i, step := range steps {
+		if err := context.Cause(ctx); err != nil {
+			return i, err
+		}
+		if err := buzz(ctx, step.Intensity, step.Duration, step.Reverse); err != nil {
+			return i, err
+		}
+		if vib.Load() == nil || step.Pause <= 0 || i == len(steps)-1 {
+			continue
+		}
+		estop, err := abortChannel()
+		if err != nil {
+			return i + 1, err
+		}
+		abort, release := abortOnDone(ctx, estop)
+		aborted := sleepOrAbort(step.Pause, abort)
+		release()
+		if aborted {
+			return i + 1, abortError(ctx)
+		}
+	}
+	return len(steps), nil
+}
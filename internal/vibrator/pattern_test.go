@@ -0,0 +1,66 @@
+package vibrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
+)
+
+// ensure RunPattern drives each step in its direction and honours pauses
+func TestRunPatternRunsAllSteps(t *testing.T) {
+	prev := vib.Load()
+	in3 := &levelLog{Pin: gpiotest.Pin{N: "IN3"}}
+	in4 := &levelLog{Pin: gpiotest.Pin{N: "IN4"}}
+	vib.Store(&vibrator{in3Pin: in3, in4Pin: in4, enbPin: &gpiotest.Pin{N: "ENB"}})
+	defer vib.Store(prev)
+
+	steps := []PatternStep{
+		{Intensity: 0.5, Duration: 10 * time.Millisecond, Pause: 30 * time.Millisecond},
+		{Intensity: 0.5, Duration: 10 * time.Millisecond, Pause: time.Hour, Reverse: true},
+	}
+	start := time.Now()
+	completed, err := RunPattern(context.Background(), steps)
+	if err != nil || completed != 2 {
+		t.Fatalf("expected 2 completed steps, got %d (err=%v)", completed, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("unexpected pattern duration %v (pause after last step must be skipped)", elapsed)
+	}
+	if len(in3.levels) != 3 || in3.levels[0] != gpio.High {
+		t.Fatalf("expected IN3 driven for the forward step only, got %v", in3.levels)
+	}
+	if len(in4.levels) != 3 || in4.levels[1] != gpio.High {
+		t.Fatalf("expected IN4 driven for the reverse step only, got %v", in4.levels)
+	}
+}
+
+// ensure cancelling the context stops a running pattern between or during steps
+func TestRunPatternCancel(t *testing.T) {
+	prev := vib.Load()
+	vib.Store(&vibrator{sim: true})
+	defer vib.Store(prev)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	steps := []PatternStep{
+		{Intensity: 0.5, Duration: 10 * time.Millisecond, Pause: 10 * time.Millisecond},
+		{Intensity: 0.5, Duration: 2 * time.Second},
+		{Intensity: 0.5, Duration: 2 * time.Second},
+	}
+	start := time.Now()
+	completed, err := RunPattern(ctx, steps)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if completed != 1 {
+		t.Fatalf("expected 1 completed step, got %d", completed)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("pattern did not stop on cancel: %v", elapsed)
+	}
+}
@@ -1,8 +1,12 @@
 package vibrator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"periph.io/x/conn/v3/gpio"
@@ -14,9 +18,21 @@ import (
 // Config holds vibrator hardware configuration.
 type Config struct {
 	Enabled bool
-	IN3Pin  string // e.g., "GPIO16"
-	IN4Pin  string // e.g., "GPIO20"
-	ENBPin  string // e.g., "GPIO18" (supports hardware PWM on Raspberry Pi)
+	IN3Pin  string        // e.g., "GPIO16"
+	IN4Pin  string        // e.g., "GPIO20"
+	ENBPin  string        // e.g., "GPIO18" (supports hardware PWM on Raspberry Pi)
+	Usage   UsageRecorder // optional wear tracking of hardware bursts
+
+	// Duty-cycle budget: at most MaxOnTime of buzzing per rolling DutyWindow.
+	// Zero or negative values disable the budget.
+	MaxOnTime  time.Duration
+	DutyWindow time.Duration
+}
+
+// UsageRecorder receives vibrator usage for wear tracking.
+type UsageRecorder interface {
+	// RecordVibration counts one burst with its on-time.
+	RecordVibration(d time.Duration)
 }
 
 type vibrator struct {
@@ -24,9 +40,24 @@ type vibrator struct {
 	in4Pin gpio.PinOut
 	enbPin gpio.PinOut
 	sim    bool
+	usage  UsageRecorder
+	duty   *dutyCycle
 }
 
-var vib *vibrator
+// vib is the initialised vibrator, nil when disabled or cleaned up. Load it once per
+// call so a concurrent Cleanup cannot swap it out halfway.
+var vib atomic.Pointer[vibrator]
+
+// ErrEmergencyStop is returned by Buzz while the emergency stop is latched.
+var ErrEmergencyStop = errors.New("vibrator: emergency stop active")
+
+// Emergency stop latch. abort is closed when the stop trips so running Buzz calls
+// return early; ResetEmergencyStop replaces it.
+var (
+	estopMu sync.Mutex
+	stopped bool
+	abort   = make(chan struct{})
+)
 
 // Init initializes the vibrator GPIO pins. Falls back to simulation mode if GPIO is unavailable.
 func Init(cfg Config) error {
@@ -37,28 +68,28 @@ func Init(cfg Config) error {
 
 	if _, err := host.Init(); err != nil {
 		log.Printf("Warning: GPIO not available for vibrator, running in simulation mode: %v", err)
-		vib = &vibrator{sim: true}
+		vib.Store(&vibrator{sim: true, duty: newDutyCycle(cfg.MaxOnTime, cfg.DutyWindow)})
 		return nil
 	}
 
 	in3 := gpioreg.ByName(cfg.IN3Pin)
 	if in3 == nil {
 		log.Printf("Warning: failed to open vibrator IN3 pin %s, running in simulation mode", cfg.IN3Pin)
-		vib = &vibrator{sim: true}
+		vib.Store(&vibrator{sim: true, duty: newDutyCycle(cfg.MaxOnTime, cfg.DutyWindow)})
 		return nil
 	}
 
 	in4 := gpioreg.ByName(cfg.IN4Pin)
 	if in4 == nil {
 		log.Printf("Warning: failed to open vibrator IN4 pin %s, running in simulation mode", cfg.IN4Pin)
-		vib = &vibrator{sim: true}
+		vib.Store(&vibrator{sim: true, duty: newDutyCycle(cfg.MaxOnTime, cfg.DutyWindow)})
 		return nil
 	}
 
 	enb := gpioreg.ByName(cfg.ENBPin)
 	if enb == nil {
 		log.Printf("Warning: failed to open vibrator ENB pin %s, running in simulation mode", cfg.ENBPin)
-		vib = &vibrator{sim: true}
+		vib.Store(&vibrator{sim: true, duty: newDutyCycle(cfg.MaxOnTime, cfg.DutyWindow)})
 		return nil
 	}
 
@@ -69,28 +100,46 @@ func Init(cfg Config) error {
 		}
 	}
 
-	vib = &vibrator{
+	vib.Store(&vibrator{
 		in3Pin: in3,
 		in4Pin: in4,
 		enbPin: enb,
-	}
+		usage:  cfg.Usage,
+		duty:   newDutyCycle(cfg.MaxOnTime, cfg.DutyWindow),
+	})
 	log.Println("Vibrator initialised successfully")
 	return nil
 }
 
+// IsInitialised reports whether Init set up the vibrator, on GPIO or in simulation mode.
+func IsInitialised() bool { return vib.Load() != nil }
+
+// IsSimulation reports whether the vibrator runs without GPIO.
+func IsSimulation() bool {
+	v := vib.Load()
+	return v != nil && v.sim
+}
+
 // pinToggler is a minimal interface used by softwarePWM.
 type pinToggler interface {
 	Out(l gpio.Level) error
 }
 
 // softwarePWM emulates PWM on a digital output pin by toggling it at ~100 Hz.
-// This is used as a fallback when hardware PWM is unavailable.
-func softwarePWM(pin pinToggler, intensity float64, duration time.Duration) {
+// This is used as a fallback when hardware PWM is unavailable. It returns early once
+// abort is closed; a nil abort channel never fires.
+func softwarePWM(pin pinToggler, intensity float64, duration time.Duration, abort <-chan struct{}) {
 	const periodMs = 10 // 100 Hz
 	highMs := time.Duration(float64(periodMs)*intensity) * time.Millisecond
 	lowMs := time.Duration(float64(periodMs)*(1-intensity)) * time.Millisecond
 	deadline := time.Now().Add(duration)
 	for time.Now().Before(deadline) {
+		select {
+		case <-abort:
+			_ = pin.Out(gpio.Low)
+			return
+		default:
+		}
 		if highMs > 0 {
 			_ = pin.Out(gpio.High)
 			time.Sleep(highMs)
@@ -108,31 +157,70 @@ func softwarePWM(pin pinToggler, intensity float64, duration time.Duration) {
 // Intensity is applied via hardware PWM on the ENB pin; falls back to software PWM if
 // hardware PWM is not supported by the GPIO driver.
 func Buzz(intensity float64, duration time.Duration) error {
+	return buzz(context.Background(), intensity, duration, false)
+}
+
+// BuzzReverse is like Buzz but spins the motor backwards (IN3=LOW, IN4=HIGH).
+// Alternating direction shakes loose balls that a one-way eccentric mass cannot.
+func BuzzReverse(intensity float64, duration time.Duration) error {
+	return buzz(context.Background(), intensity, duration, true)
+}
+
+// buzz runs one burst. It stops early on an emergency stop (ErrEmergencyStop) or when
+// ctx is cancelled (the context's cause).
+func buzz(ctx context.Context, intensity float64, duration time.Duration, reverse bool) error {
+	vib := vib.Load()
 	if vib == nil {
 		return nil
 	}
+	estop, err := abortChannel()
+	if err != nil {
+		return err
+	}
+	abort, release := abortOnDone(ctx, estop)
+	defer release()
 	if intensity < 0 {
 		intensity = 0
 	}
 	if intensity > 1 {
 		intensity = 1
 	}
+	granted, finish, err := vib.duty.reserve(duration)
+	if err != nil {
+		log.Printf("%v, refusing %v burst", err, duration)
+		return err
+	}
+	defer finish()
+	if granted < duration {
+		log.Printf("Vibrator: duty-cycle budget nearly used up, shortening burst from %v to %v", duration, granted)
+		duration = granted
+	}
+	direction := "forward"
+	if reverse {
+		direction = "reverse"
+	}
 
 	if vib.sim {
-		log.Printf("Vibrator (SIMULATION): buzzing at %.0f%% for %v", intensity*100, duration)
-		time.Sleep(duration)
+		log.Printf("Vibrator (SIMULATION): buzzing %s at %.0f%% for %v", direction, intensity*100, duration)
+		if sleepOrAbort(duration, abort) {
+			return abortError(ctx)
+		}
 		return nil
 	}
 
-	// Set forward direction: IN3=HIGH, IN4=LOW
-	if err := vib.in3Pin.Out(gpio.High); err != nil {
-		return fmt.Errorf("vibrator: failed to set IN3 high: %w", err)
+	started := time.Now()
+	defer func() { vib.recordVibration(time.Since(started)) }()
+
+	// Forward: IN3=HIGH, IN4=LOW. Reverse: IN3=LOW, IN4=HIGH.
+	drive, drivePin, idle, idlePin := vib.in3Pin, "IN3", vib.in4Pin, "IN4"
+	if reverse {
+		drive, drivePin, idle, idlePin = vib.in4Pin, "IN4", vib.in3Pin, "IN3"
 	}
-	if err := vib.in4Pin.Out(gpio.Low); err != nil {
-		if stopErr := vib.in3Pin.Out(gpio.Low); stopErr != nil {
-			log.Printf("vibrator: failed to reset IN3 after IN4 error: %v", stopErr)
-		}
-		return fmt.Errorf("vibrator: failed to set IN4 low: %w", err)
+	if err := idle.Out(gpio.Low); err != nil {
+		return fmt.Errorf("vibrator: failed to set %s low: %w", idlePin, err)
+	}
+	if err := drive.Out(gpio.High); err != nil {
+		return fmt.Errorf("vibrator: failed to set %s high: %w", drivePin, err)
 	}
 
 	// Attempt hardware PWM on ENB pin; fall back to software PWM if unsupported.
@@ -141,30 +229,137 @@ func Buzz(intensity float64, duration time.Duration) error {
 		log.Printf("Vibrator: hardware PWM unavailable on ENB pin (using software PWM): %v", err)
 		// Software PWM: toggle ENB at ~100 Hz with correct duty cycle to honour intensity.
 		// This mirrors the Python gpiozero PWMOutputDevice approach.
-		softwarePWM(vib.enbPin, intensity, duration)
-		if stopErr := vib.in3Pin.Out(gpio.Low); stopErr != nil {
-			log.Printf("vibrator: failed to set IN3 low on stop: %v", stopErr)
+		softwarePWM(vib.enbPin, intensity, duration, abort)
+		if stopErr := drive.Out(gpio.Low); stopErr != nil {
+			log.Printf("vibrator: failed to set %s low on stop: %v", drivePin, stopErr)
+		}
+		select {
+		case <-abort:
+			return abortError(ctx)
+		default:
 		}
-		log.Printf("Vibrator: buzzed at %.0f%% for %v (software PWM)", intensity*100, duration)
+		log.Printf("Vibrator: buzzed %s at %.0f%% for %v (software PWM)", direction, intensity*100, duration)
 		return nil
 	}
 
-	time.Sleep(duration)
+	aborted := sleepOrAbort(duration, abort)
 
 	// Stop: all pins LOW
-	if err := vib.in3Pin.Out(gpio.Low); err != nil {
-		log.Printf("vibrator: failed to set IN3 low on stop: %v", err)
+	if err := drive.Out(gpio.Low); err != nil {
+		log.Printf("vibrator: failed to set %s low on stop: %v", drivePin, err)
 	}
 	if err := vib.enbPin.Out(gpio.Low); err != nil {
 		log.Printf("vibrator: failed to set ENB low on stop: %v", err)
 	}
 
-	log.Printf("Vibrator: buzzed at %.0f%% for %v (hardware PWM)", intensity*100, duration)
+	if aborted {
+		return abortError(ctx)
+	}
+	log.Printf("Vibrator: buzzed %s at %.0f%% for %v (hardware PWM)", direction, intensity*100, duration)
 	return nil
 }
 
+func (v *vibrator) recordVibration(d time.Duration) {
+	if v == nil || v.usage == nil {
+		return
+	}
+	v.usage.RecordVibration(d)
+}
+
+// EmergencyStop drives IN3, IN4 and ENB low immediately, aborts a running Buzz and
+// makes Buzz fail with ErrEmergencyStop until ResetEmergencyStop is called.
+func EmergencyStop() {
+	estopMu.Lock()
+	if !stopped {
+		stopped = true
+		close(abort)
+	}
+	estopMu.Unlock()
+
+	vib := vib.Load()
+	if vib == nil {
+		return
+	}
+	if vib.sim {
+		log.Println("Vibrator (SIMULATION): EMERGENCY STOP")
+		return
+	}
+	for _, pin := range []gpio.PinOut{vib.enbPin, vib.in3Pin, vib.in4Pin} {
+		if err := pin.Out(gpio.Low); err != nil {
+			log.Printf("vibrator: emergency stop pin.Out error: %v", err)
+		}
+	}
+	log.Println("Vibrator: EMERGENCY STOP - motor power cut")
+}
+
+// ResetEmergencyStop allows Buzz again.
+func ResetEmergencyStop() {
+	estopMu.Lock()
+	defer estopMu.Unlock()
+	if stopped {
+		stopped = false
+		abort = make(chan struct{})
+	}
+}
+
+// abortChannel returns the channel closed by the next emergency stop, or
+// ErrEmergencyStop while the stop is latched.
+func abortChannel() (<-chan struct{}, error) {
+	estopMu.Lock()
+	defer estopMu.Unlock()
+	if stopped {
+		return nil, ErrEmergencyStop
+	}
+	return abort, nil
+}
+
+// abortOnDone returns a channel that is closed when estop is closed or ctx is done.
+// The returned release func must be called once the caller stops waiting.
+func abortOnDone(ctx context.Context, estop <-chan struct{}) (<-chan struct{}, func()) {
+	if ctx.Done() == nil {
+		return estop, func() {}
+	}
+	merged := make(chan struct{})
+	released := make(chan struct{})
+	go func() {
+		select {
+		case <-estop:
+			close(merged)
+		case <-ctx.Done():
+			close(merged)
+		case <-released:
+		}
+	}()
+	return merged, func() { close(released) }
+}
+
+// abortError reports why a burst was aborted: the emergency stop takes precedence
+// over a cancelled context.
+func abortError(ctx context.Context) error {
+	if _, err := abortChannel(); err != nil {
+		return err
+	}
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	return ErrEmergencyStop
+}
+
+// sleepOrAbort waits for d or until abort is closed and reports whether it was aborted.
+func sleepOrAbort(d time.Duration, abort <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return false
+	case <-abort:
+		return true
+	}
+}
+
 // Cleanup safely stops the vibrator and releases GPIO resources.
 func Cleanup() {
+	vib := vib.Swap(nil)
 	if vib == nil {
 		return
 	}
@@ -182,6 +377,5 @@ func Cleanup() {
 			}
 		}
 	}
-	vib = nil
 	log.Println("Vibrator cleaned up")
 }
@@ -1,31 +1,33 @@
 package vibrator
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpiotest"
 )
 
 // ensure Init is a no-op when disabled and does not set the global vib
 func TestInitDisabledLeavesVibNil(t *testing.T) {
-	prev := vib
-	vib = nil
-	defer func() { vib = prev }()
+	prev := vib.Load()
+	vib.Store(nil)
+	defer vib.Store(prev)
 
 	if err := Init(Config{Enabled: false}); err != nil {
 		t.Fatalf("Init returned error for disabled config: %v", err)
 	}
-	if vib != nil {
+	if vib.Load() != nil {
 		t.Fatalf("vib should remain nil when disabled")
 	}
 }
 
 // ensure Buzz with nil vib is a safe no-op
 func TestBuzzNilSafe(t *testing.T) {
-	prev := vib
-	vib = nil
-	defer func() { vib = prev }()
+	prev := vib.Load()
+	vib.Store(nil)
+	defer vib.Store(prev)
 
 	if err := Buzz(0.5, 10*time.Millisecond); err != nil {
 		t.Fatalf("Buzz with nil vib returned error: %v", err)
@@ -34,18 +36,18 @@ func TestBuzzNilSafe(t *testing.T) {
 
 // ensure Cleanup tolerates nil vib without panicking
 func TestCleanupNilSafe(t *testing.T) {
-	prev := vib
-	vib = nil
-	defer func() { vib = prev }()
+	prev := vib.Load()
+	vib.Store(nil)
+	defer vib.Store(prev)
 
 	Cleanup() // must not panic
 }
 
 // validate Buzz sleeps approximately the given duration in simulation mode
 func TestBuzzSimTiming(t *testing.T) {
-	prev := vib
-	vib = &vibrator{sim: true}
-	defer func() { vib = prev }()
+	prev := vib.Load()
+	vib.Store(&vibrator{sim: true})
+	defer vib.Store(prev)
 
 	buzz := 50 * time.Millisecond
 	start := time.Now()
@@ -96,7 +98,7 @@ func TestSoftwarePWMDutyCycle(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			pin := &stubPin{}
-			softwarePWM(pin, tt.intensity, 100*time.Millisecond)
+			softwarePWM(pin, tt.intensity, 100*time.Millisecond, nil)
 
 			if len(pin.states) < 2 {
 				t.Fatal("not enough pin transitions recorded")
@@ -128,7 +130,7 @@ func TestSoftwarePWMDutyCycle(t *testing.T) {
 // TestSoftwarePWMEndsLow verifies the pin is driven LOW at the end of softwarePWM.
 func TestSoftwarePWMEndsLow(t *testing.T) {
 	pin := &stubPin{}
-	softwarePWM(pin, 0.5, 50*time.Millisecond)
+	softwarePWM(pin, 0.5, 50*time.Millisecond, nil)
 	if len(pin.states) == 0 {
 		t.Fatal("no pin transitions recorded")
 	}
@@ -137,3 +139,97 @@ func TestSoftwarePWMEndsLow(t *testing.T) {
 		t.Errorf("expected pin to end LOW, got %v", last)
 	}
 }
+
+// ensure EmergencyStop aborts a running simulated Buzz and latches until reset
+func TestEmergencyStopAbortsBuzzAndLatches(t *testing.T) {
+	prev := vib.Load()
+	vib.Store(&vibrator{sim: true})
+	defer vib.Store(prev)
+	defer ResetEmergencyStop()
+
+	time.AfterFunc(20*time.Millisecond, EmergencyStop)
+	start := time.Now()
+	if err := Buzz(0.5, 2*time.Second); !errors.Is(err, ErrEmergencyStop) {
+		t.Fatalf("expected ErrEmergencyStop, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Buzz did not stop on emergency stop: %v", elapsed)
+	}
+	if err := Buzz(0.5, 10*time.Millisecond); !errors.Is(err, ErrEmergencyStop) {
+		t.Fatalf("expected latched emergency stop to refuse Buzz, got %v", err)
+	}
+
+	ResetEmergencyStop()
+	if err := Buzz(0.5, 10*time.Millisecond); err != nil {
+		t.Fatalf("Buzz after reset returned error: %v", err)
+	}
+}
+
+// ensure softwarePWM stops toggling and ends LOW once aborted
+func TestSoftwarePWMAbort(t *testing.T) {
+	pin := &stubPin{}
+	stop := make(chan struct{})
+	time.AfterFunc(20*time.Millisecond, func() { close(stop) })
+
+	start := time.Now()
+	softwarePWM(pin, 0.5, 2*time.Second, stop)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("softwarePWM did not stop on abort: %v", elapsed)
+	}
+	if last := pin.states[len(pin.states)-1].level; last != gpio.Low {
+		t.Errorf("expected pin to end LOW, got %v", last)
+	}
+}
+
+type usageCounter struct {
+	bursts int
+	on     time.Duration
+}
+
+func (u *usageCounter) RecordVibration(d time.Duration) {
+	u.bursts++
+	u.on += d
+}
+
+// ensure hardware bursts are reported for wear tracking
+func TestBuzzRecordsUsage(t *testing.T) {
+	prev := vib.Load()
+	usage := &usageCounter{}
+	vib.Store(&vibrator{in3Pin: &gpiotest.Pin{N: "IN3"}, in4Pin: &gpiotest.Pin{N: "IN4"}, enbPin: &gpiotest.Pin{N: "ENB"}, usage: usage})
+	defer vib.Store(prev)
+
+	if err := Buzz(0.5, 30*time.Millisecond); err != nil {
+		t.Fatalf("Buzz returned error: %v", err)
+	}
+	if usage.bursts != 1 || usage.on < 30*time.Millisecond {
+		t.Fatalf("unexpected recorded usage: %+v", usage)
+	}
+}
+
+type levelLog struct {
+	gpiotest.Pin
+	levels []gpio.Level
+}
+
+func (p *levelLog) Out(l gpio.Level) error {
+	p.levels = append(p.levels, l)
+	return p.Pin.Out(l)
+}
+
+func TestBuzzReverseDrivesIN4(t *testing.T) {
+	prev := vib.Load()
+	in3 := &levelLog{Pin: gpiotest.Pin{N: "IN3"}}
+	in4 := &levelLog{Pin: gpiotest.Pin{N: "IN4"}}
+	vib.Store(&vibrator{in3Pin: in3, in4Pin: in4, enbPin: &gpiotest.Pin{N: "ENB"}})
+	defer vib.Store(prev)
+
+	if err := BuzzReverse(0.5, 10*time.Millisecond); err != nil {
+		t.Fatalf("BuzzReverse returned error: %v", err)
+	}
+	if len(in3.levels) == 0 || in3.levels[0] != gpio.Low {
+		t.Fatalf("expected IN3 held low, got %v", in3.levels)
+	}
+	if len(in4.levels) != 2 || in4.levels[0] != gpio.High || in4.levels[1] != gpio.Low {
+		t.Fatalf("expected IN4 high then low, got %v", in4.levels)
+	}
+}
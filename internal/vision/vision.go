@@ -0,0 +1,284 @@
+// Package vision estimates the funnel fill level and votes on jams by comparing a
+// region of interest of a camera frame against reference frames captured during
+// calibration. It only uses the standard library image packages.
+package vision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the JPEG decoder for camera frames
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jsalamander/baendaeli-client/internal/atomicfile"
+)
+
+// Reference frame names.
+const (
+	ReferenceEmpty  = "empty"
+	ReferenceFull   = "full"
+	ReferenceJammed = "jammed"
+)
+
+// References lists the reference frames in calibration order.
+var References = []string{ReferenceEmpty, ReferenceFull, ReferenceJammed}
+
+// ErrNotCalibrated is returned by Analyze until at least two reference frames exist.
+var ErrNotCalibrated = errors.New("vision: not calibrated (need at least two reference frames)")
+
+// Frames are reduced to a grid of gridW x gridH luminance cells before comparing, which
+// smooths out sensor noise and makes the comparison independent of the resolution.
+const (
+	gridW = 32
+	gridH = 24
+)
+
+// ROI is the region of interest around the funnel, in pixels of the camera frame.
+// A zero ROI uses the whole frame.
+type ROI struct {
+	X, Y, W, H int
+}
+
+// ParseROI parses "x,y,w,h". An empty string selects the whole frame.
+func ParseROI(s string) (ROI, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ROI{}, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return ROI{}, fmt.Errorf("vision: ROI must be \"x,y,w,h\", got %q", s)
+	}
+	var v [4]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return ROI{}, fmt.Errorf("vision: invalid ROI value %q in %q", part, s)
+		}
+		v[i] = n
+	}
+	if v[2] == 0 || v[3] == 0 {
+		return ROI{}, fmt.Errorf("vision: ROI width and height must be positive, got %q", s)
+	}
+	return ROI{X: v[0], Y: v[1], W: v[2], H: v[3]}, nil
+}
+
+// Result is one analysed frame.
+type Result struct {
+	// FillLevel is 0 for a frame like the empty reference and 1 for one like the full
+	// reference. It is -1 without both references.
+	FillLevel float64 `json:"fill_level"`
+	// BallEstimate is FillLevel scaled by the funnel capacity, or -1 when unknown.
+	BallEstimate int `json:"ball_estimate"`
+	// Nearest is the reference frame most similar to the analysed frame.
+	Nearest string `json:"nearest"`
+	// JamVote is true when the jammed reference is the nearest one.
+	JamVote bool `json:"jam_vote"`
+	// Confidence is how much closer the nearest reference is than the runner-up (0-1).
+	Confidence float64 `json:"confidence"`
+	// Distances is the mean absolute luminance difference to each reference (0-255).
+	Distances map[string]float64 `json:"distances"`
+}
+
+type frame [gridW * gridH]float64
+
+// Analyzer holds the reference frames. Reference frames are stored as JPEG files named
+// after the reference in dir, so calibration survives restarts.
+type Analyzer struct {
+	mu       sync.Mutex
+	dir      string
+	roi      ROI
+	capacity int
+	refs     map[string]*frame
+}
+
+// Open creates an analyzer and loads the reference frames found in dir. Missing
+// references are fine; the funnel can be calibrated later.
+func Open(dir string, roi ROI, capacity int) (*Analyzer, error) {
+	a := &Analyzer{dir: dir, roi: roi, capacity: capacity, refs: map[string]*frame{}}
+	for _, name := range References {
+		data, err := os.ReadFile(a.referencePath(name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vision reference %s: %w", name, err)
+		}
+		f, err := a.reduce(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vision reference %s: %w", name, err)
+		}
+		a.refs[name] = f
+	}
+	log.Printf("Vision: loaded references %v from %s", a.Calibrated(), dir)
+	return a, nil
+}
+
+// Calibrated returns the names of the available reference frames.
+func (a *Analyzer) Calibrated() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var names []string
+	for _, name := range References {
+		if a.refs[name] != nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Calibrate stores jpeg as the named reference frame.
+func (a *Analyzer) Calibrate(name string, jpeg []byte) error {
+	if !isReference(name) {
+		return fmt.Errorf("vision: unknown reference %q (want one of %v)", name, References)
+	}
+	f, err := a.reduce(jpeg)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.saveLocked(name, jpeg); err != nil {
+		return err
+	}
+	a.refs[name] = f
+	return nil
+}
+
+// Analyze compares jpeg against the reference frames.
+func (a *Analyzer) Analyze(jpeg []byte) (Result, error) {
+	f, err := a.reduce(jpeg)
+	if err != nil {
+		return Result{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.refs) < 2 {
+		return Result{}, ErrNotCalibrated
+	}
+
+	result := Result{FillLevel: -1, BallEstimate: -1, Distances: map[string]float64{}}
+	best, second := math.Inf(1), math.Inf(1)
+	for _, name := range References {
+		ref := a.refs[name]
+		if ref == nil {
+			continue
+		}
+		d := distance(f, ref)
+		result.Distances[name] = d
+		if d < best {
+			best, second = d, best
+			result.Nearest = name
+		} else if d < second {
+			second = d
+		}
+	}
+	result.JamVote = result.Nearest == ReferenceJammed
+	if second > 0 {
+		result.Confidence = (second - best) / second
+	}
+
+	empty, hasEmpty := result.Distances[ReferenceEmpty]
+	full, hasFull := result.Distances[ReferenceFull]
+	if hasEmpty && hasFull {
+		if empty+full == 0 {
+			result.FillLevel = 0
+		} else {
+			result.FillLevel = empty / (empty + full)
+		}
+		if a.capacity > 0 {
+			result.BallEstimate = int(math.Round(result.FillLevel * float64(a.capacity)))
+		}
+	}
+	return result, nil
+}
+
+// reduce decodes a JPEG, crops it to the ROI and averages it into the luminance grid.
+// The grid is normalised to zero mean so a change in ambient light does not look like
+// a change in the funnel.
+func (a *Analyzer) reduce(data []byte) (*frame, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("vision: failed to decode frame: %w", err)
+	}
+	bounds := img.Bounds()
+	if a.roi != (ROI{}) {
+		roi := image.Rect(a.roi.X, a.roi.Y, a.roi.X+a.roi.W, a.roi.Y+a.roi.H).Add(bounds.Min)
+		if !roi.In(bounds) {
+			return nil, fmt.Errorf("vision: ROI %v outside the %dx%d frame", a.roi, bounds.Dx(), bounds.Dy())
+		}
+		bounds = roi
+	}
+
+	var f frame
+	var mean float64
+	for gy := 0; gy < gridH; gy++ {
+		y0 := bounds.Min.Y + gy*bounds.Dy()/gridH
+		y1 := max(bounds.Min.Y+(gy+1)*bounds.Dy()/gridH, y0+1)
+		for gx := 0; gx < gridW; gx++ {
+			x0 := bounds.Min.X + gx*bounds.Dx()/gridW
+			x1 := max(bounds.Min.X+(gx+1)*bounds.Dx()/gridW, x0+1)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += luminance(img, x, y)
+				}
+			}
+			cell := sum / float64((x1-x0)*(y1-y0))
+			f[gy*gridW+gx] = cell
+			mean += cell
+		}
+	}
+	mean /= float64(len(f))
+	for i := range f {
+		f[i] -= mean
+	}
+	return &f, nil
+}
+
+// luminance returns the Rec. 601 luma of a pixel on a 0-255 scale.
+func luminance(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
+
+func distance(a, b *frame) float64 {
+	var sum float64
+	for i := range a {
+		sum += math.Abs(a[i] - b[i])
+	}
+	return sum / float64(len(a))
+}
+
+func isReference(name string) bool {
+	for _, ref := range References {
+		if ref == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Analyzer) referencePath(name string) string {
+	return filepath.Join(a.dir, name+".jpg")
+}
+
+// saveLocked writes the reference with atomicfile.Write, creating the directory first.
+func (a *Analyzer) saveLocked(name string, jpeg []byte) error {
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to save vision reference: %w", err)
+	}
+	if err := atomicfile.Write(a.referencePath(name), jpeg); err != nil {
+		return fmt.Errorf("failed to save vision reference: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package vision
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testFrame draws a funnel between dark walls, filled with bright balls up to fill (0-1)
+// from the bottom, and optionally a ball stuck in the neck, brightened by light.
+func testFrame(t *testing.T, fill float64, stuck bool, light uint8) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 160; x++ {
+			v := uint8(40)
+			if x >= 40 && x < 120 {
+				v = 90
+				if float64(120-y) <= fill*120 {
+					v = 200
+				}
+			}
+			if stuck && x >= 70 && x < 90 && y >= 100 {
+				v = 250
+			}
+			if int(v)+int(light) > 255 {
+				v = 255
+			} else {
+				v += light
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// ensure frames are matched to the nearest reference and the fill level is estimated
+func TestAnalyzeFillLevelAndJamVote(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(dir, ROI{}, 20)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if _, err := a.Analyze(testFrame(t, 0, false, 0)); !errors.Is(err, ErrNotCalibrated) {
+		t.Fatalf("expected ErrNotCalibrated, got %v", err)
+	}
+
+	for name, frame := range map[string][]byte{
+		ReferenceEmpty:  testFrame(t, 0, false, 0),
+		ReferenceFull:   testFrame(t, 1, false, 0),
+		ReferenceJammed: testFrame(t, 0, true, 0),
+	} {
+		if err := a.Calibrate(name, frame); err != nil {
+			t.Fatalf("Calibrate(%s) returned error: %v", name, err)
+		}
+	}
+
+	// Brighter light must not matter: frames are compared after removing the mean.
+	result, err := a.Analyze(testFrame(t, 0.75, false, 30))
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if result.Nearest != ReferenceFull || result.JamVote {
+		t.Fatalf("expected a mostly full funnel without jam, got %+v", result)
+	}
+	if result.FillLevel < 0.55 || result.FillLevel > 0.95 || result.BallEstimate < 11 || result.BallEstimate > 19 {
+		t.Fatalf("unexpected fill estimate %+v", result)
+	}
+
+	result, err = a.Analyze(testFrame(t, 0, true, 0))
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if !result.JamVote || result.Confidence <= 0.5 {
+		t.Fatalf("expected a confident jam vote, got %+v", result)
+	}
+
+	// References survive a restart.
+	reopened, err := Open(dir, ROI{}, 20)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if got := reopened.Calibrated(); len(got) != 3 {
+		t.Fatalf("expected 3 persisted references, got %v", got)
+	}
+}
+
+// ensure the ROI restricts the comparison to the funnel
+func TestAnalyzeUsesROI(t *testing.T) {
+	a, err := Open(t.TempDir(), ROI{X: 0, Y: 0, W: 160, H: 60}, 0)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	// Both references only differ below the ROI, so they look the same inside it.
+	if err := a.Calibrate(ReferenceEmpty, testFrame(t, 0, false, 0)); err != nil {
+		t.Fatalf("Calibrate returned error: %v", err)
+	}
+	if err := a.Calibrate(ReferenceJammed, testFrame(t, 0, true, 0)); err != nil {
+		t.Fatalf("Calibrate returned error: %v", err)
+	}
+	result, err := a.Analyze(testFrame(t, 0, true, 0))
+	if err != nil {
+		t.Fatalf("Analyze returned error: %v", err)
+	}
+	if result.Confidence > 0.1 || result.FillLevel != -1 || result.BallEstimate != -1 {
+		t.Fatalf("expected an undecided result without fill level, got %+v", result)
+	}
+
+	outside, _ := Open(t.TempDir(), ROI{X: 100, Y: 100, W: 100, H: 100}, 0)
+	if err := outside.Calibrate(ReferenceEmpty, testFrame(t, 0, false, 0)); err == nil {
+		t.Fatal("expected an error for a ROI outside the frame")
+	}
+}
+
+func TestParseROI(t *testing.T) {
+	if roi, err := ParseROI(" 10, 20,300,200 "); err != nil || roi != (ROI{X: 10, Y: 20, W: 300, H: 200}) {
+		t.Fatalf("unexpected ROI %+v (err=%v)", roi, err)
+	}
+	if roi, err := ParseROI(""); err != nil || roi != (ROI{}) {
+		t.Fatalf("expected full-frame ROI, got %+v (err=%v)", roi, err)
+	}
+	for _, bad := range []string{"1,2,3", "a,b,c,d", "0,0,0,10", "-1,0,10,10"} {
+		if _, err := ParseROI(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package wear
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jsalamander/baendaeli-client/internal/atomicfile"
+)
+
+// Maintenance reasons reported by MaintenanceDue.
+const (
+	ReasonActuatorCycles  = "actuator_cycles"
+	ReasonActuatorMotorOn = "actuator_motor_on"
+	ReasonVibratorOn      = "vibrator_on"
+)
+
+// Components accepted by Reset.
+const (
+	ComponentActuator = "actuator"
+	ComponentVibrator = "vibrator"
+)
+
+// Counters holds usage of the actuator and vibrator motors.
+type Counters struct {
+	ActuatorCycles         int64   `json:"actuator_cycles"`
+	ActuatorExtendSeconds  float64 `json:"actuator_extend_seconds"`
+	ActuatorRetractSeconds float64 `json:"actuator_retract_seconds"`
+	ActuatorHomings        int64   `json:"actuator_homings"`
+	VibratorOnSeconds      float64 `json:"vibrator_on_seconds"`
+	VibratorBursts         int64   `json:"vibrator_bursts"`
+}
+
+// Thresholds raise a maintenance flag once the usage since the last maintenance
+// reaches them. Zero or negative values disable a threshold.
+type Thresholds struct {
+	ActuatorCycles         int64
+	ActuatorMotorOnSeconds float64
+	VibratorOnSeconds      float64
+}
+
+// Report is the persisted counter state plus the derived maintenance flags.
+type Report struct {
+	Total                Counters   `json:"total"`
+	SinceMaintenance     Counters   `json:"since_maintenance"`
+	ActuatorMaintainedAt *time.Time `json:"actuator_maintained_at,omitempty"`
+	VibratorMaintainedAt *time.Time `json:"vibrator_maintained_at,omitempty"`
+	MaintenanceDue       []string   `json:"maintenance_due,omitempty"`
+}
+
+// flushInterval is how long updates stay in memory before they are written. Every
+// movement and vibrator burst is an update; batching them spares the SD card.
+var flushInterval = time.Minute
+
+// Tracker accumulates wear counters in memory and persists them to a JSON file at
+// most once per flushInterval, on Reset and on Close, so they survive restarts. A nil
+// *Tracker ignores all updates.
+type Tracker struct {
+	mu         sync.Mutex
+	path       string
+	thresholds Thresholds
+	report     Report
+	flushTimer *time.Timer // pending write of unsaved updates, nil when saved
+}
+
+// Open loads the counters stored at path, starting from zero when the file does not
+// exist yet.
+func Open(path string, thresholds Thresholds) (*Tracker, error) {
+	t := &Tracker{path: path, thresholds: thresholds}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Wear counters: %s not found, starting from zero", path)
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wear counters: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.report); err != nil {
+		return nil, fmt.Errorf("failed to parse wear counters %s: %w", path, err)
+	}
+	t.report.MaintenanceDue = nil
+
+	log.Printf("Wear counters: loaded from %s (actuator_cycles=%d vibrator_bursts=%d)", path, t.report.Total.ActuatorCycles, t.report.Total.VibratorBursts)
+	return t, nil
+}
+
+// RecordMotorOn adds actuator motor-on time in the given direction ("extend" or
+// "retract"; homing runs count as retract).
+func (t *Tracker) RecordMotorOn(direction string, d time.Duration) {
+	t.update(func(c *Counters) {
+		if direction == "extend" {
+			c.ActuatorExtendSeconds += d.Seconds()
+		} else {
+			c.ActuatorRetractSeconds += d.Seconds()
+		}
+	})
+}
+
+// RecordCycle counts one completed extend-retract cycle.
+func (t *Tracker) RecordCycle() {
+	t.update(func(c *Counters) { c.ActuatorCycles++ })
+}
+
+// RecordHoming counts one homing run.
+func (t *Tracker) RecordHoming() {
+	t.update(func(c *Counters) { c.ActuatorHomings++ })
+}
+
+// RecordVibration counts one vibrator burst with its on-time.
+func (t *Tracker) RecordVibration(d time.Duration) {
+	t.update(func(c *Counters) {
+		c.VibratorBursts++
+		c.VibratorOnSeconds += d.Seconds()
+	})
+}
+
+// Report returns a copy of the counters and the maintenance reasons that are due.
+func (t *Tracker) Report() Report {
+	if t == nil {
+		return Report{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := t.report
+	report.MaintenanceDue = t.dueLocked()
+	return report
+}
+
+// MaintenanceDue returns the reasons whose threshold has been reached since the last
+// maintenance, or nil when none is due.
+func (t *Tracker) MaintenanceDue() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dueLocked()
+}
+
+// Reset clears the since-maintenance counters of component ("actuator", "vibrator",
+// or "" for both) after a part was serviced or replaced. Totals are kept.
+func (t *Tracker) Reset(component string) error {
+	if t == nil {
+		return errors.New("wear tracking unavailable")
+	}
+	if component != "" && component != ComponentActuator && component != ComponentVibrator {
+		return fmt.Errorf("unknown maintenance component %q (want %q, %q or empty)", component, ComponentActuator, ComponentVibrator)
+	}
+
+	t.mu.Lock()
+	t.stopFlushLocked()
+	now := time.Now().UTC()
+	since := &t.report.SinceMaintenance
+	if component == "" || component == ComponentActuator {
+		since.ActuatorCycles = 0
+		since.ActuatorExtendSeconds = 0
+		since.ActuatorRetractSeconds = 0
+		since.ActuatorHomings = 0
+		t.report.ActuatorMaintainedAt = &now
+	}
+	if component == "" || component == ComponentVibrator {
+		since.VibratorOnSeconds = 0
+		since.VibratorBursts = 0
+		t.report.VibratorMaintainedAt = &now
+	}
+	err := t.saveLocked()
+	t.mu.Unlock()
+
+	if component == "" {
+		component = "actuator+vibrator"
+	}
+	log.Printf("Wear counters: maintenance reset for %s", component)
+	return err
+}
+
+func (t *Tracker) update(apply func(c *Counters)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wasDue := len(t.dueLocked())
+	apply(&t.report.Total)
+	apply(&t.report.SinceMaintenance)
+	if due := t.dueLocked(); len(due) > wasDue {
+		log.Printf("Wear counters: maintenance due (%v)", due)
+	}
+	if t.flushTimer == nil {
+		t.flushTimer = time.AfterFunc(flushInterval, func() {
+			if err := t.Flush(); err != nil {
+				log.Printf("Wear counters: %v", err)
+			}
+		})
+	}
+}
+
+// Flush writes updates that are not saved yet.
+func (t *Tracker) Flush() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.flushTimer == nil {
+		return nil
+	}
+	t.stopFlushLocked()
+	return t.saveLocked()
+}
+
+// Close writes the unsaved updates; call it on shutdown.
+func (t *Tracker) Close() error {
+	return t.Flush()
+}
+
+func (t *Tracker) stopFlushLocked() {
+	if t.flushTimer != nil {
+		t.flushTimer.Stop()
+		t.flushTimer = nil
+	}
+}
+
+func (t *Tracker) dueLocked() []string {
+	since := t.report.SinceMaintenance
+	var due []string
+	if t.thresholds.ActuatorCycles > 0 && since.ActuatorCycles >= t.thresholds.ActuatorCycles {
+		due = append(due, ReasonActuatorCycles)
+	}
+	if t.thresholds.ActuatorMotorOnSeconds > 0 && since.ActuatorExtendSeconds+since.ActuatorRetractSeconds >= t.thresholds.ActuatorMotorOnSeconds {
+		due = append(due, ReasonActuatorMotorOn)
+	}
+	if t.thresholds.VibratorOnSeconds > 0 && since.VibratorOnSeconds >= t.thresholds.VibratorOnSeconds {
+		due = append(due, ReasonVibratorOn)
+	}
+	return due
+}
+
+// saveLocked writes the counters with atomicfile.Write.
+func (t *Tracker) saveLocked() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode wear counters: %w", err)
+	}
+	if err := atomicfile.Write(t.path, data); err != nil {
+		return fmt.Errorf("failed to save wear counters: %w", err)
+	}
+	return nil
+}
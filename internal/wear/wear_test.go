@@ -0,0 +1,135 @@
+package wear
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCountersPersistAcrossOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wear.json")
+
+	tracker, err := Open(path, Thresholds{})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	tracker.RecordMotorOn("extend", 2*time.Second)
+	tracker.RecordMotorOn("retract", 3*time.Second)
+	tracker.RecordCycle()
+	tracker.RecordHoming()
+	tracker.RecordVibration(400 * time.Millisecond)
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	reopened, err := Open(path, Thresholds{})
+	if err != nil {
+		t.Fatalf("reopen returned error: %v", err)
+	}
+	want := Counters{
+		ActuatorCycles:         1,
+		ActuatorExtendSeconds:  2,
+		ActuatorRetractSeconds: 3,
+		ActuatorHomings:        1,
+		VibratorOnSeconds:      0.4,
+		VibratorBursts:         1,
+	}
+	if got := reopened.Report().Total; got != want {
+		t.Fatalf("unexpected persisted totals: %+v, want %+v", got, want)
+	}
+}
+
+func TestMaintenanceDueAndReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wear.json")
+	tracker, err := Open(path, Thresholds{ActuatorCycles: 2, ActuatorMotorOnSeconds: 5, VibratorOnSeconds: 1})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	tracker.RecordCycle()
+	if due := tracker.MaintenanceDue(); due != nil {
+		t.Fatalf("unexpected maintenance due: %v", due)
+	}
+	tracker.RecordCycle()
+	tracker.RecordMotorOn("retract", 5*time.Second)
+	tracker.RecordVibration(time.Second)
+	want := []string{ReasonActuatorCycles, ReasonActuatorMotorOn, ReasonVibratorOn}
+	if due := tracker.MaintenanceDue(); !reflect.DeepEqual(due, want) {
+		t.Fatalf("expected %v due, got %v", want, due)
+	}
+
+	if err := tracker.Reset(ComponentVibrator); err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+	if due := tracker.MaintenanceDue(); !reflect.DeepEqual(due, want[:2]) {
+		t.Fatalf("expected only actuator reasons after vibrator reset, got %v", due)
+	}
+
+	if err := tracker.Reset(""); err != nil {
+		t.Fatalf("Reset returned error: %v", err)
+	}
+	report := tracker.Report()
+	if report.MaintenanceDue != nil || report.ActuatorMaintainedAt == nil || report.VibratorMaintainedAt == nil {
+		t.Fatalf("expected full reset, got %+v", report)
+	}
+	if report.Total.ActuatorCycles != 2 {
+		t.Fatalf("reset must keep totals, got %+v", report.Total)
+	}
+
+	if err := tracker.Reset("conveyor"); err == nil {
+		t.Fatal("expected error for unknown component")
+	}
+}
+
+// ensure updates are batched in memory and written on the flush timer
+func TestUpdatesFlushOnTimer(t *testing.T) {
+	prev := flushInterval
+	flushInterval = 50 * time.Millisecond
+	defer func() { flushInterval = prev }()
+
+	path := filepath.Join(t.TempDir(), "wear.json")
+	tracker, err := Open(path, Thresholds{})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	tracker.RecordCycle()
+	tracker.RecordVibration(time.Second)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no write before the flush, got %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if reopened, err := Open(path, Thresholds{}); err == nil && reopened.Report().Total.VibratorBursts == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the flush timer to write the counters")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOpenRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wear.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := Open(path, Thresholds{}); err == nil {
+		t.Fatal("expected error for corrupt counters file")
+	}
+}
+
+func TestNilTrackerIsNoop(t *testing.T) {
+	var tracker *Tracker
+	tracker.RecordCycle()
+	tracker.RecordVibration(time.Second)
+	if due := tracker.MaintenanceDue(); due != nil {
+		t.Fatalf("unexpected due from nil tracker: %v", due)
+	}
+	if err := tracker.Reset(""); err == nil {
+		t.Fatal("expected error resetting nil tracker")
+	}
+}
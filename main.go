@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -18,11 +20,20 @@ import (
 	"github.com/jsalamander/baendaeli-client/internal/camera"
 	"github.com/jsalamander/baendaeli-client/internal/colorsensor"
 	"github.com/jsalamander/baendaeli-client/internal/config"
+	"github.com/jsalamander/baendaeli-client/internal/currentsensor"
 	"github.com/jsalamander/baendaeli-client/internal/device"
+	"github.com/jsalamander/baendaeli-client/internal/estop"
+	"github.com/jsalamander/baendaeli-client/internal/jamclear"
+	"github.com/jsalamander/baendaeli-client/internal/sdnotify"
 	"github.com/jsalamander/baendaeli-client/internal/server"
 	"github.com/jsalamander/baendaeli-client/internal/vibrator"
+	"github.com/jsalamander/baendaeli-client/internal/vision"
+	"github.com/jsalamander/baendaeli-client/internal/wear"
 )
 
+// httpShutdownTimeout bounds how long shutdown waits for in-flight HTTP requests.
+const httpShutdownTimeout = 10 * time.Second
+
 func main() {
 	// Check for subcommands
 	if len(os.Args) > 1 {
@@ -67,21 +78,39 @@ func main() {
 	// Check camera tool availability at startup regardless of config
 	camera.CheckTools()
 
-	// Initialize actuator if enabled
-	if cfg.ActuatorEnabled {
-		actuatorCfg := actuator.Config{
-			Enabled:      cfg.ActuatorEnabled,
-			ENAPin:       cfg.ActuatorENAPin,
-			IN1Pin:       cfg.ActuatorIN1Pin,
-			IN2Pin:       cfg.ActuatorIN2Pin,
-			MovementTime: cfg.ActuatorMovement,
-			PauseTime:    cfg.ActuatorPause,
+	// Load persistent wear counters for actuator and vibrator
+	wearTracker, err := wear.Open(cfg.WearCountersFile, wear.Thresholds{
+		ActuatorCycles:         int64(cfg.MaintenanceActuatorCycles),
+		ActuatorMotorOnSeconds: float64(cfg.MaintenanceActuatorMotorOnSeconds),
+		VibratorOnSeconds:      float64(cfg.MaintenanceVibratorOnSeconds),
+	})
+	if err != nil {
+		log.Printf("Warning: Wear counters unavailable: %v. Continuing without wear tracking.", err)
+	}
+	// Deferred first so it runs last, after the actuator and vibrator recorded their
+	// final usage.
+	defer func() {
+		if err := wearTracker.Close(); err != nil {
+			log.Printf("Warning: failed to save wear counters: %v", err)
 		}
-		if err := actuator.Init(actuatorCfg); err != nil {
-			log.Printf("Warning: Actuator initialization failed: %v. Continuing without actuator.", err)
+	}()
+
+	// Initialize actuator (simulated when disabled or GPIO is unavailable)
+	actuatorCfg := newActuatorConfig(cfg)
+	if wearTracker != nil {
+		actuatorCfg.Usage = wearTracker
+	}
+	if cfg.ActuatorEnabled {
+		if sensor := initCurrentSensor(cfg, &actuatorCfg); sensor != nil {
+			defer sensor.Close()
 		}
-		defer actuator.Cleanup()
 	}
+	act, err := actuator.New(actuatorCfg)
+	if err != nil {
+		log.Printf("Warning: Actuator initialization failed: %v. Continuing with simulated actuator.", err)
+		act = actuator.NewSimulated(actuatorCfg)
+	}
+	defer act.Close()
 
 	// Initialize vibrator if enabled
 	if cfg.VibrationEnabled {
@@ -90,6 +119,12 @@ func main() {
 			IN3Pin:  cfg.VibrationIN3Pin,
 			IN4Pin:  cfg.VibrationIN4Pin,
 			ENBPin:  cfg.VibrationENBPin,
+
+			MaxOnTime:  time.Duration(cfg.VibrationDutyMaxOnSeconds) * time.Second,
+			DutyWindow: time.Duration(cfg.VibrationDutyWindowSeconds) * time.Second,
+		}
+		if wearTracker != nil {
+			vibCfg.Usage = wearTracker
 		}
 		if err := vibrator.Init(vibCfg); err != nil {
 			log.Printf("Warning: Vibrator initialization failed: %v. Continuing without vibrator.", err)
@@ -99,17 +134,67 @@ func main() {
 
 	// Initialize camera if enabled
 	if cfg.CameraEnabled {
-		if err := camera.Init(camera.Config{Enabled: true}); err != nil {
+		camCfg := camera.Config{
+			Enabled:  true,
+			Backend:  cfg.CameraBackend,
+			Device:   cfg.CameraDevice,
+			FilePath: cfg.CameraFilePath,
+		}
+		camCfg.Defaults = camera.Options{
+			Width:           cfg.CameraWidth,
+			Height:          cfg.CameraHeight,
+			Quality:         cfg.CameraQuality,
+			Rotation:        cfg.CameraRotation,
+			HFlip:           camera.Flag(cfg.CameraHFlip),
+			VFlip:           camera.Flag(cfg.CameraVFlip),
+			ShutterUs:       cfg.CameraShutterUs,
+			EV:              cfg.CameraEV,
+			BurstCount:      cfg.CameraBurstCount,
+			BurstIntervalMs: cfg.CameraBurstIntervalMs,
+		}
+		crop, err := camera.ParseCrop(cfg.CameraCrop)
+		if err == nil {
+			camCfg.Defaults.Crop = crop
+			err = camCfg.Defaults.Validate()
+		}
+		if err != nil {
+			log.Printf("Warning: Invalid camera capture options: %v. Using the camera defaults.", err)
+			camCfg.Defaults = camera.Options{}
+		}
+		if err := camera.Init(camCfg); err != nil {
 			log.Printf("Warning: Camera initialization failed: %v. Continuing without camera.", err)
 		}
 		defer camera.Cleanup()
 	}
 
+	// Load jam-clearing statistics so the adaptive strategy keeps learning across restarts
+	jamClearEngine, err := jamclear.Open(cfg.JamClearStatsFile, cfg.JamClearStrategy, jamclear.ParamsFromConfig(cfg))
+	if err != nil {
+		log.Printf("Warning: Jam-clearing engine unavailable: %v. Falling back to the default escalation.", err)
+	}
+
+	// Load the funnel reference frames for the camera's vote in the ball-ready decision
+	var visionAnalyzer *vision.Analyzer
+	if cfg.VisionEnabled && cfg.CameraEnabled {
+		roi, err := vision.ParseROI(cfg.VisionROI)
+		if err == nil {
+			visionAnalyzer, err = vision.Open(cfg.VisionReferenceDir, roi, cfg.VisionFunnelCapacity)
+		}
+		if err != nil {
+			log.Printf("Warning: Vision analysis unavailable: %v. Continuing without it.", err)
+		}
+	}
+
 	// Create server
 	srv := server.New(cfg)
+	srv.SetActuator(act)
 
 	// Create device client and set it on the server
 	deviceClient := device.New(cfg)
+	deviceClient.SetActuator(act)
+	deviceClient.SetWearTracker(wearTracker)
+	deviceClient.SetJamClearEngine(jamClearEngine)
+	deviceClient.SetVisionAnalyzer(visionAnalyzer)
 	originalLogOutput := log.Writer()
 	deviceClient.SetLogShippingDiagnosticsWriter(originalLogOutput)
 	log.SetOutput(io.MultiWriter(originalLogOutput, deviceClient.LogSinkWriter()))
@@ -117,11 +202,45 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Cut motor power as soon as a shutdown signal arrives, even while Start is still
+	// homing the actuator.
+	shutdown := make(chan os.Signal, 1)
+	go func() {
+		sig := <-sigChan
+		deviceClient.EmergencyStop(fmt.Sprintf("signal %v", sig))
+		shutdown <- sig
+	}()
+
+	// Watch the optional physical e-stop button
+	estopButton := estop.New(cfg)
+	if err := estopButton.Init(cfg); err != nil {
+		log.Printf("Warning: E-stop button initialization failed: %v. Continuing without button.", err)
+	}
+	defer estopButton.Close()
+	buttonCtx, stopButtonWatch := context.WithCancel(context.Background())
+	defer stopButtonWatch()
+	go estopButton.Watch(buttonCtx, func() {
+		deviceClient.EmergencyStop("e-stop button")
+	})
+
 	// Start HTTP server in a goroutine
+	httpServer, err := srv.Listen()
+	if err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+	scheme := "http"
+	if httpServer.TLS() {
+		scheme = "https"
+	}
+	for _, addr := range httpServer.Addrs() {
+		if addr.Network() == "unix" {
+			log.Printf("Starting server on unix socket %s", addr.String())
+			continue
+		}
+		log.Printf("Starting server on %s", buildServerURL(scheme, addr.String()))
+	}
 	go func() {
-		addr := "0.0.0.0:8000"
-		log.Printf("Starting server on %s", buildServerURL(addr))
-		if err := http.ListenAndServe(addr, srv.Router()); err != nil && err != http.ErrServerClosed {
+		if err := httpServer.Serve(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
@@ -129,27 +248,95 @@ func main() {
 	// Start device client
 	deviceClient.Start()
 
+	// Report readiness and state to systemd, and feed its watchdog only while the poll
+	// loop keeps making progress.
+	systemdCtx, stopSystemd := context.WithCancel(context.Background())
+	if sdnotify.Enabled() {
+		maxPollAge := time.Duration(cfg.WatchdogMaxPollAgeSeconds) * time.Second
+		go mirrorSystemdStatus(systemdCtx, deviceClient)
+		go sdnotify.RunWatchdog(systemdCtx, func() bool {
+			return time.Since(deviceClient.LastProgress()) < maxPollAge
+		})
+		if _, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+			log.Printf("Warning: systemd notification failed: %v", err)
+		}
+	}
+
 	// Wait for interrupt signal
-	sig := <-sigChan
+	sig := <-shutdown
 	fmt.Printf("\nReceived signal: %v. Shutting down...\n", sig)
+	stopSystemd()
+	sdnotify.Notify(sdnotify.Stopping)
+
+	// The emergency stop has already aborted running movements. Let in-flight requests
+	// answer before the device client and the hardware go away.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: HTTP server shutdown: %v", err)
+	}
+	cancelShutdown()
 
 	// Stop device client gracefully
 	deviceClient.Stop()
 }
 
-func buildServerURL(addr string) string {
+// mirrorSystemdStatus sends the runtime state and message to systemd as STATUS= lines
+// until ctx is done.
+func mirrorSystemdStatus(ctx context.Context, deviceClient *device.Client) {
+	lastStatus := ""
+	send := func(event device.StateEvent) {
+		var snapshot device.StateSnapshot
+		if err := json.Unmarshal(event.Data, &snapshot); err != nil {
+			return
+		}
+		status := snapshot.State
+		if snapshot.Message != "" {
+			status += ": " + snapshot.Message
+		}
+		if status == lastStatus {
+			return
+		}
+		lastStatus = status
+		if _, err := sdnotify.Notify(sdnotify.Status(status)); err != nil {
+			log.Printf("Warning: systemd status notification failed: %v", err)
+		}
+	}
+
+	// A subscriber that falls behind is dropped; subscribe again.
+	for ctx.Err() == nil {
+		replay, events, cancel := deviceClient.SubscribeState(0)
+		for _, event := range replay {
+			send(event)
+		}
+		for subscribed := true; subscribed; {
+			select {
+			case <-ctx.Done():
+				subscribed = false
+			case event, ok := <-events:
+				if !ok {
+					subscribed = false
+					break
+				}
+				send(event)
+			}
+		}
+		cancel()
+	}
+}
+
+func buildServerURL(scheme, addr string) string {
 	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
 		if strings.HasPrefix(addr, ":") {
 			port = strings.TrimPrefix(addr, ":")
 			host = ""
 		} else {
-			return "http://" + addr
+			return scheme + "://" + addr
 		}
 	}
 
 	if host == "0.0.0.0" || host == "::" {
-		return fmt.Sprintf("http://%s:%s", host, port)
+		return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port))
 	}
 
 	if host == "" {
@@ -160,7 +347,7 @@ func buildServerURL(addr string) string {
 		host = "localhost"
 	}
 
-	return fmt.Sprintf("http://%s:%s", host, port)
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port))
 }
 
 func resolvePrimaryOutboundIP() string {
@@ -295,12 +482,13 @@ func runStateCalibrationCommand() {
 	}
 	defer sensor.Close()
 
-	if err := initActuatorForCommand(); err != nil {
+	act, closeActuator, err := initActuatorForCommand()
+	if err != nil {
 		printStopCommandsIfServerActive()
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer actuator.Cleanup()
+	defer closeActuator()
 
 	reader, cleanup, err := newInteractiveReader()
 	if err != nil {
@@ -343,7 +531,7 @@ func runStateCalibrationCommand() {
 			restartCycle := false
 			for {
 				fmt.Printf("Cycle %d/%d - moving actuator to output the ball...\n", cycle, repeatCount)
-				if _, err := actuator.Trigger(); err != nil {
+				if _, err := act.Trigger(context.Background()); err != nil {
 					fmt.Printf("Error moving actuator: %v\n", err)
 					os.Exit(1)
 				}
@@ -414,14 +602,15 @@ func runExtendCommand() {
 	duration := time.Duration(ms) * time.Millisecond
 	fmt.Printf("Extending actuator for %v (%dms)...\n", duration, ms)
 
-	if err := initActuatorForCommand(); err != nil {
+	act, closeActuator, err := initActuatorForCommand()
+	if err != nil {
 		printStopCommandsIfServerActive()
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer actuator.Cleanup()
+	defer closeActuator()
 
-	if err := actuator.Extend(duration); err != nil {
+	if err := act.Extend(context.Background(), duration); err != nil {
 		printStopCommandsIfServerActive()
 		fmt.Printf("Error extending actuator: %v\n", err)
 		os.Exit(1)
@@ -450,14 +639,15 @@ func runRetractCommand() {
 	duration := time.Duration(ms) * time.Millisecond
 	fmt.Printf("Retracting actuator for %v (%dms)...\n", duration, ms)
 
-	if err := initActuatorForCommand(); err != nil {
+	act, closeActuator, err := initActuatorForCommand()
+	if err != nil {
 		printStopCommandsIfServerActive()
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer actuator.Cleanup()
+	defer closeActuator()
 
-	if err := actuator.Retract(duration); err != nil {
+	if err := act.Retract(context.Background(), duration); err != nil {
 		printStopCommandsIfServerActive()
 		fmt.Printf("Error retracting actuator: %v\n", err)
 		os.Exit(1)
@@ -472,44 +662,94 @@ func runHomeCommand() {
 
 	printStopCommandsIfServerActive()
 
-	if err := initActuatorForCommand(); err != nil {
+	act, closeActuator, err := initActuatorForCommand()
+	if err != nil {
 		printStopCommandsIfServerActive()
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	defer actuator.Cleanup()
+	defer closeActuator()
 
-	actuator.Home()
+	if err := act.Home(context.Background()); err != nil {
+		fmt.Printf("Error homing actuator: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Println("✓ Homing complete")
 }
 
-// initActuatorForCommand initializes the actuator for testing commands
-func initActuatorForCommand() error {
+// initActuatorForCommand initializes the actuator for testing commands. The returned
+// func closes the actuator and its current sensor.
+func initActuatorForCommand() (actuator.Actuator, func(), error) {
 	cfg, err := config.Load("config.yaml")
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	if !cfg.ActuatorEnabled {
-		return fmt.Errorf("actuator is disabled in config.yaml. Set ACTUATOR_ENABLED: true to use actuator commands")
+		return nil, nil, fmt.Errorf("actuator is disabled in config.yaml. Set ACTUATOR_ENABLED: true to use actuator commands")
 	}
 
 	cfg.SetDefaults()
 
-	actuatorCfg := actuator.Config{
+	actuatorCfg := newActuatorConfig(cfg)
+	sensor := initCurrentSensor(cfg, &actuatorCfg)
+
+	act, err := actuator.New(actuatorCfg)
+	if err != nil {
+		if sensor != nil {
+			sensor.Close()
+		}
+		return nil, nil, fmt.Errorf("actuator initialization failed: %w", err)
+	}
+
+	// Close the actuator before the current sensor it samples.
+	closeAll := func() {
+		act.Close()
+		if sensor != nil {
+			sensor.Close()
+		}
+	}
+	return act, closeAll, nil
+}
+
+// newActuatorConfig maps the application config to the actuator driver config.
+func newActuatorConfig(cfg *config.Config) actuator.Config {
+	return actuator.Config{
 		Enabled:      cfg.ActuatorEnabled,
 		ENAPin:       cfg.ActuatorENAPin,
 		IN1Pin:       cfg.ActuatorIN1Pin,
 		IN2Pin:       cfg.ActuatorIN2Pin,
 		MovementTime: cfg.ActuatorMovement,
 		PauseTime:    cfg.ActuatorPause,
+		ExtendSpeed:  float64(cfg.ActuatorExtendSpeedPercent) / 100.0,
+		RetractSpeed: float64(cfg.ActuatorRetractSpeedPercent) / 100.0,
+		RampUpMs:     cfg.ActuatorRampUpMs,
+		RampDownMs:   cfg.ActuatorRampDownMs,
 	}
+}
 
-	if err := actuator.Init(actuatorCfg); err != nil {
-		return fmt.Errorf("actuator initialization failed: %w", err)
+// initCurrentSensor opens the motor current sensor when enabled and attaches it with
+// the stall thresholds to the actuator config. Returns nil when the sensor is disabled
+// or failed to initialize.
+func initCurrentSensor(cfg *config.Config, actuatorCfg *actuator.Config) *currentsensor.Sensor {
+	if !cfg.CurrentSensorEnabled {
+		return nil
 	}
 
-	return nil
+	sensor := currentsensor.New(cfg)
+	if err := sensor.Init(cfg); err != nil {
+		log.Printf("Warning: Current sensor initialization failed: %v. Continuing without stall detection.", err)
+		return nil
+	}
+
+	actuatorCfg.CurrentSensor = sensor
+	actuatorCfg.StallCurrentMa = cfg.ActuatorStallCurrentMa
+	actuatorCfg.StallDurationMs = cfg.ActuatorStallDurationMs
+	actuatorCfg.OverloadCurrentMa = cfg.ActuatorOverloadCurrentMa
+	actuatorCfg.InrushIgnoreMs = cfg.ActuatorCurrentInrushIgnoreMs
+	actuatorCfg.CurrentSampleMs = cfg.ActuatorCurrentSampleIntervalMs
+	actuatorCfg.StallReverseMs = cfg.ActuatorStallReverseMs
+	return sensor
 }
 
 func initColorSensorForCommand() (*colorsensor.Sensor, error) {
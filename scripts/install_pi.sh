@@ -65,10 +65,14 @@ After=network-online.target
 Wants=network-online.target
 
 [Service]
-Type=simple
+Type=notify
+NotifyAccess=main
 User=baendaeli-client
 WorkingDirectory=/opt/baendaeli-client
 ExecStart=/usr/local/bin/baendaeli-client
+# Homing and the startup ball check run before READY=1
+TimeoutStartSec=180
+WatchdogSec=30
 Restart=on-failure
 RestartSec=3
 